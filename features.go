@@ -0,0 +1,63 @@
+package main
+
+import (
+	"github.com/neothematrix/netatmo-exporter/v2/internal/config"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// enabledFeatures returns the optional features of the exporter and whether they are enabled in the configuration.
+func enabledFeatures(cfg config.Config) map[string]bool {
+	return map[string]bool{
+		"home_coach":              cfg.EnableHomeCoach,
+		"compression":             cfg.EnableCompression,
+		"metric_filter":           len(cfg.EnableMetrics) > 0 || len(cfg.DisableMetrics) > 0,
+		"sensor_bounds":           len(cfg.SensorBounds) > 0,
+		"convenience_metrics":     cfg.ConvenienceMetrics,
+		"averages":                cfg.AverageWindow > 0,
+		"signal_trend":            cfg.SignalTrend,
+		"compact_cache":           cfg.CompactCache,
+		"cache_file":              cfg.CacheFile != "",
+		"replay":                  cfg.ReplayFile != "",
+		"remote_write":            cfg.RemoteWriteURL != "",
+		"backfill":                cfg.BackfillDuration > 0,
+		"module_id_label":         cfg.ModuleIDLabel,
+		"omit_missing_timestamps": cfg.OmitMissingTimestamps,
+		"metric_timestamps":       cfg.MetricTimestamps,
+		"token_refresh_handler":   cfg.TokenRefreshHandler,
+		"trust_forwarded_headers": cfg.TrustForwardedHeaders,
+		"debug_handlers":          cfg.DebugHandlers,
+	}
+}
+
+// featuresCollector creates a metric containing one series for every optional feature, so that the
+// configuration of a running exporter can be checked. It does not change after starting.
+func featuresCollector(cfg config.Config) prometheus.Collector {
+	gauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "netatmo_exporter_feature_enabled",
+		Help: "Contains one for every enabled and zero for every disabled optional feature.",
+	}, []string{"feature"})
+
+	for feature, enabled := range enabledFeatures(cfg) {
+		value := 0.0
+		if enabled {
+			value = 1
+		}
+		gauge.WithLabelValues(feature).Set(value)
+	}
+
+	return gauge
+}
+
+// debugHandlersCollector creates a metric showing whether the debug handlers are enabled. They can expose the
+// token, so this allows alerting on instances which have them enabled by accident.
+func debugHandlersCollector(cfg config.Config) prometheus.Collector {
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "netatmo_exporter_debug_handlers_enabled",
+		Help: "Contains one if the debug handlers are enabled, zero otherwise.",
+	})
+	if cfg.DebugHandlers {
+		gauge.Set(1)
+	}
+
+	return gauge
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/neothematrix/netatmo-exporter/v2/internal/config"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestFeaturesCollector(t *testing.T) {
+	tt := []struct {
+		desc string
+		cfg  config.Config
+		want string
+	}{
+		{
+			desc: "defaults",
+			cfg:  config.Config{},
+			want: `# HELP netatmo_exporter_feature_enabled Contains one for every enabled and zero for every disabled optional feature.
+# TYPE netatmo_exporter_feature_enabled gauge
+netatmo_exporter_feature_enabled{feature="averages"} 0
+netatmo_exporter_feature_enabled{feature="backfill"} 0
+netatmo_exporter_feature_enabled{feature="cache_file"} 0
+netatmo_exporter_feature_enabled{feature="compact_cache"} 0
+netatmo_exporter_feature_enabled{feature="compression"} 0
+netatmo_exporter_feature_enabled{feature="convenience_metrics"} 0
+netatmo_exporter_feature_enabled{feature="debug_handlers"} 0
+netatmo_exporter_feature_enabled{feature="home_coach"} 0
+netatmo_exporter_feature_enabled{feature="metric_filter"} 0
+netatmo_exporter_feature_enabled{feature="metric_timestamps"} 0
+netatmo_exporter_feature_enabled{feature="module_id_label"} 0
+netatmo_exporter_feature_enabled{feature="omit_missing_timestamps"} 0
+netatmo_exporter_feature_enabled{feature="remote_write"} 0
+netatmo_exporter_feature_enabled{feature="replay"} 0
+netatmo_exporter_feature_enabled{feature="sensor_bounds"} 0
+netatmo_exporter_feature_enabled{feature="signal_trend"} 0
+netatmo_exporter_feature_enabled{feature="token_refresh_handler"} 0
+netatmo_exporter_feature_enabled{feature="trust_forwarded_headers"} 0
+`,
+		},
+		{
+			desc: "enabled features",
+			cfg: config.Config{
+				EnableHomeCoach:  true,
+				DisableMetrics:   []string{"netatmo_sensor_noise_db"},
+				CacheFile:        "cache.json",
+				BackfillDuration: time.Hour,
+				DebugHandlers:    true,
+			},
+			want: `# HELP netatmo_exporter_feature_enabled Contains one for every enabled and zero for every disabled optional feature.
+# TYPE netatmo_exporter_feature_enabled gauge
+netatmo_exporter_feature_enabled{feature="averages"} 0
+netatmo_exporter_feature_enabled{feature="backfill"} 1
+netatmo_exporter_feature_enabled{feature="cache_file"} 1
+netatmo_exporter_feature_enabled{feature="compact_cache"} 0
+netatmo_exporter_feature_enabled{feature="compression"} 0
+netatmo_exporter_feature_enabled{feature="convenience_metrics"} 0
+netatmo_exporter_feature_enabled{feature="debug_handlers"} 1
+netatmo_exporter_feature_enabled{feature="home_coach"} 1
+netatmo_exporter_feature_enabled{feature="metric_filter"} 1
+netatmo_exporter_feature_enabled{feature="metric_timestamps"} 0
+netatmo_exporter_feature_enabled{feature="module_id_label"} 0
+netatmo_exporter_feature_enabled{feature="omit_missing_timestamps"} 0
+netatmo_exporter_feature_enabled{feature="remote_write"} 0
+netatmo_exporter_feature_enabled{feature="replay"} 0
+netatmo_exporter_feature_enabled{feature="sensor_bounds"} 0
+netatmo_exporter_feature_enabled{feature="signal_trend"} 0
+netatmo_exporter_feature_enabled{feature="token_refresh_handler"} 0
+netatmo_exporter_feature_enabled{feature="trust_forwarded_headers"} 0
+`,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			if err := testutil.CollectAndCompare(featuresCollector(tc.cfg), strings.NewReader(tc.want)); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestDebugHandlersCollector(t *testing.T) {
+	tt := []struct {
+		desc          string
+		debugHandlers bool
+		want          string
+	}{
+		{
+			desc: "disabled",
+			want: `# HELP netatmo_exporter_debug_handlers_enabled Contains one if the debug handlers are enabled, zero otherwise.
+# TYPE netatmo_exporter_debug_handlers_enabled gauge
+netatmo_exporter_debug_handlers_enabled 0
+`,
+		},
+		{
+			desc:          "enabled",
+			debugHandlers: true,
+			want: `# HELP netatmo_exporter_debug_handlers_enabled Contains one if the debug handlers are enabled, zero otherwise.
+# TYPE netatmo_exporter_debug_handlers_enabled gauge
+netatmo_exporter_debug_handlers_enabled 1
+`,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := config.Config{
+				DebugHandlers: tc.debugHandlers,
+			}
+			if err := testutil.CollectAndCompare(debugHandlersCollector(cfg), strings.NewReader(tc.want)); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package backfill
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	netatmo "github.com/exzz/netatmo-api-go"
+	"golang.org/x/oauth2"
+)
+
+// DefaultURL is the API endpoint returning the historical measurements of a device or module.
+const DefaultURL = "https://api.netatmo.com/api/getmeasure"
+
+// moduleTypes contains the measurement types which can be requested for each type of device or module.
+var moduleTypes = map[string][]string{
+	"NAMain":    {"Temperature", "Humidity", "CO2", "Pressure", "Noise"},
+	"NAModule1": {"Temperature", "Humidity"},
+	"NAModule2": {"WindStrength", "WindAngle", "GustStrength", "GustAngle"},
+	"NAModule3": {"Rain"},
+	"NAModule4": {"Temperature", "Humidity", "CO2"},
+	"NHC":       {"Temperature", "Humidity", "CO2", "Pressure", "Noise"},
+}
+
+// Sample contains the values measured by a module at one point in time. Types without a value are left out.
+type Sample struct {
+	Time   int64              `json:"time"`
+	Values map[string]float64 `json:"values"`
+}
+
+// Module contains the historical measurements of one device or module.
+type Module struct {
+	Station  string   `json:"station"`
+	Module   string   `json:"module"`
+	ModuleID string   `json:"module_id"`
+	Type     string   `json:"type"`
+	Types    []string `json:"types"`
+	Samples  []Sample `json:"samples"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// Client reads historical measurements using the getmeasure API.
+type Client struct {
+	URL        string
+	HTTPClient *http.Client
+	TokenFunc  func() (*oauth2.Token, error)
+}
+
+// New creates a new Client using the token returned by tokenFunc for authentication.
+func New(httpClient *http.Client, tokenFunc func() (*oauth2.Token, error)) *Client {
+	return &Client{
+		URL:        DefaultURL,
+		HTTPClient: httpClient,
+		TokenFunc:  tokenFunc,
+	}
+}
+
+// Modules retrieves the measurements between begin and end for all devices and modules contained in devices.
+// Errors reading a single module are reported in the module, so that the other modules are still returned.
+// Modules of unknown types are skipped.
+func (c *Client) Modules(devices []*netatmo.Device, begin, end time.Time) []Module {
+	result := []Module{}
+	for _, device := range devices {
+		result = c.appendModule(result, device.ID, device, device.StationName, begin, end)
+		for _, module := range device.LinkedModules {
+			result = c.appendModule(result, device.ID, module, device.StationName, begin, end)
+		}
+	}
+
+	return result
+}
+
+func (c *Client) appendModule(result []Module, deviceID string, module *netatmo.Device, stationName string, begin, end time.Time) []Module {
+	types, ok := moduleTypes[module.Type]
+	if !ok {
+		return result
+	}
+
+	moduleID := module.ID
+	if moduleID == deviceID {
+		moduleID = ""
+	}
+
+	entry := Module{
+		Station:  stationName,
+		Module:   module.ModuleName,
+		ModuleID: module.ID,
+		Type:     module.Type,
+		Types:    types,
+	}
+
+	samples, err := c.Measure(deviceID, moduleID, types, begin, end)
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	entry.Samples = samples
+
+	return append(result, entry)
+}
+
+// Measure retrieves the measurements of the given types between begin and end at the native resolution of the
+// module. The moduleID is empty for measurements of the main device.
+func (c *Client) Measure(deviceID, moduleID string, types []string, begin, end time.Time) ([]Sample, error) {
+	token, err := c.TokenFunc()
+	if err != nil {
+		return nil, err
+	}
+
+	query := url.Values{
+		"device_id":  {deviceID},
+		"scale":      {"max"},
+		"type":       {strings.Join(types, ",")},
+		"date_begin": {strconv.FormatInt(begin.Unix(), 10)},
+		"date_end":   {strconv.FormatInt(end.Unix(), 10)},
+		"optimize":   {"false"},
+		"real_time":  {"true"},
+	}
+	if moduleID != "" {
+		query.Set("module_id", moduleID)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, c.URL+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	token.SetAuthHeader(req)
+
+	res, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error reading measurements: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status reading measurements: %s", res.Status)
+	}
+
+	// Without optimization the body maps the timestamp of every sample to the values in the order of the
+	// requested types.
+	var data struct {
+		Body map[string][]*float64 `json:"body"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&data); err != nil {
+		return nil, fmt.Errorf("error decoding measurements: %w", err)
+	}
+
+	samples := make([]Sample, 0, len(data.Body))
+	for timestamp, values := range data.Body {
+		unix, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timestamp %q: %w", timestamp, err)
+		}
+
+		sample := Sample{
+			Time:   unix,
+			Values: make(map[string]float64, len(types)),
+		}
+		for i, value := range values {
+			if i >= len(types) || value == nil {
+				continue
+			}
+			sample.Values[types[i]] = *value
+		}
+		samples = append(samples, sample)
+	}
+	sort.Slice(samples, func(i, j int) bool {
+		return samples[i].Time < samples[j].Time
+	})
+
+	return samples, nil
+}
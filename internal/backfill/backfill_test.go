@@ -0,0 +1,176 @@
+package backfill
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	netatmo "github.com/exzz/netatmo-api-go"
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/oauth2"
+)
+
+const testResponse = `{
+  "body": {
+    "3900": [21.7, null],
+    "3600": [21.5, 48]
+  },
+  "status": "ok"
+}`
+
+func TestClientMeasure(t *testing.T) {
+	tt := []struct {
+		desc        string
+		moduleID    string
+		tokenErr    error
+		status      int
+		wantQuery   string
+		wantSamples []Sample
+		wantErr     bool
+	}{
+		{
+			desc:      "main device",
+			status:    http.StatusOK,
+			wantQuery: "date_begin=3600&date_end=7200&device_id=70%3Aee%3A50%3A00%3A00%3A01&optimize=false&real_time=true&scale=max&type=Temperature%2CHumidity",
+			wantSamples: []Sample{
+				{Time: 3600, Values: map[string]float64{"Temperature": 21.5, "Humidity": 48}},
+				{Time: 3900, Values: map[string]float64{"Temperature": 21.7}},
+			},
+		},
+		{
+			desc:      "module",
+			moduleID:  "02:00:00:00:00:01",
+			status:    http.StatusOK,
+			wantQuery: "date_begin=3600&date_end=7200&device_id=70%3Aee%3A50%3A00%3A00%3A01&module_id=02%3A00%3A00%3A00%3A00%3A01&optimize=false&real_time=true&scale=max&type=Temperature%2CHumidity",
+			wantSamples: []Sample{
+				{Time: 3600, Values: map[string]float64{"Temperature": 21.5, "Humidity": 48}},
+				{Time: 3900, Values: map[string]float64{"Temperature": 21.7}},
+			},
+		},
+		{
+			desc:     "not authenticated",
+			tokenErr: netatmo.ErrNotAuthenticated,
+			status:   http.StatusOK,
+			wantErr:  true,
+		},
+		{
+			desc:      "error status",
+			status:    http.StatusForbidden,
+			wantQuery: "date_begin=3600&date_end=7200&device_id=70%3Aee%3A50%3A00%3A00%3A01&optimize=false&real_time=true&scale=max&type=Temperature%2CHumidity",
+			wantErr:   true,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if auth := r.Header.Get("Authorization"); auth != "Bearer access-token" {
+					t.Errorf("got authorization %q", auth)
+				}
+
+				if r.URL.RawQuery != tc.wantQuery {
+					t.Errorf("got query %q, want %q", r.URL.RawQuery, tc.wantQuery)
+				}
+
+				w.WriteHeader(tc.status)
+				w.Write([]byte(testResponse))
+			}))
+			defer server.Close()
+
+			c := New(server.Client(), func() (*oauth2.Token, error) {
+				if tc.tokenErr != nil {
+					return nil, tc.tokenErr
+				}
+
+				return &oauth2.Token{AccessToken: "access-token"}, nil
+			})
+			c.URL = server.URL
+
+			samples, err := c.Measure("70:ee:50:00:00:01", tc.moduleID, []string{"Temperature", "Humidity"}, time.Unix(3600, 0), time.Unix(7200, 0))
+			if (err != nil) != tc.wantErr {
+				t.Errorf("got error %v, want error %v", err, tc.wantErr)
+			}
+
+			if diff := cmp.Diff(samples, tc.wantSamples); diff != "" {
+				t.Errorf("samples differ: -got+want\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestClientModules(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("module_id") != "" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Write([]byte(`{"body": {"3600": [21.5, 48, 650, 1015.2, 35]}}`))
+	}))
+	defer server.Close()
+
+	c := New(server.Client(), func() (*oauth2.Token, error) {
+		return &oauth2.Token{AccessToken: "access-token"}, nil
+	})
+	c.URL = server.URL
+
+	devices := []*netatmo.Device{
+		{
+			ID:          "70:ee:50:00:00:01",
+			StationName: "Home",
+			ModuleName:  "Living Room",
+			Type:        "NAMain",
+			LinkedModules: []*netatmo.Device{
+				{
+					ID:         "02:00:00:00:00:01",
+					ModuleName: "Outdoor",
+					Type:       "NAModule1",
+				},
+				{
+					ID:         "09:00:00:00:00:01",
+					ModuleName: "Unknown",
+					Type:       "NAModule9",
+				},
+			},
+		},
+	}
+
+	got := c.Modules(devices, time.Unix(3600, 0), time.Unix(7200, 0))
+	want := []Module{
+		{
+			Station:  "Home",
+			Module:   "Living Room",
+			ModuleID: "70:ee:50:00:00:01",
+			Type:     "NAMain",
+			Types:    moduleTypes["NAMain"],
+			Samples: []Sample{
+				{
+					Time: 3600,
+					Values: map[string]float64{
+						"Temperature": 21.5,
+						"Humidity":    48,
+						"CO2":         650,
+						"Pressure":    1015.2,
+						"Noise":       35,
+					},
+				},
+			},
+		},
+		{
+			Station:  "Home",
+			Module:   "Outdoor",
+			ModuleID: "02:00:00:00:00:01",
+			Type:     "NAModule1",
+			Types:    moduleTypes["NAModule1"],
+			Error:    "unexpected status reading measurements: 500 Internal Server Error",
+		},
+	}
+
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("modules differ: -got+want\n%s", diff)
+	}
+}
@@ -0,0 +1,155 @@
+package collector
+
+import (
+	"time"
+
+	netatmo "github.com/exzz/netatmo-api-go"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// outdoorModuleType is the type of the outdoor module, which is not part of the station aggregates.
+	outdoorModuleType = "NAModule1"
+
+	// stationType is the type of the main device of a weather station, which is used as indoor module of the
+	// convenience metrics.
+	stationType = "NAMain"
+)
+
+var (
+	stationAvgTemperatureDesc = newLabelledDesc(
+		prefix+"station_avg_temperature_celsius",
+		"Average temperature in celsius of the indoor modules of the station.",
+		[]string{stationLabel})
+	stationMinTemperatureDesc = newLabelledDesc(
+		prefix+"station_min_temperature_celsius",
+		"Lowest temperature in celsius of the indoor modules of the station.",
+		[]string{stationLabel})
+	stationMaxTemperatureDesc = newLabelledDesc(
+		prefix+"station_max_temperature_celsius",
+		"Highest temperature in celsius of the indoor modules of the station.",
+		[]string{stationLabel})
+	stationAltitudeDesc = newLabelledDesc(
+		prefix+"station_altitude_meters",
+		"Altitude of the station in meters, derived from the difference between the sea level and the absolute pressure.",
+		[]string{stationLabel})
+
+	indoorTemperatureDesc = newLabelledDesc(
+		prefix+"indoor_temperature_celsius",
+		"Temperature in celsius of the main device of the station.",
+		[]string{stationLabel})
+	outdoorTemperatureDesc = newLabelledDesc(
+		prefix+"outdoor_temperature_celsius",
+		"Temperature in celsius of the outdoor module of the station. The module with the lowest ID is used, if there are several.",
+		[]string{stationLabel})
+)
+
+// collectStationAggregates emits the aggregated temperatures of the indoor modules of a station. Modules without
+// temperature, with stale data or with a temperature outside of the configured bounds are skipped.
+func (c *NetatmoCollector) collectStationAggregates(ch chan<- prometheus.Metric, station *netatmo.Device, stationName string) {
+	now := c.clock()
+	var count int
+	var sum, minValue, maxValue float64
+	for _, device := range append([]*netatmo.Device{station}, stationModules(station)...) {
+		value, ok := c.aggregateTemperature(now, device)
+		if !ok {
+			continue
+		}
+
+		if count == 0 || value < minValue {
+			minValue = value
+		}
+		if count == 0 || value > maxValue {
+			maxValue = value
+		}
+		sum += value
+		count++
+	}
+
+	if count == 0 {
+		return
+	}
+
+	c.sendMetric(ch, stationAvgTemperatureDesc, prometheus.GaugeValue, sum/float64(count), stationName)
+	c.sendMetric(ch, stationMinTemperatureDesc, prometheus.GaugeValue, minValue, stationName)
+	c.sendMetric(ch, stationMaxTemperatureDesc, prometheus.GaugeValue, maxValue, stationName)
+}
+
+func (c *NetatmoCollector) aggregateTemperature(now time.Time, device *netatmo.Device) (float64, bool) {
+	if device.Type == outdoorModuleType {
+		return 0, false
+	}
+
+	return c.temperature(now, device)
+}
+
+// temperature returns the temperature of the device, unless it is stale or outside of the configured bounds.
+func (c *NetatmoCollector) temperature(now time.Time, device *netatmo.Device) (float64, bool) {
+	data := device.DashboardData
+	if data.Temperature == nil || data.LastMeasure == nil {
+		return 0, false
+	}
+
+	if now.Sub(time.Unix(*data.LastMeasure, 0)) > c.staleThreshold(device) {
+		return 0, false
+	}
+
+	value := float64(*data.Temperature)
+	if bounds, ok := c.SensorBounds[sensorDescNames[tempDesc]]; ok && (value < bounds.Min || value > bounds.Max) {
+		return 0, false
+	}
+
+	return value, true
+}
+
+// collectStationAltitude emits the altitude of the station derived from its pressure measurements. The station
+// data does not contain the altitude, but Netatmo uses it for correcting the pressure to sea level. The altitude
+// is only emitted while the pressure metrics it is derived from are enabled.
+func (c *NetatmoCollector) collectStationAltitude(ch chan<- prometheus.Metric, station *netatmo.Device, stationName string) {
+	if !c.metricEnabled(absolutePressureDesc) || !(c.metricEnabled(pressureDesc) || c.metricEnabled(pressureSeaLevelDesc)) {
+		return
+	}
+
+	data := station.DashboardData
+	if data.Pressure == nil || data.AbsolutePressure == nil || data.LastMeasure == nil {
+		return
+	}
+
+	if c.clock().Sub(time.Unix(*data.LastMeasure, 0)) > c.staleThreshold(station) {
+		return
+	}
+
+	value, ok := altitude(float64(*data.Pressure), float64(*data.AbsolutePressure))
+	if !ok {
+		return
+	}
+
+	c.sendMetric(ch, stationAltitudeDesc, prometheus.GaugeValue, value, stationName)
+}
+
+// collectConvenienceTemperatures emits the indoor and outdoor temperature of a station without module labels, so
+// that they can be queried without knowing the module names. The indoor temperature is the one of the main device,
+// the outdoor temperature the one of the outdoor module with the lowest ID.
+func (c *NetatmoCollector) collectConvenienceTemperatures(ch chan<- prometheus.Metric, station *netatmo.Device, stationName string) {
+	if !c.ConvenienceMetrics {
+		return
+	}
+
+	now := c.clock()
+	if station.Type == stationType {
+		if value, ok := c.temperature(now, station); ok {
+			c.sendMetric(ch, indoorTemperatureDesc, prometheus.GaugeValue, value, stationName)
+		}
+	}
+
+	for _, module := range stationModules(station) {
+		if module.Type != outdoorModuleType {
+			continue
+		}
+
+		if value, ok := c.temperature(now, module); ok {
+			c.sendMetric(ch, outdoorTemperatureDesc, prometheus.GaugeValue, value, stationName)
+		}
+		return
+	}
+}
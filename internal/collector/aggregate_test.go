@@ -0,0 +1,242 @@
+package collector
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	netatmo "github.com/exzz/netatmo-api-go"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+)
+
+func TestNetatmoCollector_CollectStationAggregates(t *testing.T) {
+	module := func(id, moduleType string, temperature *float32, lastMeasure int64) *netatmo.Device {
+		return &netatmo.Device{
+			ID:         id,
+			ModuleName: id,
+			Type:       moduleType,
+			DashboardData: netatmo.DashboardData{
+				Temperature: temperature,
+				LastMeasure: int64Ptr(lastMeasure),
+			},
+		}
+	}
+
+	testDevices := &netatmo.DeviceCollection{}
+	testDevices.Body.Devices = []*netatmo.Device{
+		{
+			ID:          "aa:bb:cc:dd:ee:f0",
+			ModuleName:  "Living Room",
+			StationName: "Home",
+			Type:        "NAMain",
+			DashboardData: netatmo.DashboardData{
+				Temperature: float32Ptr(22),
+				LastMeasure: int64Ptr(3500),
+			},
+			LinkedModules: []*netatmo.Device{
+				module("Bedroom", "NAModule4", float32Ptr(18), 3500),
+				module("Kitchen", "NAModule4", float32Ptr(23), 3500),
+				module("Outside", outdoorModuleType, float32Ptr(-5), 3500),
+				module("Wind", "NAModule2", nil, 3500),
+				module("Attic", "NAModule4", float32Ptr(30), 0),
+				module("Cellar", "NAModule4", float32Ptr(-100), 3500),
+			},
+		},
+		{
+			ID:          "aa:bb:cc:dd:ee:e0",
+			ModuleName:  "Garage",
+			StationName: "Stale",
+			Type:        "NAMain",
+			DashboardData: netatmo.DashboardData{
+				Temperature: float32Ptr(10),
+				LastMeasure: int64Ptr(0),
+			},
+		},
+	}
+
+	c := New(context.Background(), logrus.New(), func() (*netatmo.DeviceCollection, error) {
+		return testDevices, nil
+	}, time.Hour, time.Hour)
+	c.clock = func() time.Time {
+		return time.Unix(7000, 0)
+	}
+	c.SensorBounds = map[string]Bounds{
+		"netatmo_aircare_temperature_celsius": {Min: -50, Max: 60},
+	}
+	c.RefreshData(c.clock())
+
+	expected := `# HELP netatmo_station_avg_temperature_celsius Average temperature in celsius of the indoor modules of the station.
+# TYPE netatmo_station_avg_temperature_celsius gauge
+netatmo_station_avg_temperature_celsius{station="Home"} 21
+# HELP netatmo_station_max_temperature_celsius Highest temperature in celsius of the indoor modules of the station.
+# TYPE netatmo_station_max_temperature_celsius gauge
+netatmo_station_max_temperature_celsius{station="Home"} 23
+# HELP netatmo_station_min_temperature_celsius Lowest temperature in celsius of the indoor modules of the station.
+# TYPE netatmo_station_min_temperature_celsius gauge
+netatmo_station_min_temperature_celsius{station="Home"} 18
+`
+	metricNames := []string{
+		"netatmo_station_avg_temperature_celsius",
+		"netatmo_station_min_temperature_celsius",
+		"netatmo_station_max_temperature_celsius",
+	}
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected), metricNames...); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNetatmoCollector_CollectStationAltitude(t *testing.T) {
+	station := func(name string, lastMeasure int64) *netatmo.Device {
+		return &netatmo.Device{
+			ID:          name,
+			ModuleName:  "Living Room",
+			StationName: name,
+			Type:        "NAMain",
+			DashboardData: netatmo.DashboardData{
+				Pressure:         float32Ptr(1013.25),
+				AbsolutePressure: float32Ptr(954.6),
+				LastMeasure:      int64Ptr(lastMeasure),
+			},
+		}
+	}
+
+	testDevices := &netatmo.DeviceCollection{}
+	testDevices.Body.Devices = []*netatmo.Device{
+		station("Home", 3500),
+		station("Stale", 0),
+	}
+
+	disabledPressure, _ := MetricFilter(nil, []string{"netatmo_aircare_absolute_pressure"})
+	tt := []struct {
+		desc            string
+		disabledMetrics map[string]bool
+		want            string
+	}{
+		{
+			desc: "all metrics",
+			want: `# HELP netatmo_station_altitude_meters Altitude of the station in meters, derived from the difference between the sea level and the absolute pressure.
+# TYPE netatmo_station_altitude_meters gauge
+netatmo_station_altitude_meters{station="Home"} 500.1477592082555
+`,
+		},
+		{
+			desc:            "absolute pressure disabled",
+			disabledMetrics: disabledPressure,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			c := New(context.Background(), logrus.New(), func() (*netatmo.DeviceCollection, error) {
+				return testDevices, nil
+			}, time.Hour, time.Hour)
+			c.clock = func() time.Time {
+				return time.Unix(7000, 0)
+			}
+			c.DisabledMetrics = tc.disabledMetrics
+			c.RefreshData(c.clock())
+
+			if err := testutil.CollectAndCompare(c, strings.NewReader(tc.want), "netatmo_station_altitude_meters"); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestNetatmoCollector_CollectConvenienceTemperatures(t *testing.T) {
+	module := func(id, moduleType string, temperature float32, lastMeasure int64) *netatmo.Device {
+		return &netatmo.Device{
+			ID:         id,
+			ModuleName: id,
+			Type:       moduleType,
+			DashboardData: netatmo.DashboardData{
+				Temperature: float32Ptr(temperature),
+				LastMeasure: int64Ptr(lastMeasure),
+			},
+		}
+	}
+
+	testDevices := &netatmo.DeviceCollection{}
+	testDevices.Body.Devices = []*netatmo.Device{
+		{
+			ID:          "aa:bb:cc:dd:ee:f0",
+			ModuleName:  "Living Room",
+			StationName: "Home",
+			Type:        stationType,
+			DashboardData: netatmo.DashboardData{
+				Temperature: float32Ptr(22),
+				LastMeasure: int64Ptr(3500),
+			},
+			LinkedModules: []*netatmo.Device{
+				module("Garden", outdoorModuleType, 4, 3500),
+				module("Balcony", outdoorModuleType, -5, 3500),
+				module("Bedroom", "NAModule4", 18, 3500),
+			},
+		},
+		{
+			ID:          "aa:bb:cc:dd:ee:e0",
+			ModuleName:  "Garage",
+			StationName: "Stale",
+			Type:        stationType,
+			DashboardData: netatmo.DashboardData{
+				Temperature: float32Ptr(10),
+				LastMeasure: int64Ptr(0),
+			},
+			LinkedModules: []*netatmo.Device{
+				module("Yard", outdoorModuleType, 2, 0),
+			},
+		},
+	}
+
+	metricNames := []string{
+		"netatmo_indoor_temperature_celsius",
+		"netatmo_outdoor_temperature_celsius",
+	}
+
+	tt := []struct {
+		desc    string
+		enabled bool
+		want    string
+	}{
+		{
+			desc: "disabled",
+			want: "",
+		},
+		{
+			desc:    "enabled",
+			enabled: true,
+			want: `# HELP netatmo_indoor_temperature_celsius Temperature in celsius of the main device of the station.
+# TYPE netatmo_indoor_temperature_celsius gauge
+netatmo_indoor_temperature_celsius{station="Home"} 22
+# HELP netatmo_outdoor_temperature_celsius Temperature in celsius of the outdoor module of the station. The module with the lowest ID is used, if there are several.
+# TYPE netatmo_outdoor_temperature_celsius gauge
+netatmo_outdoor_temperature_celsius{station="Home"} -5
+`,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			c := New(context.Background(), logrus.New(), func() (*netatmo.DeviceCollection, error) {
+				return testDevices, nil
+			}, time.Hour, time.Hour)
+			c.clock = func() time.Time {
+				return time.Unix(7000, 0)
+			}
+			c.ConvenienceMetrics = tc.enabled
+			c.RefreshData(c.clock())
+
+			if err := testutil.CollectAndCompare(c, strings.NewReader(tc.want), metricNames...); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
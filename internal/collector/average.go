@@ -0,0 +1,167 @@
+package collector
+
+import (
+	netatmo "github.com/exzz/netatmo-api-go"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	cotwoAverageDesc = newLabelledDesc(
+		sensorPrefix+"co2_ppm_avg",
+		"Average of the last CO2 measurements in parts per million.",
+		varLabels)
+	noiseAverageDesc = newLabelledDesc(
+		sensorPrefix+"noise_db_avg",
+		"Average of the last noise measurements in decibels.",
+		varLabels)
+)
+
+// averagedSensor contains a metric, which is exported as the average of the last measurements in addition to
+// the metric containing the last value.
+type averagedSensor struct {
+	desc  *prometheus.Desc
+	raw   *prometheus.Desc
+	value func(data netatmo.DashboardData) (float64, bool)
+}
+
+// averagedSensors contains the sensors with noisy measurements, which are averaged if enabled.
+var averagedSensors = []averagedSensor{
+	{
+		desc: cotwoAverageDesc,
+		raw:  cotwoDesc,
+		value: func(data netatmo.DashboardData) (float64, bool) {
+			if data.CO2 == nil {
+				return 0, false
+			}
+			return float64(*data.CO2), true
+		},
+	},
+	{
+		desc: noiseAverageDesc,
+		raw:  noiseDesc,
+		value: func(data netatmo.DashboardData) (float64, bool) {
+			if data.Noise == nil {
+				return 0, false
+			}
+			return float64(*data.Noise), true
+		},
+	},
+}
+
+// window is a ring buffer containing the last values of a sensor.
+type window struct {
+	values []float64
+	next   int
+}
+
+func (w *window) add(size int, value float64) {
+	if len(w.values) < size {
+		w.values = append(w.values, value)
+		return
+	}
+
+	w.values[w.next] = value
+	w.next = (w.next + 1) % size
+}
+
+// average returns the average of the values in the window. The second return value is false if it is empty.
+func (w *window) average() (float64, bool) {
+	if len(w.values) == 0 {
+		return 0, false
+	}
+
+	var sum float64
+	for _, value := range w.values {
+		sum += value
+	}
+
+	return sum / float64(len(w.values)), true
+}
+
+// moduleAverages contains the windows of the averaged sensors of one module.
+type moduleAverages struct {
+	lastMeasure int64
+	windows     map[*prometheus.Desc]*window
+}
+
+// averages contains the last measurements of the averaged sensors, keyed by the module ID. It is guarded by the
+// cacheLock of the collector.
+type averages map[string]*moduleAverages
+
+// update adds the measurements contained in devices to the windows of the modules. A measurement is only added
+// once, even if the cached data is returned again, and values outside of the configured bounds are skipped.
+// Modules which are not contained in devices anymore are removed.
+func (a averages) update(devices *netatmo.DeviceCollection, size int, bounds map[string]Bounds) {
+	seen := make(map[string]bool)
+	if devices != nil {
+		for _, dev := range devices.Devices() {
+			for _, device := range append([]*netatmo.Device{dev}, stationModules(dev)...) {
+				seen[device.ID] = true
+				a.see(device, size, bounds)
+			}
+		}
+	}
+
+	for id := range a {
+		if !seen[id] {
+			delete(a, id)
+		}
+	}
+}
+
+func (a averages) see(device *netatmo.Device, size int, bounds map[string]Bounds) {
+	data := device.DashboardData
+	if data.LastMeasure == nil {
+		return
+	}
+
+	module, ok := a[device.ID]
+	if !ok {
+		module = &moduleAverages{
+			windows: make(map[*prometheus.Desc]*window),
+		}
+		a[device.ID] = module
+	}
+
+	if *data.LastMeasure == module.lastMeasure {
+		return
+	}
+	module.lastMeasure = *data.LastMeasure
+
+	for _, sensor := range averagedSensors {
+		value, ok := sensor.value(data)
+		if !ok {
+			continue
+		}
+
+		if b, ok := bounds[sensorDescNames[sensor.raw]]; ok && (value < b.Min || value > b.Max) {
+			continue
+		}
+
+		w, ok := module.windows[sensor.desc]
+		if !ok {
+			w = &window{}
+			module.windows[sensor.desc] = w
+		}
+		w.add(size, value)
+	}
+}
+
+// collectAverages emits the averages of the module. The caller needs to hold the cacheLock.
+func (c *NetatmoCollector) collectAverages(ch chan<- prometheus.Metric, device *netatmo.Device, labelValues []string) {
+	module, ok := c.averages[device.ID]
+	if !ok {
+		return
+	}
+
+	for _, sensor := range averagedSensors {
+		w, ok := module.windows[sensor.desc]
+		if !ok {
+			continue
+		}
+
+		if value, ok := w.average(); ok {
+			c.sendSensorMetric(ch, sensor.desc, module.lastMeasure, value, labelValues...)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package collector
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	netatmo "github.com/exzz/netatmo-api-go"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+)
+
+func averageTestDevices(lastMeasure int64, co2 int32, noise *int32, withModule bool) *netatmo.DeviceCollection {
+	station := &netatmo.Device{
+		ID:          "aa:bb:cc:dd:ee:f0",
+		ModuleName:  "Living Room",
+		StationName: "Home",
+		Type:        "NAMain",
+		DashboardData: netatmo.DashboardData{
+			CO2:         &co2,
+			Noise:       noise,
+			LastMeasure: &lastMeasure,
+		},
+	}
+	if withModule {
+		station.LinkedModules = []*netatmo.Device{
+			{
+				ID:         "aa:bb:cc:dd:ee:f1",
+				ModuleName: "Bedroom",
+				Type:       "NAModule4",
+				DashboardData: netatmo.DashboardData{
+					CO2:         int32Ptr(co2 * 2),
+					LastMeasure: &lastMeasure,
+				},
+			},
+		}
+	}
+
+	dc := &netatmo.DeviceCollection{}
+	dc.Body.Devices = []*netatmo.Device{station}
+	return dc
+}
+
+func TestNetatmoCollector_CollectAverages(t *testing.T) {
+	tt := []struct {
+		desc      string
+		responses []*netatmo.DeviceCollection
+		bounds    map[string]Bounds
+		want      string
+	}{
+		{
+			desc: "window not full",
+			responses: []*netatmo.DeviceCollection{
+				averageTestDevices(3000, 500, int32Ptr(40), false),
+				averageTestDevices(3300, 700, int32Ptr(50), false),
+			},
+			want: `# HELP netatmo_aircare_co2_ppm_avg Average of the last CO2 measurements in parts per million.
+# TYPE netatmo_aircare_co2_ppm_avg gauge
+netatmo_aircare_co2_ppm_avg{module="Living Room",module_type="NAMain",role="station",station="Home"} 600
+# HELP netatmo_aircare_noise_db_avg Average of the last noise measurements in decibels.
+# TYPE netatmo_aircare_noise_db_avg gauge
+netatmo_aircare_noise_db_avg{module="Living Room",module_type="NAMain",role="station",station="Home"} 45
+`,
+		},
+		{
+			desc: "oldest value dropped",
+			responses: []*netatmo.DeviceCollection{
+				averageTestDevices(2700, 2000, nil, false),
+				averageTestDevices(3000, 500, nil, false),
+				averageTestDevices(3300, 600, nil, false),
+				averageTestDevices(3600, 1000, nil, false),
+			},
+			want: `# HELP netatmo_aircare_co2_ppm_avg Average of the last CO2 measurements in parts per million.
+# TYPE netatmo_aircare_co2_ppm_avg gauge
+netatmo_aircare_co2_ppm_avg{module="Living Room",module_type="NAMain",role="station",station="Home"} 700
+`,
+		},
+		{
+			desc: "same measurement only counted once",
+			responses: []*netatmo.DeviceCollection{
+				averageTestDevices(3000, 400, nil, false),
+				averageTestDevices(3600, 800, nil, false),
+				averageTestDevices(3600, 800, nil, false),
+			},
+			want: `# HELP netatmo_aircare_co2_ppm_avg Average of the last CO2 measurements in parts per million.
+# TYPE netatmo_aircare_co2_ppm_avg gauge
+netatmo_aircare_co2_ppm_avg{module="Living Room",module_type="NAMain",role="station",station="Home"} 600
+`,
+		},
+		{
+			desc: "values outside of bounds skipped",
+			responses: []*netatmo.DeviceCollection{
+				averageTestDevices(3000, 400, nil, false),
+				averageTestDevices(3300, 9000, nil, false),
+				averageTestDevices(3600, 600, nil, false),
+			},
+			bounds: map[string]Bounds{
+				"netatmo_aircare_co2_ppm": {Min: 0, Max: 5000},
+			},
+			want: `# HELP netatmo_aircare_co2_ppm_avg Average of the last CO2 measurements in parts per million.
+# TYPE netatmo_aircare_co2_ppm_avg gauge
+netatmo_aircare_co2_ppm_avg{module="Living Room",module_type="NAMain",role="station",station="Home"} 500
+`,
+		},
+		{
+			desc: "disappeared module removed",
+			responses: []*netatmo.DeviceCollection{
+				averageTestDevices(3000, 400, nil, true),
+				averageTestDevices(3300, 600, nil, false),
+				averageTestDevices(3600, 800, nil, true),
+			},
+			want: `# HELP netatmo_aircare_co2_ppm_avg Average of the last CO2 measurements in parts per million.
+# TYPE netatmo_aircare_co2_ppm_avg gauge
+netatmo_aircare_co2_ppm_avg{module="Bedroom",module_type="NAModule4",role="module",station="Home"} 1600
+netatmo_aircare_co2_ppm_avg{module="Living Room",module_type="NAMain",role="station",station="Home"} 600
+`,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			var response *netatmo.DeviceCollection
+			c := New(context.Background(), logrus.New(), func() (*netatmo.DeviceCollection, error) {
+				return response, nil
+			}, time.Hour, time.Hour)
+			c.clock = func() time.Time {
+				return time.Unix(3600, 0)
+			}
+			c.AverageWindow = 3
+			c.SensorBounds = tc.bounds
+
+			for i, r := range tc.responses {
+				response = r
+				c.RefreshData(time.Unix(int64(i), 0))
+			}
+
+			if err := testutil.CollectAndCompare(c, strings.NewReader(tc.want), "netatmo_aircare_co2_ppm_avg", "netatmo_aircare_noise_db_avg"); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestNetatmoCollector_CollectAveragesDisabled(t *testing.T) {
+	dc := averageTestDevices(3600, 400, int32Ptr(40), false)
+	c := New(context.Background(), logrus.New(), func() (*netatmo.DeviceCollection, error) {
+		return dc, nil
+	}, time.Hour, time.Hour)
+	c.clock = func() time.Time {
+		return time.Unix(3600, 0)
+	}
+	c.RefreshData(time.Unix(3600, 0))
+
+	if err := testutil.CollectAndCompare(c, strings.NewReader(""), "netatmo_aircare_co2_ppm_avg", "netatmo_aircare_noise_db_avg"); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,95 @@
+package collector
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var sensorRejectedDesc = newLabelledDesc(
+	"netatmo_sensor_rejected_total",
+	"Counts the measurements which were dropped, because their value was outside of the configured bounds.",
+	append([]string{"metric"}, varLabels...))
+
+// Bounds contains the range of plausible values for a sensor metric.
+type Bounds struct {
+	Min float64
+	Max float64
+}
+
+// IsSensorMetric returns true, if name is the name of a metric created from sensor data.
+func IsSensorMetric(name string) bool {
+	for _, desc := range sensorDescs {
+		if sensorDescNames[desc] == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// rejectedSeries contains the state of the rejection counter for one series.
+type rejectedSeries struct {
+	labelValues  []string
+	lastMeasured int64
+	count        uint64
+}
+
+// rejections counts the measurements dropped by the bounds check. Every measurement is only counted once,
+// even though the cached data is checked on every scrape.
+type rejections struct {
+	lock   sync.Mutex
+	series map[string]*rejectedSeries
+}
+
+func (r *rejections) add(labelValues []string, measured int64) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.series == nil {
+		r.series = make(map[string]*rejectedSeries)
+	}
+
+	key := strings.Join(labelValues, "\x00")
+	series, ok := r.series[key]
+	if !ok {
+		series = &rejectedSeries{
+			labelValues: labelValues,
+		}
+		r.series[key] = series
+	}
+
+	if ok && series.lastMeasured == measured {
+		return
+	}
+	series.lastMeasured = measured
+	series.count++
+}
+
+func (r *rejections) collect(c *NetatmoCollector, ch chan<- prometheus.Metric) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for _, series := range r.series {
+		c.sendMetric(ch, sensorRejectedDesc, prometheus.CounterValue, float64(series.count), series.labelValues...)
+	}
+}
+
+// inBounds checks the value of a sensor metric against the configured bounds and counts it as rejected, if
+// it is outside of them.
+func (c *NetatmoCollector) inBounds(desc *prometheus.Desc, measured int64, value float64, labelValues []string) bool {
+	name, ok := sensorDescNames[desc]
+	if !ok {
+		return true
+	}
+
+	bounds, ok := c.SensorBounds[name]
+	if !ok || (value >= bounds.Min && value <= bounds.Max) {
+		return true
+	}
+
+	c.Log.Debugf("Dropping %s value %v outside of bounds [%v, %v].", name, value, bounds.Min, bounds.Max)
+	c.rejected.add(append([]string{name}, labelValues...), measured)
+	return false
+}
@@ -0,0 +1,70 @@
+package collector
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	netatmo "github.com/exzz/netatmo-api-go"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+)
+
+func TestNetatmoCollector_CollectSensorBounds(t *testing.T) {
+	testDevices := func(lastMeasure int64) *netatmo.DeviceCollection {
+		dc := &netatmo.DeviceCollection{}
+		dc.Body.Devices = []*netatmo.Device{
+			{
+				ID:          "aa:bb:cc:dd:ee:f0",
+				ModuleName:  "Outside",
+				StationName: "Home",
+				Type:        "NAModule1",
+				DashboardData: netatmo.DashboardData{
+					Temperature: float32Ptr(-80),
+					Humidity:    int32Ptr(50),
+					LastMeasure: int64Ptr(lastMeasure),
+				},
+			},
+		}
+		return dc
+	}
+	mockClock := func() time.Time {
+		return time.Unix(3600, 0)
+	}
+
+	c := New(context.Background(), logrus.New(), func() (*netatmo.DeviceCollection, error) {
+		return testDevices(3500), nil
+	}, time.Hour, time.Hour)
+	c.clock = mockClock
+	c.SensorBounds = map[string]Bounds{
+		"netatmo_aircare_temperature_celsius": {Min: -50, Max: 60},
+		"netatmo_aircare_humidity_percent":    {Min: 0, Max: 100},
+	}
+	c.RefreshData(mockClock())
+
+	want := func(rejected string) string {
+		return `# HELP netatmo_aircare_humidity_percent Relative humidity measurement in percent
+# TYPE netatmo_aircare_humidity_percent gauge
+netatmo_aircare_humidity_percent{module="Outside",module_type="NAModule1",role="station",station="Home"} 50
+# HELP netatmo_sensor_rejected_total Counts the measurements which were dropped, because their value was outside of the configured bounds.
+# TYPE netatmo_sensor_rejected_total counter
+netatmo_sensor_rejected_total{metric="netatmo_aircare_temperature_celsius",module="Outside",module_type="NAModule1",role="station",station="Home"} ` + rejected + "\n"
+	}
+	metricNames := []string{"netatmo_aircare_temperature_celsius", "netatmo_aircare_humidity_percent", "netatmo_sensor_rejected_total"}
+
+	for i := 0; i < 2; i++ {
+		if err := testutil.CollectAndCompare(c, strings.NewReader(want("1")), metricNames...); err != nil {
+			t.Errorf("scrape %d: %s", i, err)
+		}
+	}
+
+	c.ReadFunction = func() (*netatmo.DeviceCollection, error) {
+		return testDevices(3550), nil
+	}
+	c.RefreshData(mockClock())
+
+	if err := testutil.CollectAndCompare(c, strings.NewReader(want("2")), metricNames...); err != nil {
+		t.Errorf("new measurement: %s", err)
+	}
+}
@@ -0,0 +1,75 @@
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	netatmo "github.com/exzz/netatmo-api-go"
+)
+
+// cacheFile is the content of the cache file. The devices are stored in the cache format, which only contains
+// the fields used by the metrics.
+type cacheFile struct {
+	Timestamp time.Time      `json:"timestamp"`
+	Devices   []cachedDevice `json:"devices"`
+}
+
+// LoadCacheFile restores the cached data from the file set as CacheFile.
+// The returned error satisfies os.IsNotExist if the file does not exist.
+func (c *NetatmoCollector) LoadCacheFile() error {
+	file, err := os.Open(c.CacheFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var content cacheFile
+	if err := json.NewDecoder(file).Decode(&content); err != nil {
+		return fmt.Errorf("error decoding cache file: %w", err)
+	}
+
+	c.cacheLock.Lock()
+	defer c.cacheLock.Unlock()
+	c.cacheTimestamp = content.Timestamp
+	c.cachedData = cachedCollection(content.Devices)
+
+	return nil
+}
+
+// saveCacheFile persists the data to CacheFile. The data is written to a temporary file first, so that
+// an existing cache file is not corrupted by an incomplete write.
+func (c *NetatmoCollector) saveCacheFile(timestamp time.Time, data *netatmo.DeviceCollection) error {
+	content := cacheFile{
+		Timestamp: timestamp,
+		Devices:   newCachedDevices(data, keepAll),
+	}
+
+	raw, err := json.Marshal(content)
+	if err != nil {
+		return fmt.Errorf("error marshalling data: %w", err)
+	}
+
+	tempFile, err := os.CreateTemp(filepath.Dir(c.CacheFile), filepath.Base(c.CacheFile)+".*")
+	if err != nil {
+		return fmt.Errorf("error creating temporary file: %w", err)
+	}
+	defer os.Remove(tempFile.Name())
+
+	if _, err := tempFile.Write(raw); err != nil {
+		tempFile.Close()
+		return fmt.Errorf("error writing temporary file: %w", err)
+	}
+
+	if err := tempFile.Close(); err != nil {
+		return fmt.Errorf("error closing temporary file: %w", err)
+	}
+
+	if err := os.Rename(tempFile.Name(), c.CacheFile); err != nil {
+		return fmt.Errorf("error replacing cache file: %w", err)
+	}
+
+	return nil
+}
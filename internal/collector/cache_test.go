@@ -0,0 +1,105 @@
+package collector
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	netatmo "github.com/exzz/netatmo-api-go"
+	"github.com/google/go-cmp/cmp"
+	"github.com/sirupsen/logrus"
+)
+
+func TestCacheFile(t *testing.T) {
+	testData := &netatmo.DeviceCollection{}
+	testData.Body.Devices = []*netatmo.Device{
+		{
+			ID:          "aa:bb:cc:dd:ee:f0",
+			ModuleName:  "Living Room",
+			StationName: "Home",
+			DashboardData: netatmo.DashboardData{
+				Temperature: float32Ptr(23),
+				LastMeasure: int64Ptr(3500),
+			},
+		},
+	}
+	cacheFile := filepath.Join(t.TempDir(), "cache.json")
+	read := func() (*netatmo.DeviceCollection, error) {
+		return testData, nil
+	}
+
+	c := New(context.Background(), logrus.New(), read, 0, 0)
+	c.CacheFile = cacheFile
+	c.RefreshData(time.Unix(3600, 0))
+
+	restored := New(context.Background(), logrus.New(), read, 0, 0)
+	restored.CacheFile = cacheFile
+	if err := restored.LoadCacheFile(); err != nil {
+		t.Fatalf("error loading cache file: %s", err)
+	}
+
+	wantTime := time.Unix(3600, 0)
+	if !restored.cacheTimestamp.Equal(wantTime) {
+		t.Errorf("got time %s, want %s", restored.cacheTimestamp, wantTime)
+	}
+
+	if diff := cmp.Diff(restored.cachedData, testData); diff != "" {
+		t.Errorf("data differs: -got+want\n%s", diff)
+	}
+}
+
+func TestCacheFileNotExist(t *testing.T) {
+	c := New(context.Background(), logrus.New(), nil, 0, 0)
+	c.CacheFile = filepath.Join(t.TempDir(), "cache.json")
+
+	err := c.LoadCacheFile()
+	if !os.IsNotExist(err) {
+		t.Errorf("got error %q, want not exist", err)
+	}
+}
+
+func TestCacheFileFormat(t *testing.T) {
+	testData := &netatmo.DeviceCollection{}
+	testData.Body.Devices = []*netatmo.Device{
+		{
+			ID:          "aa:bb:cc:dd:ee:f0",
+			HomeID:      "home-id",
+			HomeName:    "Home",
+			ModuleName:  "Living Room",
+			StationName: "Home",
+			Type:        "NAMain",
+			WifiStatus:  int32Ptr(45),
+			DashboardData: netatmo.DashboardData{
+				Temperature: float32Ptr(23),
+				GustAngle:   int32Ptr(10),
+				LastMeasure: int64Ptr(3500),
+			},
+			LinkedModules: []*netatmo.Device{
+				{
+					ID:         "aa:bb:cc:dd:ee:f1",
+					ModuleName: "Outside",
+					Type:       "NAModule1",
+				},
+			},
+		},
+	}
+	cacheFile := filepath.Join(t.TempDir(), "cache.json")
+
+	c := New(context.Background(), logrus.New(), func() (*netatmo.DeviceCollection, error) {
+		return testData, nil
+	}, 0, 0)
+	c.CacheFile = cacheFile
+	c.RefreshData(time.Unix(3600, 0).UTC())
+
+	raw, err := os.ReadFile(cacheFile)
+	if err != nil {
+		t.Fatalf("error reading cache file: %s", err)
+	}
+
+	want := `{"timestamp":"1970-01-01T01:00:00Z","devices":[{"id":"aa:bb:cc:dd:ee:f0","station_name":"Home","module_name":"Living Room","type":"NAMain","wifi_status":45,"data":{"temperature":23,"last_measure":3500},"modules":[{"id":"aa:bb:cc:dd:ee:f1","module_name":"Outside","type":"NAModule1","data":{}}]}]}`
+	if diff := cmp.Diff(string(raw), want); diff != "" {
+		t.Errorf("cache file differs: -got+want\n%s", diff)
+	}
+}
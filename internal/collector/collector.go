@@ -1,158 +1,327 @@
 package collector
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"runtime/debug"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	netatmo "github.com/exzz/netatmo-api-go"
 	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/sirupsen/logrus"
 )
 
+// ErrRefreshInProgress is returned by ForceRefresh, if another refresh is already running.
+var ErrRefreshInProgress = errors.New("refresh already in progress")
+
+// refreshPollInterval is the interval in which WaitForRefresh checks whether the refresh has completed.
+const refreshPollInterval = 10 * time.Millisecond
+
 var (
 	prefix        = "netatmo_"
-	netatmoUpDesc = prometheus.NewDesc(prefix+"up",
-		"Zero if there was an error during the last refresh try.",
-		nil, nil)
+	netatmoUpDesc = newDesc(prefix+"up",
+		"Zero if there was an error during the last refresh try or the cached data is older than the maximum cache age.",
+		nil)
 
-	refreshIntervalDesc = prometheus.NewDesc(
+	refreshIntervalDesc = newDesc(
 		prefix+"refresh_interval_seconds",
 		"Contains the configured refresh interval in seconds. This is provided as a convenience for calculations with the cache update time.",
-		nil, nil)
+		nil)
+	// actualIntervalDesc differs from the configured interval, because scrapes trigger the refreshes.
+	actualIntervalDesc = newDesc(
+		prefix+"refresh_interval_actual_seconds",
+		"Contains the time between the last two refresh tries in seconds. Zero until the second refresh.",
+		nil)
 	refreshPrefix        = prefix + "last_refresh_"
-	refreshTimestampDesc = prometheus.NewDesc(
+	refreshTimestampDesc = newDesc(
 		refreshPrefix+"time",
 		"Contains the time of the last refresh try, successful or not.",
-		nil, nil)
-	refreshDurationDesc = prometheus.NewDesc(
+		nil)
+	// lastSuccessDesc also changes for empty responses, which do not update the cache, so it can differ from
+	// cacheTimestampDesc.
+	lastSuccessDesc = newDesc(
+		prefix+"last_successful_api_call_seconds",
+		"Contains the time of the last read from the API which completed without an error. Zero if there was none yet.",
+		nil)
+	refreshDurationDesc = newDesc(
 		refreshPrefix+"duration_seconds",
 		"Contains the time it took for the last refresh to complete, even if it was unsuccessful.",
-		nil, nil)
+		nil)
+	refreshDurationHistogramDesc = newDesc(
+		prefix+"refresh_duration_seconds",
+		"Histogram of the time it took for refreshes to complete, even if they were unsuccessful.",
+		nil)
 
-	cacheTimestampDesc = prometheus.NewDesc(
+	refreshInProgressDesc = newDesc(
+		prefix+"refresh_in_progress",
+		"One while a refresh of the data is in progress, zero otherwise.",
+		nil)
+
+	cacheTimestampDesc = newDesc(
 		prefix+"cache_updated_time",
 		"Contains the time of the cached data.",
-		nil, nil)
+		nil)
+
+	cacheStalenessDesc = newDesc(
+		prefix+"cache_staleness_seconds",
+		"Contains the age of the cached data at the time of the scrape. Not present if there is no cached data.",
+		nil)
+
+	consecutiveFailuresDesc = newDesc(
+		prefix+"consecutive_refresh_failures",
+		"Contains the number of refresh tries which failed in a row. Reset to zero by a successful refresh.",
+		nil)
+
+	cacheServedDesc = newDesc(
+		prefix+"cache_served_total",
+		"Counts the scrapes which were served from the cache without triggering a refresh.",
+		nil)
+	emptyResponseDesc = newDesc(
+		prefix+"empty_response_total",
+		"Counts the refreshes which returned no devices and were ignored to keep the cached data.",
+		nil)
+	refreshTriggeredDesc = newDesc(
+		prefix+"refresh_triggered_total",
+		"Counts the scrapes which triggered a refresh, because the refresh interval had elapsed.",
+		nil)
+
+	stationUpDesc = newLabelledDesc(
+		prefix+"station_up",
+		"Zero if the station was missing from the response of the last refresh try.",
+		[]string{stationLabel})
+	stationModulesDesc = newLabelledDesc(
+		prefix+"station_linked_modules",
+		"Contains the number of modules linked to the station in the cached data.",
+		[]string{stationLabel})
 
 	varLabels = []string{
-		"module",
-		"station",
+		moduleLabel,
+		stationLabel,
+		"module_type",
+		"role",
 	}
 
+	freshnessDesc = newLabelledDesc(
+		prefix+"data_freshness_ratio",
+		"Age of the module data relative to the stale threshold. Zero is fresh, one or more means that the data is stale.",
+		varLabels)
+
+	moduleOnlineDesc = newLabelledDesc(
+		prefix+"module_online",
+		"One if the module is reachable and has data newer than the stale threshold, zero otherwise.",
+		varLabels)
+
 	sensorPrefix = prefix + "aircare_"
 
-	updatedDesc = prometheus.NewDesc(
-		sensorPrefix+"updated",
-		"Timestamp of last update",
-		varLabels,
-		nil)
+	updatedDesc = newSensorDesc(
+		"updated",
+		"Timestamp of last update")
 
-	tempDesc = prometheus.NewDesc(
-		sensorPrefix+"temperature_celsius",
-		"Temperature measurement in celsius",
-		varLabels,
-		nil)
+	tempDesc = newSensorDesc(
+		"temperature_celsius",
+		"Temperature measurement in celsius")
 
-	humidityDesc = prometheus.NewDesc(
-		sensorPrefix+"humidity_percent",
-		"Relative humidity measurement in percent",
-		varLabels,
-		nil)
+	humidityDesc = newSensorDesc(
+		"humidity_percent",
+		"Relative humidity measurement in percent")
 
-	cotwoDesc = prometheus.NewDesc(
-		sensorPrefix+"co2_ppm",
-		"Carbondioxide measurement in parts per million",
-		varLabels,
-		nil)
+	dewPointDesc = newSensorDesc(
+		"dew_point_celsius",
+		"Dew point in celsius calculated from temperature and humidity")
 
-	noiseDesc = prometheus.NewDesc(
-		sensorPrefix+"noise_db",
-		"Noise measurement in decibels",
-		varLabels,
-		nil)
+	absoluteHumidityDesc = newSensorDesc(
+		"absolute_humidity_grams_per_cubic_meter",
+		"Absolute humidity in grams per cubic meter calculated from temperature and humidity")
 
-	pressureDesc = prometheus.NewDesc(
-		sensorPrefix+"pressure_mb",
-		"Atmospheric pressure measurement in millibar",
-		varLabels,
-		nil)
+	heatIndexDesc = newSensorDesc(
+		"heat_index_celsius",
+		"Heat index (\"feels like\" temperature) in celsius, same as temperature below 27°C")
 
-	windStrengthDesc = prometheus.NewDesc(
-		sensorPrefix+"wind_strength_kph",
-		"Wind strength in kilometers per hour",
-		varLabels,
-		nil)
+	cotwoDesc = newSensorDesc(
+		"co2_ppm",
+		"Carbondioxide measurement in parts per million")
 
-	windDirectionDesc = prometheus.NewDesc(
-		sensorPrefix+"wind_direction_degrees",
-		"Wind direction in degrees",
-		varLabels,
-		nil)
+	comfortDesc = newSensorDesc(
+		"comfort_level",
+		"Comfort level calculated from CO2, temperature and humidity: 0 = Good, 1 = Fair, 2 = Poor, 3 = Bad")
 
-	rainDesc = prometheus.NewDesc(
-		sensorPrefix+"rain_amount_mm",
-		"Rain amount in millimeters",
-		varLabels,
-		nil)
+	noiseDesc = newSensorDesc(
+		"noise_db",
+		"Noise measurement in decibels")
 
-	batteryDesc = prometheus.NewDesc(
-		sensorPrefix+"battery_percent",
-		"Battery remaining life (10: low)",
-		varLabels,
-		nil)
-	wifiDesc = prometheus.NewDesc(
-		sensorPrefix+"wifi_signal_strength",
-		"Wifi signal strength (86: bad, 71: avg, 56: good)",
-		varLabels,
-		nil)
-	rfDesc = prometheus.NewDesc(
-		sensorPrefix+"rf_signal_strength",
-		"RF signal strength (90: lowest, 60: highest)",
-		varLabels,
-		nil)
-	absolutePressureDesc = prometheus.NewDesc(
-		sensorPrefix+"absolute_pressure",
-		"Absolute pressure",
-		varLabels,
-		nil)
-	lastMeasureUtcDesc = prometheus.NewDesc(
-		sensorPrefix+"last_measure_utc",
-		"Measurement time UTC",
-		varLabels,
-		nil)
-	healthIndexDesc = prometheus.NewDesc(
-		sensorPrefix+"health_index",
-		"Health index: 0 = Healthy,1 = Fine,2 = Fair,3 = Poor,4 = Unhealthy",
-		varLabels,
-		nil)
+	pressureDesc = newSensorDesc(
+		"pressure_mb",
+		"Atmospheric pressure measurement in millibar, corrected to sea level")
+
+	// pressureSeaLevelDesc contains the same value as pressureDesc, but makes the difference to the absolute
+	// pressure clear from the name. The altitude used for the correction is exported in stationAltitudeDesc.
+	pressureSeaLevelDesc = newSensorDesc(
+		"pressure_sea_level_mb",
+		"Atmospheric pressure measurement in millibar, corrected to sea level")
+
+	// windStrengthDesc uses the unit reported by the API, the descriptors of the other units are in windUnits.
+	windStrengthDesc = newSensorDesc(
+		"wind_strength_kph",
+		"Wind strength in kilometers per hour")
+
+	windDirectionDesc = newSensorDesc(
+		"wind_direction_degrees",
+		"Wind direction in degrees")
+
+	// rainDesc is the amount of the last measurement, not a running sum, so it is exported as a gauge.
+	rainDesc = newSensorDesc(
+		"rain_amount_mm",
+		"Rain amount in millimeters of the last measurement. This is not a cumulative sum.")
+
+	rainHourDesc = newSensorDesc(
+		"rain_sum_1h_mm",
+		"Rain amount in millimeters of the last hour.")
+
+	// rainDayDesc only increases until Netatmo resets it at midnight, so it is exported as a counter and rate() and
+	// increase() handle the reset. The resets are counted in rainResetDesc.
+	rainDayDesc = newSensorDesc(
+		"rain_sum_today_mm",
+		"Rain amount in millimeters since midnight. This is a counter, which is reset by Netatmo every day.")
+
+	batteryDesc = newSensorDesc(
+		"battery_percent",
+		"Battery remaining life (10: low)")
+	wifiDesc = newSensorDesc(
+		"wifi_signal_strength",
+		"Wifi signal strength (86: bad, 71: avg, 56: good)")
+	rfDesc = newSensorDesc(
+		"rf_signal_strength",
+		"RF signal strength (90: lowest, 60: highest)")
+	// counterDescs are the sensor metrics, which are cumulative sums and exported as counters.
+	counterDescs = map[*prometheus.Desc]bool{
+		rainDayDesc: true,
+	}
+	// connectivityDescs are the metrics which are also exported for stale data.
+	connectivityDescs = map[*prometheus.Desc]bool{
+		batteryDesc: true,
+		wifiDesc:    true,
+		rfDesc:      true,
+	}
+	absolutePressureDesc = newSensorDesc(
+		"absolute_pressure",
+		"Absolute pressure measurement in millibar at the altitude of the station")
+	lastMeasureUtcDesc = newSensorDesc(
+		"last_measure_utc",
+		"Measurement time UTC")
+	healthIndexDesc = newSensorDesc(
+		"health_index",
+		"Health index: 0 = Healthy,1 = Fine,2 = Fair,3 = Poor,4 = Unhealthy")
+	// homeCoachDescs are the metrics only reported by Home Coach devices, which are not read by default.
+	homeCoachDescs = map[*prometheus.Desc]bool{
+		healthIndexDesc: true,
+	}
+)
+
+// Values of the "role" label, which distinguishes the sensors of the main device of a station from the
+// sensors of the additional modules.
+const (
+	roleStation = "station"
+	roleModule  = "module"
 )
 
+// DefaultModuleGracePeriod is the time the last-seen timestamp of a module is still emitted after the module
+// disappeared from the API.
+const DefaultModuleGracePeriod = 24 * time.Hour
+
 // ReadFunction defines the interface for reading from the Netatmo API.
 type ReadFunction func() (*netatmo.DeviceCollection, error)
 
 // NetatmoCollector is a Prometheus collector for Netatmo sensor values.
 type NetatmoCollector struct {
-	Log             logrus.FieldLogger
-	RefreshInterval time.Duration
-	StaleThreshold  time.Duration
-	ReadFunction    ReadFunction
-	clock           func() time.Time
+	Log                    logrus.FieldLogger
+	RefreshInterval        time.Duration
+	RefreshJitter          time.Duration
+	StaleThreshold         time.Duration
+	TypeStaleThresholds    map[string]time.Duration
+	MaxCacheAge            time.Duration
+	ReadFunction           ReadFunction
+	CacheFile              string
+	RefreshDurationBuckets []float64
+	DisabledMetrics        map[string]bool
+	SensorBounds           map[string]Bounds
+	AcceptEmptyResponse    bool
+	ModuleGracePeriod      time.Duration
+	ComfortThresholds      ComfortThresholds
+	ConvenienceMetrics     bool
+	CompactCache           bool
+	InitialRefreshTimeout  time.Duration
+	WarmupDelay            time.Duration
+	WindUnit               WindUnit
+	TemperatureUnits       []TemperatureUnit
+	LabelNames             LabelNames
+	ModuleIDLabel          bool
+	OmitMissingTimestamps  bool
+	MetricTimestamps       bool
+	AverageWindow          int
+	SignalTrend            bool
+	EnableHomeCoach        bool
+	ctx                    context.Context
+	clock                  func() time.Time
+	random                 *rand.Rand
+	started                time.Time
 
+	// The refresh bookkeeping is written by the refresh goroutine and is guarded by the cacheLock like the cache.
 	lastRefresh         time.Time
+	refreshOffset       time.Duration
+	actualInterval      time.Duration
 	lastRefreshError    error
+	lastSuccess         time.Time
+	consecutiveFailures int
 	lastRefreshDuration time.Duration
+	refreshDurations    durationHistogram
+	rejected            rejections
+	cacheServed         atomic.Uint64
+	refreshTriggered    atomic.Uint64
+	refreshing          atomic.Bool
+	initialRefresh      chan struct{}
+	initialRefreshOnce  sync.Once
+	emptyResponses      atomic.Uint64
+	duplicateModules    atomic.Uint64
 	cacheLock           sync.RWMutex
 	cacheTimestamp      time.Time
 	cachedData          *netatmo.DeviceCollection
+	stationUp           map[string]bool
+	modulesSeen         lastSeen
+	rainSums            rainResets
+	averages            averages
+	signals             signalTrends
+	renameOnce          sync.Once
+	renamed             map[*prometheus.Desc]*prometheus.Desc
 }
 
-func New(log *logrus.Logger, readFunction ReadFunction, refreshInterval, staleDuration time.Duration) *NetatmoCollector {
+// New creates a new NetatmoCollector. Refreshes of the data are stopped once the context is cancelled.
+// The buckets of the refresh duration histogram default to prometheus.DefBuckets and the grace period of the
+// last-seen timestamps to DefaultModuleGracePeriod. The comfort level uses the DefaultComfortThresholds.
+func New(ctx context.Context, log *logrus.Logger, readFunction ReadFunction, refreshInterval, staleDuration time.Duration) *NetatmoCollector {
 	return &NetatmoCollector{
-		Log:             log,
-		RefreshInterval: refreshInterval,
-		StaleThreshold:  staleDuration,
-		ReadFunction:    readFunction,
-		clock:           time.Now,
+		Log:                    log,
+		RefreshInterval:        refreshInterval,
+		StaleThreshold:         staleDuration,
+		ReadFunction:           readFunction,
+		RefreshDurationBuckets: prometheus.DefBuckets,
+		ModuleGracePeriod:      DefaultModuleGracePeriod,
+		ComfortThresholds:      DefaultComfortThresholds,
+		modulesSeen:            make(lastSeen),
+		rainSums:               make(rainResets),
+		averages:               make(averages),
+		signals:                make(signalTrends),
+		initialRefresh:         make(chan struct{}),
+		ctx:                    ctx,
+		clock:                  time.Now,
+		random:                 rand.New(rand.NewSource(time.Now().UnixNano())),
+		started:                time.Now(),
 	}
 }
 
@@ -160,87 +329,595 @@ func New(log *logrus.Logger, readFunction ReadFunction, refreshInterval, staleDu
 func (c *NetatmoCollector) Describe(dChan chan<- *prometheus.Desc) {
 	dChan <- netatmoUpDesc
 	dChan <- refreshIntervalDesc
+	dChan <- actualIntervalDesc
 	dChan <- refreshTimestampDesc
+	dChan <- lastSuccessDesc
 	dChan <- refreshDurationDesc
+	dChan <- refreshDurationHistogramDesc
+	dChan <- refreshInProgressDesc
+	dChan <- consecutiveFailuresDesc
 	dChan <- cacheTimestampDesc
-	dChan <- updatedDesc
-	dChan <- tempDesc
-	dChan <- humidityDesc
-	dChan <- cotwoDesc
-	dChan <- noiseDesc
-	dChan <- pressureDesc
-	dChan <- windStrengthDesc
-	dChan <- windDirectionDesc
-	dChan <- rainDesc
-	dChan <- batteryDesc
-	dChan <- wifiDesc
-	dChan <- rfDesc
-	dChan <- absolutePressureDesc
-	dChan <- lastMeasureUtcDesc
-	dChan <- healthIndexDesc
+	dChan <- cacheStalenessDesc
+	dChan <- cacheServedDesc
+	dChan <- refreshTriggeredDesc
+	dChan <- emptyResponseDesc
+	dChan <- duplicateModulesDesc
+	dChan <- c.desc(stationUpDesc)
+	dChan <- c.desc(stationModulesDesc)
+	dChan <- c.desc(stationAvgTemperatureDesc)
+	dChan <- c.desc(stationMinTemperatureDesc)
+	dChan <- c.desc(stationMaxTemperatureDesc)
+	dChan <- c.desc(stationAltitudeDesc)
+	if c.ConvenienceMetrics {
+		dChan <- c.desc(indoorTemperatureDesc)
+		dChan <- c.desc(outdoorTemperatureDesc)
+	}
+	if c.AverageWindow > 0 {
+		for _, sensor := range averagedSensors {
+			dChan <- c.desc(sensor.desc)
+		}
+	}
+	if c.SignalTrend {
+		dChan <- c.desc(wifiTrendDesc)
+		dChan <- c.desc(rfTrendDesc)
+	}
+	dChan <- c.desc(freshnessDesc)
+	dChan <- c.desc(moduleOnlineDesc)
+	dChan <- c.desc(moduleLastSeenDesc)
+	dChan <- c.desc(rainResetDesc)
+	dChan <- c.desc(sensorRejectedDesc)
+	for _, desc := range sensorDescs {
+		if c.metricEnabled(desc) {
+			dChan <- c.desc(desc)
+		}
+	}
 }
 
 // Collect implements prometheus.Collector
 func (c *NetatmoCollector) Collect(mChan chan<- prometheus.Metric) {
 	now := c.clock()
-	if now.Sub(c.lastRefresh) >= c.RefreshInterval {
-		go c.RefreshData(now)
-	}
+	c.triggerRefresh(now)
+	c.waitForInitialRefresh()
+
+	c.cacheLock.RLock()
+	defer c.cacheLock.RUnlock()
 
-	upValue := 1.0
-	if c.lastRefresh.IsZero() || c.lastRefreshError != nil {
-		upValue = 0
-	}
-	c.sendMetric(mChan, netatmoUpDesc, prometheus.GaugeValue, upValue)
 	c.sendMetric(mChan, refreshIntervalDesc, prometheus.GaugeValue, c.RefreshInterval.Seconds())
-	c.sendMetric(mChan, refreshTimestampDesc, prometheus.GaugeValue, convertTime(c.lastRefresh))
+	c.sendMetric(mChan, actualIntervalDesc, prometheus.GaugeValue, c.actualInterval.Seconds())
+	c.sendTimestamp(mChan, refreshTimestampDesc, c.lastRefresh)
+	c.sendTimestamp(mChan, lastSuccessDesc, c.lastSuccess)
 	c.sendMetric(mChan, refreshDurationDesc, prometheus.GaugeValue, c.lastRefreshDuration.Seconds())
+	if histogram, err := c.refreshDurations.metric(refreshDurationHistogramDesc, c.RefreshDurationBuckets); err != nil {
+		c.Log.Errorf("Error creating refresh duration histogram: %s", err)
+	} else {
+		mChan <- histogram
+	}
+	c.sendMetric(mChan, refreshInProgressDesc, prometheus.GaugeValue, boolToFloat(c.refreshing.Load()))
+	c.sendMetric(mChan, consecutiveFailuresDesc, prometheus.GaugeValue, float64(c.consecutiveFailures))
+	c.sendMetric(mChan, cacheServedDesc, prometheus.CounterValue, float64(c.cacheServed.Load()))
+	c.sendMetric(mChan, refreshTriggeredDesc, prometheus.CounterValue, float64(c.refreshTriggered.Load()))
+	c.sendMetric(mChan, emptyResponseDesc, prometheus.CounterValue, float64(c.emptyResponses.Load()))
+	c.sendMetric(mChan, duplicateModulesDesc, prometheus.CounterValue, float64(c.duplicateModules.Load()))
+	c.sendMetric(mChan, netatmoUpDesc, prometheus.GaugeValue, boolToFloat(c.up() && !c.cacheExpired(now)))
+	c.sendTimestamp(mChan, cacheTimestampDesc, c.cacheTimestamp)
+	if !c.cacheTimestamp.IsZero() {
+		c.sendMetric(mChan, cacheStalenessDesc, prometheus.GaugeValue, now.Sub(c.cacheTimestamp).Seconds())
+	}
+	c.collectStations(mChan, func(string) bool {
+		return true
+	})
+	c.rejected.collect(c, mChan)
+	c.modulesSeen.collect(c, mChan)
+	c.rainSums.collect(c, mChan)
+}
 
+// Up returns true, if the last refresh of the data was successful.
+func (c *NetatmoCollector) Up() bool {
 	c.cacheLock.RLock()
 	defer c.cacheLock.RUnlock()
 
-	c.sendMetric(mChan, cacheTimestampDesc, prometheus.GaugeValue, convertTime(c.cacheTimestamp))
-	if c.cachedData != nil {
-		for _, dev := range c.cachedData.Devices() {
-			stationName := dev.StationName //nolint: staticcheck
-			c.collectData(mChan, dev, stationName)
+	return c.up()
+}
+
+// up is Up for callers, which already hold the cacheLock.
+func (c *NetatmoCollector) up() bool {
+	return !c.lastRefresh.IsZero() && c.lastRefreshError == nil
+}
+
+// ForceRefresh refreshes the data immediately, independent of the refresh interval, and returns the error of the
+// refresh. Only one refresh runs at a time, so ErrRefreshInProgress is returned without refreshing if another
+// one is already running.
+func (c *NetatmoCollector) ForceRefresh() error {
+	if !c.refreshing.CompareAndSwap(false, true) {
+		return ErrRefreshInProgress
+	}
+
+	c.RefreshData(c.clock())
+
+	c.cacheLock.RLock()
+	defer c.cacheLock.RUnlock()
+
+	return c.lastRefreshError
+}
+
+// RefreshNow refreshes the data immediately, independent of the refresh interval, like ForceRefresh. If another
+// refresh is already running, it waits for that one to complete instead of starting a second one, so that the data
+// is available once it returns. It returns early when the context of the collector is cancelled.
+func (c *NetatmoCollector) RefreshNow() {
+	if !c.refreshing.CompareAndSwap(false, true) {
+		c.waitForRefresh(c.ctx.Done())
+		return
+	}
+
+	c.RefreshData(c.clock())
+}
+
+// Snapshot returns the metrics the collector currently emits, sorted by name and labels, without serving them
+// through HTTP. Like a scrape it triggers a refresh, if the data is older than the refresh interval.
+func (c *NetatmoCollector) Snapshot() ([]*dto.MetricFamily, error) {
+	registry := prometheus.NewRegistry()
+	if err := registry.Register(c); err != nil {
+		return nil, fmt.Errorf("error registering collector: %w", err)
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		return nil, fmt.Errorf("error gathering metrics: %w", err)
+	}
+
+	return families, nil
+}
+
+// WaitForRefresh waits until a refresh, which is currently running, has completed. It returns false if the
+// refresh is still running after the timeout.
+func (c *NetatmoCollector) WaitForRefresh(timeout time.Duration) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return c.waitForRefresh(ctx.Done())
+}
+
+// waitForRefresh polls until the running refresh has completed or done is closed.
+func (c *NetatmoCollector) waitForRefresh(done <-chan struct{}) bool {
+	ticker := time.NewTicker(refreshPollInterval)
+	defer ticker.Stop()
+
+	for c.refreshing.Load() {
+		select {
+		case <-done:
+			return false
+		case <-ticker.C:
+		}
+	}
+
+	return true
+}
+
+// StationCollector returns a collector, which only emits the sensor metrics of the station with the given name.
+// It reads from the same cache as the NetatmoCollector and triggers a refresh in the same way when the data
+// is older than the refresh interval, so it is safe to use many of them concurrently.
+func (c *NetatmoCollector) StationCollector(stationName string) prometheus.Collector {
+	return &stationCollector{
+		parent:      c,
+		stationName: stationName,
+	}
+}
+
+type stationCollector struct {
+	parent      *NetatmoCollector
+	stationName string
+}
+
+// Describe implements prometheus.Collector
+func (s *stationCollector) Describe(dChan chan<- *prometheus.Desc) {
+	s.parent.Describe(dChan)
+}
+
+// Collect implements prometheus.Collector
+func (s *stationCollector) Collect(mChan chan<- prometheus.Metric) {
+	s.parent.triggerRefresh(s.parent.clock())
+	s.parent.waitForInitialRefresh()
+
+	s.parent.cacheLock.RLock()
+	defer s.parent.cacheLock.RUnlock()
+
+	s.parent.collectStations(mChan, func(stationName string) bool {
+		return stationName == s.stationName
+	})
+}
+
+func (c *NetatmoCollector) triggerRefresh(now time.Time) {
+	if c.ctx.Err() != nil {
+		return
+	}
+
+	// Scrapes during the warm-up are not counted as served from the cache, as there is no cache yet.
+	if c.warmingUp(now) {
+		c.Log.Debug("Waiting for warm-up delay before first refresh.")
+		return
+	}
+
+	if !c.refreshDue(now) {
+		c.cacheServed.Add(1)
+		return
+	}
+
+	// Only one refresh is started at a time, otherwise scrapes arriving before the running refresh has updated
+	// lastRefresh would start additional ones.
+	if !c.refreshing.CompareAndSwap(false, true) {
+		c.Log.Debug("Refresh already in progress.")
+		c.cacheServed.Add(1)
+		return
+	}
+
+	c.refreshTriggered.Add(1)
+	go c.RefreshData(now)
+}
+
+// warmingUp returns true during the warm-up delay after the start, in which no refresh is triggered, so that the
+// token source has time to settle.
+func (c *NetatmoCollector) warmingUp(now time.Time) bool {
+	c.cacheLock.RLock()
+	defer c.cacheLock.RUnlock()
+
+	return c.WarmupDelay > 0 && c.lastRefresh.IsZero() && now.Sub(c.started) < c.WarmupDelay
+}
+
+// refreshDue returns true, if the refresh interval plus the jitter of the last refresh has passed.
+func (c *NetatmoCollector) refreshDue(now time.Time) bool {
+	c.cacheLock.RLock()
+	defer c.cacheLock.RUnlock()
+
+	return now.Sub(c.lastRefresh) >= c.RefreshInterval+c.refreshOffset
+}
+
+// jitter returns a random offset between -RefreshJitter and RefreshJitter, which is added to the refresh interval,
+// so that many exporters do not refresh at the same time.
+func (c *NetatmoCollector) jitter() time.Duration {
+	if c.RefreshJitter <= 0 {
+		return 0
+	}
+
+	return time.Duration(c.random.Int63n(2*int64(c.RefreshJitter)+1)) - c.RefreshJitter
+}
+
+// waitForInitialRefresh blocks until the first refresh has completed, so that the first scrape after a start
+// already contains data. It waits at most InitialRefreshTimeout and does not wait at all if that is zero.
+func (c *NetatmoCollector) waitForInitialRefresh() {
+	if c.InitialRefreshTimeout <= 0 || c.warmingUp(c.clock()) {
+		return
+	}
+
+	select {
+	case <-c.initialRefresh:
+		return
+	default:
+	}
+
+	timer := time.NewTimer(c.InitialRefreshTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-c.initialRefresh:
+	case <-c.ctx.Done():
+	case <-timer.C:
+		c.Log.Warnf("Initial refresh did not complete within %s, serving metrics without data.", c.InitialRefreshTimeout)
+	}
+}
+
+// collectStations emits the metrics of all stations in the cache, which are accepted by the filter.
+// The caller needs to hold the cacheLock.
+func (c *NetatmoCollector) collectStations(mChan chan<- prometheus.Metric, filter func(stationName string) bool) {
+	if c.cachedData == nil {
+		return
+	}
+
+	if c.cacheExpired(c.clock()) {
+		c.Log.Debugf("Cached data is older than %s, not exporting sensor metrics.", c.MaxCacheAge)
+		return
+	}
+
+	for _, dev := range sortedByID(c.cachedData.Devices()) {
+		stationName := dev.StationName //nolint: staticcheck
+		if !filter(stationName) {
+			continue
+		}
+
+		c.sendMetric(mChan, stationUpDesc, prometheus.GaugeValue, boolToFloat(c.stationUp[dev.ID]), stationName)
+		c.sendMetric(mChan, stationModulesDesc, prometheus.GaugeValue, float64(len(stationModules(dev))), stationName)
+		c.collectData(mChan, dev, deviceName(dev, stationName), stationName, roleStation)
 
-			for _, module := range dev.LinkedModules {
-				c.collectData(mChan, module, stationName)
+		for _, module := range stationModules(dev) {
+			c.collectData(mChan, module, deviceName(module, ""), stationName, roleModule)
+		}
+
+		c.collectStationAggregates(mChan, dev, stationName)
+		c.collectStationAltitude(mChan, dev, stationName)
+		c.collectConvenienceTemperatures(mChan, dev, stationName)
+	}
+}
+
+// cacheExpired returns true, if the cached data is older than MaxCacheAge. This is a limit for the whole cache
+// in addition to the stale threshold of the modules, which is disabled if MaxCacheAge is zero. The caller needs
+// to hold the cacheLock.
+func (c *NetatmoCollector) cacheExpired(now time.Time) bool {
+	return c.MaxCacheAge > 0 && !c.cacheTimestamp.IsZero() && now.Sub(c.cacheTimestamp) > c.MaxCacheAge
+}
+
+// sortedByID returns a copy of the devices sorted by their ID, so that the metrics are always emitted in the
+// same order, independent of the order of the API response.
+func sortedByID(devices []*netatmo.Device) []*netatmo.Device {
+	sorted := append([]*netatmo.Device(nil), devices...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].ID < sorted[j].ID
+	})
+
+	return sorted
+}
+
+// stationModules returns the modules linked to the station sorted by their ID. This includes the modules linked
+// to other modules, for example through a relay. Every ID is only returned once, so that a cycle in the data does
+// not result in an endless loop.
+func stationModules(station *netatmo.Device) []*netatmo.Device {
+	seen := map[string]bool{station.ID: true}
+	var modules []*netatmo.Device
+	var add func(devices []*netatmo.Device)
+	add = func(devices []*netatmo.Device) {
+		for _, module := range devices {
+			if seen[module.ID] {
+				continue
 			}
+			seen[module.ID] = true
+
+			modules = append(modules, module)
+			add(module.LinkedModules)
 		}
 	}
+	add(station.LinkedModules)
+
+	return sortedByID(modules)
 }
 
 // RefreshData causes the collector to try to refresh the cached data.
 func (c *NetatmoCollector) RefreshData(now time.Time) {
+	c.cacheLock.Lock()
 	c.Log.Debugf("Refreshing data. Time since last refresh: %s", now.Sub(c.lastRefresh))
+	if !c.lastRefresh.IsZero() {
+		c.actualInterval = now.Sub(c.lastRefresh)
+	}
 	c.lastRefresh = now
+	c.refreshOffset = c.jitter()
+	c.cacheLock.Unlock()
 
+	c.refreshing.Store(true)
+	defer c.refreshing.Store(false)
+	defer c.initialRefreshOnce.Do(func() {
+		close(c.initialRefresh)
+	})
+
+	// The refresh bookkeeping is read by concurrent scrapes, so it is only written while holding the cacheLock.
+	// The deferred unlock of the cache below runs before this one.
 	defer func(start time.Time) {
-		c.lastRefreshDuration = c.clock().Sub(start)
+		duration := c.clock().Sub(start)
+		c.refreshDurations.observe(c.RefreshDurationBuckets, duration.Seconds())
+
+		c.cacheLock.Lock()
+		c.lastRefreshDuration = duration
+		c.cacheLock.Unlock()
 	}(c.clock())
 
-	devices, err := c.ReadFunction()
+	devices, err := c.read()
+	if c.ctx.Err() != nil {
+		c.Log.Debugf("Refresh cancelled: %s", c.ctx.Err())
+		return
+	}
+
+	c.cacheLock.Lock()
+	defer c.cacheLock.Unlock()
+
 	c.lastRefreshError = err
+	if err != nil {
+		c.consecutiveFailures++
+	} else {
+		c.consecutiveFailures = 0
+		c.lastSuccess = now
+	}
+
 	if err != nil {
 		c.Log.Errorf("Error during refresh: %s", err)
+		if devices == nil || len(devices.Devices()) == 0 {
+			for id := range c.stationUp {
+				c.stationUp[id] = false
+			}
+			return
+		}
+
+		c.Log.Warnf("Refresh returned partial data for %d stations, keeping cached data for the others.", len(devices.Devices()))
+	}
+
+	if err == nil && !c.AcceptEmptyResponse && (devices == nil || len(devices.Devices()) == 0) {
+		c.emptyResponses.Add(1)
+		c.Log.Warn("Refresh returned no devices, keeping cached data.")
+		for id := range c.stationUp {
+			c.stationUp[id] = false
+		}
 		return
 	}
 
-	c.cacheLock.Lock()
-	defer c.cacheLock.Unlock()
+	devices, dropped := removeDuplicates(devices)
+	if dropped > 0 {
+		c.duplicateModules.Add(uint64(dropped))
+		c.Log.Warnf("Refresh returned %d duplicated stations or modules, keeping the entries with the newest measurement.", dropped)
+	}
+
+	stationUp := make(map[string]bool)
+	if devices != nil {
+		for _, dev := range devices.Devices() {
+			stationUp[dev.ID] = true
+		}
+	}
+	c.modulesSeen.update(now, devices, c.ModuleGracePeriod, c.moduleLabels)
+	c.rainSums.update(devices, c.moduleLabels)
+
+	if err != nil {
+		devices = mergeDevices(c.cachedData, devices)
+	} else if missing := missingStations(c.cachedData, devices); len(missing) > 0 {
+		c.Log.Warnf("Refresh response is missing %d previously known stations, the response might be truncated: %s", len(missing), strings.Join(missing, ", "))
+	}
+
+	if c.AverageWindow > 0 {
+		c.averages.update(devices, c.AverageWindow, c.SensorBounds)
+	}
+	if c.SignalTrend {
+		c.signals.update(devices)
+	}
+
+	if c.CompactCache {
+		devices = c.compactDevices(devices)
+	}
+
 	c.cacheTimestamp = now
 	c.cachedData = devices
+	c.stationUp = stationUp
+
+	if c.CacheFile != "" {
+		if err := c.saveCacheFile(now, devices); err != nil {
+			c.Log.Errorf("Error saving cache file: %s", err)
+		}
+	}
+}
+
+// read calls the ReadFunction, but returns early when the context of the collector is cancelled.
+// The ReadFunction itself can not be interrupted, but its result is discarded in that case.
+func (c *NetatmoCollector) read() (*netatmo.DeviceCollection, error) {
+	type result struct {
+		devices *netatmo.DeviceCollection
+		err     error
+	}
+
+	resultCh := make(chan result, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				c.Log.Errorf("Read function panicked: %v\n%s", r, debug.Stack())
+				resultCh <- result{err: fmt.Errorf("read function panicked: %v", r)}
+			}
+		}()
+
+		devices, err := c.ReadFunction()
+		resultCh <- result{devices, err}
+	}()
+
+	select {
+	case <-c.ctx.Done():
+		return nil, c.ctx.Err()
+	case r := <-resultCh:
+		return r.devices, r.err
+	}
 }
 
-func (c *NetatmoCollector) collectData(ch chan<- prometheus.Metric, device *netatmo.Device, stationName string) {
-	moduleName := device.ModuleName
-	if moduleName == "" {
-		moduleName = "id-" + device.ID
+// mergeDevices creates a new collection containing all devices from update and the devices from previous,
+// which are not part of update.
+func mergeDevices(previous, update *netatmo.DeviceCollection) *netatmo.DeviceCollection {
+	merged := &netatmo.DeviceCollection{}
+	merged.Body.Devices = append(merged.Body.Devices, update.Devices()...)
+	if previous == nil {
+		return merged
 	}
 
+	updated := make(map[string]bool, len(update.Devices()))
+	for _, dev := range update.Devices() {
+		updated[dev.ID] = true
+	}
+
+	for _, dev := range previous.Devices() {
+		if !updated[dev.ID] {
+			merged.Body.Devices = append(merged.Body.Devices, dev)
+		}
+	}
+
+	return merged
+}
+
+// missingStations returns the IDs of the stations contained in the previous data which are not part of the update.
+func missingStations(previous, update *netatmo.DeviceCollection) []string {
+	if previous == nil {
+		return nil
+	}
+
+	updated := make(map[string]bool)
+	if update != nil {
+		for _, dev := range update.Devices() {
+			updated[dev.ID] = true
+		}
+	}
+
+	var missing []string
+	for _, dev := range previous.Devices() {
+		if !updated[dev.ID] {
+			missing = append(missing, dev.ID)
+		}
+	}
+
+	return missing
+}
+
+// deviceName returns the name used for the module label of a device. If the device has no name, the fallback
+// is used. The ID of the device is only used if neither is available.
+func deviceName(device *netatmo.Device, fallback string) string {
+	switch {
+	case device.ModuleName != "":
+		return device.ModuleName
+	case fallback != "":
+		return fallback
+	default:
+		return "id-" + device.ID
+	}
+}
+
+// reachable returns true, if the station could reach the module during its last update. The library does not
+// expose the "reachable" flag of the API, but the API omits the dashboard data of unreachable modules.
+func reachable(device *netatmo.Device) bool {
+	return device.DashboardData.LastMeasure != nil
+}
+
+// staleThreshold returns the age after which the data of the device is stale. Modules which sometimes skip an
+// update can be given a longer threshold based on their type using TypeStaleThresholds.
+func (c *NetatmoCollector) staleThreshold(device *netatmo.Device) time.Duration {
+	if threshold, ok := c.TypeStaleThresholds[device.Type]; ok {
+		return threshold
+	}
+
+	return c.StaleThreshold
+}
+
+func (c *NetatmoCollector) collectData(ch chan<- prometheus.Metric, device *netatmo.Device, moduleName, stationName, role string) {
 	data := device.DashboardData
+	labels := c.moduleLabels(device, moduleName, stationName, role)
+
+	// The connectivity of a module is also interesting when it has no current measurements, so it is emitted
+	// before checking the dashboard data. This is the only exception to not exporting stale data.
+	var measured int64
+	if data.LastMeasure != nil {
+		measured = *data.LastMeasure
+	}
+	// Only the battery percentage is available, the library does not expose the "battery_state" reported by
+	// older modules, so no separate state metric can be exported.
+	if device.BatteryPercent != nil {
+		c.sendSensorMetric(ch, batteryDesc, measured, float64(*device.BatteryPercent), labels...)
+	}
+	if device.WifiStatus != nil {
+		c.sendSensorMetric(ch, wifiDesc, measured, float64(*device.WifiStatus), labels...)
+	}
+	if device.RFStatus != nil {
+		c.sendSensorMetric(ch, rfDesc, measured, float64(*device.RFStatus), labels...)
+	}
+	c.collectSignalTrends(ch, device, labels)
+
+	// The online state is emitted for every module, so that queries do not need absent().
+	staleThreshold := c.staleThreshold(device)
+	online := reachable(device) && c.clock().Sub(time.Unix(*data.LastMeasure, 0)) <= staleThreshold
+	c.sendMetric(ch, moduleOnlineDesc, prometheus.GaugeValue, boolToFloat(online), labels...)
 
 	if data.LastMeasure == nil {
 		c.Log.Debugf("No data available.")
@@ -249,73 +926,166 @@ func (c *NetatmoCollector) collectData(ch chan<- prometheus.Metric, device *neta
 
 	date := time.Unix(*data.LastMeasure, 0)
 	dataAge := c.clock().Sub(date)
-	if dataAge > c.StaleThreshold {
-		c.Log.Debugf("Data is stale for %s: %s > %s", moduleName, dataAge, c.StaleThreshold)
+	if staleThreshold > 0 {
+		c.sendMetric(ch, freshnessDesc, prometheus.GaugeValue, dataAge.Seconds()/staleThreshold.Seconds(), labels...)
+	}
+
+	if dataAge > staleThreshold {
+		c.Log.Debugf("Data is stale for %s: %s > %s", moduleName, dataAge, staleThreshold)
 		return
 	}
 
-	c.sendMetric(ch, updatedDesc, prometheus.GaugeValue, float64(date.UTC().Unix()), moduleName, stationName)
+	c.sendSensorMetric(ch, updatedDesc, *data.LastMeasure, float64(date.UTC().Unix()), labels...)
 
 	if data.Temperature != nil {
-		c.sendMetric(ch, tempDesc, prometheus.GaugeValue, float64(*data.Temperature), moduleName, stationName)
+		c.sendTemperatureMetric(ch, func(m temperatureMetrics) *prometheus.Desc {
+			return m.temperature
+		}, *data.LastMeasure, float64(*data.Temperature), labels...)
 	}
 
+	// The dashboard data does not contain daily extremes for humidity and CO2, so only their current values are available.
 	if data.Humidity != nil {
-		c.sendMetric(ch, humidityDesc, prometheus.GaugeValue, float64(*data.Humidity), moduleName, stationName)
+		c.sendSensorMetric(ch, humidityDesc, *data.LastMeasure, float64(*data.Humidity), labels...)
+	}
+
+	if data.Temperature != nil && data.Humidity != nil {
+		if value, ok := dewPoint(float64(*data.Temperature), float64(*data.Humidity)); ok {
+			c.sendTemperatureMetric(ch, func(m temperatureMetrics) *prometheus.Desc {
+				return m.dewPoint
+			}, *data.LastMeasure, value, labels...)
+		}
+
+		c.sendSensorMetric(ch, absoluteHumidityDesc, *data.LastMeasure, absoluteHumidity(float64(*data.Temperature), float64(*data.Humidity)), labels...)
+		c.sendTemperatureMetric(ch, func(m temperatureMetrics) *prometheus.Desc {
+			return m.heatIndex
+		}, *data.LastMeasure, heatIndex(float64(*data.Temperature), float64(*data.Humidity)), labels...)
 	}
 
 	if data.CO2 != nil {
-		c.sendMetric(ch, cotwoDesc, prometheus.GaugeValue, float64(*data.CO2), moduleName, stationName)
+		c.sendSensorMetric(ch, cotwoDesc, *data.LastMeasure, float64(*data.CO2), labels...)
+
+		// Only modules measuring CO2 are inside, so the comfort level is not calculated for outdoor modules.
+		var temperature, humidity *float64
+		if data.Temperature != nil {
+			value := float64(*data.Temperature)
+			temperature = &value
+		}
+		if data.Humidity != nil {
+			value := float64(*data.Humidity)
+			humidity = &value
+		}
+		level := c.ComfortThresholds.level(float64(*data.CO2), temperature, humidity)
+		c.sendSensorMetric(ch, comfortDesc, *data.LastMeasure, float64(level), labels...)
 	}
 
 	if data.Noise != nil {
-		c.sendMetric(ch, noiseDesc, prometheus.GaugeValue, float64(*data.Noise), moduleName, stationName)
+		c.sendSensorMetric(ch, noiseDesc, *data.LastMeasure, float64(*data.Noise), labels...)
 	}
 
 	if data.Pressure != nil {
-		c.sendMetric(ch, pressureDesc, prometheus.GaugeValue, float64(*data.Pressure), moduleName, stationName)
+		c.sendSensorMetric(ch, pressureDesc, *data.LastMeasure, float64(*data.Pressure), labels...)
+		c.sendSensorMetric(ch, pressureSeaLevelDesc, *data.LastMeasure, float64(*data.Pressure), labels...)
 	}
 
+	wind := windUnits[c.windUnit()]
 	if data.WindStrength != nil {
-		c.sendMetric(ch, windStrengthDesc, prometheus.GaugeValue, float64(*data.WindStrength), moduleName, stationName)
+		c.sendSensorMetric(ch, wind.strength, *data.LastMeasure, float64(*data.WindStrength)*wind.factor, labels...)
+	}
+
+	if data.GustStrength != nil {
+		c.sendSensorMetric(ch, wind.gust, *data.LastMeasure, float64(*data.GustStrength)*wind.factor, labels...)
 	}
 
 	if data.WindAngle != nil {
-		c.sendMetric(ch, windDirectionDesc, prometheus.GaugeValue, float64(*data.WindAngle), moduleName, stationName)
+		c.sendSensorMetric(ch, windDirectionDesc, *data.LastMeasure, float64(*data.WindAngle), labels...)
 	}
 
 	if data.Rain != nil {
-		c.sendMetric(ch, rainDesc, prometheus.GaugeValue, float64(*data.Rain), moduleName, stationName)
+		c.sendSensorMetric(ch, rainDesc, *data.LastMeasure, float64(*data.Rain), labels...)
 	}
 
-	if device.BatteryPercent != nil {
-		c.sendMetric(ch, batteryDesc, prometheus.GaugeValue, float64(*device.BatteryPercent), moduleName, stationName)
+	if data.Rain1Hour != nil {
+		c.sendSensorMetric(ch, rainHourDesc, *data.LastMeasure, float64(*data.Rain1Hour), labels...)
 	}
-	if device.WifiStatus != nil {
-		c.sendMetric(ch, wifiDesc, prometheus.GaugeValue, float64(*device.WifiStatus), moduleName, stationName)
-	}
-	if device.RFStatus != nil {
-		c.sendMetric(ch, rfDesc, prometheus.GaugeValue, float64(*device.RFStatus), moduleName, stationName)
+
+	if data.Rain1Day != nil {
+		c.sendSensorMetric(ch, rainDayDesc, *data.LastMeasure, float64(*data.Rain1Day), labels...)
 	}
 
 	if data.HealthIdx != nil {
-		c.sendMetric(ch, healthIndexDesc, prometheus.GaugeValue, float64(*data.HealthIdx), moduleName, stationName)
+		c.sendSensorMetric(ch, healthIndexDesc, *data.LastMeasure, float64(*data.HealthIdx), labels...)
 	}
 	if data.AbsolutePressure != nil {
-		c.sendMetric(ch, absolutePressureDesc, prometheus.GaugeValue, float64(*data.AbsolutePressure), moduleName, stationName)
+		c.sendSensorMetric(ch, absolutePressureDesc, *data.LastMeasure, float64(*data.AbsolutePressure), labels...)
 	}
 	if data.LastMeasure != nil {
-		c.sendMetric(ch, lastMeasureUtcDesc, prometheus.GaugeValue, float64(*data.LastMeasure), moduleName, stationName)
+		c.sendSensorMetric(ch, lastMeasureUtcDesc, *data.LastMeasure, float64(*data.LastMeasure), labels...)
+	}
+
+	c.collectAverages(ch, device, labels)
+}
+
+// sendSensorMetric sends a gauge created from the data of a measurement, unless its value is outside of the
+// configured bounds. If MetricTimestamps is set, the time of the measurement is used as the timestamp of the
+// sample, except for the connectivity metrics, which are also exported for stale data.
+func (c *NetatmoCollector) sendSensorMetric(ch chan<- prometheus.Metric, desc *prometheus.Desc, measured int64, value float64, labelValues ...string) {
+	if !c.inBounds(desc, measured, value, labelValues) {
+		return
+	}
+
+	valueType := prometheus.GaugeValue
+	if counterDescs[desc] {
+		valueType = prometheus.CounterValue
+	}
+
+	m, ok := c.newMetric(desc, valueType, value, labelValues...)
+	if !ok {
+		return
 	}
+
+	if c.MetricTimestamps && measured > 0 && !connectivityDescs[desc] {
+		m = prometheus.NewMetricWithTimestamp(time.Unix(measured, 0), m)
+	}
+	ch <- m
 }
 
 func (c *NetatmoCollector) sendMetric(ch chan<- prometheus.Metric, desc *prometheus.Desc, valueType prometheus.ValueType, value float64, labelValues ...string) {
-	m, err := prometheus.NewConstMetric(desc, valueType, value, labelValues...)
+	if m, ok := c.newMetric(desc, valueType, value, labelValues...); ok {
+		ch <- m
+	}
+}
+
+// newMetric creates a constant metric, unless it has been disabled. Errors creating the metric are logged.
+func (c *NetatmoCollector) newMetric(desc *prometheus.Desc, valueType prometheus.ValueType, value float64, labelValues ...string) (prometheus.Metric, bool) {
+	if !c.metricEnabled(desc) {
+		return nil, false
+	}
+
+	m, err := prometheus.NewConstMetric(c.desc(desc), valueType, value, labelValues...)
 	if err != nil {
 		c.Log.Errorf("Error creating %s metric: %s", updatedDesc.String(), err)
+		return nil, false
+	}
+
+	return m, true
+}
+
+// sendTimestamp emits the time as a gauge in seconds. A zero time is emitted as zero, unless
+// OmitMissingTimestamps is set, in which case the metric is left out.
+func (c *NetatmoCollector) sendTimestamp(ch chan<- prometheus.Metric, desc *prometheus.Desc, t time.Time, labelValues ...string) {
+	if t.IsZero() && c.OmitMissingTimestamps {
 		return
 	}
-	ch <- m
+
+	c.sendMetric(ch, desc, prometheus.GaugeValue, convertTime(t), labelValues...)
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1.0
+	}
+
+	return 0.0
 }
 
 func convertTime(t time.Time) float64 {
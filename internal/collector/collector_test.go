@@ -1,19 +1,28 @@
 package collector
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"math/rand"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	netatmo "github.com/exzz/netatmo-api-go"
 	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/sirupsen/logrus"
 )
 
 func TestRefreshData(t *testing.T) {
 	testData := &netatmo.DeviceCollection{}
+	testData.Body.Devices = []*netatmo.Device{
+		{ID: "aa:bb:cc:dd:ee:f0"},
+	}
 	testError := errors.New("test error")
 	tt := []struct {
 		desc         string
@@ -50,7 +59,7 @@ func TestRefreshData(t *testing.T) {
 		t.Run(tc.desc, func(t *testing.T) {
 			t.Parallel()
 
-			c := New(logrus.New(), tc.readFunction, 0, 0)
+			c := New(context.Background(), logrus.New(), tc.readFunction, 0, 0)
 			c.RefreshData(tc.time)
 
 			if c.cacheTimestamp != tc.wantTime {
@@ -68,6 +77,76 @@ func TestRefreshData(t *testing.T) {
 	}
 }
 
+func TestRefreshDataEmpty(t *testing.T) {
+	previous := &netatmo.DeviceCollection{}
+	previous.Body.Devices = []*netatmo.Device{
+		{ID: "aa:bb:cc:dd:ee:f0"},
+	}
+	empty := &netatmo.DeviceCollection{}
+
+	tt := []struct {
+		desc               string
+		acceptEmpty        bool
+		wantTime           time.Time
+		wantData           *netatmo.DeviceCollection
+		wantEmptyResponses uint64
+		wantUp             map[string]bool
+	}{
+		{
+			desc:               "keep cache",
+			acceptEmpty:        false,
+			wantTime:           time.Unix(0, 0),
+			wantData:           previous,
+			wantEmptyResponses: 1,
+			wantUp: map[string]bool{
+				"aa:bb:cc:dd:ee:f0": false,
+			},
+		},
+		{
+			desc:               "accept empty",
+			acceptEmpty:        true,
+			wantTime:           time.Unix(1, 0),
+			wantData:           empty,
+			wantEmptyResponses: 0,
+			wantUp:             map[string]bool{},
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			c := New(context.Background(), logrus.New(), func() (*netatmo.DeviceCollection, error) {
+				return previous, nil
+			}, 0, 0)
+			c.AcceptEmptyResponse = tc.acceptEmpty
+			c.RefreshData(time.Unix(0, 0))
+
+			c.ReadFunction = func() (*netatmo.DeviceCollection, error) {
+				return empty, nil
+			}
+			c.RefreshData(time.Unix(1, 0))
+
+			if c.cacheTimestamp != tc.wantTime {
+				t.Errorf("got time %s, want %s", c.cacheTimestamp, tc.wantTime)
+			}
+
+			if diff := cmp.Diff(c.cachedData, tc.wantData); diff != "" {
+				t.Errorf("data differs: -got+want\n%s", diff)
+			}
+
+			if got := c.emptyResponses.Load(); got != tc.wantEmptyResponses {
+				t.Errorf("got %d empty responses, want %d", got, tc.wantEmptyResponses)
+			}
+
+			if diff := cmp.Diff(c.stationUp, tc.wantUp); diff != "" {
+				t.Errorf("station up differs: -got+want\n%s", diff)
+			}
+		})
+	}
+}
+
 func TestRefreshDataResetError(t *testing.T) {
 	testData := &netatmo.DeviceCollection{}
 	testError := errors.New("test error")
@@ -78,7 +157,7 @@ func TestRefreshDataResetError(t *testing.T) {
 		return nil, testError
 	}
 
-	c := New(logrus.New(), successFunc, 0, 0)
+	c := New(context.Background(), logrus.New(), successFunc, 0, 0)
 	c.RefreshData(time.Unix(0, 0))
 
 	if c.lastRefreshError != nil {
@@ -100,6 +179,428 @@ func TestRefreshDataResetError(t *testing.T) {
 	}
 }
 
+func TestRefreshDataConsecutiveFailures(t *testing.T) {
+	testError := errors.New("test error")
+	results := []error{testError, testError, testError, nil, testError}
+	wantFailures := []int{1, 2, 3, 0, 1}
+
+	c := New(context.Background(), logrus.New(), nil, 0, 0)
+	for i, result := range results {
+		result := result
+		c.ReadFunction = func() (*netatmo.DeviceCollection, error) {
+			if result != nil {
+				return nil, result
+			}
+
+			return &netatmo.DeviceCollection{}, nil
+		}
+		c.RefreshData(time.Unix(int64(i), 0))
+
+		if c.consecutiveFailures != wantFailures[i] {
+			t.Errorf("refresh %d: got %d failures, want %d", i, c.consecutiveFailures, wantFailures[i])
+		}
+	}
+}
+
+func TestNetatmoCollector_CollectLastSuccess(t *testing.T) {
+	testError := errors.New("test error")
+	results := []error{testError, nil, testError, testError, nil}
+	wantSuccess := []string{"0", "1", "1", "1", "4"}
+
+	c := New(context.Background(), logrus.New(), nil, time.Hour, time.Hour)
+	c.clock = func() time.Time {
+		return time.Unix(0, 0)
+	}
+	for i, result := range results {
+		result := result
+		// The successful reads return no devices, so the cache is not updated by them.
+		c.ReadFunction = func() (*netatmo.DeviceCollection, error) {
+			if result != nil {
+				return nil, result
+			}
+
+			return &netatmo.DeviceCollection{}, nil
+		}
+		c.RefreshData(time.Unix(int64(i), 0))
+
+		expected := `# HELP netatmo_last_successful_api_call_seconds Contains the time of the last read from the API which completed without an error. Zero if there was none yet.
+# TYPE netatmo_last_successful_api_call_seconds gauge
+netatmo_last_successful_api_call_seconds ` + wantSuccess[i] + "\n"
+		if err := testutil.CollectAndCompare(c, strings.NewReader(expected), "netatmo_last_successful_api_call_seconds"); err != nil {
+			t.Errorf("refresh %d: %s", i, err)
+		}
+	}
+}
+
+func TestNetatmoCollector_CollectActualInterval(t *testing.T) {
+	refreshes := []int64{100, 580, 1200}
+	wantInterval := []string{"0", "480", "620"}
+
+	c := New(context.Background(), logrus.New(), func() (*netatmo.DeviceCollection, error) {
+		return &netatmo.DeviceCollection{}, nil
+	}, time.Hour, time.Hour)
+	c.clock = func() time.Time {
+		return time.Unix(0, 0)
+	}
+	for i, refresh := range refreshes {
+		c.RefreshData(time.Unix(refresh, 0))
+
+		expected := `# HELP netatmo_refresh_interval_actual_seconds Contains the time between the last two refresh tries in seconds. Zero until the second refresh.
+# TYPE netatmo_refresh_interval_actual_seconds gauge
+netatmo_refresh_interval_actual_seconds ` + wantInterval[i] + "\n"
+		if err := testutil.CollectAndCompare(c, strings.NewReader(expected), "netatmo_refresh_interval_actual_seconds"); err != nil {
+			t.Errorf("refresh %d: %s", i, err)
+		}
+	}
+}
+
+func TestNetatmoCollector_CollectMissingTimestamps(t *testing.T) {
+	tt := []struct {
+		desc string
+		omit bool
+		want string
+	}{
+		{
+			desc: "zero",
+			omit: false,
+			want: `# HELP netatmo_cache_updated_time Contains the time of the cached data.
+# TYPE netatmo_cache_updated_time gauge
+netatmo_cache_updated_time 0
+# HELP netatmo_last_refresh_time Contains the time of the last refresh try, successful or not.
+# TYPE netatmo_last_refresh_time gauge
+netatmo_last_refresh_time 0
+# HELP netatmo_last_successful_api_call_seconds Contains the time of the last read from the API which completed without an error. Zero if there was none yet.
+# TYPE netatmo_last_successful_api_call_seconds gauge
+netatmo_last_successful_api_call_seconds 0
+`,
+		},
+		{
+			desc: "omitted",
+			omit: true,
+			want: "",
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			// The cancelled context prevents the scrape from triggering a refresh.
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			c := New(ctx, logrus.New(), nil, time.Hour, time.Hour)
+			c.OmitMissingTimestamps = tc.omit
+
+			if err := testutil.CollectAndCompare(c, strings.NewReader(tc.want), "netatmo_cache_updated_time", "netatmo_last_refresh_time", "netatmo_last_successful_api_call_seconds"); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestForceRefresh(t *testing.T) {
+	testErr := errors.New("test error")
+	var readErr error
+	reads := 0
+	c := New(context.Background(), logrus.New(), func() (*netatmo.DeviceCollection, error) {
+		reads++
+		return &netatmo.DeviceCollection{}, readErr
+	}, time.Hour, time.Hour)
+	c.clock = func() time.Time {
+		return time.Unix(3600, 0)
+	}
+	c.RefreshData(time.Unix(3600, 0))
+
+	if err := c.ForceRefresh(); err != nil {
+		t.Errorf("got error %q, want none", err)
+	}
+
+	readErr = testErr
+	if err := c.ForceRefresh(); !errors.Is(err, testErr) {
+		t.Errorf("got error %q, want %q", err, testErr)
+	}
+
+	if reads != 3 {
+		t.Errorf("got %d reads, want %d", reads, 3)
+	}
+
+	c.refreshing.Store(true)
+	if err := c.ForceRefresh(); !errors.Is(err, ErrRefreshInProgress) {
+		t.Errorf("got error %q, want %q", err, ErrRefreshInProgress)
+	}
+
+	if reads != 3 {
+		t.Errorf("got %d reads after refresh in progress, want %d", reads, 3)
+	}
+}
+
+func TestNetatmoCollector_Snapshot(t *testing.T) {
+	testDevices := &netatmo.DeviceCollection{}
+	testDevices.Body.Devices = []*netatmo.Device{
+		{
+			ID:          "aa:bb:cc:dd:ee:f0",
+			ModuleName:  "Living Room",
+			StationName: "Home",
+			Type:        "NAMain",
+			DashboardData: netatmo.DashboardData{
+				Temperature: float32Ptr(23),
+				LastMeasure: int64Ptr(3600),
+			},
+		},
+	}
+	c := New(context.Background(), logrus.New(), func() (*netatmo.DeviceCollection, error) {
+		return testDevices, nil
+	}, time.Hour, time.Hour)
+	c.clock = func() time.Time {
+		return time.Unix(3600, 0)
+	}
+	c.RefreshData(time.Unix(3600, 0))
+
+	families, err := c.Snapshot()
+	if err != nil {
+		t.Fatalf("got error %q, want none", err)
+	}
+
+	byName := make(map[string]*dto.MetricFamily, len(families))
+	for i, family := range families {
+		byName[family.GetName()] = family
+		if i > 0 && families[i-1].GetName() >= family.GetName() {
+			t.Errorf("families not sorted: %q before %q", families[i-1].GetName(), family.GetName())
+		}
+	}
+
+	for name, want := range map[string]float64{
+		"netatmo_up":                          1,
+		"netatmo_station_up":                  1,
+		"netatmo_aircare_temperature_celsius": 23,
+	} {
+		family, ok := byName[name]
+		if !ok {
+			t.Errorf("missing family %q", name)
+			continue
+		}
+
+		if len(family.GetMetric()) != 1 {
+			t.Errorf("got %d metrics for %q, want 1", len(family.GetMetric()), name)
+			continue
+		}
+
+		if got := family.GetMetric()[0].GetGauge().GetValue(); got != want {
+			t.Errorf("got value %v for %q, want %v", got, name, want)
+		}
+	}
+
+	wantLabels := map[string]string{
+		"module":      "Living Room",
+		"module_type": "NAMain",
+		"role":        "station",
+		"station":     "Home",
+	}
+	gotLabels := make(map[string]string)
+	for _, label := range byName["netatmo_aircare_temperature_celsius"].GetMetric()[0].GetLabel() {
+		gotLabels[label.GetName()] = label.GetValue()
+	}
+	if diff := cmp.Diff(wantLabels, gotLabels); diff != "" {
+		t.Errorf("labels differ: %s", diff)
+	}
+}
+
+func TestWaitForRefresh(t *testing.T) {
+	c := New(context.Background(), logrus.New(), nil, time.Hour, time.Hour)
+	if !c.WaitForRefresh(time.Second) {
+		t.Error("got timeout without running refresh")
+	}
+
+	c.refreshing.Store(true)
+	if c.WaitForRefresh(20 * time.Millisecond) {
+		t.Error("got no timeout with running refresh")
+	}
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		c.refreshing.Store(false)
+	}()
+	if !c.WaitForRefresh(time.Second) {
+		t.Error("got timeout for completed refresh")
+	}
+}
+
+func TestRefreshNow(t *testing.T) {
+	reads := 0
+	c := New(context.Background(), logrus.New(), func() (*netatmo.DeviceCollection, error) {
+		reads++
+		return &netatmo.DeviceCollection{}, nil
+	}, time.Hour, time.Hour)
+
+	c.RefreshNow()
+	if reads != 1 {
+		t.Errorf("got %d reads, want %d", reads, 1)
+	}
+	if c.refreshing.Load() {
+		t.Error("got refresh in progress after refresh")
+	}
+
+	c.refreshing.Store(true)
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		c.refreshing.Store(false)
+	}()
+	c.RefreshNow()
+	if reads != 1 {
+		t.Errorf("got %d reads with refresh in progress, want %d", reads, 1)
+	}
+	if c.refreshing.Load() {
+		t.Error("got return before running refresh completed")
+	}
+}
+
+func TestRefreshDataPanic(t *testing.T) {
+	c := New(context.Background(), logrus.New(), func() (*netatmo.DeviceCollection, error) {
+		var devices map[string]*netatmo.Device
+		devices["test"] = &netatmo.Device{}
+		return &netatmo.DeviceCollection{}, nil
+	}, time.Hour, time.Hour)
+	c.RefreshData(time.Unix(0, 0))
+
+	if c.lastRefreshError == nil {
+		t.Fatal("got no error, want one")
+	}
+
+	wantError := "read function panicked: assignment to entry in nil map"
+	if c.lastRefreshError.Error() != wantError {
+		t.Errorf("got error %q, want %q", c.lastRefreshError, wantError)
+	}
+
+	if c.Up() {
+		t.Error("got up, want down")
+	}
+}
+
+func TestRefreshDataCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	block := make(chan struct{})
+	defer close(block)
+
+	c := New(ctx, logrus.New(), func() (*netatmo.DeviceCollection, error) {
+		cancel()
+		<-block
+		return &netatmo.DeviceCollection{}, nil
+	}, 0, 0)
+	c.RefreshData(time.Unix(0, 0))
+
+	if c.lastRefreshError != nil {
+		t.Errorf("got error %q, want none", c.lastRefreshError)
+	}
+
+	if c.cachedData != nil {
+		t.Errorf("got data %v, want none", c.cachedData)
+	}
+}
+
+func TestRefreshDataPartial(t *testing.T) {
+	createCollection := func(devices ...*netatmo.Device) *netatmo.DeviceCollection {
+		dc := &netatmo.DeviceCollection{}
+		dc.Body.Devices = devices
+		return dc
+	}
+	oldFirst := &netatmo.Device{ID: "aa:bb:cc:dd:ee:f0", StationName: "First"}
+	oldSecond := &netatmo.Device{ID: "aa:bb:cc:dd:ee:e0", StationName: "Second"}
+	newFirst := &netatmo.Device{ID: "aa:bb:cc:dd:ee:f0", StationName: "First (new)"}
+	testError := errors.New("test error")
+
+	c := New(context.Background(), logrus.New(), func() (*netatmo.DeviceCollection, error) {
+		return createCollection(oldFirst, oldSecond), nil
+	}, 0, 0)
+	c.RefreshData(time.Unix(0, 0))
+
+	c.ReadFunction = func() (*netatmo.DeviceCollection, error) {
+		return createCollection(newFirst), testError
+	}
+	c.RefreshData(time.Unix(1, 0))
+
+	if c.lastRefreshError != testError {
+		t.Errorf("got error %q, want %q", c.lastRefreshError, testError)
+	}
+
+	wantTime := time.Unix(1, 0)
+	if c.cacheTimestamp != wantTime {
+		t.Errorf("got time %s, want %s", c.cacheTimestamp, wantTime)
+	}
+
+	wantData := createCollection(newFirst, oldSecond)
+	if diff := cmp.Diff(c.cachedData, wantData); diff != "" {
+		t.Errorf("data differs: -got+want\n%s", diff)
+	}
+
+	wantUp := map[string]bool{
+		"aa:bb:cc:dd:ee:f0": true,
+	}
+	if diff := cmp.Diff(c.stationUp, wantUp); diff != "" {
+		t.Errorf("station up differs: -got+want\n%s", diff)
+	}
+}
+
+func TestMissingStations(t *testing.T) {
+	createCollection := func(devices ...*netatmo.Device) *netatmo.DeviceCollection {
+		dc := &netatmo.DeviceCollection{}
+		dc.Body.Devices = devices
+		return dc
+	}
+	first := &netatmo.Device{ID: "aa:bb:cc:dd:ee:f0"}
+	second := &netatmo.Device{ID: "aa:bb:cc:dd:ee:e0"}
+
+	tt := []struct {
+		desc        string
+		previous    *netatmo.DeviceCollection
+		update      *netatmo.DeviceCollection
+		wantMissing []string
+	}{
+		{
+			desc:        "no previous data",
+			previous:    nil,
+			update:      createCollection(first),
+			wantMissing: nil,
+		},
+		{
+			desc:        "complete",
+			previous:    createCollection(first, second),
+			update:      createCollection(second, first),
+			wantMissing: nil,
+		},
+		{
+			desc:        "new station",
+			previous:    createCollection(first),
+			update:      createCollection(first, second),
+			wantMissing: nil,
+		},
+		{
+			desc:        "truncated",
+			previous:    createCollection(first, second),
+			update:      createCollection(first),
+			wantMissing: []string{"aa:bb:cc:dd:ee:e0"},
+		},
+		{
+			desc:        "empty update",
+			previous:    createCollection(first, second),
+			update:      nil,
+			wantMissing: []string{"aa:bb:cc:dd:ee:f0", "aa:bb:cc:dd:ee:e0"},
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			missing := missingStations(tc.previous, tc.update)
+			if diff := cmp.Diff(missing, tc.wantMissing); diff != "" {
+				t.Errorf("missing stations differ: -got+want\n%s", diff)
+			}
+		})
+	}
+}
+
 func TestNetatmoCollector_Collect(t *testing.T) {
 	testDevices := &netatmo.DeviceCollection{}
 	testDevices.Body.Devices = []*netatmo.Device{
@@ -170,81 +671,235 @@ func TestNetatmoCollector_Collect(t *testing.T) {
 		{
 			desc: "success, no data",
 			data: &netatmo.DeviceCollection{},
-			wantMetrics: `# HELP netatmo_cache_updated_time Contains the time of the cached data.
-		# TYPE netatmo_cache_updated_time gauge
-		netatmo_cache_updated_time 3600
-		# HELP netatmo_last_refresh_duration_seconds Contains the time it took for the last refresh to complete, even if it was unsuccessful.
-		# TYPE netatmo_last_refresh_duration_seconds gauge
-		netatmo_last_refresh_duration_seconds 0
-		# HELP netatmo_last_refresh_time Contains the time of the last refresh try, successful or not.
-		# TYPE netatmo_last_refresh_time gauge
-		netatmo_last_refresh_time 3600
-		# HELP netatmo_refresh_interval_seconds Contains the configured refresh interval in seconds. This is provided as a convenience for calculations with the cache update time.
-		# TYPE netatmo_refresh_interval_seconds gauge
-		netatmo_refresh_interval_seconds 3600
-		# HELP netatmo_up Zero if there was an error during the last refresh try.
-		# TYPE netatmo_up gauge
-		netatmo_up 1
-		`,
+			wantMetrics: `# HELP netatmo_cache_served_total Counts the scrapes which were served from the cache without triggering a refresh.
+# TYPE netatmo_cache_served_total counter
+netatmo_cache_served_total 1
+# HELP netatmo_cache_updated_time Contains the time of the cached data.
+# TYPE netatmo_cache_updated_time gauge
+netatmo_cache_updated_time 0
+# HELP netatmo_consecutive_refresh_failures Contains the number of refresh tries which failed in a row. Reset to zero by a successful refresh.
+# TYPE netatmo_consecutive_refresh_failures gauge
+netatmo_consecutive_refresh_failures 0
+# HELP netatmo_duplicate_modules_total Counts the entries of stations and modules which were dropped, because the API returned their ID more than once.
+# TYPE netatmo_duplicate_modules_total counter
+netatmo_duplicate_modules_total 0
+# HELP netatmo_empty_response_total Counts the refreshes which returned no devices and were ignored to keep the cached data.
+# TYPE netatmo_empty_response_total counter
+netatmo_empty_response_total 1
+# HELP netatmo_last_refresh_duration_seconds Contains the time it took for the last refresh to complete, even if it was unsuccessful.
+# TYPE netatmo_last_refresh_duration_seconds gauge
+netatmo_last_refresh_duration_seconds 0
+# HELP netatmo_last_refresh_time Contains the time of the last refresh try, successful or not.
+# TYPE netatmo_last_refresh_time gauge
+netatmo_last_refresh_time 3600
+# HELP netatmo_last_successful_api_call_seconds Contains the time of the last read from the API which completed without an error. Zero if there was none yet.
+# TYPE netatmo_last_successful_api_call_seconds gauge
+netatmo_last_successful_api_call_seconds 3600
+# HELP netatmo_refresh_duration_seconds Histogram of the time it took for refreshes to complete, even if they were unsuccessful.
+# TYPE netatmo_refresh_duration_seconds histogram
+netatmo_refresh_duration_seconds_bucket{le="0.005"} 1
+netatmo_refresh_duration_seconds_bucket{le="0.01"} 1
+netatmo_refresh_duration_seconds_bucket{le="0.025"} 1
+netatmo_refresh_duration_seconds_bucket{le="0.05"} 1
+netatmo_refresh_duration_seconds_bucket{le="0.1"} 1
+netatmo_refresh_duration_seconds_bucket{le="0.25"} 1
+netatmo_refresh_duration_seconds_bucket{le="0.5"} 1
+netatmo_refresh_duration_seconds_bucket{le="1"} 1
+netatmo_refresh_duration_seconds_bucket{le="2.5"} 1
+netatmo_refresh_duration_seconds_bucket{le="5"} 1
+netatmo_refresh_duration_seconds_bucket{le="10"} 1
+netatmo_refresh_duration_seconds_bucket{le="+Inf"} 1
+netatmo_refresh_duration_seconds_sum 0
+netatmo_refresh_duration_seconds_count 1
+# HELP netatmo_refresh_in_progress One while a refresh of the data is in progress, zero otherwise.
+# TYPE netatmo_refresh_in_progress gauge
+netatmo_refresh_in_progress 0
+# HELP netatmo_refresh_interval_actual_seconds Contains the time between the last two refresh tries in seconds. Zero until the second refresh.
+# TYPE netatmo_refresh_interval_actual_seconds gauge
+netatmo_refresh_interval_actual_seconds 0
+# HELP netatmo_refresh_interval_seconds Contains the configured refresh interval in seconds. This is provided as a convenience for calculations with the cache update time.
+# TYPE netatmo_refresh_interval_seconds gauge
+netatmo_refresh_interval_seconds 3600
+# HELP netatmo_refresh_triggered_total Counts the scrapes which triggered a refresh, because the refresh interval had elapsed.
+# TYPE netatmo_refresh_triggered_total counter
+netatmo_refresh_triggered_total 0
+# HELP netatmo_up Zero if there was an error during the last refresh try or the cached data is older than the maximum cache age.
+# TYPE netatmo_up gauge
+netatmo_up 1
+`,
 		},
 		{
 			desc: "success",
 			data: testDevices,
-			wantMetrics: `# HELP netatmo_cache_updated_time Contains the time of the cached data.
+			wantMetrics: `# HELP netatmo_aircare_absolute_humidity_grams_per_cubic_meter Absolute humidity in grams per cubic meter calculated from temperature and humidity
+# TYPE netatmo_aircare_absolute_humidity_grams_per_cubic_meter gauge
+netatmo_aircare_absolute_humidity_grams_per_cubic_meter{module="Bedroom",module_type="NAModule4",role="module",station="Home (Living Room)"} 7.509534447793945
+netatmo_aircare_absolute_humidity_grams_per_cubic_meter{module="Living Room",module_type="NAMain",role="station",station="Home (Living Room)"} 9.229691159374614
+netatmo_aircare_absolute_humidity_grams_per_cubic_meter{module="Outside",module_type="NAModule1",role="module",station="Home (Living Room)"} 5.638017761487498
+netatmo_aircare_absolute_humidity_grams_per_cubic_meter{module="id-aa:bb:cc:dd:ee:f3",module_type="NAModule4",role="module",station="Home (Living Room)"} 15.38281859895769
+# HELP netatmo_aircare_absolute_pressure Absolute pressure measurement in millibar at the altitude of the station
+# TYPE netatmo_aircare_absolute_pressure gauge
+netatmo_aircare_absolute_pressure{module="Living Room",module_type="NAMain",role="station",station="Home (Living Room)"} 987
+# HELP netatmo_aircare_battery_percent Battery remaining life (10: low)
+# TYPE netatmo_aircare_battery_percent gauge
+netatmo_aircare_battery_percent{module="Bedroom",module_type="NAModule4",role="module",station="Home (Living Room)"} 55
+netatmo_aircare_battery_percent{module="Outside",module_type="NAModule1",role="module",station="Home (Living Room)"} 70
+netatmo_aircare_battery_percent{module="id-aa:bb:cc:dd:ee:f3",module_type="NAModule4",role="module",station="Home (Living Room)"} 60
+# HELP netatmo_aircare_co2_ppm Carbondioxide measurement in parts per million
+# TYPE netatmo_aircare_co2_ppm gauge
+netatmo_aircare_co2_ppm{module="Bedroom",module_type="NAModule4",role="module",station="Home (Living Room)"} 510
+netatmo_aircare_co2_ppm{module="Living Room",module_type="NAMain",role="station",station="Home (Living Room)"} 650
+netatmo_aircare_co2_ppm{module="id-aa:bb:cc:dd:ee:f3",module_type="NAModule4",role="module",station="Home (Living Room)"} 750
+# HELP netatmo_aircare_comfort_level Comfort level calculated from CO2, temperature and humidity: 0 = Good, 1 = Fair, 2 = Poor, 3 = Bad
+# TYPE netatmo_aircare_comfort_level gauge
+netatmo_aircare_comfort_level{module="Bedroom",module_type="NAModule4",role="module",station="Home (Living Room)"} 1
+netatmo_aircare_comfort_level{module="Living Room",module_type="NAMain",role="station",station="Home (Living Room)"} 0
+netatmo_aircare_comfort_level{module="id-aa:bb:cc:dd:ee:f3",module_type="NAModule4",role="module",station="Home (Living Room)"} 2
+# HELP netatmo_aircare_dew_point_celsius Dew point in celsius calculated from temperature and humidity
+# TYPE netatmo_aircare_dew_point_celsius gauge
+netatmo_aircare_dew_point_celsius{module="Bedroom",module_type="NAModule4",role="module",station="Home (Living Room)"} 7.065671799081191
+netatmo_aircare_dew_point_celsius{module="Living Room",module_type="NAMain",role="station",station="Home (Living Room)"} 10.42287325274187
+netatmo_aircare_dew_point_celsius{module="Outside",module_type="NAModule1",role="module",station="Home (Living Room)"} 2.3507874849305193
+netatmo_aircare_dew_point_celsius{module="id-aa:bb:cc:dd:ee:f3",module_type="NAModule4",role="module",station="Home (Living Room)"} 18.327511566877888
+# HELP netatmo_aircare_heat_index_celsius Heat index ("feels like" temperature) in celsius, same as temperature below 27°C
+# TYPE netatmo_aircare_heat_index_celsius gauge
+netatmo_aircare_heat_index_celsius{module="Bedroom",module_type="NAModule4",role="module",station="Home (Living Room)"} 17
+netatmo_aircare_heat_index_celsius{module="Living Room",module_type="NAMain",role="station",station="Home (Living Room)"} 23
+netatmo_aircare_heat_index_celsius{module="Outside",module_type="NAModule1",role="module",station="Home (Living Room)"} 5
+netatmo_aircare_heat_index_celsius{module="id-aa:bb:cc:dd:ee:f3",module_type="NAModule4",role="module",station="Home (Living Room)"} 23
+# HELP netatmo_aircare_humidity_percent Relative humidity measurement in percent
+# TYPE netatmo_aircare_humidity_percent gauge
+netatmo_aircare_humidity_percent{module="Bedroom",module_type="NAModule4",role="module",station="Home (Living Room)"} 52
+netatmo_aircare_humidity_percent{module="Living Room",module_type="NAMain",role="station",station="Home (Living Room)"} 45
+netatmo_aircare_humidity_percent{module="Outside",module_type="NAModule1",role="module",station="Home (Living Room)"} 83
+netatmo_aircare_humidity_percent{module="id-aa:bb:cc:dd:ee:f3",module_type="NAModule4",role="module",station="Home (Living Room)"} 75
+# HELP netatmo_aircare_last_measure_utc Measurement time UTC
+# TYPE netatmo_aircare_last_measure_utc gauge
+netatmo_aircare_last_measure_utc{module="Bedroom",module_type="NAModule4",role="module",station="Home (Living Room)"} 3502
+netatmo_aircare_last_measure_utc{module="Living Room",module_type="NAMain",role="station",station="Home (Living Room)"} 3500
+netatmo_aircare_last_measure_utc{module="Outside",module_type="NAModule1",role="module",station="Home (Living Room)"} 3501
+netatmo_aircare_last_measure_utc{module="id-aa:bb:cc:dd:ee:f3",module_type="NAModule4",role="module",station="Home (Living Room)"} 3503
+# HELP netatmo_aircare_noise_db Noise measurement in decibels
+# TYPE netatmo_aircare_noise_db gauge
+netatmo_aircare_noise_db{module="Living Room",module_type="NAMain",role="station",station="Home (Living Room)"} 40
+# HELP netatmo_aircare_pressure_mb Atmospheric pressure measurement in millibar, corrected to sea level
+# TYPE netatmo_aircare_pressure_mb gauge
+netatmo_aircare_pressure_mb{module="Living Room",module_type="NAMain",role="station",station="Home (Living Room)"} 1234
+# HELP netatmo_aircare_pressure_sea_level_mb Atmospheric pressure measurement in millibar, corrected to sea level
+# TYPE netatmo_aircare_pressure_sea_level_mb gauge
+netatmo_aircare_pressure_sea_level_mb{module="Living Room",module_type="NAMain",role="station",station="Home (Living Room)"} 1234
+# HELP netatmo_aircare_rf_signal_strength RF signal strength (90: lowest, 60: highest)
+# TYPE netatmo_aircare_rf_signal_strength gauge
+netatmo_aircare_rf_signal_strength{module="Bedroom",module_type="NAModule4",role="module",station="Home (Living Room)"} 80
+netatmo_aircare_rf_signal_strength{module="Outside",module_type="NAModule1",role="module",station="Home (Living Room)"} 57
+netatmo_aircare_rf_signal_strength{module="id-aa:bb:cc:dd:ee:f3",module_type="NAModule4",role="module",station="Home (Living Room)"} 70
+# HELP netatmo_aircare_temperature_celsius Temperature measurement in celsius
+# TYPE netatmo_aircare_temperature_celsius gauge
+netatmo_aircare_temperature_celsius{module="Bedroom",module_type="NAModule4",role="module",station="Home (Living Room)"} 17
+netatmo_aircare_temperature_celsius{module="Living Room",module_type="NAMain",role="station",station="Home (Living Room)"} 23
+netatmo_aircare_temperature_celsius{module="Outside",module_type="NAModule1",role="module",station="Home (Living Room)"} 5
+netatmo_aircare_temperature_celsius{module="id-aa:bb:cc:dd:ee:f3",module_type="NAModule4",role="module",station="Home (Living Room)"} 23
+# HELP netatmo_aircare_updated Timestamp of last update
+# TYPE netatmo_aircare_updated gauge
+netatmo_aircare_updated{module="Bedroom",module_type="NAModule4",role="module",station="Home (Living Room)"} 3502
+netatmo_aircare_updated{module="Living Room",module_type="NAMain",role="station",station="Home (Living Room)"} 3500
+netatmo_aircare_updated{module="Outside",module_type="NAModule1",role="module",station="Home (Living Room)"} 3501
+netatmo_aircare_updated{module="id-aa:bb:cc:dd:ee:f3",module_type="NAModule4",role="module",station="Home (Living Room)"} 3503
+# HELP netatmo_aircare_wifi_signal_strength Wifi signal strength (86: bad, 71: avg, 56: good)
+# TYPE netatmo_aircare_wifi_signal_strength gauge
+netatmo_aircare_wifi_signal_strength{module="Living Room",module_type="NAMain",role="station",station="Home (Living Room)"} 45
+# HELP netatmo_cache_served_total Counts the scrapes which were served from the cache without triggering a refresh.
+# TYPE netatmo_cache_served_total counter
+netatmo_cache_served_total 1
+# HELP netatmo_cache_staleness_seconds Contains the age of the cached data at the time of the scrape. Not present if there is no cached data.
+# TYPE netatmo_cache_staleness_seconds gauge
+netatmo_cache_staleness_seconds 0
+# HELP netatmo_cache_updated_time Contains the time of the cached data.
 # TYPE netatmo_cache_updated_time gauge
 netatmo_cache_updated_time 3600
+# HELP netatmo_consecutive_refresh_failures Contains the number of refresh tries which failed in a row. Reset to zero by a successful refresh.
+# TYPE netatmo_consecutive_refresh_failures gauge
+netatmo_consecutive_refresh_failures 0
+# HELP netatmo_data_freshness_ratio Age of the module data relative to the stale threshold. Zero is fresh, one or more means that the data is stale.
+# TYPE netatmo_data_freshness_ratio gauge
+netatmo_data_freshness_ratio{module="Bedroom",module_type="NAModule4",role="module",station="Home (Living Room)"} 0.02722222222222222
+netatmo_data_freshness_ratio{module="Living Room",module_type="NAMain",role="station",station="Home (Living Room)"} 0.027777777777777776
+netatmo_data_freshness_ratio{module="Outside",module_type="NAModule1",role="module",station="Home (Living Room)"} 0.0275
+netatmo_data_freshness_ratio{module="id-aa:bb:cc:dd:ee:f3",module_type="NAModule4",role="module",station="Home (Living Room)"} 0.026944444444444444
+# HELP netatmo_duplicate_modules_total Counts the entries of stations and modules which were dropped, because the API returned their ID more than once.
+# TYPE netatmo_duplicate_modules_total counter
+netatmo_duplicate_modules_total 0
+# HELP netatmo_empty_response_total Counts the refreshes which returned no devices and were ignored to keep the cached data.
+# TYPE netatmo_empty_response_total counter
+netatmo_empty_response_total 0
 # HELP netatmo_last_refresh_duration_seconds Contains the time it took for the last refresh to complete, even if it was unsuccessful.
 # TYPE netatmo_last_refresh_duration_seconds gauge
 netatmo_last_refresh_duration_seconds 0
 # HELP netatmo_last_refresh_time Contains the time of the last refresh try, successful or not.
 # TYPE netatmo_last_refresh_time gauge
 netatmo_last_refresh_time 3600
+# HELP netatmo_last_successful_api_call_seconds Contains the time of the last read from the API which completed without an error. Zero if there was none yet.
+# TYPE netatmo_last_successful_api_call_seconds gauge
+netatmo_last_successful_api_call_seconds 3600
+# HELP netatmo_module_last_seen_seconds Contains the time of the last refresh which included the module. Still present for the grace period after the module disappeared from the API.
+# TYPE netatmo_module_last_seen_seconds gauge
+netatmo_module_last_seen_seconds{module="Bedroom",module_type="NAModule4",role="module",station="Home (Living Room)"} 3600
+netatmo_module_last_seen_seconds{module="Living Room",module_type="NAMain",role="station",station="Home (Living Room)"} 3600
+netatmo_module_last_seen_seconds{module="Outside",module_type="NAModule1",role="module",station="Home (Living Room)"} 3600
+netatmo_module_last_seen_seconds{module="id-aa:bb:cc:dd:ee:f3",module_type="NAModule4",role="module",station="Home (Living Room)"} 3600
+# HELP netatmo_module_online One if the module is reachable and has data newer than the stale threshold, zero otherwise.
+# TYPE netatmo_module_online gauge
+netatmo_module_online{module="Bedroom",module_type="NAModule4",role="module",station="Home (Living Room)"} 1
+netatmo_module_online{module="Living Room",module_type="NAMain",role="station",station="Home (Living Room)"} 1
+netatmo_module_online{module="Outside",module_type="NAModule1",role="module",station="Home (Living Room)"} 1
+netatmo_module_online{module="id-aa:bb:cc:dd:ee:f3",module_type="NAModule4",role="module",station="Home (Living Room)"} 1
+# HELP netatmo_refresh_duration_seconds Histogram of the time it took for refreshes to complete, even if they were unsuccessful.
+# TYPE netatmo_refresh_duration_seconds histogram
+netatmo_refresh_duration_seconds_bucket{le="0.005"} 1
+netatmo_refresh_duration_seconds_bucket{le="0.01"} 1
+netatmo_refresh_duration_seconds_bucket{le="0.025"} 1
+netatmo_refresh_duration_seconds_bucket{le="0.05"} 1
+netatmo_refresh_duration_seconds_bucket{le="0.1"} 1
+netatmo_refresh_duration_seconds_bucket{le="0.25"} 1
+netatmo_refresh_duration_seconds_bucket{le="0.5"} 1
+netatmo_refresh_duration_seconds_bucket{le="1"} 1
+netatmo_refresh_duration_seconds_bucket{le="2.5"} 1
+netatmo_refresh_duration_seconds_bucket{le="5"} 1
+netatmo_refresh_duration_seconds_bucket{le="10"} 1
+netatmo_refresh_duration_seconds_bucket{le="+Inf"} 1
+netatmo_refresh_duration_seconds_sum 0
+netatmo_refresh_duration_seconds_count 1
+# HELP netatmo_refresh_in_progress One while a refresh of the data is in progress, zero otherwise.
+# TYPE netatmo_refresh_in_progress gauge
+netatmo_refresh_in_progress 0
+# HELP netatmo_refresh_interval_actual_seconds Contains the time between the last two refresh tries in seconds. Zero until the second refresh.
+# TYPE netatmo_refresh_interval_actual_seconds gauge
+netatmo_refresh_interval_actual_seconds 0
 # HELP netatmo_refresh_interval_seconds Contains the configured refresh interval in seconds. This is provided as a convenience for calculations with the cache update time.
 # TYPE netatmo_refresh_interval_seconds gauge
 netatmo_refresh_interval_seconds 3600
-# HELP netatmo_sensor_battery_percent Battery remaining life (10: low)
-# TYPE netatmo_sensor_battery_percent gauge
-netatmo_sensor_battery_percent{home="Home",module="Bedroom",station="Home (Living Room)"} 55
-netatmo_sensor_battery_percent{home="Home",module="Outside",station="Home (Living Room)"} 70
-netatmo_sensor_battery_percent{home="Home",module="id-aa:bb:cc:dd:ee:f3",station="Home (Living Room)"} 60
-# HELP netatmo_sensor_co2_ppm Carbondioxide measurement in parts per million
-# TYPE netatmo_sensor_co2_ppm gauge
-netatmo_sensor_co2_ppm{home="Home",module="Bedroom",station="Home (Living Room)"} 510
-netatmo_sensor_co2_ppm{home="Home",module="Living Room",station="Home (Living Room)"} 650
-netatmo_sensor_co2_ppm{home="Home",module="id-aa:bb:cc:dd:ee:f3",station="Home (Living Room)"} 750
-# HELP netatmo_sensor_humidity_percent Relative humidity measurement in percent
-# TYPE netatmo_sensor_humidity_percent gauge
-netatmo_sensor_humidity_percent{home="Home",module="Bedroom",station="Home (Living Room)"} 52
-netatmo_sensor_humidity_percent{home="Home",module="Living Room",station="Home (Living Room)"} 45
-netatmo_sensor_humidity_percent{home="Home",module="Outside",station="Home (Living Room)"} 83
-netatmo_sensor_humidity_percent{home="Home",module="id-aa:bb:cc:dd:ee:f3",station="Home (Living Room)"} 75
-# HELP netatmo_sensor_noise_db Noise measurement in decibels
-# TYPE netatmo_sensor_noise_db gauge
-netatmo_sensor_noise_db{home="Home",module="Living Room",station="Home (Living Room)"} 40
-# HELP netatmo_sensor_pressure_mb Atmospheric pressure measurement in millibar
-# TYPE netatmo_sensor_pressure_mb gauge
-netatmo_sensor_pressure_mb{home="Home",module="Living Room",station="Home (Living Room)"} 1234
-# HELP netatmo_sensor_rf_signal_strength RF signal strength (90: lowest, 60: highest)
-# TYPE netatmo_sensor_rf_signal_strength gauge
-netatmo_sensor_rf_signal_strength{home="Home",module="Bedroom",station="Home (Living Room)"} 80
-netatmo_sensor_rf_signal_strength{home="Home",module="Outside",station="Home (Living Room)"} 57
-netatmo_sensor_rf_signal_strength{home="Home",module="id-aa:bb:cc:dd:ee:f3",station="Home (Living Room)"} 70
-# HELP netatmo_sensor_temperature_celsius Temperature measurement in celsius
-# TYPE netatmo_sensor_temperature_celsius gauge
-netatmo_sensor_temperature_celsius{home="Home",module="Bedroom",station="Home (Living Room)"} 17
-netatmo_sensor_temperature_celsius{home="Home",module="Living Room",station="Home (Living Room)"} 23
-netatmo_sensor_temperature_celsius{home="Home",module="Outside",station="Home (Living Room)"} 5
-netatmo_sensor_temperature_celsius{home="Home",module="id-aa:bb:cc:dd:ee:f3",station="Home (Living Room)"} 23
-# HELP netatmo_sensor_updated Timestamp of last update
-# TYPE netatmo_sensor_updated gauge
-netatmo_sensor_updated{home="Home",module="Bedroom",station="Home (Living Room)"} 3502
-netatmo_sensor_updated{home="Home",module="Living Room",station="Home (Living Room)"} 3500
-netatmo_sensor_updated{home="Home",module="Outside",station="Home (Living Room)"} 3501
-netatmo_sensor_updated{home="Home",module="id-aa:bb:cc:dd:ee:f3",station="Home (Living Room)"} 3503
-# HELP netatmo_sensor_wifi_signal_strength Wifi signal strength (86: bad, 71: avg, 56: good)
-# TYPE netatmo_sensor_wifi_signal_strength gauge
-netatmo_sensor_wifi_signal_strength{home="Home",module="Living Room",station="Home (Living Room)"} 45
-# HELP netatmo_up Zero if there was an error during the last refresh try.
+# HELP netatmo_refresh_triggered_total Counts the scrapes which triggered a refresh, because the refresh interval had elapsed.
+# TYPE netatmo_refresh_triggered_total counter
+netatmo_refresh_triggered_total 0
+# HELP netatmo_station_altitude_meters Altitude of the station in meters, derived from the difference between the sea level and the absolute pressure.
+# TYPE netatmo_station_altitude_meters gauge
+netatmo_station_altitude_meters{station="Home (Living Room)"} 1844.6207181601735
+# HELP netatmo_station_avg_temperature_celsius Average temperature in celsius of the indoor modules of the station.
+# TYPE netatmo_station_avg_temperature_celsius gauge
+netatmo_station_avg_temperature_celsius{station="Home (Living Room)"} 21
+# HELP netatmo_station_linked_modules Contains the number of modules linked to the station in the cached data.
+# TYPE netatmo_station_linked_modules gauge
+netatmo_station_linked_modules{station="Home (Living Room)"} 3
+# HELP netatmo_station_max_temperature_celsius Highest temperature in celsius of the indoor modules of the station.
+# TYPE netatmo_station_max_temperature_celsius gauge
+netatmo_station_max_temperature_celsius{station="Home (Living Room)"} 23
+# HELP netatmo_station_min_temperature_celsius Lowest temperature in celsius of the indoor modules of the station.
+# TYPE netatmo_station_min_temperature_celsius gauge
+netatmo_station_min_temperature_celsius{station="Home (Living Room)"} 17
+# HELP netatmo_station_up Zero if the station was missing from the response of the last refresh try.
+# TYPE netatmo_station_up gauge
+netatmo_station_up{station="Home (Living Room)"} 1
+# HELP netatmo_up Zero if there was an error during the last refresh try or the cached data is older than the maximum cache age.
 # TYPE netatmo_up gauge
 netatmo_up 1
 `,
@@ -265,7 +920,7 @@ netatmo_up 1
 			}
 			expected := strings.NewReader(tc.wantMetrics)
 
-			c := New(logrus.New(), read, time.Hour, time.Hour)
+			c := New(context.Background(), logrus.New(), read, time.Hour, time.Hour)
 			c.clock = mockClock
 			c.RefreshData(mockClock())
 
@@ -276,6 +931,1044 @@ netatmo_up 1
 	}
 }
 
+func TestNetatmoCollector_CollectStationName(t *testing.T) {
+	testDevices := &netatmo.DeviceCollection{}
+	testDevices.Body.Devices = []*netatmo.Device{
+		{
+			ID:          "aa:bb:cc:dd:ee:f0",
+			StationName: "Home",
+			Type:        "NAMain",
+			DashboardData: netatmo.DashboardData{
+				Temperature: float32Ptr(23),
+				LastMeasure: int64Ptr(3500),
+			},
+			LinkedModules: []*netatmo.Device{
+				{
+					ID:   "aa:bb:cc:dd:ee:f1",
+					Type: "NAModule1",
+					DashboardData: netatmo.DashboardData{
+						Temperature: float32Ptr(5),
+						LastMeasure: int64Ptr(3500),
+					},
+				},
+			},
+		},
+		{
+			ID:   "aa:bb:cc:dd:ee:e0",
+			Type: "NAMain",
+			DashboardData: netatmo.DashboardData{
+				Temperature: float32Ptr(19),
+				LastMeasure: int64Ptr(3500),
+			},
+		},
+	}
+	mockClock := func() time.Time {
+		return time.Unix(3600, 0)
+	}
+	read := func() (*netatmo.DeviceCollection, error) {
+		return testDevices, nil
+	}
+
+	c := New(context.Background(), logrus.New(), read, time.Hour, time.Hour)
+	c.clock = mockClock
+	c.RefreshData(mockClock())
+
+	expected := strings.NewReader(`# HELP netatmo_aircare_temperature_celsius Temperature measurement in celsius
+# TYPE netatmo_aircare_temperature_celsius gauge
+netatmo_aircare_temperature_celsius{module="Home",module_type="NAMain",role="station",station="Home"} 23
+netatmo_aircare_temperature_celsius{module="id-aa:bb:cc:dd:ee:e0",module_type="NAMain",role="station",station=""} 19
+netatmo_aircare_temperature_celsius{module="id-aa:bb:cc:dd:ee:f1",module_type="NAModule1",role="module",station="Home"} 5
+`)
+
+	if err := testutil.CollectAndCompare(c, expected, "netatmo_aircare_temperature_celsius"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNetatmoCollector_CollectLinkedModules(t *testing.T) {
+	testDevices := &netatmo.DeviceCollection{}
+	testDevices.Body.Devices = []*netatmo.Device{
+		{
+			ID:          "aa:bb:cc:dd:ee:f0",
+			StationName: "Home",
+			Type:        "NAMain",
+			LinkedModules: []*netatmo.Device{
+				{ID: "aa:bb:cc:dd:ee:f1", ModuleName: "Outdoor", Type: "NAModule1"},
+				{ID: "aa:bb:cc:dd:ee:f2", ModuleName: "Wind", Type: "NAModule2"},
+				{ID: "aa:bb:cc:dd:ee:f3", ModuleName: "Rain", Type: "NAModule3"},
+			},
+		},
+		{
+			ID:          "aa:bb:cc:dd:ee:e0",
+			StationName: "Office",
+			Type:        "NAMain",
+			LinkedModules: []*netatmo.Device{
+				{ID: "aa:bb:cc:dd:ee:e1", ModuleName: "Meeting Room", Type: "NAModule4"},
+			},
+		},
+	}
+	mockClock := func() time.Time {
+		return time.Unix(3600, 0)
+	}
+	read := func() (*netatmo.DeviceCollection, error) {
+		return testDevices, nil
+	}
+
+	c := New(context.Background(), logrus.New(), read, time.Hour, time.Hour)
+	c.clock = mockClock
+	c.RefreshData(mockClock())
+
+	expected := strings.NewReader(`# HELP netatmo_station_linked_modules Contains the number of modules linked to the station in the cached data.
+# TYPE netatmo_station_linked_modules gauge
+netatmo_station_linked_modules{station="Home"} 3
+netatmo_station_linked_modules{station="Office"} 1
+`)
+
+	if err := testutil.CollectAndCompare(c, expected, "netatmo_station_linked_modules"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNetatmoCollector_CollectNestedModules(t *testing.T) {
+	relay := &netatmo.Device{
+		ID:         "aa:bb:cc:dd:ee:f1",
+		ModuleName: "Relay",
+		Type:       "NAModule4",
+		DashboardData: netatmo.DashboardData{
+			Temperature: float32Ptr(21),
+			LastMeasure: int64Ptr(3600),
+		},
+		LinkedModules: []*netatmo.Device{
+			{
+				ID:         "aa:bb:cc:dd:ee:f2",
+				ModuleName: "Garage",
+				Type:       "NAModule4",
+				DashboardData: netatmo.DashboardData{
+					Temperature: float32Ptr(12),
+					LastMeasure: int64Ptr(3600),
+				},
+				LinkedModules: []*netatmo.Device{
+					{
+						ID:         "aa:bb:cc:dd:ee:f3",
+						ModuleName: "Shed",
+						Type:       "NAModule1",
+						DashboardData: netatmo.DashboardData{
+							Temperature: float32Ptr(8),
+							LastMeasure: int64Ptr(3600),
+						},
+					},
+				},
+			},
+		},
+	}
+	// The relay is linked to itself, which should not happen, but must not result in an endless loop.
+	relay.LinkedModules = append(relay.LinkedModules, relay)
+
+	testDevices := &netatmo.DeviceCollection{}
+	testDevices.Body.Devices = []*netatmo.Device{
+		{
+			ID:          "aa:bb:cc:dd:ee:f0",
+			ModuleName:  "Living Room",
+			StationName: "Home",
+			Type:        "NAMain",
+			DashboardData: netatmo.DashboardData{
+				Temperature: float32Ptr(23),
+				LastMeasure: int64Ptr(3600),
+			},
+			LinkedModules: []*netatmo.Device{relay},
+		},
+	}
+	mockClock := func() time.Time {
+		return time.Unix(3600, 0)
+	}
+	read := func() (*netatmo.DeviceCollection, error) {
+		return testDevices, nil
+	}
+
+	c := New(context.Background(), logrus.New(), read, time.Hour, time.Hour)
+	c.clock = mockClock
+	c.RefreshData(mockClock())
+
+	expected := strings.NewReader(`# HELP netatmo_aircare_temperature_celsius Temperature measurement in celsius
+# TYPE netatmo_aircare_temperature_celsius gauge
+netatmo_aircare_temperature_celsius{module="Garage",module_type="NAModule4",role="module",station="Home"} 12
+netatmo_aircare_temperature_celsius{module="Living Room",module_type="NAMain",role="station",station="Home"} 23
+netatmo_aircare_temperature_celsius{module="Relay",module_type="NAModule4",role="module",station="Home"} 21
+netatmo_aircare_temperature_celsius{module="Shed",module_type="NAModule1",role="module",station="Home"} 8
+# HELP netatmo_station_linked_modules Contains the number of modules linked to the station in the cached data.
+# TYPE netatmo_station_linked_modules gauge
+netatmo_station_linked_modules{station="Home"} 3
+`)
+
+	if err := testutil.CollectAndCompare(c, expected, "netatmo_aircare_temperature_celsius", "netatmo_station_linked_modules"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNetatmoCollector_CollectConnectivityWithoutData(t *testing.T) {
+	// The station has stale data and the module has none, so only the battery and signal strengths are exported.
+	testDevices := &netatmo.DeviceCollection{}
+	testDevices.Body.Devices = []*netatmo.Device{
+		{
+			ID:          "aa:bb:cc:dd:ee:f0",
+			ModuleName:  "Living Room",
+			StationName: "Home",
+			WifiStatus:  int32Ptr(45),
+			Type:        "NAMain",
+			DashboardData: netatmo.DashboardData{
+				Temperature: float32Ptr(23),
+				LastMeasure: int64Ptr(100),
+			},
+			LinkedModules: []*netatmo.Device{
+				{
+					ID:             "aa:bb:cc:dd:ee:f1",
+					ModuleName:     "Outside",
+					BatteryPercent: int32Ptr(20),
+					RFStatus:       int32Ptr(85),
+					Type:           "NAModule1",
+				},
+			},
+		},
+	}
+
+	c := New(context.Background(), logrus.New(), func() (*netatmo.DeviceCollection, error) {
+		return testDevices, nil
+	}, time.Hour, time.Hour)
+	c.clock = func() time.Time {
+		return time.Unix(7200, 0)
+	}
+	c.RefreshData(c.clock())
+
+	expected := `# HELP netatmo_aircare_battery_percent Battery remaining life (10: low)
+# TYPE netatmo_aircare_battery_percent gauge
+netatmo_aircare_battery_percent{module="Outside",module_type="NAModule1",role="module",station="Home"} 20
+# HELP netatmo_aircare_rf_signal_strength RF signal strength (90: lowest, 60: highest)
+# TYPE netatmo_aircare_rf_signal_strength gauge
+netatmo_aircare_rf_signal_strength{module="Outside",module_type="NAModule1",role="module",station="Home"} 85
+# HELP netatmo_aircare_wifi_signal_strength Wifi signal strength (86: bad, 71: avg, 56: good)
+# TYPE netatmo_aircare_wifi_signal_strength gauge
+netatmo_aircare_wifi_signal_strength{module="Living Room",module_type="NAMain",role="station",station="Home"} 45
+`
+	metricNames := []string{
+		"netatmo_aircare_battery_percent",
+		"netatmo_aircare_rf_signal_strength",
+		"netatmo_aircare_wifi_signal_strength",
+		"netatmo_aircare_temperature_celsius",
+	}
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected), metricNames...); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNetatmoCollector_CollectModuleOnline(t *testing.T) {
+	outside := &netatmo.Device{
+		ID:         "aa:bb:cc:dd:ee:f1",
+		ModuleName: "Outside",
+		RFStatus:   int32Ptr(85),
+		Type:       "NAModule1",
+		DashboardData: netatmo.DashboardData{
+			Temperature: float32Ptr(10),
+			LastMeasure: int64Ptr(100),
+		},
+	}
+	// The API omits the dashboard data of unreachable modules.
+	bedroom := &netatmo.Device{
+		ID:         "aa:bb:cc:dd:ee:f2",
+		ModuleName: "Bedroom",
+		Type:       "NAModule4",
+	}
+	kitchen := &netatmo.Device{
+		ID:         "aa:bb:cc:dd:ee:f3",
+		ModuleName: "Kitchen",
+		Type:       "NAModule4",
+		DashboardData: netatmo.DashboardData{
+			LastMeasure: int64Ptr(3600),
+		},
+	}
+	station := func(modules ...*netatmo.Device) *netatmo.DeviceCollection {
+		dc := &netatmo.DeviceCollection{}
+		dc.Body.Devices = []*netatmo.Device{
+			{
+				ID:          "aa:bb:cc:dd:ee:f0",
+				ModuleName:  "Living Room",
+				StationName: "Home",
+				WifiStatus:  int32Ptr(45),
+				Type:        "NAMain",
+				DashboardData: netatmo.DashboardData{
+					Temperature: float32Ptr(23),
+					LastMeasure: int64Ptr(7000),
+				},
+				LinkedModules: modules,
+			},
+		}
+		return dc
+	}
+
+	var devices *netatmo.DeviceCollection
+	c := New(context.Background(), logrus.New(), func() (*netatmo.DeviceCollection, error) {
+		return devices, nil
+	}, time.Hour, time.Hour)
+	c.clock = func() time.Time {
+		return time.Unix(7200, 0)
+	}
+	c.ModuleGracePeriod = time.Hour
+
+	const header = `# HELP netatmo_module_online One if the module is reachable and has data newer than the stale threshold, zero otherwise.
+# TYPE netatmo_module_online gauge
+`
+	tt := []struct {
+		desc    string
+		devices *netatmo.DeviceCollection
+		want    string
+	}{
+		{
+			desc:    "reachable and stale",
+			devices: station(outside, bedroom, kitchen),
+			want: header + `netatmo_module_online{module="Bedroom",module_type="NAModule4",role="module",station="Home"} 0
+netatmo_module_online{module="Kitchen",module_type="NAModule4",role="module",station="Home"} 1
+netatmo_module_online{module="Living Room",module_type="NAMain",role="station",station="Home"} 1
+netatmo_module_online{module="Outside",module_type="NAModule1",role="module",station="Home"} 0
+`,
+		},
+		{
+			desc: "unreachable",
+			devices: station(outside, bedroom, &netatmo.Device{
+				ID:         kitchen.ID,
+				ModuleName: kitchen.ModuleName,
+				Type:       kitchen.Type,
+			}),
+			want: header + `netatmo_module_online{module="Bedroom",module_type="NAModule4",role="module",station="Home"} 0
+netatmo_module_online{module="Kitchen",module_type="NAModule4",role="module",station="Home"} 0
+netatmo_module_online{module="Living Room",module_type="NAMain",role="station",station="Home"} 1
+netatmo_module_online{module="Outside",module_type="NAModule1",role="module",station="Home"} 0
+`,
+		},
+		{
+			desc:    "disappeared",
+			devices: station(outside, bedroom),
+			want: header + `netatmo_module_online{module="Bedroom",module_type="NAModule4",role="module",station="Home"} 0
+netatmo_module_online{module="Kitchen",module_type="NAModule4",role="module",station="Home"} 0
+netatmo_module_online{module="Living Room",module_type="NAMain",role="station",station="Home"} 1
+netatmo_module_online{module="Outside",module_type="NAModule1",role="module",station="Home"} 0
+`,
+		},
+	}
+
+	// The steps build on each other, so they can not run in parallel.
+	for _, tc := range tt {
+		devices = tc.devices
+		c.RefreshData(c.clock())
+
+		if err := testutil.CollectAndCompare(c, strings.NewReader(tc.want), "netatmo_module_online"); err != nil {
+			t.Errorf("%s: %s", tc.desc, err)
+		}
+	}
+}
+
+func TestNetatmoCollector_CollectTypeStaleThresholds(t *testing.T) {
+	dc := &netatmo.DeviceCollection{}
+	dc.Body.Devices = []*netatmo.Device{
+		{
+			ID:          "aa:bb:cc:dd:ee:f0",
+			ModuleName:  "Living Room",
+			StationName: "Home",
+			Type:        "NAMain",
+			DashboardData: netatmo.DashboardData{
+				Temperature: float32Ptr(23),
+				LastMeasure: int64Ptr(3000),
+			},
+			LinkedModules: []*netatmo.Device{
+				{
+					ID:         "aa:bb:cc:dd:ee:f1",
+					ModuleName: "Outside",
+					Type:       "NAModule1",
+					DashboardData: netatmo.DashboardData{
+						Temperature: float32Ptr(8),
+						LastMeasure: int64Ptr(3000),
+					},
+				},
+				{
+					ID:         "aa:bb:cc:dd:ee:f2",
+					ModuleName: "Bedroom",
+					Type:       "NAModule4",
+					DashboardData: netatmo.DashboardData{
+						Temperature: float32Ptr(19),
+						LastMeasure: int64Ptr(6000),
+					},
+				},
+			},
+		},
+	}
+
+	const header = `# HELP netatmo_aircare_temperature_celsius Temperature measurement in celsius
+# TYPE netatmo_aircare_temperature_celsius gauge
+`
+	tt := []struct {
+		desc       string
+		thresholds map[string]time.Duration
+		want       string
+	}{
+		{
+			desc: "default threshold",
+			want: header + `netatmo_aircare_temperature_celsius{module="Bedroom",module_type="NAModule4",role="module",station="Home"} 19
+`,
+		},
+		{
+			desc: "longer threshold for outdoor module",
+			thresholds: map[string]time.Duration{
+				"NAModule1": 2 * time.Hour,
+			},
+			want: header + `netatmo_aircare_temperature_celsius{module="Bedroom",module_type="NAModule4",role="module",station="Home"} 19
+netatmo_aircare_temperature_celsius{module="Outside",module_type="NAModule1",role="module",station="Home"} 8
+`,
+		},
+		{
+			desc: "shorter threshold for indoor module",
+			thresholds: map[string]time.Duration{
+				"NAModule4": 10 * time.Minute,
+			},
+			want: "",
+		},
+		{
+			desc: "threshold for station",
+			thresholds: map[string]time.Duration{
+				"NAMain": 2 * time.Hour,
+			},
+			want: header + `netatmo_aircare_temperature_celsius{module="Bedroom",module_type="NAModule4",role="module",station="Home"} 19
+netatmo_aircare_temperature_celsius{module="Living Room",module_type="NAMain",role="station",station="Home"} 23
+`,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			c := New(context.Background(), logrus.New(), func() (*netatmo.DeviceCollection, error) {
+				return dc, nil
+			}, time.Hour, time.Hour)
+			c.clock = func() time.Time {
+				return time.Unix(7200, 0)
+			}
+			c.TypeStaleThresholds = tc.thresholds
+			c.RefreshData(c.clock())
+
+			if err := testutil.CollectAndCompare(c, strings.NewReader(tc.want), "netatmo_aircare_temperature_celsius"); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestNetatmoCollector_CollectSorted(t *testing.T) {
+	station := func(id string, modules ...string) *netatmo.Device {
+		device := &netatmo.Device{
+			ID:         id,
+			ModuleName: id,
+			Type:       "NAMain",
+			DashboardData: netatmo.DashboardData{
+				Temperature: float32Ptr(20),
+				LastMeasure: int64Ptr(3500),
+			},
+		}
+		for _, moduleID := range modules {
+			device.LinkedModules = append(device.LinkedModules, &netatmo.Device{
+				ID:         moduleID,
+				ModuleName: moduleID,
+				Type:       "NAModule4",
+				DashboardData: netatmo.DashboardData{
+					Temperature: float32Ptr(20),
+					LastMeasure: int64Ptr(3500),
+				},
+			})
+		}
+		return device
+	}
+
+	testDevices := &netatmo.DeviceCollection{}
+	testDevices.Body.Devices = []*netatmo.Device{
+		station("c0", "c2", "c1"),
+		station("a0", "a1"),
+		station("b0", "b3", "b1", "b2"),
+	}
+
+	c := New(context.Background(), logrus.New(), func() (*netatmo.DeviceCollection, error) {
+		return testDevices, nil
+	}, time.Hour, time.Hour)
+	c.clock = func() time.Time {
+		return time.Unix(3600, 0)
+	}
+	c.RefreshData(c.clock())
+
+	for i := 0; i < 2; i++ {
+		ch := make(chan prometheus.Metric)
+		go func() {
+			c.Collect(ch)
+			close(ch)
+		}()
+
+		var modules []string
+		for m := range ch {
+			if m.Desc() != tempDesc {
+				continue
+			}
+
+			var metric dto.Metric
+			if err := m.Write(&metric); err != nil {
+				t.Fatalf("error writing metric: %s", err)
+			}
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "module" {
+					modules = append(modules, label.GetValue())
+				}
+			}
+		}
+
+		want := []string{"a0", "a1", "b0", "b1", "b2", "b3", "c0", "c1", "c2"}
+		if diff := cmp.Diff(modules, want); diff != "" {
+			t.Errorf("scrape %d: modules differ: %s", i, diff)
+		}
+	}
+}
+
+func TestNetatmoCollector_CollectMetricTimestamps(t *testing.T) {
+	testDevices := &netatmo.DeviceCollection{}
+	testDevices.Body.Devices = []*netatmo.Device{
+		{
+			ID:             "aa:bb:cc:dd:ee:f0",
+			StationName:    "Home",
+			Type:           "NAMain",
+			WifiStatus:     int32Ptr(56),
+			BatteryPercent: int32Ptr(80),
+			DashboardData: netatmo.DashboardData{
+				Temperature: float32Ptr(23),
+				LastMeasure: int64Ptr(3500),
+			},
+		},
+	}
+
+	tt := []struct {
+		desc       string
+		timestamps bool
+		wantSensor int64
+	}{
+		{
+			desc:       "disabled",
+			timestamps: false,
+		},
+		{
+			desc:       "enabled",
+			timestamps: true,
+			wantSensor: 3500 * 1000,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			c := New(context.Background(), logrus.New(), func() (*netatmo.DeviceCollection, error) {
+				return testDevices, nil
+			}, time.Hour, time.Hour)
+			c.clock = func() time.Time {
+				return time.Unix(3600, 0)
+			}
+			c.MetricTimestamps = tc.timestamps
+			c.RefreshData(c.clock())
+
+			ch := make(chan prometheus.Metric)
+			go func() {
+				c.Collect(ch)
+				close(ch)
+			}()
+
+			timestamps := make(map[*prometheus.Desc]int64)
+			for m := range ch {
+				var metric dto.Metric
+				if err := m.Write(&metric); err != nil {
+					t.Fatalf("error writing metric: %s", err)
+				}
+				timestamps[m.Desc()] = metric.GetTimestampMs()
+			}
+
+			for desc, want := range map[*prometheus.Desc]int64{
+				tempDesc:           tc.wantSensor,
+				updatedDesc:        tc.wantSensor,
+				wifiDesc:           0,
+				batteryDesc:        0,
+				cacheTimestampDesc: 0,
+			} {
+				got, ok := timestamps[desc]
+				if !ok {
+					t.Errorf("metric %s missing", desc)
+					continue
+				}
+
+				if got != want {
+					t.Errorf("got timestamp %d for %s, want %d", got, desc, want)
+				}
+			}
+		})
+	}
+}
+
+func TestNetatmoCollector_CollectFreshness(t *testing.T) {
+	testDevices := &netatmo.DeviceCollection{}
+	testDevices.Body.Devices = []*netatmo.Device{
+		{
+			ID:          "aa:bb:cc:dd:ee:f0",
+			ModuleName:  "Fresh",
+			StationName: "Home",
+			Type:        "NAMain",
+			DashboardData: netatmo.DashboardData{
+				Temperature: float32Ptr(23),
+				LastMeasure: int64Ptr(7200 - 1800),
+			},
+			LinkedModules: []*netatmo.Device{
+				{
+					ID:         "aa:bb:cc:dd:ee:f1",
+					ModuleName: "Stale",
+					Type:       "NAModule1",
+					DashboardData: netatmo.DashboardData{
+						Temperature: float32Ptr(5),
+						LastMeasure: int64Ptr(0),
+					},
+				},
+			},
+		},
+	}
+	mockClock := func() time.Time {
+		return time.Unix(7200, 0)
+	}
+	read := func() (*netatmo.DeviceCollection, error) {
+		return testDevices, nil
+	}
+
+	c := New(context.Background(), logrus.New(), read, time.Hour, time.Hour)
+	c.clock = mockClock
+	c.RefreshData(mockClock())
+
+	expected := strings.NewReader(`# HELP netatmo_aircare_temperature_celsius Temperature measurement in celsius
+# TYPE netatmo_aircare_temperature_celsius gauge
+netatmo_aircare_temperature_celsius{module="Fresh",module_type="NAMain",role="station",station="Home"} 23
+# HELP netatmo_data_freshness_ratio Age of the module data relative to the stale threshold. Zero is fresh, one or more means that the data is stale.
+# TYPE netatmo_data_freshness_ratio gauge
+netatmo_data_freshness_ratio{module="Fresh",module_type="NAMain",role="station",station="Home"} 0.5
+netatmo_data_freshness_ratio{module="Stale",module_type="NAModule1",role="module",station="Home"} 2
+`)
+
+	if err := testutil.CollectAndCompare(c, expected, "netatmo_aircare_temperature_celsius", "netatmo_data_freshness_ratio"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNetatmoCollector_CollectCacheStaleness(t *testing.T) {
+	testDevices := &netatmo.DeviceCollection{}
+	testDevices.Body.Devices = []*netatmo.Device{
+		{ID: "aa:bb:cc:dd:ee:f0"},
+	}
+	now := time.Unix(3600, 0)
+	mockClock := func() time.Time {
+		return now
+	}
+	read := func() (*netatmo.DeviceCollection, error) {
+		return testDevices, nil
+	}
+
+	c := New(context.Background(), logrus.New(), read, time.Hour, time.Hour)
+	c.clock = mockClock
+	c.RefreshData(mockClock())
+	now = now.Add(90 * time.Second)
+
+	expected := `# HELP netatmo_cache_staleness_seconds Contains the age of the cached data at the time of the scrape. Not present if there is no cached data.
+# TYPE netatmo_cache_staleness_seconds gauge
+netatmo_cache_staleness_seconds 90
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected), "netatmo_cache_staleness_seconds"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNetatmoCollector_CollectMaxCacheAge(t *testing.T) {
+	testDevices := &netatmo.DeviceCollection{}
+	testDevices.Body.Devices = []*netatmo.Device{
+		{
+			ID:          "aa:bb:cc:dd:ee:f0",
+			ModuleName:  "Living Room",
+			StationName: "Home",
+			Type:        "NAMain",
+			DashboardData: netatmo.DashboardData{
+				Temperature: float32Ptr(23),
+				LastMeasure: int64Ptr(3600),
+			},
+		},
+	}
+	now := time.Unix(3600, 0)
+	mockClock := func() time.Time {
+		return now
+	}
+	read := func() (*netatmo.DeviceCollection, error) {
+		return testDevices, nil
+	}
+
+	c := New(context.Background(), logrus.New(), read, 24*time.Hour, 24*time.Hour)
+	c.MaxCacheAge = 30 * time.Minute
+	c.clock = mockClock
+	c.RefreshData(mockClock())
+
+	tt := []struct {
+		desc string
+		age  time.Duration
+		want string
+	}{
+		{
+			desc: "fresh cache",
+			age:  10 * time.Minute,
+			want: `# HELP netatmo_up Zero if there was an error during the last refresh try or the cached data is older than the maximum cache age.
+# TYPE netatmo_up gauge
+netatmo_up 1
+# HELP netatmo_aircare_temperature_celsius Temperature measurement in celsius
+# TYPE netatmo_aircare_temperature_celsius gauge
+netatmo_aircare_temperature_celsius{module="Living Room",module_type="NAMain",role="station",station="Home"} 23
+`,
+		},
+		{
+			desc: "expired cache",
+			age:  31 * time.Minute,
+			want: `# HELP netatmo_up Zero if there was an error during the last refresh try or the cached data is older than the maximum cache age.
+# TYPE netatmo_up gauge
+netatmo_up 0
+`,
+		},
+	}
+
+	// The steps share the clock of the collector, so they can not run in parallel.
+	for _, tc := range tt {
+		now = time.Unix(3600, 0).Add(tc.age)
+
+		if err := testutil.CollectAndCompare(c, strings.NewReader(tc.want), "netatmo_up", "netatmo_aircare_temperature_celsius"); err != nil {
+			t.Errorf("%s: %s", tc.desc, err)
+		}
+	}
+}
+
+func TestNetatmoCollector_CollectCacheCounters(t *testing.T) {
+	read := func() (*netatmo.DeviceCollection, error) {
+		return &netatmo.DeviceCollection{}, nil
+	}
+	var now atomic.Int64
+	mockClock := func() time.Time {
+		return time.Unix(now.Load(), 0)
+	}
+
+	c := New(context.Background(), logrus.New(), read, time.Hour, time.Hour)
+	c.clock = mockClock
+	c.RefreshData(mockClock())
+
+	steps := []struct {
+		time          int64
+		wantServed    int
+		wantTriggered int
+	}{
+		{
+			time:          1800,
+			wantServed:    1,
+			wantTriggered: 0,
+		},
+		{
+			time:          3599,
+			wantServed:    2,
+			wantTriggered: 0,
+		},
+		{
+			time:          3600,
+			wantServed:    2,
+			wantTriggered: 1,
+		},
+	}
+
+	for _, step := range steps {
+		now.Store(step.time)
+
+		expected := fmt.Sprintf(`# HELP netatmo_cache_served_total Counts the scrapes which were served from the cache without triggering a refresh.
+# TYPE netatmo_cache_served_total counter
+netatmo_cache_served_total %d
+# HELP netatmo_refresh_triggered_total Counts the scrapes which triggered a refresh, because the refresh interval had elapsed.
+# TYPE netatmo_refresh_triggered_total counter
+netatmo_refresh_triggered_total %d
+`, step.wantServed, step.wantTriggered)
+
+		if err := testutil.CollectAndCompare(c, strings.NewReader(expected), "netatmo_cache_served_total", "netatmo_refresh_triggered_total"); err != nil {
+			t.Errorf("time %d: %s", step.time, err)
+		}
+	}
+}
+
+func TestNetatmoCollector_RefreshJitter(t *testing.T) {
+	const seed = 42
+	read := func() (*netatmo.DeviceCollection, error) {
+		return &netatmo.DeviceCollection{}, nil
+	}
+
+	c := New(context.Background(), logrus.New(), read, time.Hour, time.Hour)
+	c.RefreshJitter = 5 * time.Minute
+	c.random = rand.New(rand.NewSource(seed))
+
+	for i := 0; i < 1000; i++ {
+		offset := c.jitter()
+		if offset < -c.RefreshJitter || offset > c.RefreshJitter {
+			t.Fatalf("got offset %s, want at most %s", offset, c.RefreshJitter)
+		}
+	}
+
+	c.random = rand.New(rand.NewSource(seed))
+	seeded := New(context.Background(), logrus.New(), read, time.Hour, time.Hour)
+	seeded.RefreshJitter = c.RefreshJitter
+	seeded.random = rand.New(rand.NewSource(seed))
+	wantOffset := seeded.jitter()
+
+	c.RefreshData(time.Unix(0, 0))
+	if c.refreshOffset != wantOffset {
+		t.Fatalf("got offset %s, want %s", c.refreshOffset, wantOffset)
+	}
+
+	due := time.Unix(0, 0).Add(c.RefreshInterval + wantOffset)
+	c.triggerRefresh(due.Add(-time.Second))
+	if got := c.refreshTriggered.Load(); got != 0 {
+		t.Errorf("got %d triggered refreshes before the jittered interval, want none", got)
+	}
+
+	c.triggerRefresh(due)
+	if got := c.refreshTriggered.Load(); got != 1 {
+		t.Errorf("got %d triggered refreshes after the jittered interval, want one", got)
+	}
+}
+
+func TestNetatmoCollector_CollectRefreshInProgress(t *testing.T) {
+	started := make(chan struct{}, 2)
+	block := make(chan struct{})
+
+	c := New(context.Background(), logrus.New(), func() (*netatmo.DeviceCollection, error) {
+		started <- struct{}{}
+		<-block
+		return &netatmo.DeviceCollection{}, nil
+	}, time.Hour, time.Hour)
+	c.clock = func() time.Time {
+		return time.Unix(3600, 0)
+	}
+
+	expected := func(inProgress, served, triggered int) string {
+		return fmt.Sprintf(`# HELP netatmo_cache_served_total Counts the scrapes which were served from the cache without triggering a refresh.
+# TYPE netatmo_cache_served_total counter
+netatmo_cache_served_total %d
+# HELP netatmo_refresh_in_progress One while a refresh of the data is in progress, zero otherwise.
+# TYPE netatmo_refresh_in_progress gauge
+netatmo_refresh_in_progress %d
+# HELP netatmo_refresh_triggered_total Counts the scrapes which triggered a refresh, because the refresh interval had elapsed.
+# TYPE netatmo_refresh_triggered_total counter
+netatmo_refresh_triggered_total %d
+`, served, inProgress, triggered)
+	}
+	metricNames := []string{"netatmo_cache_served_total", "netatmo_refresh_in_progress", "netatmo_refresh_triggered_total"}
+
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected(1, 0, 1)), metricNames...); err != nil {
+		t.Errorf("first scrape: %s", err)
+	}
+	<-started
+
+	// lastRefresh has been updated by now, so reset it to check that the running refresh is not started again.
+	c.lastRefresh = time.Time{}
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected(1, 1, 1)), metricNames...); err != nil {
+		t.Errorf("second scrape: %s", err)
+	}
+
+	close(block)
+	for deadline := time.Now().Add(time.Second); c.refreshing.Load(); {
+		if time.Now().After(deadline) {
+			t.Fatal("refresh did not finish")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if len(started) != 0 {
+		t.Errorf("got %d additional refreshes, want none", len(started))
+	}
+}
+
+func TestNetatmoCollector_CollectInitialRefresh(t *testing.T) {
+	tt := []struct {
+		desc          string
+		delay         time.Duration
+		timeout       time.Duration
+		wantCacheTime int
+	}{
+		{
+			desc:          "refresh completes",
+			delay:         10 * time.Millisecond,
+			timeout:       10 * time.Second,
+			wantCacheTime: 3600,
+		},
+		{
+			desc:          "timeout",
+			delay:         10 * time.Second,
+			timeout:       10 * time.Millisecond,
+			wantCacheTime: 0,
+		},
+		{
+			desc:          "disabled",
+			delay:         10 * time.Millisecond,
+			timeout:       0,
+			wantCacheTime: 0,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			c := New(ctx, logrus.New(), func() (*netatmo.DeviceCollection, error) {
+				select {
+				case <-time.After(tc.delay):
+				case <-ctx.Done():
+				}
+
+				dc := &netatmo.DeviceCollection{}
+				dc.Body.Devices = []*netatmo.Device{
+					{
+						ID: "aa:bb:cc:dd:ee:f0",
+					},
+				}
+				return dc, nil
+			}, time.Hour, time.Hour)
+			c.clock = func() time.Time {
+				return time.Unix(3600, 0)
+			}
+			c.InitialRefreshTimeout = tc.timeout
+
+			expected := fmt.Sprintf(`# HELP netatmo_cache_updated_time Contains the time of the cached data.
+# TYPE netatmo_cache_updated_time gauge
+netatmo_cache_updated_time %d
+`, tc.wantCacheTime)
+			if err := testutil.CollectAndCompare(c, strings.NewReader(expected), "netatmo_cache_updated_time"); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestNetatmoCollector_CollectWarmupDelay(t *testing.T) {
+	read := func() (*netatmo.DeviceCollection, error) {
+		return &netatmo.DeviceCollection{}, nil
+	}
+	var now atomic.Int64
+	mockClock := func() time.Time {
+		return time.Unix(now.Load(), 0)
+	}
+
+	c := New(context.Background(), logrus.New(), read, time.Hour, time.Hour)
+	c.clock = mockClock
+	c.started = mockClock()
+	c.WarmupDelay = time.Minute
+	c.InitialRefreshTimeout = 10 * time.Second
+
+	steps := []struct {
+		time          int64
+		wantServed    int
+		wantTriggered int
+	}{
+		{
+			time:          0,
+			wantServed:    0,
+			wantTriggered: 0,
+		},
+		{
+			time:          59,
+			wantServed:    0,
+			wantTriggered: 0,
+		},
+		{
+			time:          60,
+			wantServed:    0,
+			wantTriggered: 1,
+		},
+	}
+
+	for _, step := range steps {
+		now.Store(step.time)
+
+		expected := fmt.Sprintf(`# HELP netatmo_cache_served_total Counts the scrapes which were served from the cache without triggering a refresh.
+# TYPE netatmo_cache_served_total counter
+netatmo_cache_served_total %d
+# HELP netatmo_refresh_triggered_total Counts the scrapes which triggered a refresh, because the refresh interval had elapsed.
+# TYPE netatmo_refresh_triggered_total counter
+netatmo_refresh_triggered_total %d
+`, step.wantServed, step.wantTriggered)
+
+		start := time.Now()
+		if err := testutil.CollectAndCompare(c, strings.NewReader(expected), "netatmo_cache_served_total", "netatmo_refresh_triggered_total"); err != nil {
+			t.Errorf("time %d: %s", step.time, err)
+		}
+
+		if step.wantTriggered == 0 && time.Since(start) >= c.InitialRefreshTimeout {
+			t.Errorf("time %d: scrape waited for the initial refresh during the warm-up", step.time)
+		}
+	}
+}
+
+func TestStationCollector(t *testing.T) {
+	testDevices := &netatmo.DeviceCollection{}
+	testDevices.Body.Devices = []*netatmo.Device{
+		{
+			ID:          "aa:bb:cc:dd:ee:f0",
+			ModuleName:  "Living Room",
+			StationName: "First",
+			Type:        "NAMain",
+			DashboardData: netatmo.DashboardData{
+				Temperature: float32Ptr(23),
+				LastMeasure: int64Ptr(3500),
+			},
+		},
+		{
+			ID:          "aa:bb:cc:dd:ee:e0",
+			ModuleName:  "Living Room",
+			StationName: "Second",
+			Type:        "NAMain",
+			DashboardData: netatmo.DashboardData{
+				Temperature: float32Ptr(19),
+				LastMeasure: int64Ptr(3500),
+			},
+		},
+	}
+	mockClock := func() time.Time {
+		return time.Unix(3600, 0)
+	}
+	read := func() (*netatmo.DeviceCollection, error) {
+		return testDevices, nil
+	}
+
+	c := New(context.Background(), logrus.New(), read, time.Hour, time.Hour)
+	c.clock = mockClock
+	c.RefreshData(mockClock())
+
+	expected := strings.NewReader(`# HELP netatmo_aircare_temperature_celsius Temperature measurement in celsius
+# TYPE netatmo_aircare_temperature_celsius gauge
+netatmo_aircare_temperature_celsius{module="Living Room",module_type="NAMain",role="station",station="Second"} 19
+# HELP netatmo_station_up Zero if the station was missing from the response of the last refresh try.
+# TYPE netatmo_station_up gauge
+netatmo_station_up{station="Second"} 1
+`)
+
+	if err := testutil.CollectAndCompare(c.StationCollector("Second"), expected, "netatmo_aircare_temperature_celsius", "netatmo_station_up"); err != nil {
+		t.Error(err)
+	}
+}
+
 func int32Ptr(i int32) *int32 {
 	return &i
 }
@@ -0,0 +1,106 @@
+package collector
+
+import (
+	"fmt"
+)
+
+// comfortLevels is the number of limits needed for classifying a value into the comfort levels 0 to 3.
+const comfortLevels = 3
+
+// ComfortLimits contains the limits between the four comfort levels of one input.
+type ComfortLimits [comfortLevels]float64
+
+// ComfortThresholds contains the limits used for calculating the comfort level of a room. Values up to the
+// first limit of CO2 and the upper limits of temperature and humidity are level 0 (good), values up to the second
+// limit are level 1 and so on. The lower limits of temperature and humidity work the same way in the other direction.
+type ComfortThresholds struct {
+	CO2             ComfortLimits
+	HumidityLow     ComfortLimits
+	HumidityHigh    ComfortLimits
+	TemperatureLow  ComfortLimits
+	TemperatureHigh ComfortLimits
+}
+
+// DefaultComfortThresholds follow the ranges the Netatmo apps use for coloring the measurements.
+var DefaultComfortThresholds = ComfortThresholds{
+	CO2:             ComfortLimits{1000, 1500, 2000},
+	HumidityLow:     ComfortLimits{40, 30, 20},
+	HumidityHigh:    ComfortLimits{60, 70, 80},
+	TemperatureLow:  ComfortLimits{19, 17, 15},
+	TemperatureHigh: ComfortLimits{24, 26, 28},
+}
+
+func comfortThresholdFields(t *ComfortThresholds) map[string]*ComfortLimits {
+	return map[string]*ComfortLimits{
+		"co2":              &t.CO2,
+		"humidity_low":     &t.HumidityLow,
+		"humidity_high":    &t.HumidityHigh,
+		"temperature_low":  &t.TemperatureLow,
+		"temperature_high": &t.TemperatureHigh,
+	}
+}
+
+// Set replaces the limits of the input with the given name. The limits of the lower bounds need to be
+// decreasing, all others increasing.
+func (t *ComfortThresholds) Set(name string, limits ComfortLimits) error {
+	field, ok := comfortThresholdFields(t)[name]
+	if !ok {
+		return fmt.Errorf("unknown comfort threshold: %s", name)
+	}
+
+	decreasing := name == "humidity_low" || name == "temperature_low"
+	for i := 1; i < len(limits); i++ {
+		if (decreasing && limits[i] >= limits[i-1]) || (!decreasing && limits[i] <= limits[i-1]) {
+			return fmt.Errorf("limits of comfort threshold %s are not in order: %v", name, limits)
+		}
+	}
+
+	*field = limits
+	return nil
+}
+
+// level calculates the comfort level of a room, which is the worst level of all available inputs.
+func (t ComfortThresholds) level(co2 float64, temperature, humidity *float64) int {
+	level := levelAbove(co2, t.CO2)
+
+	if temperature != nil {
+		level = maxInt(level, levelAbove(*temperature, t.TemperatureHigh), levelBelow(*temperature, t.TemperatureLow))
+	}
+
+	if humidity != nil {
+		level = maxInt(level, levelAbove(*humidity, t.HumidityHigh), levelBelow(*humidity, t.HumidityLow))
+	}
+
+	return level
+}
+
+func levelAbove(value float64, limits ComfortLimits) int {
+	for i, limit := range limits {
+		if value <= limit {
+			return i
+		}
+	}
+
+	return len(limits)
+}
+
+func levelBelow(value float64, limits ComfortLimits) int {
+	for i, limit := range limits {
+		if value >= limit {
+			return i
+		}
+	}
+
+	return len(limits)
+}
+
+func maxInt(values ...int) int {
+	result := values[0]
+	for _, v := range values[1:] {
+		if v > result {
+			result = v
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,155 @@
+package collector
+
+import (
+	"testing"
+)
+
+func TestComfortThresholdsLevel(t *testing.T) {
+	float64Ptr := func(value float64) *float64 {
+		return &value
+	}
+
+	tt := []struct {
+		desc        string
+		co2         float64
+		temperature *float64
+		humidity    *float64
+		wantLevel   int
+	}{
+		{
+			desc:      "co2 good",
+			co2:       1000,
+			wantLevel: 0,
+		},
+		{
+			desc:      "co2 fair",
+			co2:       1001,
+			wantLevel: 1,
+		},
+		{
+			desc:      "co2 poor",
+			co2:       2000,
+			wantLevel: 2,
+		},
+		{
+			desc:      "co2 bad",
+			co2:       2001,
+			wantLevel: 3,
+		},
+		{
+			desc:        "all good",
+			co2:         600,
+			temperature: float64Ptr(21),
+			humidity:    float64Ptr(50),
+			wantLevel:   0,
+		},
+		{
+			desc:        "temperature too high",
+			co2:         600,
+			temperature: float64Ptr(25),
+			humidity:    float64Ptr(50),
+			wantLevel:   1,
+		},
+		{
+			desc:        "temperature too low",
+			co2:         600,
+			temperature: float64Ptr(16),
+			humidity:    float64Ptr(50),
+			wantLevel:   2,
+		},
+		{
+			desc:        "humidity too low",
+			co2:         600,
+			temperature: float64Ptr(21),
+			humidity:    float64Ptr(10),
+			wantLevel:   3,
+		},
+		{
+			desc:        "humidity too high",
+			co2:         600,
+			temperature: float64Ptr(21),
+			humidity:    float64Ptr(65),
+			wantLevel:   1,
+		},
+		{
+			desc:        "worst input wins",
+			co2:         1200,
+			temperature: float64Ptr(27),
+			humidity:    float64Ptr(50),
+			wantLevel:   2,
+		},
+		{
+			desc:      "only humidity",
+			co2:       600,
+			humidity:  float64Ptr(75),
+			wantLevel: 2,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			level := DefaultComfortThresholds.level(tc.co2, tc.temperature, tc.humidity)
+			if level != tc.wantLevel {
+				t.Errorf("got level %d, want %d", level, tc.wantLevel)
+			}
+		})
+	}
+}
+
+func TestComfortThresholdsSet(t *testing.T) {
+	tt := []struct {
+		desc    string
+		name    string
+		limits  ComfortLimits
+		wantErr bool
+	}{
+		{
+			desc:   "increasing",
+			name:   "co2",
+			limits: ComfortLimits{800, 1200, 1600},
+		},
+		{
+			desc:   "decreasing lower limit",
+			name:   "temperature_low",
+			limits: ComfortLimits{20, 18, 16},
+		},
+		{
+			desc:    "lower limit increasing",
+			name:    "humidity_low",
+			limits:  ComfortLimits{20, 30, 40},
+			wantErr: true,
+		},
+		{
+			desc:    "upper limit not increasing",
+			name:    "humidity_high",
+			limits:  ComfortLimits{60, 60, 80},
+			wantErr: true,
+		},
+		{
+			desc:    "unknown name",
+			name:    "noise",
+			limits:  ComfortLimits{40, 50, 60},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			thresholds := DefaultComfortThresholds
+			err := thresholds.Set(tc.name, tc.limits)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("got error %v, want error %v", err, tc.wantErr)
+			}
+
+			if err == nil && *comfortThresholdFields(&thresholds)[tc.name] != tc.limits {
+				t.Errorf("got limits %v, want %v", *comfortThresholdFields(&thresholds)[tc.name], tc.limits)
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package collector
+
+import (
+	netatmo "github.com/exzz/netatmo-api-go"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// cachedDevice contains the fields of a device which are used by the metrics. It is the format of the compact
+// cache and of the cache file, so that neither of them depends on the remaining fields of the API response.
+//
+// Fields used by new metrics need to be added here as well, otherwise the metrics are missing when the compact
+// cache is enabled or the data is restored from the cache file.
+type cachedDevice struct {
+	ID             string              `json:"id"`
+	StationName    string              `json:"station_name,omitempty"`
+	ModuleName     string              `json:"module_name,omitempty"`
+	Type           string              `json:"type,omitempty"`
+	BatteryPercent *int32              `json:"battery_percent,omitempty"`
+	WifiStatus     *int32              `json:"wifi_status,omitempty"`
+	RFStatus       *int32              `json:"rf_status,omitempty"`
+	Data           cachedDashboardData `json:"data"`
+	Modules        []cachedDevice      `json:"modules,omitempty"`
+}
+
+type cachedDashboardData struct {
+	Temperature      *float32 `json:"temperature,omitempty"`
+	Humidity         *int32   `json:"humidity,omitempty"`
+	CO2              *int32   `json:"co2,omitempty"`
+	Noise            *int32   `json:"noise,omitempty"`
+	Pressure         *float32 `json:"pressure,omitempty"`
+	AbsolutePressure *float32 `json:"absolute_pressure,omitempty"`
+	Rain             *float32 `json:"rain,omitempty"`
+	Rain1Hour        *float32 `json:"rain_1h,omitempty"`
+	Rain1Day         *float32 `json:"rain_today,omitempty"`
+	WindAngle        *int32   `json:"wind_angle,omitempty"`
+	WindStrength     *int32   `json:"wind_strength,omitempty"`
+	GustStrength     *int32   `json:"gust_strength,omitempty"`
+	HealthIdx        *int32   `json:"health_idx,omitempty"`
+	LastMeasure      *int64   `json:"last_measure,omitempty"`
+}
+
+// keepAll is used as the filter of newCachedDevices to keep the fields of all metrics.
+func keepAll(...*prometheus.Desc) bool {
+	return true
+}
+
+// newCachedDevices converts the devices to the cache format. Fields are only kept, if keep returns true for
+// at least one of the metrics using them.
+func newCachedDevices(devices *netatmo.DeviceCollection, keep func(descs ...*prometheus.Desc) bool) []cachedDevice {
+	if devices == nil {
+		return nil
+	}
+
+	result := make([]cachedDevice, 0, len(devices.Devices()))
+	for _, dev := range devices.Devices() {
+		result = append(result, newCachedDevice(dev, keep))
+	}
+
+	return result
+}
+
+func newCachedDevice(device *netatmo.Device, keep func(descs ...*prometheus.Desc) bool) cachedDevice {
+	data := device.DashboardData
+	result := cachedDevice{
+		ID:          device.ID,
+		StationName: device.StationName, //nolint: staticcheck
+		ModuleName:  device.ModuleName,
+		Type:        device.Type,
+		Data: cachedDashboardData{
+			LastMeasure: data.LastMeasure,
+		},
+	}
+
+	if keep(batteryDesc) {
+		result.BatteryPercent = device.BatteryPercent
+	}
+	if keep(wifiDesc) {
+		result.WifiStatus = device.WifiStatus
+	}
+	if keep(rfDesc) {
+		result.RFStatus = device.RFStatus
+	}
+
+	if keep(append(temperatureDescs(), absoluteHumidityDesc, comfortDesc)...) {
+		result.Data.Temperature = data.Temperature
+	}
+	if keep(append(derivedTemperatureDescs(), humidityDesc, absoluteHumidityDesc, comfortDesc)...) {
+		result.Data.Humidity = data.Humidity
+	}
+	if keep(cotwoDesc, comfortDesc) {
+		result.Data.CO2 = data.CO2
+	}
+	if keep(noiseDesc) {
+		result.Data.Noise = data.Noise
+	}
+	if keep(pressureDesc, pressureSeaLevelDesc) {
+		result.Data.Pressure = data.Pressure
+	}
+	if keep(absolutePressureDesc) {
+		result.Data.AbsolutePressure = data.AbsolutePressure
+	}
+	if keep(rainDesc) {
+		result.Data.Rain = data.Rain
+	}
+	if keep(rainHourDesc) {
+		result.Data.Rain1Hour = data.Rain1Hour
+	}
+	if keep(rainDayDesc) {
+		result.Data.Rain1Day = data.Rain1Day
+	}
+	if keep(windDirectionDesc) {
+		result.Data.WindAngle = data.WindAngle
+	}
+	if keep(windStrengthDescs()...) {
+		result.Data.WindStrength = data.WindStrength
+	}
+	if keep(gustStrengthDescs()...) {
+		result.Data.GustStrength = data.GustStrength
+	}
+	if keep(healthIndexDesc) {
+		result.Data.HealthIdx = data.HealthIdx
+	}
+
+	for _, module := range device.LinkedModules {
+		result.Modules = append(result.Modules, newCachedDevice(module, keep))
+	}
+
+	return result
+}
+
+// cachedCollection converts the cached devices back to the format used for creating the metrics.
+func cachedCollection(devices []cachedDevice) *netatmo.DeviceCollection {
+	if devices == nil {
+		return nil
+	}
+
+	collection := &netatmo.DeviceCollection{}
+	collection.Body.Devices = make([]*netatmo.Device, 0, len(devices))
+	for _, dev := range devices {
+		collection.Body.Devices = append(collection.Body.Devices, dev.device())
+	}
+
+	return collection
+}
+
+func (d cachedDevice) device() *netatmo.Device {
+	result := &netatmo.Device{
+		ID:             d.ID,
+		StationName:    d.StationName,
+		ModuleName:     d.ModuleName,
+		Type:           d.Type,
+		BatteryPercent: d.BatteryPercent,
+		WifiStatus:     d.WifiStatus,
+		RFStatus:       d.RFStatus,
+		DashboardData: netatmo.DashboardData{
+			Temperature:      d.Data.Temperature,
+			Humidity:         d.Data.Humidity,
+			CO2:              d.Data.CO2,
+			Noise:            d.Data.Noise,
+			Pressure:         d.Data.Pressure,
+			AbsolutePressure: d.Data.AbsolutePressure,
+			Rain:             d.Data.Rain,
+			Rain1Hour:        d.Data.Rain1Hour,
+			Rain1Day:         d.Data.Rain1Day,
+			WindAngle:        d.Data.WindAngle,
+			WindStrength:     d.Data.WindStrength,
+			GustStrength:     d.Data.GustStrength,
+			HealthIdx:        d.Data.HealthIdx,
+			LastMeasure:      d.Data.LastMeasure,
+		},
+	}
+
+	for _, module := range d.Modules {
+		result.LinkedModules = append(result.LinkedModules, module.device())
+	}
+
+	return result
+}
+
+// compactDevices creates a copy of the devices, which only contains the fields needed for the enabled metrics.
+// The copy is created from the cache format, so it does not reference the original devices and the memory of
+// the full API response can be released.
+func (c *NetatmoCollector) compactDevices(devices *netatmo.DeviceCollection) *netatmo.DeviceCollection {
+	return cachedCollection(newCachedDevices(devices, func(descs ...*prometheus.Desc) bool {
+		for _, desc := range descs {
+			if c.metricEnabled(desc) {
+				return true
+			}
+		}
+
+		return false
+	}))
+}
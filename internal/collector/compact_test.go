@@ -0,0 +1,206 @@
+package collector
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"runtime"
+	"testing"
+	"time"
+
+	netatmo "github.com/exzz/netatmo-api-go"
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+	"github.com/sirupsen/logrus"
+)
+
+// largeCollection creates a synthetic collection with the given number of stations, each having an outdoor,
+// a wind, a rain and two indoor modules.
+func largeCollection(stations int) *netatmo.DeviceCollection {
+	module := func(id, name, moduleType string, data netatmo.DashboardData) *netatmo.Device {
+		data.LastMeasure = int64Ptr(3500)
+		return &netatmo.Device{
+			ID:             id,
+			ModuleName:     name,
+			Type:           moduleType,
+			BatteryPercent: int32Ptr(80),
+			RFStatus:       int32Ptr(70),
+			DashboardData:  data,
+		}
+	}
+
+	dc := &netatmo.DeviceCollection{}
+	for i := 0; i < stations; i++ {
+		id := func(n int) string {
+			return fmt.Sprintf("70:ee:50:%02x:%02x:%02x", i>>8, i&0xff, n)
+		}
+
+		dc.Body.Devices = append(dc.Body.Devices, &netatmo.Device{
+			ID:          id(0),
+			StationName: fmt.Sprintf("Station %d", i),
+			ModuleName:  "Living Room",
+			Type:        "NAMain",
+			WifiStatus:  int32Ptr(50),
+			DashboardData: netatmo.DashboardData{
+				Temperature:      float32Ptr(21),
+				Humidity:         int32Ptr(45),
+				CO2:              int32Ptr(600),
+				Noise:            int32Ptr(35),
+				Pressure:         float32Ptr(1013),
+				AbsolutePressure: float32Ptr(980),
+				LastMeasure:      int64Ptr(3500),
+			},
+			LinkedModules: []*netatmo.Device{
+				module(id(1), "Outside", "NAModule1", netatmo.DashboardData{Temperature: float32Ptr(10), Humidity: int32Ptr(80)}),
+				module(id(2), "Wind", "NAModule2", netatmo.DashboardData{WindAngle: int32Ptr(180), WindStrength: int32Ptr(12)}),
+				module(id(3), "Rain", "NAModule3", netatmo.DashboardData{Rain: float32Ptr(0.5)}),
+				module(id(4), "Bedroom", "NAModule4", netatmo.DashboardData{Temperature: float32Ptr(19), Humidity: int32Ptr(50), CO2: int32Ptr(800)}),
+				module(id(5), "Kitchen", "NAModule4", netatmo.DashboardData{Temperature: float32Ptr(22), Humidity: int32Ptr(55), CO2: int32Ptr(700)}),
+			},
+		})
+	}
+
+	return dc
+}
+
+func newCompactTestCollector(stations int, compact bool, disabledMetrics map[string]bool) *NetatmoCollector {
+	c := New(context.Background(), logrus.New(), func() (*netatmo.DeviceCollection, error) {
+		return largeCollection(stations), nil
+	}, time.Hour, time.Hour)
+	c.clock = func() time.Time {
+		return time.Unix(3600, 0)
+	}
+	c.CompactCache = compact
+	c.DisabledMetrics = disabledMetrics
+	c.RefreshData(c.clock())
+
+	return c
+}
+
+func gatherText(t *testing.T, c prometheus.Collector) string {
+	t.Helper()
+
+	registry := prometheus.NewPedanticRegistry()
+	registry.MustRegister(c)
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("error gathering metrics: %s", err)
+	}
+
+	var buf bytes.Buffer
+	for _, family := range families {
+		if _, err := expfmt.MetricFamilyToText(&buf, family); err != nil {
+			t.Fatalf("error formatting metrics: %s", err)
+		}
+	}
+
+	return buf.String()
+}
+
+func TestNetatmoCollector_CollectCompactCache(t *testing.T) {
+	disabledMetrics, _ := MetricFilter(nil, []string{"netatmo_aircare_co2_ppm", "netatmo_aircare_rf_signal_strength"})
+
+	tt := []struct {
+		desc            string
+		disabledMetrics map[string]bool
+	}{
+		{
+			desc: "all metrics",
+		},
+		{
+			desc:            "disabled metrics",
+			disabledMetrics: disabledMetrics,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			full := newCompactTestCollector(2, false, tc.disabledMetrics)
+			compact := newCompactTestCollector(2, true, tc.disabledMetrics)
+
+			if diff := cmp.Diff(gatherText(t, full), gatherText(t, compact)); diff != "" {
+				t.Errorf("metrics differ: %s", diff)
+			}
+		})
+	}
+}
+
+func TestCompactDevices(t *testing.T) {
+	disabledMetrics, _ := MetricFilter([]string{"netatmo_aircare_temperature_celsius"}, nil)
+	c := &NetatmoCollector{
+		DisabledMetrics: disabledMetrics,
+	}
+
+	devices := largeCollection(1)
+	got := c.compactDevices(devices)
+
+	want := &netatmo.DeviceCollection{}
+	want.Body.Devices = []*netatmo.Device{
+		{
+			ID:          devices.Devices()[0].ID,
+			StationName: "Station 0",
+			ModuleName:  "Living Room",
+			Type:        "NAMain",
+			DashboardData: netatmo.DashboardData{
+				Temperature: float32Ptr(21),
+				LastMeasure: int64Ptr(3500),
+			},
+		},
+	}
+	for _, module := range devices.Devices()[0].LinkedModules {
+		want.Body.Devices[0].LinkedModules = append(want.Body.Devices[0].LinkedModules, &netatmo.Device{
+			ID:         module.ID,
+			ModuleName: module.ModuleName,
+			Type:       module.Type,
+			DashboardData: netatmo.DashboardData{
+				Temperature: module.DashboardData.Temperature,
+				LastMeasure: int64Ptr(3500),
+			},
+		})
+	}
+
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("devices differ: %s", diff)
+	}
+
+	if got.Devices()[0] == devices.Devices()[0] {
+		t.Error("compact devices reference the original devices")
+	}
+}
+
+// BenchmarkCompactCache reports the memory retained by the cached data of a large account as "cached-bytes".
+func BenchmarkCompactCache(b *testing.B) {
+	temperatureOnly, _ := MetricFilter([]string{"netatmo_aircare_temperature_celsius"}, nil)
+
+	for _, bc := range []struct {
+		desc            string
+		compact         bool
+		disabledMetrics map[string]bool
+	}{
+		{desc: "full"},
+		{desc: "compact", compact: true},
+		{desc: "compact temperature only", compact: true, disabledMetrics: temperatureOnly},
+	} {
+		bc := bc
+		b.Run(bc.desc, func(b *testing.B) {
+			var retained uint64
+			for i := 0; i < b.N; i++ {
+				var before, after runtime.MemStats
+				runtime.GC()
+				runtime.ReadMemStats(&before)
+
+				c := newCompactTestCollector(100, bc.compact, bc.disabledMetrics)
+
+				runtime.GC()
+				runtime.ReadMemStats(&after)
+				retained += after.HeapAlloc - before.HeapAlloc
+				runtime.KeepAlive(c)
+			}
+			b.ReportMetric(float64(retained)/float64(b.N), "cached-bytes")
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package collector
+
+import "math"
+
+const (
+	// Coefficients for the Magnus formula (Sonntag 1990), valid between -45°C and 60°C.
+	magnusA = 17.62
+	magnusB = 243.12
+
+	// Molar mass of water divided by the universal gas constant (g*K/J), scaled for hPa.
+	waterVaporConstant = 216.74
+
+	// Constants of the international barometric formula, which assumes the standard atmosphere.
+	barometricHeight   = 44330
+	barometricExponent = 1 / 5.255
+)
+
+// dewPoint calculates the dew point in celsius using the Magnus formula.
+// The second return value is false if no dew point can be calculated for the inputs.
+func dewPoint(temperature, humidity float64) (float64, bool) {
+	if humidity <= 0 {
+		return 0, false
+	}
+
+	gamma := math.Log(humidity/100) + magnusA*temperature/(magnusB+temperature)
+	return magnusB * gamma / (magnusA - gamma), true
+}
+
+// absoluteHumidity calculates the absolute humidity in grams per cubic meter from the saturation vapor
+// pressure given by the Magnus formula and the ideal gas law.
+func absoluteHumidity(temperature, humidity float64) float64 {
+	saturationPressure := 6.112 * math.Exp(magnusA*temperature/(magnusB+temperature))
+	vaporPressure := saturationPressure * humidity / 100
+	return waterVaporConstant * vaporPressure / (273.15 + temperature)
+}
+
+// heatIndex calculates the "feels like" temperature in celsius using the regression by Rothfusz used by the NOAA.
+// The regression is only valid for temperatures of about 27°C (80°F) and above, so for lower temperatures the
+// temperature itself is returned.
+func heatIndex(temperature, humidity float64) float64 {
+	t := celsiusToFahrenheit(temperature)
+	if t < 80 {
+		return temperature
+	}
+
+	rh := humidity
+	hi := -42.379 + 2.04901523*t + 10.14333127*rh -
+		0.22475541*t*rh - 0.00683783*t*t - 0.05481717*rh*rh +
+		0.00122874*t*t*rh + 0.00085282*t*rh*rh - 0.00000199*t*t*rh*rh
+
+	switch {
+	case rh < 13 && t <= 112:
+		hi -= (13 - rh) / 4 * math.Sqrt((17-math.Abs(t-95))/17)
+	case rh > 85 && t <= 87:
+		hi += (rh - 85) / 10 * (87 - t) / 5
+	}
+
+	return fahrenheitToCelsius(hi)
+}
+
+// altitude calculates the altitude in meters, which the correction from the absolute pressure to the sea level
+// pressure is based on, using the international barometric formula. The second return value is false if no
+// altitude can be calculated for the inputs.
+func altitude(seaLevelPressure, absolutePressure float64) (float64, bool) {
+	if seaLevelPressure <= 0 || absolutePressure <= 0 {
+		return 0, false
+	}
+
+	return barometricHeight * (1 - math.Pow(absolutePressure/seaLevelPressure, barometricExponent)), true
+}
+
+func celsiusToFahrenheit(c float64) float64 {
+	return c*9/5 + 32
+}
+
+func fahrenheitToCelsius(f float64) float64 {
+	return (f - 32) * 5 / 9
+}
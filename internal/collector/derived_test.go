@@ -0,0 +1,208 @@
+package collector
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDewPoint(t *testing.T) {
+	tt := []struct {
+		desc        string
+		temperature float64
+		humidity    float64
+		wantOk      bool
+		wantValue   float64
+	}{
+		{
+			desc:        "saturated",
+			temperature: 15,
+			humidity:    100,
+			wantOk:      true,
+			wantValue:   15,
+		},
+		{
+			desc:        "indoor",
+			temperature: 20,
+			humidity:    50,
+			wantOk:      true,
+			wantValue:   9.26,
+		},
+		{
+			desc:        "summer",
+			temperature: 30,
+			humidity:    70,
+			wantOk:      true,
+			wantValue:   23.93,
+		},
+		{
+			desc:        "below freezing",
+			temperature: -10,
+			humidity:    80,
+			wantOk:      true,
+			wantValue:   -12.80,
+		},
+		{
+			desc:        "zero humidity",
+			temperature: 20,
+			humidity:    0,
+			wantOk:      false,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			value, ok := dewPoint(tc.temperature, tc.humidity)
+			if ok != tc.wantOk {
+				t.Fatalf("got ok %v, want %v", ok, tc.wantOk)
+			}
+
+			if math.Abs(value-tc.wantValue) > 0.01 {
+				t.Errorf("got value %f, want %f", value, tc.wantValue)
+			}
+		})
+	}
+}
+
+func TestAbsoluteHumidity(t *testing.T) {
+	tt := []struct {
+		desc        string
+		temperature float64
+		humidity    float64
+		wantValue   float64
+	}{
+		{
+			desc:        "freezing saturated",
+			temperature: 0,
+			humidity:    100,
+			wantValue:   4.85,
+		},
+		{
+			desc:        "indoor",
+			temperature: 20,
+			humidity:    50,
+			wantValue:   8.65,
+		},
+		{
+			desc:        "summer",
+			temperature: 30,
+			humidity:    80,
+			wantValue:   24.30,
+		},
+		{
+			desc:        "dry",
+			temperature: 25,
+			humidity:    0,
+			wantValue:   0,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			// Reference values are taken from published tables, which are rounded.
+			value := absoluteHumidity(tc.temperature, tc.humidity)
+			if math.Abs(value-tc.wantValue) > 0.1 {
+				t.Errorf("got value %f, want %f", value, tc.wantValue)
+			}
+		})
+	}
+}
+
+func TestHeatIndex(t *testing.T) {
+	tt := []struct {
+		desc        string
+		temperature float64
+		humidity    float64
+		wantValue   float64
+	}{
+		{
+			desc:        "below threshold",
+			temperature: 20,
+			humidity:    90,
+			wantValue:   20,
+		},
+		{
+			desc:        "90F 70%",
+			temperature: fahrenheitToCelsius(90),
+			humidity:    70,
+			wantValue:   fahrenheitToCelsius(106),
+		},
+		{
+			desc:        "100F 40%",
+			temperature: fahrenheitToCelsius(100),
+			humidity:    40,
+			wantValue:   fahrenheitToCelsius(109),
+		},
+		{
+			desc:        "86F 90%",
+			temperature: fahrenheitToCelsius(86),
+			humidity:    90,
+			wantValue:   fahrenheitToCelsius(105),
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			// Reference values are taken from the NOAA heat index chart, which is rounded to full degrees fahrenheit.
+			value := heatIndex(tc.temperature, tc.humidity)
+			if math.Abs(value-tc.wantValue) > 0.5 {
+				t.Errorf("got value %f, want %f", value, tc.wantValue)
+			}
+		})
+	}
+}
+
+func TestAltitude(t *testing.T) {
+	tt := []struct {
+		desc             string
+		seaLevelPressure float64
+		absolutePressure float64
+		wantOk           bool
+		wantValue        float64
+	}{
+		{
+			desc:             "sea level",
+			seaLevelPressure: 1013.25,
+			absolutePressure: 1013.25,
+			wantOk:           true,
+			wantValue:        0,
+		},
+		{
+			desc:             "500 meters",
+			seaLevelPressure: 1013.25,
+			absolutePressure: 954.6,
+			wantOk:           true,
+			wantValue:        500.15,
+		},
+		{
+			desc:             "no pressure",
+			seaLevelPressure: 0,
+			absolutePressure: 980,
+			wantOk:           false,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			value, ok := altitude(tc.seaLevelPressure, tc.absolutePressure)
+			if ok != tc.wantOk {
+				t.Fatalf("got ok %v, want %v", ok, tc.wantOk)
+			}
+
+			if math.Abs(value-tc.wantValue) > 0.01 {
+				t.Errorf("got value %f, want %f", value, tc.wantValue)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package collector
+
+import (
+	"sort"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// MetricDescription describes a metric which can be exported by the collector.
+type MetricDescription struct {
+	Name   string   `json:"name"`
+	Help   string   `json:"help"`
+	Labels []string `json:"labels"`
+}
+
+// Descriptions returns the metrics declared by Describe using the current configuration sorted by name. They
+// do not depend on the cached data, so they also contain the metrics for which no data is available.
+func (c *NetatmoCollector) Descriptions() []MetricDescription {
+	dChan := make(chan *prometheus.Desc)
+	go func() {
+		c.Describe(dChan)
+		close(dChan)
+	}()
+
+	var descs []*prometheus.Desc
+	for desc := range dChan {
+		descs = append(descs, desc)
+	}
+
+	// The renamed descriptors are created when describing them, so they are looked up afterwards.
+	original := make(map[*prometheus.Desc]*prometheus.Desc, len(c.renamed))
+	for desc, renamed := range c.renamed {
+		original[renamed] = desc
+	}
+
+	seen := make(map[*prometheus.Desc]bool, len(descs))
+	result := make([]MetricDescription, 0, len(descs))
+	for _, desc := range descs {
+		if seen[desc] {
+			continue
+		}
+		seen[desc] = true
+
+		spec, ok := descSpecs[desc]
+		if orig, renamed := original[desc]; renamed {
+			spec, ok = descSpecs[orig].renamed(c.LabelNames, c.ModuleIDLabel), true
+		}
+		if !ok {
+			continue
+		}
+
+		result = append(result, MetricDescription{
+			Name:   spec.fqName,
+			Help:   spec.help,
+			Labels: append([]string{}, spec.labels...),
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+
+	return result
+}
@@ -0,0 +1,107 @@
+package collector
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	netatmo "github.com/exzz/netatmo-api-go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+func TestNetatmoCollector_Descriptions(t *testing.T) {
+	tt := []struct {
+		desc      string
+		configure func(c *NetatmoCollector)
+	}{
+		{
+			desc:      "default",
+			configure: func(c *NetatmoCollector) {},
+		},
+		{
+			desc: "optional metrics",
+			configure: func(c *NetatmoCollector) {
+				c.AverageWindow = 3
+				c.SignalTrend = true
+				c.ConvenienceMetrics = true
+				c.EnableHomeCoach = true
+				c.TemperatureUnits = []TemperatureUnit{TemperatureUnitCelsius, TemperatureUnitFahrenheit}
+			},
+		},
+		{
+			desc: "renamed labels",
+			configure: func(c *NetatmoCollector) {
+				c.LabelNames = LabelNames{
+					Station: "location",
+					Module:  "sensor",
+				}
+				c.ModuleIDLabel = true
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			c := New(context.Background(), logrus.New(), func() (*netatmo.DeviceCollection, error) {
+				return nil, nil
+			}, time.Minute, time.Hour)
+			tc.configure(c)
+
+			dChan := make(chan *prometheus.Desc)
+			go func() {
+				c.Describe(dChan)
+				close(dChan)
+			}()
+			declared := make(map[string]bool)
+			for desc := range dChan {
+				declared[desc.String()] = true
+			}
+
+			descriptions := c.Descriptions()
+			if len(descriptions) != len(declared) {
+				t.Errorf("got %d descriptions, want %d", len(descriptions), len(declared))
+			}
+
+			for _, description := range descriptions {
+				want := prometheus.NewDesc(description.Name, description.Help, description.Labels, nil).String()
+				if !declared[want] {
+					t.Errorf("description of %s does not match a declared descriptor: %s", description.Name, want)
+				}
+			}
+
+			for i := 1; i < len(descriptions); i++ {
+				if descriptions[i-1].Name >= descriptions[i].Name {
+					t.Errorf("descriptions not sorted: %s before %s", descriptions[i-1].Name, descriptions[i].Name)
+				}
+			}
+		})
+	}
+}
+
+func TestNetatmoCollector_DescriptionsLabels(t *testing.T) {
+	c := New(context.Background(), logrus.New(), func() (*netatmo.DeviceCollection, error) {
+		return nil, nil
+	}, time.Minute, time.Hour)
+	c.LabelNames = LabelNames{
+		Station: "location",
+		Module:  "sensor",
+	}
+
+	for _, description := range c.Descriptions() {
+		if description.Name != "netatmo_aircare_co2_ppm" {
+			continue
+		}
+
+		if got, want := strings.Join(description.Labels, ","), "sensor,location,module_type,role"; got != want {
+			t.Errorf("got labels %q, want %q", got, want)
+		}
+		return
+	}
+
+	t.Error("description of netatmo_aircare_co2_ppm missing")
+}
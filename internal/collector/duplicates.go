@@ -0,0 +1,69 @@
+package collector
+
+import netatmo "github.com/exzz/netatmo-api-go"
+
+var duplicateModulesDesc = newDesc(
+	prefix+"duplicate_modules_total",
+	"Counts the entries of stations and modules which were dropped, because the API returned their ID more than once.",
+	nil)
+
+// removeDuplicates returns the devices with only one entry for every station ID and every module ID within a
+// station, because duplicated entries would create the same series more than once. Of the duplicates, the entry
+// with the newest measurement is kept. The devices passed in are not modified and the number of dropped entries
+// is returned as well.
+func removeDuplicates(devices *netatmo.DeviceCollection) (*netatmo.DeviceCollection, int) {
+	if devices == nil {
+		return nil, 0
+	}
+
+	stations, dropped := uniqueDevices(devices.Devices())
+	result := &netatmo.DeviceCollection{}
+	for _, station := range stations {
+		modules, droppedModules := uniqueDevices(station.LinkedModules)
+		if droppedModules > 0 {
+			copied := *station
+			copied.LinkedModules = modules
+			station = &copied
+			dropped += droppedModules
+		}
+
+		result.Body.Devices = append(result.Body.Devices, station)
+	}
+
+	if dropped == 0 {
+		return devices, 0
+	}
+
+	return result, dropped
+}
+
+// uniqueDevices returns the devices with only one entry per ID, keeping the order of the first occurrence.
+func uniqueDevices(devices []*netatmo.Device) ([]*netatmo.Device, int) {
+	result := make([]*netatmo.Device, 0, len(devices))
+	index := make(map[string]int, len(devices))
+	dropped := 0
+	for _, device := range devices {
+		i, ok := index[device.ID]
+		if !ok {
+			index[device.ID] = len(result)
+			result = append(result, device)
+			continue
+		}
+
+		dropped++
+		if measureTime(device) > measureTime(result[i]) {
+			result[i] = device
+		}
+	}
+
+	return result, dropped
+}
+
+// measureTime returns the time of the last measurement of the device or zero, if it has none.
+func measureTime(device *netatmo.Device) int64 {
+	if device.DashboardData.LastMeasure == nil {
+		return 0
+	}
+
+	return *device.DashboardData.LastMeasure
+}
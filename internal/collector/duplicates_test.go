@@ -0,0 +1,92 @@
+package collector
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	netatmo "github.com/exzz/netatmo-api-go"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+)
+
+func TestNetatmoCollector_CollectDuplicateModules(t *testing.T) {
+	module := func(temperature float32, lastMeasure int64) *netatmo.Device {
+		return &netatmo.Device{
+			ID:         "aa:bb:cc:dd:ee:f1",
+			ModuleName: "Outside",
+			Type:       "NAModule1",
+			DashboardData: netatmo.DashboardData{
+				Temperature: &temperature,
+				LastMeasure: &lastMeasure,
+			},
+		}
+	}
+	dc := &netatmo.DeviceCollection{}
+	dc.Body.Devices = []*netatmo.Device{
+		{
+			ID:            "aa:bb:cc:dd:ee:f0",
+			ModuleName:    "Living Room",
+			StationName:   "Home",
+			Type:          "NAMain",
+			LinkedModules: []*netatmo.Device{module(12, 3500), module(10, 3000)},
+		},
+	}
+
+	c := New(context.Background(), logrus.New(), func() (*netatmo.DeviceCollection, error) {
+		return dc, nil
+	}, time.Minute, time.Hour)
+	c.clock = func() time.Time {
+		return time.Unix(3600, 0)
+	}
+
+	for i, wantDuplicates := range []string{"1", "2"} {
+		c.RefreshData(time.Unix(3600, 0))
+
+		want := `# HELP netatmo_aircare_temperature_celsius Temperature measurement in celsius
+# TYPE netatmo_aircare_temperature_celsius gauge
+netatmo_aircare_temperature_celsius{module="Outside",module_type="NAModule1",role="module",station="Home"} 12
+# HELP netatmo_duplicate_modules_total Counts the entries of stations and modules which were dropped, because the API returned their ID more than once.
+# TYPE netatmo_duplicate_modules_total counter
+netatmo_duplicate_modules_total ` + wantDuplicates + "\n"
+		if err := testutil.CollectAndCompare(c, strings.NewReader(want), "netatmo_aircare_temperature_celsius", "netatmo_duplicate_modules_total"); err != nil {
+			t.Errorf("refresh %d: %s", i, err)
+		}
+	}
+
+	if got := len(dc.Body.Devices[0].LinkedModules); got != 2 {
+		t.Errorf("got %d modules in the response, want it unmodified", got)
+	}
+}
+
+func TestRemoveDuplicates(t *testing.T) {
+	lastMeasure := func(value int64) *int64 {
+		return &value
+	}
+	dc := &netatmo.DeviceCollection{}
+	dc.Body.Devices = []*netatmo.Device{
+		{ID: "aa:bb:cc:dd:ee:f0", StationName: "First", DashboardData: netatmo.DashboardData{LastMeasure: lastMeasure(100)}},
+		{ID: "aa:bb:cc:dd:ee:e0", StationName: "Second"},
+		{ID: "aa:bb:cc:dd:ee:f0", StationName: "Newer", DashboardData: netatmo.DashboardData{LastMeasure: lastMeasure(200)}},
+	}
+
+	got, dropped := removeDuplicates(dc)
+	if dropped != 1 {
+		t.Errorf("got %d dropped, want %d", dropped, 1)
+	}
+
+	var names []string
+	for _, device := range got.Devices() {
+		names = append(names, device.StationName)
+	}
+	if strings.Join(names, ",") != "Newer,Second" {
+		t.Errorf("got stations %v, want [Newer Second]", names)
+	}
+
+	unique := &netatmo.DeviceCollection{}
+	unique.Body.Devices = dc.Body.Devices[:2]
+	if got, dropped := removeDuplicates(unique); got != unique || dropped != 0 {
+		t.Errorf("got %v and %d dropped, want unchanged devices", got, dropped)
+	}
+}
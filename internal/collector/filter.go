@@ -0,0 +1,76 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// sensorDescs contains the descriptors of all metrics created from sensor data in the order of declaration.
+	sensorDescs []*prometheus.Desc
+
+	// sensorDescNames maps the descriptors of the sensor metrics to their names.
+	sensorDescNames = make(map[*prometheus.Desc]string)
+)
+
+// newSensorDesc creates the descriptor of a metric created from sensor data. These metrics can be disabled by name.
+func newSensorDesc(name, help string) *prometheus.Desc {
+	fqName := sensorPrefix + name
+	desc := newLabelledDesc(fqName, help, varLabels)
+
+	sensorDescs = append(sensorDescs, desc)
+	sensorDescNames[desc] = fqName
+	return desc
+}
+
+// MetricFilter creates the set of disabled sensor metrics for the collector. If the list of enabled metrics is
+// not empty, all other metrics are disabled. Names which do not match any sensor metric are returned as unknown.
+func MetricFilter(enabled, disabled []string) (disabledMetrics map[string]bool, unknown []string) {
+	known := make(map[string]bool, len(sensorDescs))
+	for _, desc := range sensorDescs {
+		known[sensorDescNames[desc]] = true
+	}
+
+	for _, name := range append(append([]string{}, enabled...), disabled...) {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+
+	disabledMetrics = make(map[string]bool)
+	if len(enabled) > 0 {
+		enabledSet := make(map[string]bool, len(enabled))
+		for _, name := range enabled {
+			enabledSet[name] = true
+		}
+
+		for name := range known {
+			if !enabledSet[name] {
+				disabledMetrics[name] = true
+			}
+		}
+	}
+
+	for _, name := range disabled {
+		if known[name] {
+			disabledMetrics[name] = true
+		}
+	}
+
+	return disabledMetrics, unknown
+}
+
+// metricEnabled returns false, if the descriptor belongs to a sensor metric which has been disabled, to a wind
+// or temperature metric using a unit which is not configured or to a Home Coach metric while Home Coach is not
+// enabled.
+func (c *NetatmoCollector) metricEnabled(desc *prometheus.Desc) bool {
+	if !c.windUnitEnabled(desc) || !c.temperatureUnitEnabled(desc) {
+		return false
+	}
+
+	if homeCoachDescs[desc] && !c.EnableHomeCoach {
+		return false
+	}
+
+	name, ok := sensorDescNames[desc]
+	return !ok || !c.DisabledMetrics[name]
+}
@@ -0,0 +1,168 @@
+package collector
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	netatmo "github.com/exzz/netatmo-api-go"
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+)
+
+func TestMetricFilter(t *testing.T) {
+	tt := []struct {
+		desc         string
+		enabled      []string
+		disabled     []string
+		wantDisabled []string
+		wantUnknown  []string
+	}{
+		{
+			desc:         "default",
+			wantDisabled: []string{},
+		},
+		{
+			desc:         "disabled",
+			disabled:     []string{"netatmo_aircare_noise_db", "netatmo_aircare_heat_index_celsius"},
+			wantDisabled: []string{"netatmo_aircare_heat_index_celsius", "netatmo_aircare_noise_db"},
+		},
+		{
+			desc:     "enabled",
+			enabled:  []string{"netatmo_aircare_temperature_celsius", "netatmo_aircare_co2_ppm"},
+			disabled: []string{"netatmo_aircare_co2_ppm"},
+			wantDisabled: func() []string {
+				var names []string
+				for _, desc := range sensorDescs {
+					if desc != tempDesc {
+						names = append(names, sensorDescNames[desc])
+					}
+				}
+				return names
+			}(),
+		},
+		{
+			desc:     "unknown",
+			enabled:  []string{"netatmo_aircare_temperature"},
+			disabled: []string{"netatmo_up"},
+			wantDisabled: func() []string {
+				var names []string
+				for _, desc := range sensorDescs {
+					names = append(names, sensorDescNames[desc])
+				}
+				return names
+			}(),
+			wantUnknown: []string{"netatmo_aircare_temperature", "netatmo_up"},
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			disabled, unknown := MetricFilter(tc.enabled, tc.disabled)
+
+			wantDisabled := make(map[string]bool)
+			for _, name := range tc.wantDisabled {
+				wantDisabled[name] = true
+			}
+			if diff := cmp.Diff(disabled, wantDisabled); diff != "" {
+				t.Errorf("disabled metrics differ: -got+want\n%s", diff)
+			}
+
+			if diff := cmp.Diff(unknown, tc.wantUnknown); diff != "" {
+				t.Errorf("unknown metrics differ: -got+want\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNetatmoCollector_CollectDisabledMetrics(t *testing.T) {
+	testDevices := &netatmo.DeviceCollection{}
+	testDevices.Body.Devices = []*netatmo.Device{
+		{
+			ID:          "aa:bb:cc:dd:ee:f0",
+			StationName: "Home",
+			Type:        "NAMain",
+			DashboardData: netatmo.DashboardData{
+				Temperature: float32Ptr(23),
+				CO2:         int32Ptr(500),
+				Noise:       int32Ptr(40),
+				LastMeasure: int64Ptr(3500),
+			},
+		},
+	}
+	mockClock := func() time.Time {
+		return time.Unix(3600, 0)
+	}
+	read := func() (*netatmo.DeviceCollection, error) {
+		return testDevices, nil
+	}
+
+	c := New(context.Background(), logrus.New(), read, time.Hour, time.Hour)
+	c.clock = mockClock
+	c.DisabledMetrics, _ = MetricFilter([]string{"netatmo_aircare_temperature_celsius", "netatmo_aircare_co2_ppm"}, nil)
+	c.RefreshData(mockClock())
+
+	expected := strings.NewReader(`# HELP netatmo_aircare_co2_ppm Carbondioxide measurement in parts per million
+# TYPE netatmo_aircare_co2_ppm gauge
+netatmo_aircare_co2_ppm{module="Home",module_type="NAMain",role="station",station="Home"} 500
+# HELP netatmo_aircare_temperature_celsius Temperature measurement in celsius
+# TYPE netatmo_aircare_temperature_celsius gauge
+netatmo_aircare_temperature_celsius{module="Home",module_type="NAMain",role="station",station="Home"} 23
+`)
+
+	if err := testutil.CollectAndCompare(c, expected, "netatmo_aircare_co2_ppm", "netatmo_aircare_noise_db", "netatmo_aircare_temperature_celsius", "netatmo_aircare_updated"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNetatmoCollector_DescribeHomeCoach(t *testing.T) {
+	tt := []struct {
+		desc            string
+		enableHomeCoach bool
+		wantHealthIndex bool
+	}{
+		{
+			desc:            "weather stations only",
+			enableHomeCoach: false,
+			wantHealthIndex: false,
+		},
+		{
+			desc:            "home coach enabled",
+			enableHomeCoach: true,
+			wantHealthIndex: true,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			c := New(context.Background(), logrus.New(), nil, time.Hour, time.Hour)
+			c.EnableHomeCoach = tc.enableHomeCoach
+
+			ch := make(chan *prometheus.Desc)
+			go func() {
+				c.Describe(ch)
+				close(ch)
+			}()
+
+			gotHealthIndex := false
+			for desc := range ch {
+				if strings.Contains(desc.String(), `"netatmo_aircare_health_index"`) {
+					gotHealthIndex = true
+				}
+			}
+
+			if gotHealthIndex != tc.wantHealthIndex {
+				t.Errorf("got health index described %v, want %v", gotHealthIndex, tc.wantHealthIndex)
+			}
+		})
+	}
+}
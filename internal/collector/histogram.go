@@ -0,0 +1,45 @@
+package collector
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// durationHistogram counts observations for a constant histogram metric. The bucket boundaries are passed
+// on every call, so that they can be configured after the collector has been created.
+type durationHistogram struct {
+	lock    sync.Mutex
+	count   uint64
+	sum     float64
+	buckets map[float64]uint64
+}
+
+func (h *durationHistogram) observe(upperBounds []float64, value float64) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if h.buckets == nil {
+		h.buckets = make(map[float64]uint64, len(upperBounds))
+	}
+
+	h.count++
+	h.sum += value
+	for _, bound := range upperBounds {
+		if value <= bound {
+			h.buckets[bound]++
+		}
+	}
+}
+
+func (h *durationHistogram) metric(desc *prometheus.Desc, upperBounds []float64) (prometheus.Metric, error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	buckets := make(map[float64]uint64, len(upperBounds))
+	for _, bound := range upperBounds {
+		buckets[bound] = h.buckets[bound]
+	}
+
+	return prometheus.NewConstHistogram(desc, h.count, h.sum, buckets)
+}
@@ -0,0 +1,49 @@
+package collector
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type histogramCollector struct {
+	histogram   *durationHistogram
+	upperBounds []float64
+}
+
+func (c histogramCollector) Describe(dChan chan<- *prometheus.Desc) {
+	dChan <- refreshDurationHistogramDesc
+}
+
+func (c histogramCollector) Collect(mChan chan<- prometheus.Metric) {
+	m, err := c.histogram.metric(refreshDurationHistogramDesc, c.upperBounds)
+	if err != nil {
+		panic(err)
+	}
+	mChan <- m
+}
+
+func TestDurationHistogram(t *testing.T) {
+	upperBounds := []float64{1, 5, 10}
+	h := &durationHistogram{}
+	for _, value := range []float64{0.5, 1, 3, 7, 20} {
+		h.observe(upperBounds, value)
+	}
+
+	want := `# HELP netatmo_refresh_duration_seconds Histogram of the time it took for refreshes to complete, even if they were unsuccessful.
+# TYPE netatmo_refresh_duration_seconds histogram
+netatmo_refresh_duration_seconds_bucket{le="1"} 2
+netatmo_refresh_duration_seconds_bucket{le="5"} 3
+netatmo_refresh_duration_seconds_bucket{le="10"} 4
+netatmo_refresh_duration_seconds_bucket{le="+Inf"} 5
+netatmo_refresh_duration_seconds_sum 31.5
+netatmo_refresh_duration_seconds_count 5
+`
+
+	err := testutil.CollectAndCompare(histogramCollector{h, upperBounds}, strings.NewReader(want))
+	if err != nil {
+		t.Errorf("histogram differs: %s", err)
+	}
+}
@@ -0,0 +1,134 @@
+package collector
+
+import (
+	netatmo "github.com/exzz/netatmo-api-go"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	stationLabel  = "station"
+	moduleLabel   = "module"
+	moduleIDLabel = "module_id"
+)
+
+// LabelNames contains the names of the labels identifying the station and the module of a metric.
+type LabelNames struct {
+	Station string
+	Module  string
+}
+
+// DefaultLabelNames contains the label names used when no others are configured.
+var DefaultLabelNames = LabelNames{
+	Station: stationLabel,
+	Module:  moduleLabel,
+}
+
+// descSpec contains what is needed for describing a descriptor or creating it again with other label names.
+type descSpec struct {
+	fqName string
+	help   string
+	labels []string
+}
+
+var (
+	// descSpecs contains all descriptors of the collector.
+	descSpecs = make(map[*prometheus.Desc]descSpec)
+
+	// labelledDescs contains all descriptors with a station or module label.
+	labelledDescs = make(map[*prometheus.Desc]descSpec)
+)
+
+// newDesc creates a descriptor of the collector, so that it can be listed using Descriptions.
+func newDesc(fqName, help string, variableLabels []string) *prometheus.Desc {
+	desc := prometheus.NewDesc(fqName, help, variableLabels, nil)
+	descSpecs[desc] = descSpec{
+		fqName: fqName,
+		help:   help,
+		labels: variableLabels,
+	}
+	return desc
+}
+
+// newLabelledDesc creates a descriptor, which carries a station or module label, so that it can be renamed.
+func newLabelledDesc(fqName, help string, variableLabels []string) *prometheus.Desc {
+	desc := newDesc(fqName, help, variableLabels)
+	labelledDescs[desc] = descSpecs[desc]
+	return desc
+}
+
+// renamedDescs creates the descriptors using the configured label names. If moduleID is true, the descriptors
+// with a module label get an additional label containing the ID of the module. It returns nil, if the default
+// names are used without the module ID.
+func renamedDescs(names LabelNames, moduleID bool) map[*prometheus.Desc]*prometheus.Desc {
+	if !moduleID && (names == DefaultLabelNames || names == (LabelNames{})) {
+		return nil
+	}
+
+	result := make(map[*prometheus.Desc]*prometheus.Desc, len(labelledDescs))
+	for desc, spec := range labelledDescs {
+		renamed := spec.renamed(names, moduleID)
+		result[desc] = prometheus.NewDesc(renamed.fqName, renamed.help, renamed.labels, nil)
+	}
+
+	return result
+}
+
+// renamed returns the specification using the configured label names and the module ID label, if enabled.
+func (s descSpec) renamed(names LabelNames, moduleID bool) descSpec {
+	labels := make([]string, 0, len(s.labels)+1)
+	for _, label := range s.labels {
+		switch {
+		case label == stationLabel && names.Station != "":
+			label = names.Station
+		case label == moduleLabel && names.Module != "":
+			label = names.Module
+		}
+		labels = append(labels, label)
+	}
+	if moduleID && hasLabel(s.labels, moduleLabel) {
+		labels = append(labels, moduleIDLabel)
+	}
+
+	return descSpec{
+		fqName: s.fqName,
+		help:   s.help,
+		labels: labels,
+	}
+}
+
+// desc returns the descriptor using the configured label names.
+func (c *NetatmoCollector) desc(desc *prometheus.Desc) *prometheus.Desc {
+	c.renameOnce.Do(func() {
+		c.renamed = renamedDescs(c.LabelNames, c.ModuleIDLabel)
+	})
+
+	if renamed, ok := c.renamed[desc]; ok {
+		return renamed
+	}
+
+	return desc
+}
+
+func hasLabel(labels []string, name string) bool {
+	for _, label := range labels {
+		if label == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// labelFunc returns the values of the labels identifying a module.
+type labelFunc func(device *netatmo.Device, moduleName, stationName, role string) []string
+
+// moduleLabels returns the values of the labels identifying a module in the order of varLabels. The ID of the
+// module is appended, if the module ID label is enabled.
+func (c *NetatmoCollector) moduleLabels(device *netatmo.Device, moduleName, stationName, role string) []string {
+	labels := []string{moduleName, stationName, device.Type, role}
+	if c.ModuleIDLabel {
+		labels = append(labels, device.ID)
+	}
+
+	return labels
+}
@@ -0,0 +1,193 @@
+package collector
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	netatmo "github.com/exzz/netatmo-api-go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+)
+
+func TestNetatmoCollector_CollectLabelNames(t *testing.T) {
+	testDevices := &netatmo.DeviceCollection{}
+	testDevices.Body.Devices = []*netatmo.Device{
+		{
+			ID:          "aa:bb:cc:dd:ee:f0",
+			ModuleName:  "Living Room",
+			StationName: "Home",
+			Type:        "NAMain",
+			DashboardData: netatmo.DashboardData{
+				Temperature: float32Ptr(23),
+				LastMeasure: int64Ptr(7000),
+			},
+		},
+	}
+
+	tt := []struct {
+		desc       string
+		labelNames LabelNames
+		moduleID   bool
+		wantLabels string
+	}{
+		{
+			desc:       "default",
+			labelNames: DefaultLabelNames,
+			wantLabels: `module="Living Room",module_type="NAMain",role="station",station="Home"`,
+		},
+		{
+			desc:       "not set",
+			labelNames: LabelNames{},
+			wantLabels: `module="Living Room",module_type="NAMain",role="station",station="Home"`,
+		},
+		{
+			desc: "renamed",
+			labelNames: LabelNames{
+				Station: "location",
+				Module:  "sensor",
+			},
+			wantLabels: `location="Home",module_type="NAMain",role="station",sensor="Living Room"`,
+		},
+		{
+			desc:       "module ID",
+			labelNames: DefaultLabelNames,
+			moduleID:   true,
+			wantLabels: `module="Living Room",module_id="aa:bb:cc:dd:ee:f0",module_type="NAMain",role="station",station="Home"`,
+		},
+		{
+			desc: "renamed with module ID",
+			labelNames: LabelNames{
+				Station: "location",
+				Module:  "sensor",
+			},
+			moduleID:   true,
+			wantLabels: `location="Home",module_id="aa:bb:cc:dd:ee:f0",module_type="NAMain",role="station",sensor="Living Room"`,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			c := New(context.Background(), logrus.New(), func() (*netatmo.DeviceCollection, error) {
+				return testDevices, nil
+			}, time.Hour, time.Hour)
+			c.LabelNames = tc.labelNames
+			c.ModuleIDLabel = tc.moduleID
+			c.clock = func() time.Time {
+				return time.Unix(7200, 0)
+			}
+			c.RefreshData(c.clock())
+
+			expected := `# HELP netatmo_aircare_temperature_celsius Temperature measurement in celsius
+# TYPE netatmo_aircare_temperature_celsius gauge
+netatmo_aircare_temperature_celsius{` + tc.wantLabels + `} 23
+`
+			if err := testutil.CollectAndCompare(c, strings.NewReader(expected), "netatmo_aircare_temperature_celsius"); err != nil {
+				t.Error(err)
+			}
+
+			registry := prometheus.NewPedanticRegistry()
+			if err := registry.Register(c); err != nil {
+				t.Fatalf("error registering collector: %s", err)
+			}
+
+			if _, err := registry.Gather(); err != nil {
+				t.Errorf("error gathering metrics: %s", err)
+			}
+		})
+	}
+}
+
+func TestNetatmoCollector_CollectSameModuleNames(t *testing.T) {
+	station := func(id, stationName string, temperature float32) *netatmo.Device {
+		return &netatmo.Device{
+			ID:          id + "0",
+			ModuleName:  "Indoor",
+			StationName: stationName,
+			Type:        "NAMain",
+			DashboardData: netatmo.DashboardData{
+				Temperature: float32Ptr(temperature),
+				LastMeasure: int64Ptr(7000),
+			},
+			LinkedModules: []*netatmo.Device{
+				{
+					ID:         id + "1",
+					ModuleName: "Indoor",
+					Type:       "NAModule4",
+					DashboardData: netatmo.DashboardData{
+						Temperature: float32Ptr(temperature + 1),
+						LastMeasure: int64Ptr(7000),
+					},
+				},
+			},
+		}
+	}
+	testDevices := &netatmo.DeviceCollection{}
+	testDevices.Body.Devices = []*netatmo.Device{
+		station("aa:bb:cc:dd:ee:f", "Home", 21),
+		station("aa:bb:cc:dd:ff:f", "Cabin", 15),
+	}
+
+	tt := []struct {
+		desc     string
+		moduleID bool
+		want     string
+	}{
+		{
+			desc: "station label",
+			want: `# HELP netatmo_aircare_temperature_celsius Temperature measurement in celsius
+# TYPE netatmo_aircare_temperature_celsius gauge
+netatmo_aircare_temperature_celsius{module="Indoor",module_type="NAMain",role="station",station="Cabin"} 15
+netatmo_aircare_temperature_celsius{module="Indoor",module_type="NAMain",role="station",station="Home"} 21
+netatmo_aircare_temperature_celsius{module="Indoor",module_type="NAModule4",role="module",station="Cabin"} 16
+netatmo_aircare_temperature_celsius{module="Indoor",module_type="NAModule4",role="module",station="Home"} 22
+`,
+		},
+		{
+			desc:     "module ID label",
+			moduleID: true,
+			want: `# HELP netatmo_aircare_temperature_celsius Temperature measurement in celsius
+# TYPE netatmo_aircare_temperature_celsius gauge
+netatmo_aircare_temperature_celsius{module="Indoor",module_id="aa:bb:cc:dd:ee:f0",module_type="NAMain",role="station",station="Home"} 21
+netatmo_aircare_temperature_celsius{module="Indoor",module_id="aa:bb:cc:dd:ee:f1",module_type="NAModule4",role="module",station="Home"} 22
+netatmo_aircare_temperature_celsius{module="Indoor",module_id="aa:bb:cc:dd:ff:f0",module_type="NAMain",role="station",station="Cabin"} 15
+netatmo_aircare_temperature_celsius{module="Indoor",module_id="aa:bb:cc:dd:ff:f1",module_type="NAModule4",role="module",station="Cabin"} 16
+`,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			c := New(context.Background(), logrus.New(), func() (*netatmo.DeviceCollection, error) {
+				return testDevices, nil
+			}, time.Hour, time.Hour)
+			c.ModuleIDLabel = tc.moduleID
+			c.clock = func() time.Time {
+				return time.Unix(7200, 0)
+			}
+			c.RefreshData(c.clock())
+
+			if err := testutil.CollectAndCompare(c, strings.NewReader(tc.want), "netatmo_aircare_temperature_celsius"); err != nil {
+				t.Error(err)
+			}
+
+			// The pedantic registry fails on duplicate series, so this also checks the metrics not compared above.
+			registry := prometheus.NewPedanticRegistry()
+			if err := registry.Register(c); err != nil {
+				t.Fatalf("error registering collector: %s", err)
+			}
+
+			if _, err := registry.Gather(); err != nil {
+				t.Errorf("error gathering metrics: %s", err)
+			}
+		})
+	}
+}
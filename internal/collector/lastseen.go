@@ -0,0 +1,72 @@
+package collector
+
+import (
+	"time"
+
+	netatmo "github.com/exzz/netatmo-api-go"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var moduleLastSeenDesc = newLabelledDesc(
+	prefix+"module_last_seen_seconds",
+	"Contains the time of the last refresh which included the module. Still present for the grace period after the module disappeared from the API.",
+	varLabels)
+
+// seenModule contains the labels of a module and the time it was last included in a refresh.
+type seenModule struct {
+	labelValues []string
+	seen        time.Time
+}
+
+// lastSeen tracks when the modules were last included in a refresh, keyed by the module ID. It is guarded by
+// the cacheLock of the collector.
+type lastSeen map[string]*seenModule
+
+// update marks all modules contained in devices as seen and removes the modules, which have not been seen
+// for longer than the grace period.
+func (l lastSeen) update(now time.Time, devices *netatmo.DeviceCollection, gracePeriod time.Duration, labels labelFunc) {
+	if devices != nil {
+		for _, dev := range devices.Devices() {
+			stationName := dev.StationName //nolint: staticcheck
+			l.see(now, dev.ID, labels(dev, deviceName(dev, stationName), stationName, roleStation))
+
+			for _, module := range stationModules(dev) {
+				l.see(now, module.ID, labels(module, deviceName(module, ""), stationName, roleModule))
+			}
+		}
+	}
+
+	for id, module := range l {
+		if now.Sub(module.seen) > gracePeriod {
+			delete(l, id)
+		}
+	}
+}
+
+func (l lastSeen) see(now time.Time, id string, labelValues []string) {
+	l[id] = &seenModule{
+		labelValues: labelValues,
+		seen:        now,
+	}
+}
+
+// collect emits the last-seen timestamps. Modules which are not part of the cached data anymore are reported
+// as offline for the grace period. The caller needs to hold the cacheLock.
+func (l lastSeen) collect(c *NetatmoCollector, ch chan<- prometheus.Metric) {
+	cached := make(map[string]bool)
+	if c.cachedData != nil {
+		for _, dev := range c.cachedData.Devices() {
+			cached[dev.ID] = true
+			for _, module := range stationModules(dev) {
+				cached[module.ID] = true
+			}
+		}
+	}
+
+	for id, module := range l {
+		c.sendTimestamp(ch, moduleLastSeenDesc, module.seen, module.labelValues...)
+		if !cached[id] {
+			c.sendMetric(ch, moduleOnlineDesc, prometheus.GaugeValue, 0, module.labelValues...)
+		}
+	}
+}
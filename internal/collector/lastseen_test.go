@@ -0,0 +1,88 @@
+package collector
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	netatmo "github.com/exzz/netatmo-api-go"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+)
+
+func TestNetatmoCollector_CollectModuleLastSeen(t *testing.T) {
+	outdoor := &netatmo.Device{
+		ID:         "aa:bb:cc:dd:ee:f1",
+		ModuleName: "Outside",
+		Type:       "NAModule1",
+	}
+	station := func(modules ...*netatmo.Device) *netatmo.DeviceCollection {
+		dc := &netatmo.DeviceCollection{}
+		dc.Body.Devices = []*netatmo.Device{
+			{
+				ID:            "aa:bb:cc:dd:ee:f0",
+				ModuleName:    "Living Room",
+				StationName:   "Home",
+				Type:          "NAMain",
+				LinkedModules: modules,
+			},
+		}
+		return dc
+	}
+
+	var devices *netatmo.DeviceCollection
+	now := time.Unix(3600, 0)
+	c := New(context.Background(), logrus.New(), func() (*netatmo.DeviceCollection, error) {
+		return devices, nil
+	}, time.Minute, time.Hour)
+	c.clock = func() time.Time {
+		return now
+	}
+	c.ModuleGracePeriod = time.Hour
+
+	const header = `# HELP netatmo_module_last_seen_seconds Contains the time of the last refresh which included the module. Still present for the grace period after the module disappeared from the API.
+# TYPE netatmo_module_last_seen_seconds gauge
+`
+	tt := []struct {
+		desc    string
+		devices *netatmo.DeviceCollection
+		refresh time.Time
+		want    string
+	}{
+		{
+			desc:    "all modules",
+			devices: station(outdoor),
+			refresh: time.Unix(3600, 0),
+			want: header + `netatmo_module_last_seen_seconds{module="Living Room",module_type="NAMain",role="station",station="Home"} 3600
+netatmo_module_last_seen_seconds{module="Outside",module_type="NAModule1",role="module",station="Home"} 3600
+`,
+		},
+		{
+			desc:    "module vanished",
+			devices: station(),
+			refresh: time.Unix(5400, 0),
+			want: header + `netatmo_module_last_seen_seconds{module="Living Room",module_type="NAMain",role="station",station="Home"} 5400
+netatmo_module_last_seen_seconds{module="Outside",module_type="NAModule1",role="module",station="Home"} 3600
+`,
+		},
+		{
+			desc:    "grace period elapsed",
+			devices: station(),
+			refresh: time.Unix(7201, 0),
+			want: header + `netatmo_module_last_seen_seconds{module="Living Room",module_type="NAMain",role="station",station="Home"} 7201
+`,
+		},
+	}
+
+	// The steps build on each other, so they can not run in parallel.
+	for _, tc := range tt {
+		devices = tc.devices
+		now = tc.refresh
+		c.RefreshData(tc.refresh)
+
+		if err := testutil.CollectAndCompare(c, strings.NewReader(tc.want), "netatmo_module_last_seen_seconds"); err != nil {
+			t.Errorf("%s: %s", tc.desc, err)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package collector
+
+import (
+	netatmo "github.com/exzz/netatmo-api-go"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var rainResetDesc = newLabelledDesc(
+	prefix+"rain_reset_total",
+	"Counts the refreshes in which the daily rain sum decreased, because Netatmo reset it at midnight.",
+	varLabels)
+
+// rainSum contains the last daily rain sum of a rain gauge and the number of resets detected so far.
+type rainSum struct {
+	labelValues []string
+	last        float64
+	resets      uint64
+}
+
+// rainResets tracks the daily rain sums of the rain gauges, keyed by the module ID. It is guarded by the cacheLock
+// of the collector.
+type rainResets map[string]*rainSum
+
+// update compares the daily rain sums contained in devices with the previous ones and counts a reset for every
+// sum, which decreased.
+func (r rainResets) update(devices *netatmo.DeviceCollection, labels labelFunc) {
+	if devices == nil {
+		return
+	}
+
+	for _, dev := range devices.Devices() {
+		stationName := dev.StationName //nolint: staticcheck
+		r.see(dev, labels(dev, deviceName(dev, stationName), stationName, roleStation))
+
+		for _, module := range stationModules(dev) {
+			r.see(module, labels(module, deviceName(module, ""), stationName, roleModule))
+		}
+	}
+}
+
+func (r rainResets) see(device *netatmo.Device, labelValues []string) {
+	if device.DashboardData.Rain1Day == nil {
+		return
+	}
+
+	value := float64(*device.DashboardData.Rain1Day)
+	sum, ok := r[device.ID]
+	if !ok {
+		r[device.ID] = &rainSum{
+			labelValues: labelValues,
+			last:        value,
+		}
+		return
+	}
+
+	if value < sum.last {
+		sum.resets++
+	}
+	sum.last = value
+	sum.labelValues = labelValues
+}
+
+// collect emits the reset counters. The caller needs to hold the cacheLock.
+func (r rainResets) collect(c *NetatmoCollector, ch chan<- prometheus.Metric) {
+	for _, sum := range r {
+		c.sendMetric(ch, rainResetDesc, prometheus.CounterValue, float64(sum.resets), sum.labelValues...)
+	}
+}
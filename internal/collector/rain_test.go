@@ -0,0 +1,105 @@
+package collector
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	netatmo "github.com/exzz/netatmo-api-go"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+)
+
+func TestNetatmoCollector_CollectRainSums(t *testing.T) {
+	station := func(hour, day float32) *netatmo.DeviceCollection {
+		lastMeasure := int64(3600)
+		dc := &netatmo.DeviceCollection{}
+		dc.Body.Devices = []*netatmo.Device{
+			{
+				ID:          "aa:bb:cc:dd:ee:f0",
+				ModuleName:  "Living Room",
+				StationName: "Home",
+				Type:        "NAMain",
+				LinkedModules: []*netatmo.Device{
+					{
+						ID:         "aa:bb:cc:dd:ee:f3",
+						ModuleName: "Rain Gauge",
+						Type:       "NAModule3",
+						DashboardData: netatmo.DashboardData{
+							Rain1Hour:   &hour,
+							Rain1Day:    &day,
+							LastMeasure: &lastMeasure,
+						},
+					},
+				},
+			},
+		}
+		return dc
+	}
+
+	var devices *netatmo.DeviceCollection
+	c := New(context.Background(), logrus.New(), func() (*netatmo.DeviceCollection, error) {
+		return devices, nil
+	}, time.Minute, time.Hour)
+	c.clock = func() time.Time {
+		return time.Unix(3600, 0)
+	}
+
+	const header = `# HELP netatmo_rain_reset_total Counts the refreshes in which the daily rain sum decreased, because Netatmo reset it at midnight.
+# TYPE netatmo_rain_reset_total counter
+# HELP netatmo_aircare_rain_sum_1h_mm Rain amount in millimeters of the last hour.
+# TYPE netatmo_aircare_rain_sum_1h_mm gauge
+# HELP netatmo_aircare_rain_sum_today_mm Rain amount in millimeters since midnight. This is a counter, which is reset by Netatmo every day.
+# TYPE netatmo_aircare_rain_sum_today_mm counter
+`
+	tt := []struct {
+		desc    string
+		devices *netatmo.DeviceCollection
+		want    string
+	}{
+		{
+			desc:    "first refresh",
+			devices: station(1, 5),
+			want: header + `netatmo_rain_reset_total{module="Rain Gauge",module_type="NAModule3",role="module",station="Home"} 0
+netatmo_aircare_rain_sum_1h_mm{module="Rain Gauge",module_type="NAModule3",role="module",station="Home"} 1
+netatmo_aircare_rain_sum_today_mm{module="Rain Gauge",module_type="NAModule3",role="module",station="Home"} 5
+`,
+		},
+		{
+			desc:    "increased",
+			devices: station(2, 7),
+			want: header + `netatmo_rain_reset_total{module="Rain Gauge",module_type="NAModule3",role="module",station="Home"} 0
+netatmo_aircare_rain_sum_1h_mm{module="Rain Gauge",module_type="NAModule3",role="module",station="Home"} 2
+netatmo_aircare_rain_sum_today_mm{module="Rain Gauge",module_type="NAModule3",role="module",station="Home"} 7
+`,
+		},
+		{
+			desc:    "reset at midnight",
+			devices: station(0.5, 0.5),
+			want: header + `netatmo_rain_reset_total{module="Rain Gauge",module_type="NAModule3",role="module",station="Home"} 1
+netatmo_aircare_rain_sum_1h_mm{module="Rain Gauge",module_type="NAModule3",role="module",station="Home"} 0.5
+netatmo_aircare_rain_sum_today_mm{module="Rain Gauge",module_type="NAModule3",role="module",station="Home"} 0.5
+`,
+		},
+		{
+			desc:    "increased after reset",
+			devices: station(1.5, 2),
+			want: header + `netatmo_rain_reset_total{module="Rain Gauge",module_type="NAModule3",role="module",station="Home"} 1
+netatmo_aircare_rain_sum_1h_mm{module="Rain Gauge",module_type="NAModule3",role="module",station="Home"} 1.5
+netatmo_aircare_rain_sum_today_mm{module="Rain Gauge",module_type="NAModule3",role="module",station="Home"} 2
+`,
+		},
+	}
+
+	// The steps build on each other, so they can not run in parallel.
+	for _, tc := range tt {
+		devices = tc.devices
+		c.RefreshData(time.Unix(3600, 0))
+
+		if err := testutil.CollectAndCompare(c, strings.NewReader(tc.want),
+			"netatmo_rain_reset_total", "netatmo_aircare_rain_sum_1h_mm", "netatmo_aircare_rain_sum_today_mm"); err != nil {
+			t.Errorf("%s: %s", tc.desc, err)
+		}
+	}
+}
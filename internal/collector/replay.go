@@ -0,0 +1,29 @@
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	netatmo "github.com/exzz/netatmo-api-go"
+)
+
+// ReplayFile creates a ReadFunction, which returns the devices from a captured API response instead of reading
+// from the Netatmo API. The file uses the format returned by the /debug/data handler and is read again on every
+// refresh, so that it can be changed while the exporter is running.
+func ReplayFile(fileName string) ReadFunction {
+	return func() (*netatmo.DeviceCollection, error) {
+		file, err := os.Open(fileName)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+
+		var devices netatmo.DeviceCollection
+		if err := json.NewDecoder(file).Decode(&devices); err != nil {
+			return nil, fmt.Errorf("error decoding replay file: %w", err)
+		}
+
+		return &devices, nil
+	}
+}
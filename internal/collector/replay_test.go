@@ -0,0 +1,77 @@
+package collector
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+)
+
+func TestReplayFile(t *testing.T) {
+	dir := t.TempDir()
+	validFile := filepath.Join(dir, "capture.json")
+	if err := os.WriteFile(validFile, []byte(`{"Body":{"devices":[{"_id":"aa:bb:cc:dd:ee:f0","station_name":"Home","module_name":"Living Room","type":"NAMain","dashboard_data":{"Temperature":23,"time_utc":3500}}]}}`), 0o600); err != nil {
+		t.Fatalf("error writing replay file: %s", err)
+	}
+	invalidFile := filepath.Join(dir, "invalid.json")
+	if err := os.WriteFile(invalidFile, []byte(`{"Body":`), 0o600); err != nil {
+		t.Fatalf("error writing replay file: %s", err)
+	}
+
+	tt := []struct {
+		desc     string
+		fileName string
+		wantErr  bool
+		want     string
+	}{
+		{
+			desc:     "success",
+			fileName: validFile,
+			want: `# HELP netatmo_aircare_temperature_celsius Temperature measurement in celsius
+# TYPE netatmo_aircare_temperature_celsius gauge
+netatmo_aircare_temperature_celsius{module="Living Room",module_type="NAMain",role="station",station="Home"} 23
+`,
+		},
+		{
+			desc:     "missing file",
+			fileName: filepath.Join(dir, "missing.json"),
+			wantErr:  true,
+		},
+		{
+			desc:     "invalid file",
+			fileName: invalidFile,
+			wantErr:  true,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			read := ReplayFile(tc.fileName)
+			if _, err := read(); (err != nil) != tc.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tc.wantErr)
+			}
+
+			if tc.wantErr {
+				return
+			}
+
+			c := New(context.Background(), logrus.New(), read, time.Hour, time.Hour)
+			c.clock = func() time.Time {
+				return time.Unix(3600, 0)
+			}
+			c.RefreshData(c.clock())
+
+			if err := testutil.CollectAndCompare(c, strings.NewReader(tc.want), "netatmo_aircare_temperature_celsius"); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package collector
+
+import (
+	netatmo "github.com/exzz/netatmo-api-go"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	wifiTrendDesc = newLabelledDesc(
+		sensorPrefix+"wifi_signal_trend",
+		"Change of the wifi signal strength since the previous refresh (-1: worsening, 0: stable, 1: improving)",
+		varLabels)
+	rfTrendDesc = newLabelledDesc(
+		sensorPrefix+"rf_signal_trend",
+		"Change of the RF signal strength since the previous refresh (-1: worsening, 0: stable, 1: improving)",
+		varLabels)
+)
+
+// signalTrend contains the last signal strength of a module and how it changed compared to the refresh before.
+// The trend is only known once the signal strength was seen twice.
+type signalTrend struct {
+	last    float64
+	trend   float64
+	samples int
+}
+
+// see updates the trend using the current signal strength. Lower values are a better signal for both wifi and
+// RF, so an increase is a worsening trend.
+func (s *signalTrend) see(value *int32) {
+	if value == nil {
+		return
+	}
+
+	current := float64(*value)
+	if s.samples > 0 {
+		switch {
+		case current > s.last:
+			s.trend = -1
+		case current < s.last:
+			s.trend = 1
+		default:
+			s.trend = 0
+		}
+	}
+
+	s.last = current
+	s.samples++
+}
+
+// send emits the trend, if it is known.
+func (s *signalTrend) send(c *NetatmoCollector, ch chan<- prometheus.Metric, desc *prometheus.Desc, labelValues []string) {
+	if s.samples > 1 {
+		c.sendMetric(ch, desc, prometheus.GaugeValue, s.trend, labelValues...)
+	}
+}
+
+// moduleSignals contains the signal trends of one module.
+type moduleSignals struct {
+	wifi signalTrend
+	rf   signalTrend
+}
+
+// signalTrends contains the signal trends keyed by the module ID. It is guarded by the cacheLock of the collector.
+type signalTrends map[string]*moduleSignals
+
+// update compares the signal strengths contained in devices with the ones of the previous refresh. Modules
+// which are not contained in devices anymore are removed.
+func (s signalTrends) update(devices *netatmo.DeviceCollection) {
+	seen := make(map[string]bool)
+	if devices != nil {
+		for _, dev := range devices.Devices() {
+			for _, device := range append([]*netatmo.Device{dev}, stationModules(dev)...) {
+				seen[device.ID] = true
+				s.see(device)
+			}
+		}
+	}
+
+	for id := range s {
+		if !seen[id] {
+			delete(s, id)
+		}
+	}
+}
+
+func (s signalTrends) see(device *netatmo.Device) {
+	module, ok := s[device.ID]
+	if !ok {
+		module = &moduleSignals{}
+		s[device.ID] = module
+	}
+
+	module.wifi.see(device.WifiStatus)
+	module.rf.see(device.RFStatus)
+}
+
+// collectSignalTrends emits the signal trends of the module. The caller needs to hold the cacheLock.
+func (c *NetatmoCollector) collectSignalTrends(ch chan<- prometheus.Metric, device *netatmo.Device, labelValues []string) {
+	module, ok := c.signals[device.ID]
+	if !ok {
+		return
+	}
+
+	module.wifi.send(c, ch, wifiTrendDesc, labelValues)
+	module.rf.send(c, ch, rfTrendDesc, labelValues)
+}
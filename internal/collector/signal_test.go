@@ -0,0 +1,144 @@
+package collector
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	netatmo "github.com/exzz/netatmo-api-go"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+)
+
+func signalTestDevices(wifi int32, rf *int32) *netatmo.DeviceCollection {
+	lastMeasure := int64(3600)
+	station := &netatmo.Device{
+		ID:          "aa:bb:cc:dd:ee:f0",
+		ModuleName:  "Living Room",
+		StationName: "Home",
+		Type:        "NAMain",
+		WifiStatus:  &wifi,
+		DashboardData: netatmo.DashboardData{
+			LastMeasure: &lastMeasure,
+		},
+	}
+	if rf != nil {
+		station.LinkedModules = []*netatmo.Device{
+			{
+				ID:         "aa:bb:cc:dd:ee:f1",
+				ModuleName: "Outside",
+				Type:       "NAModule1",
+				RFStatus:   rf,
+				DashboardData: netatmo.DashboardData{
+					LastMeasure: &lastMeasure,
+				},
+			},
+		}
+	}
+
+	dc := &netatmo.DeviceCollection{}
+	dc.Body.Devices = []*netatmo.Device{station}
+	return dc
+}
+
+func TestNetatmoCollector_CollectSignalTrends(t *testing.T) {
+	tt := []struct {
+		desc      string
+		responses []*netatmo.DeviceCollection
+		want      string
+	}{
+		{
+			desc: "single refresh",
+			responses: []*netatmo.DeviceCollection{
+				signalTestDevices(50, int32Ptr(60)),
+			},
+			want: "",
+		},
+		{
+			desc: "worsening and improving",
+			responses: []*netatmo.DeviceCollection{
+				signalTestDevices(50, int32Ptr(60)),
+				signalTestDevices(55, int32Ptr(58)),
+			},
+			want: `# HELP netatmo_aircare_rf_signal_trend Change of the RF signal strength since the previous refresh (-1: worsening, 0: stable, 1: improving)
+# TYPE netatmo_aircare_rf_signal_trend gauge
+netatmo_aircare_rf_signal_trend{module="Outside",module_type="NAModule1",role="module",station="Home"} 1
+# HELP netatmo_aircare_wifi_signal_trend Change of the wifi signal strength since the previous refresh (-1: worsening, 0: stable, 1: improving)
+# TYPE netatmo_aircare_wifi_signal_trend gauge
+netatmo_aircare_wifi_signal_trend{module="Living Room",module_type="NAMain",role="station",station="Home"} -1
+`,
+		},
+		{
+			desc: "stable",
+			responses: []*netatmo.DeviceCollection{
+				signalTestDevices(55, int32Ptr(60)),
+				signalTestDevices(50, int32Ptr(60)),
+				signalTestDevices(50, int32Ptr(60)),
+			},
+			want: `# HELP netatmo_aircare_rf_signal_trend Change of the RF signal strength since the previous refresh (-1: worsening, 0: stable, 1: improving)
+# TYPE netatmo_aircare_rf_signal_trend gauge
+netatmo_aircare_rf_signal_trend{module="Outside",module_type="NAModule1",role="module",station="Home"} 0
+# HELP netatmo_aircare_wifi_signal_trend Change of the wifi signal strength since the previous refresh (-1: worsening, 0: stable, 1: improving)
+# TYPE netatmo_aircare_wifi_signal_trend gauge
+netatmo_aircare_wifi_signal_trend{module="Living Room",module_type="NAMain",role="station",station="Home"} 0
+`,
+		},
+		{
+			desc: "disappeared module removed",
+			responses: []*netatmo.DeviceCollection{
+				signalTestDevices(50, int32Ptr(60)),
+				signalTestDevices(50, nil),
+				signalTestDevices(50, int32Ptr(70)),
+			},
+			want: `# HELP netatmo_aircare_wifi_signal_trend Change of the wifi signal strength since the previous refresh (-1: worsening, 0: stable, 1: improving)
+# TYPE netatmo_aircare_wifi_signal_trend gauge
+netatmo_aircare_wifi_signal_trend{module="Living Room",module_type="NAMain",role="station",station="Home"} 0
+`,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			var response *netatmo.DeviceCollection
+			c := New(context.Background(), logrus.New(), func() (*netatmo.DeviceCollection, error) {
+				return response, nil
+			}, time.Hour, time.Hour)
+			c.clock = func() time.Time {
+				return time.Unix(3600, 0)
+			}
+			c.SignalTrend = true
+
+			for _, r := range tc.responses {
+				response = r
+				c.RefreshData(c.clock())
+			}
+
+			if err := testutil.CollectAndCompare(c, strings.NewReader(tc.want), "netatmo_aircare_wifi_signal_trend", "netatmo_aircare_rf_signal_trend"); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestNetatmoCollector_CollectSignalTrendsDisabled(t *testing.T) {
+	var response *netatmo.DeviceCollection
+	c := New(context.Background(), logrus.New(), func() (*netatmo.DeviceCollection, error) {
+		return response, nil
+	}, time.Hour, time.Hour)
+	c.clock = func() time.Time {
+		return time.Unix(3600, 0)
+	}
+
+	for _, r := range []*netatmo.DeviceCollection{signalTestDevices(50, int32Ptr(60)), signalTestDevices(55, int32Ptr(58))} {
+		response = r
+		c.RefreshData(c.clock())
+	}
+
+	if err := testutil.CollectAndCompare(c, strings.NewReader(""), "netatmo_aircare_wifi_signal_trend", "netatmo_aircare_rf_signal_trend"); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,131 @@
+package collector
+
+import (
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// TemperatureUnit is a unit used for the temperature metrics.
+type TemperatureUnit string
+
+// Supported units of the temperature. The API always reports celsius.
+const (
+	TemperatureUnitCelsius    TemperatureUnit = "celsius"
+	TemperatureUnitFahrenheit TemperatureUnit = "fahrenheit"
+)
+
+// DefaultTemperatureUnits is used when no unit is configured, it does not need a conversion.
+var DefaultTemperatureUnits = []TemperatureUnit{TemperatureUnitCelsius}
+
+// temperatureMetrics contains the descriptors of the temperature metrics using one unit and the function for
+// converting a temperature in celsius to that unit.
+type temperatureMetrics struct {
+	convert     func(float64) float64
+	temperature *prometheus.Desc
+	dewPoint    *prometheus.Desc
+	heatIndex   *prometheus.Desc
+}
+
+var (
+	temperatureUnits = map[TemperatureUnit]temperatureMetrics{
+		TemperatureUnitCelsius: {
+			convert: func(c float64) float64 {
+				return c
+			},
+			temperature: tempDesc,
+			dewPoint:    dewPointDesc,
+			heatIndex:   heatIndexDesc,
+		},
+		TemperatureUnitFahrenheit: {
+			convert: celsiusToFahrenheit,
+			temperature: newSensorDesc(
+				"temperature_fahrenheit",
+				"Temperature measurement in fahrenheit"),
+			dewPoint: newSensorDesc(
+				"dew_point_fahrenheit",
+				"Dew point in fahrenheit calculated from temperature and humidity"),
+			heatIndex: newSensorDesc(
+				"heat_index_fahrenheit",
+				"Heat index (\"feels like\" temperature) in fahrenheit, same as temperature below 80°F"),
+		},
+	}
+
+	// temperatureDescUnits maps the descriptors of the temperature metrics to their unit, so that the metrics of
+	// the units, which are not configured, can be left out.
+	temperatureDescUnits = func() map[*prometheus.Desc]TemperatureUnit {
+		result := make(map[*prometheus.Desc]TemperatureUnit, 3*len(temperatureUnits))
+		for unit, metrics := range temperatureUnits {
+			result[metrics.temperature] = unit
+			result[metrics.dewPoint] = unit
+			result[metrics.heatIndex] = unit
+		}
+		return result
+	}()
+)
+
+// ParseTemperatureUnit returns the TemperatureUnit with the given name.
+func ParseTemperatureUnit(name string) (TemperatureUnit, error) {
+	unit := TemperatureUnit(name)
+	if _, ok := temperatureUnits[unit]; !ok {
+		return "", fmt.Errorf("unknown temperature unit: %s", name)
+	}
+
+	return unit, nil
+}
+
+// enabledTemperatureUnits returns the configured units of the temperature metrics or the
+// DefaultTemperatureUnits, if none are configured.
+func (c *NetatmoCollector) enabledTemperatureUnits() []TemperatureUnit {
+	if len(c.TemperatureUnits) == 0 {
+		return DefaultTemperatureUnits
+	}
+
+	return c.TemperatureUnits
+}
+
+// temperatureUnitEnabled returns false for the temperature metrics of units, which are not configured.
+func (c *NetatmoCollector) temperatureUnitEnabled(desc *prometheus.Desc) bool {
+	unit, ok := temperatureDescUnits[desc]
+	if !ok {
+		return true
+	}
+
+	for _, enabled := range c.enabledTemperatureUnits() {
+		if enabled == unit {
+			return true
+		}
+	}
+
+	return false
+}
+
+// temperatureDescs returns the descriptors of the metrics calculated from the temperature in all units.
+func temperatureDescs() []*prometheus.Desc {
+	descs := make([]*prometheus.Desc, 0, len(temperatureDescUnits))
+	for desc := range temperatureDescUnits {
+		descs = append(descs, desc)
+	}
+
+	return descs
+}
+
+// derivedTemperatureDescs returns the descriptors of the metrics calculated from temperature and humidity in all
+// units.
+func derivedTemperatureDescs() []*prometheus.Desc {
+	descs := make([]*prometheus.Desc, 0, 2*len(temperatureUnits))
+	for _, metrics := range temperatureUnits {
+		descs = append(descs, metrics.dewPoint, metrics.heatIndex)
+	}
+
+	return descs
+}
+
+// sendTemperatureMetric sends the metric of every configured unit for a temperature in celsius. The descriptor
+// of a unit is selected by the desc function.
+func (c *NetatmoCollector) sendTemperatureMetric(ch chan<- prometheus.Metric, desc func(temperatureMetrics) *prometheus.Desc, measured int64, celsius float64, labelValues ...string) {
+	for _, unit := range c.enabledTemperatureUnits() {
+		metrics := temperatureUnits[unit]
+		c.sendSensorMetric(ch, desc(metrics), measured, metrics.convert(celsius), labelValues...)
+	}
+}
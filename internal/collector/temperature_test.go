@@ -0,0 +1,147 @@
+package collector
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	netatmo "github.com/exzz/netatmo-api-go"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+)
+
+func TestNetatmoCollector_CollectTemperatureUnits(t *testing.T) {
+	lastMeasure := int64(3600)
+	dc := &netatmo.DeviceCollection{}
+	dc.Body.Devices = []*netatmo.Device{
+		{
+			ID:          "aa:bb:cc:dd:ee:f0",
+			ModuleName:  "Living Room",
+			StationName: "Home",
+			Type:        "NAMain",
+			DashboardData: netatmo.DashboardData{
+				Temperature: float32Ptr(20),
+				LastMeasure: &lastMeasure,
+			},
+		},
+	}
+
+	metricNames := []string{
+		"netatmo_aircare_temperature_celsius",
+		"netatmo_aircare_temperature_fahrenheit",
+	}
+
+	celsius := `# HELP netatmo_aircare_temperature_celsius Temperature measurement in celsius
+# TYPE netatmo_aircare_temperature_celsius gauge
+netatmo_aircare_temperature_celsius{module="Living Room",module_type="NAMain",role="station",station="Home"} 20
+`
+	fahrenheit := `# HELP netatmo_aircare_temperature_fahrenheit Temperature measurement in fahrenheit
+# TYPE netatmo_aircare_temperature_fahrenheit gauge
+netatmo_aircare_temperature_fahrenheit{module="Living Room",module_type="NAMain",role="station",station="Home"} 68
+`
+
+	tt := []struct {
+		desc  string
+		units []TemperatureUnit
+		want  string
+	}{
+		{
+			desc: "default",
+			want: celsius,
+		},
+		{
+			desc:  "celsius",
+			units: []TemperatureUnit{TemperatureUnitCelsius},
+			want:  celsius,
+		},
+		{
+			desc:  "fahrenheit",
+			units: []TemperatureUnit{TemperatureUnitFahrenheit},
+			want:  fahrenheit,
+		},
+		{
+			desc:  "celsius and fahrenheit",
+			units: []TemperatureUnit{TemperatureUnitCelsius, TemperatureUnitFahrenheit},
+			want:  celsius + fahrenheit,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			c := New(context.Background(), logrus.New(), func() (*netatmo.DeviceCollection, error) {
+				return dc, nil
+			}, time.Minute, time.Hour)
+			c.clock = func() time.Time {
+				return time.Unix(3600, 0)
+			}
+			c.TemperatureUnits = tc.units
+			c.RefreshData(time.Unix(3600, 0))
+
+			if err := testutil.CollectAndCompare(c, strings.NewReader(tc.want), metricNames...); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestNetatmoCollector_CollectTemperatureUnitsDerived(t *testing.T) {
+	lastMeasure := int64(3600)
+	dc := &netatmo.DeviceCollection{}
+	dc.Body.Devices = []*netatmo.Device{
+		{
+			ID:          "aa:bb:cc:dd:ee:f0",
+			ModuleName:  "Living Room",
+			StationName: "Home",
+			Type:        "NAMain",
+			DashboardData: netatmo.DashboardData{
+				Temperature: float32Ptr(20),
+				Humidity:    int32Ptr(50),
+				LastMeasure: &lastMeasure,
+			},
+		},
+	}
+
+	c := New(context.Background(), logrus.New(), func() (*netatmo.DeviceCollection, error) {
+		return dc, nil
+	}, time.Minute, time.Hour)
+	c.clock = func() time.Time {
+		return time.Unix(3600, 0)
+	}
+	c.TemperatureUnits = []TemperatureUnit{TemperatureUnitCelsius, TemperatureUnitFahrenheit}
+	c.RefreshData(time.Unix(3600, 0))
+
+	want := `# HELP netatmo_aircare_dew_point_fahrenheit Dew point in fahrenheit calculated from temperature and humidity
+# TYPE netatmo_aircare_dew_point_fahrenheit gauge
+netatmo_aircare_dew_point_fahrenheit{module="Living Room",module_type="NAMain",role="station",station="Home"} 48.65931427816626
+# HELP netatmo_aircare_heat_index_fahrenheit Heat index ("feels like" temperature) in fahrenheit, same as temperature below 80°F
+# TYPE netatmo_aircare_heat_index_fahrenheit gauge
+netatmo_aircare_heat_index_fahrenheit{module="Living Room",module_type="NAMain",role="station",station="Home"} 68
+`
+
+	if err := testutil.CollectAndCompare(c, strings.NewReader(want), "netatmo_aircare_dew_point_fahrenheit", "netatmo_aircare_heat_index_fahrenheit"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestParseTemperatureUnit(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range []string{"celsius", "fahrenheit"} {
+		unit, err := ParseTemperatureUnit(name)
+		if err != nil {
+			t.Errorf("got error for %q: %s", name, err)
+		}
+
+		if string(unit) != name {
+			t.Errorf("got unit %q, want %q", unit, name)
+		}
+	}
+
+	if _, err := ParseTemperatureUnit("kelvin"); err == nil {
+		t.Error("wanted error for unknown unit")
+	}
+}
@@ -0,0 +1,124 @@
+package collector
+
+import (
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// WindUnit is the unit used for the wind and gust strength metrics.
+type WindUnit string
+
+// Supported units of the wind strength. The API always reports kilometers per hour.
+const (
+	WindUnitKph   WindUnit = "kph"
+	WindUnitMph   WindUnit = "mph"
+	WindUnitMs    WindUnit = "ms"
+	WindUnitKnots WindUnit = "knots"
+)
+
+// DefaultWindUnit is used when no unit is configured, it does not need a conversion.
+const DefaultWindUnit = WindUnitKph
+
+// windMetrics contains the descriptors of the wind metrics using one unit and the factor for converting the
+// value reported by the API to that unit.
+type windMetrics struct {
+	factor   float64
+	strength *prometheus.Desc
+	gust     *prometheus.Desc
+}
+
+var (
+	windUnits = map[WindUnit]windMetrics{
+		WindUnitKph: {
+			factor:   1,
+			strength: windStrengthDesc,
+			gust: newSensorDesc(
+				"gust_strength_kph",
+				"Gust strength in kilometers per hour"),
+		},
+		WindUnitMph: {
+			factor: 1 / 1.609344,
+			strength: newSensorDesc(
+				"wind_strength_mph",
+				"Wind strength in miles per hour"),
+			gust: newSensorDesc(
+				"gust_strength_mph",
+				"Gust strength in miles per hour"),
+		},
+		WindUnitMs: {
+			factor: 1 / 3.6,
+			strength: newSensorDesc(
+				"wind_strength_meters_per_second",
+				"Wind strength in meters per second"),
+			gust: newSensorDesc(
+				"gust_strength_meters_per_second",
+				"Gust strength in meters per second"),
+		},
+		WindUnitKnots: {
+			factor: 1 / 1.852,
+			strength: newSensorDesc(
+				"wind_strength_knots",
+				"Wind strength in knots"),
+			gust: newSensorDesc(
+				"gust_strength_knots",
+				"Gust strength in knots"),
+		},
+	}
+
+	// windDescUnits maps the descriptors of the wind metrics to their unit, so that the metrics of the other
+	// units can be left out.
+	windDescUnits = func() map[*prometheus.Desc]WindUnit {
+		result := make(map[*prometheus.Desc]WindUnit, 2*len(windUnits))
+		for unit, metrics := range windUnits {
+			result[metrics.strength] = unit
+			result[metrics.gust] = unit
+		}
+		return result
+	}()
+)
+
+// ParseWindUnit returns the WindUnit with the given name.
+func ParseWindUnit(name string) (WindUnit, error) {
+	unit := WindUnit(name)
+	if _, ok := windUnits[unit]; !ok {
+		return "", fmt.Errorf("unknown wind unit: %s", name)
+	}
+
+	return unit, nil
+}
+
+// windUnit returns the configured unit of the wind metrics or the DefaultWindUnit, if none is configured.
+func (c *NetatmoCollector) windUnit() WindUnit {
+	if _, ok := windUnits[c.WindUnit]; ok {
+		return c.WindUnit
+	}
+
+	return DefaultWindUnit
+}
+
+// windUnitEnabled returns false for the wind metrics of units other than the configured one.
+func (c *NetatmoCollector) windUnitEnabled(desc *prometheus.Desc) bool {
+	unit, ok := windDescUnits[desc]
+	return !ok || unit == c.windUnit()
+}
+
+// windStrengthDescs returns the descriptors of the wind strength metrics of all units.
+func windStrengthDescs() []*prometheus.Desc {
+	descs := make([]*prometheus.Desc, 0, len(windUnits))
+	for _, metrics := range windUnits {
+		descs = append(descs, metrics.strength)
+	}
+
+	return descs
+}
+
+// gustStrengthDescs returns the descriptors of the gust strength metrics of all units.
+func gustStrengthDescs() []*prometheus.Desc {
+	descs := make([]*prometheus.Desc, 0, len(windUnits))
+	for _, metrics := range windUnits {
+		descs = append(descs, metrics.gust)
+	}
+
+	return descs
+}
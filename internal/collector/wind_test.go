@@ -0,0 +1,142 @@
+package collector
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	netatmo "github.com/exzz/netatmo-api-go"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+)
+
+func TestNetatmoCollector_CollectWindUnit(t *testing.T) {
+	lastMeasure := int64(3600)
+	dc := &netatmo.DeviceCollection{}
+	dc.Body.Devices = []*netatmo.Device{
+		{
+			ID:          "aa:bb:cc:dd:ee:f0",
+			ModuleName:  "Living Room",
+			StationName: "Home",
+			Type:        "NAMain",
+			LinkedModules: []*netatmo.Device{
+				{
+					ID:         "aa:bb:cc:dd:ee:f2",
+					ModuleName: "Wind Gauge",
+					Type:       "NAModule2",
+					DashboardData: netatmo.DashboardData{
+						WindStrength: int32Ptr(36),
+						GustStrength: int32Ptr(54),
+						LastMeasure:  &lastMeasure,
+					},
+				},
+			},
+		},
+	}
+
+	var metricNames []string
+	for _, metrics := range windUnits {
+		metricNames = append(metricNames, sensorDescNames[metrics.strength], sensorDescNames[metrics.gust])
+	}
+
+	tt := []struct {
+		desc string
+		unit WindUnit
+		want string
+	}{
+		{
+			desc: "default",
+			want: `# HELP netatmo_aircare_wind_strength_kph Wind strength in kilometers per hour
+# TYPE netatmo_aircare_wind_strength_kph gauge
+netatmo_aircare_wind_strength_kph{module="Wind Gauge",module_type="NAModule2",role="module",station="Home"} 36
+# HELP netatmo_aircare_gust_strength_kph Gust strength in kilometers per hour
+# TYPE netatmo_aircare_gust_strength_kph gauge
+netatmo_aircare_gust_strength_kph{module="Wind Gauge",module_type="NAModule2",role="module",station="Home"} 54
+`,
+		},
+		{
+			desc: "kph",
+			unit: WindUnitKph,
+			want: `# HELP netatmo_aircare_wind_strength_kph Wind strength in kilometers per hour
+# TYPE netatmo_aircare_wind_strength_kph gauge
+netatmo_aircare_wind_strength_kph{module="Wind Gauge",module_type="NAModule2",role="module",station="Home"} 36
+# HELP netatmo_aircare_gust_strength_kph Gust strength in kilometers per hour
+# TYPE netatmo_aircare_gust_strength_kph gauge
+netatmo_aircare_gust_strength_kph{module="Wind Gauge",module_type="NAModule2",role="module",station="Home"} 54
+`,
+		},
+		{
+			desc: "mph",
+			unit: WindUnitMph,
+			want: `# HELP netatmo_aircare_wind_strength_mph Wind strength in miles per hour
+# TYPE netatmo_aircare_wind_strength_mph gauge
+netatmo_aircare_wind_strength_mph{module="Wind Gauge",module_type="NAModule2",role="module",station="Home"} 22.36936292054402
+# HELP netatmo_aircare_gust_strength_mph Gust strength in miles per hour
+# TYPE netatmo_aircare_gust_strength_mph gauge
+netatmo_aircare_gust_strength_mph{module="Wind Gauge",module_type="NAModule2",role="module",station="Home"} 33.55404438081603
+`,
+		},
+		{
+			desc: "meters per second",
+			unit: WindUnitMs,
+			want: `# HELP netatmo_aircare_wind_strength_meters_per_second Wind strength in meters per second
+# TYPE netatmo_aircare_wind_strength_meters_per_second gauge
+netatmo_aircare_wind_strength_meters_per_second{module="Wind Gauge",module_type="NAModule2",role="module",station="Home"} 10
+# HELP netatmo_aircare_gust_strength_meters_per_second Gust strength in meters per second
+# TYPE netatmo_aircare_gust_strength_meters_per_second gauge
+netatmo_aircare_gust_strength_meters_per_second{module="Wind Gauge",module_type="NAModule2",role="module",station="Home"} 15
+`,
+		},
+		{
+			desc: "knots",
+			unit: WindUnitKnots,
+			want: `# HELP netatmo_aircare_wind_strength_knots Wind strength in knots
+# TYPE netatmo_aircare_wind_strength_knots gauge
+netatmo_aircare_wind_strength_knots{module="Wind Gauge",module_type="NAModule2",role="module",station="Home"} 19.438444924406046
+# HELP netatmo_aircare_gust_strength_knots Gust strength in knots
+# TYPE netatmo_aircare_gust_strength_knots gauge
+netatmo_aircare_gust_strength_knots{module="Wind Gauge",module_type="NAModule2",role="module",station="Home"} 29.15766738660907
+`,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			c := New(context.Background(), logrus.New(), func() (*netatmo.DeviceCollection, error) {
+				return dc, nil
+			}, time.Minute, time.Hour)
+			c.clock = func() time.Time {
+				return time.Unix(3600, 0)
+			}
+			c.WindUnit = tc.unit
+			c.RefreshData(time.Unix(3600, 0))
+
+			if err := testutil.CollectAndCompare(c, strings.NewReader(tc.want), metricNames...); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestParseWindUnit(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range []string{"kph", "mph", "ms", "knots"} {
+		unit, err := ParseWindUnit(name)
+		if err != nil {
+			t.Errorf("got error for %q: %s", name, err)
+		}
+
+		if string(unit) != name {
+			t.Errorf("got unit %q, want %q", unit, name)
+		}
+	}
+
+	if _, err := ParseWindUnit("beaufort"); err == nil {
+		t.Error("wanted error for unknown unit")
+	}
+}
@@ -4,9 +4,16 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/exzz/netatmo-api-go"
+	"github.com/prometheus/common/model"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/pflag"
 )
@@ -15,40 +22,205 @@ const (
 	envVarListenAddress       = "NETATMO_EXPORTER_ADDR"
 	envVarExternalURL         = "NETATMO_EXPORTER_EXTERNAL_URL"
 	envVarTokenFile           = "NETATMO_EXPORTER_TOKEN_FILE"
+	envVarOnBadToken          = "NETATMO_EXPORTER_ON_BAD_TOKEN"
+	envVarCacheFile           = "NETATMO_EXPORTER_CACHE_FILE"
+	envVarReplayFile          = "NETATMO_EXPORTER_REPLAY_FILE"
+	envVarUserAgent           = "NETATMO_EXPORTER_USER_AGENT"
+	envVarReadHeaderTimeout   = "NETATMO_EXPORTER_READ_HEADER_TIMEOUT"
+	envVarReadTimeout         = "NETATMO_EXPORTER_READ_TIMEOUT"
+	envVarWriteTimeout        = "NETATMO_EXPORTER_WRITE_TIMEOUT"
+	envVarIdleTimeout         = "NETATMO_EXPORTER_IDLE_TIMEOUT"
+	envVarEnableCompression   = "NETATMO_EXPORTER_ENABLE_COMPRESSION"
+	envVarEnableHomeCoach     = "NETATMO_EXPORTER_ENABLE_HOMECOACH"
+	envVarSignalTrend         = "NETATMO_EXPORTER_SIGNAL_TREND"
+	envVarEnableMetrics       = "NETATMO_EXPORTER_ENABLE_METRICS"
+	envVarDisableMetrics      = "NETATMO_EXPORTER_DISABLE_METRICS"
+	envVarSensorBounds        = "NETATMO_EXPORTER_SENSOR_BOUNDS"
+	envVarComfortThresholds   = "NETATMO_EXPORTER_COMFORT_THRESHOLDS"
+	envVarConvenience         = "NETATMO_EXPORTER_ENABLE_CONVENIENCE_METRICS"
+	envVarWindUnit            = "NETATMO_EXPORTER_WIND_UNIT"
+	envVarTemperatureUnits    = "NETATMO_EXPORTER_TEMPERATURE_UNITS"
+	envVarAcceptEmpty         = "NETATMO_EXPORTER_ACCEPT_EMPTY_RESPONSE"
+	envVarCompactCache        = "NETATMO_EXPORTER_COMPACT_CACHE"
+	envVarRemoteWriteURL      = "NETATMO_EXPORTER_REMOTE_WRITE_URL"
+	envVarPrefixProcess       = "NETATMO_EXPORTER_PREFIX_PROCESS_METRICS"
+	envVarStationLabel        = "NETATMO_EXPORTER_STATION_LABEL"
+	envVarModuleLabel         = "NETATMO_EXPORTER_MODULE_LABEL"
+	envVarModuleIDLabel       = "NETATMO_EXPORTER_MODULE_ID_LABEL"
+	envVarExternalLabels      = "NETATMO_EXPORTER_EXTERNAL_LABELS"
+	envVarOmitTimestamps      = "NETATMO_EXPORTER_OMIT_MISSING_TIMESTAMPS"
+	envVarMetricTimestamps    = "NETATMO_EXPORTER_METRIC_TIMESTAMPS"
+	envVarLogCaller           = "NETATMO_LOG_CALLER"
+	envVarLogHostname         = "NETATMO_LOG_HOSTNAME"
+	envVarPostAuthRedirect    = "NETATMO_EXPORTER_POST_AUTH_REDIRECT_URL"
+	envVarMetricsPath         = "NETATMO_EXPORTER_METRICS_PATH"
+	envVarRoutePrefix         = "NETATMO_EXPORTER_ROUTE_PREFIX"
+	envVarTokenRefresh        = "NETATMO_EXPORTER_TOKEN_REFRESH_HANDLER"
+	envVarTrustForwarded      = "NETATMO_EXPORTER_TRUST_FORWARDED_HEADERS"
 	envVarDebugHandlers       = "DEBUG_HANDLERS"
 	envVarLogLevel            = "NETATMO_LOG_LEVEL"
 	envVarRefreshInterval     = "NETATMO_REFRESH_INTERVAL"
+	envVarRefreshJitter       = "NETATMO_REFRESH_JITTER"
+	envVarRefreshBuckets      = "NETATMO_REFRESH_DURATION_BUCKETS"
+	envVarInitialTimeout      = "NETATMO_EXPORTER_INITIAL_REFRESH_TIMEOUT"
+	envVarWarmupDelay         = "NETATMO_EXPORTER_WARMUP_DELAY"
 	envVarStaleDuration       = "NETATMO_AGE_STALE"
+	envVarStaleByType         = "NETATMO_AGE_STALE_BY_TYPE"
+	envVarMaxCacheAge         = "NETATMO_EXPORTER_MAX_CACHE_AGE"
+	envVarModuleGracePeriod   = "NETATMO_EXPORTER_MODULE_GRACE_PERIOD"
+	envVarBackfillDuration    = "NETATMO_EXPORTER_BACKFILL_DURATION"
+	envVarMaxResponseBytes    = "NETATMO_EXPORTER_MAX_RESPONSE_BYTES"
+	envVarAverageWindow       = "NETATMO_EXPORTER_AVERAGE_WINDOW"
+	envVarExchangeTimeout     = "NETATMO_EXPORTER_AUTH_EXCHANGE_TIMEOUT"
+	envVarExchangeRetries     = "NETATMO_EXPORTER_AUTH_EXCHANGE_RETRIES"
 	envVarNetatmoClientID     = "NETATMO_CLIENT_ID"
 	envVarNetatmoClientSecret = "NETATMO_CLIENT_SECRET"
+	envVarRefreshToken        = "NETATMO_REFRESH_TOKEN"
 
 	flagListenAddress       = "addr"
 	flagExternalURL         = "external-url"
 	flagTokenFile           = "token-file"
+	flagOnBadToken          = "on-bad-token"
+	flagCacheFile           = "cache-file"
+	flagReplayFile          = "replay-file"
+	flagUserAgent           = "user-agent"
+	flagReadHeaderTimeout   = "read-header-timeout"
+	flagReadTimeout         = "read-timeout"
+	flagWriteTimeout        = "write-timeout"
+	flagIdleTimeout         = "idle-timeout"
+	flagEnableCompression   = "enable-compression"
+	flagEnableHomeCoach     = "enable-homecoach"
+	flagSignalTrend         = "signal-trend"
+	flagEnableMetrics       = "enable-metrics"
+	flagDisableMetrics      = "disable-metrics"
+	flagSensorBounds        = "sensor-bounds"
+	flagComfortThresholds   = "comfort-thresholds"
+	flagConvenience         = "enable-convenience-metrics"
+	flagWindUnit            = "wind-unit"
+	flagTemperatureUnits    = "temperature-units"
+	flagAcceptEmpty         = "accept-empty-response"
+	flagCompactCache        = "compact-cache"
+	flagRemoteWriteURL      = "remote-write-url"
+	flagPrefixProcess       = "prefix-process-metrics"
+	flagStationLabel        = "station-label"
+	flagModuleLabel         = "module-label"
+	flagModuleIDLabel       = "module-id-label"
+	flagExternalLabels      = "external-labels"
+	flagOmitTimestamps      = "omit-missing-timestamps"
+	flagMetricTimestamps    = "metric-timestamps"
+	flagLogCaller           = "log-caller"
+	flagLogHostname         = "log-hostname"
+	flagPostAuthRedirect    = "post-auth-redirect-url"
+	flagMetricsPath         = "metrics-path"
+	flagRoutePrefix         = "route-prefix"
+	flagTokenRefresh        = "token-refresh-handler"
+	flagTrustForwarded      = "trust-forwarded-headers"
 	flagDebugHandlers       = "debug-handlers"
+	flagValidate            = "validate"
 	flagLogLevel            = "log-level"
 	flagRefreshInterval     = "refresh-interval"
+	flagRefreshJitter       = "refresh-jitter"
+	flagRefreshBuckets      = "refresh-duration-buckets"
+	flagWarmupDelay         = "warmup-delay"
+	flagInitialTimeout      = "initial-refresh-timeout"
 	flagStaleDuration       = "age-stale"
+	flagStaleByType         = "age-stale-by-type"
+	flagMaxCacheAge         = "max-cache-age"
+	flagModuleGracePeriod   = "module-grace-period"
+	flagBackfillDuration    = "backfill-duration"
+	flagMaxResponseBytes    = "max-response-bytes"
+	flagAverageWindow       = "average-window"
+	flagExchangeTimeout     = "auth-exchange-timeout"
+	flagExchangeRetries     = "auth-exchange-retries"
 	flagNetatmoClientID     = "client-id"
 	flagNetatmoClientSecret = "client-secret"
+	flagClientIDFile        = "client-id-file"
+	flagClientSecretFile    = "client-secret-file"
+	flagRefreshToken        = "refresh-token"
+
+	// maxBackfillDuration limits the requested measurements, the API returns at most 1024 samples per request.
+	maxBackfillDuration = 24 * time.Hour
+
+	// fileSuffix is appended to the name of an environment variable for reading its value from a file.
+	fileSuffix = "_FILE"
+
+	// UnixSocketPrefix marks a listen address as a path to a Unix domain socket.
+	UnixSocketPrefix = "unix:"
 
 	defaultRefreshInterval = 8 * time.Minute
 	defaultStaleDuration   = 60 * time.Minute
+	defaultGracePeriod     = 24 * time.Hour
+	defaultStationLabel    = "station"
+	defaultModuleLabel     = "module"
+	defaultWindUnit        = "kph"
+	defaultMetricsPath     = "/metrics"
+	defaultOnBadToken      = "fail"
+
+	defaultExchangeTimeout = 10 * time.Second
+	defaultExchangeRetries = 2
+
+	defaultReadHeaderTimeout = 10 * time.Second
+	defaultReadTimeout       = 30 * time.Second
+	defaultWriteTimeout      = time.Minute
+	defaultIdleTimeout       = 2 * time.Minute
 )
 
 var (
 	defaultConfig = Config{
-		Addr:            ":9210",
-		LogLevel:        logLevel(logrus.InfoLevel),
-		RefreshInterval: defaultRefreshInterval,
-		StaleDuration:   defaultStaleDuration,
+		Addr:                   ":9210",
+		ReadHeaderTimeout:      defaultReadHeaderTimeout,
+		ReadTimeout:            defaultReadTimeout,
+		WriteTimeout:           defaultWriteTimeout,
+		IdleTimeout:            defaultIdleTimeout,
+		EnableCompression:      true,
+		LogLevel:               logLevel(logrus.InfoLevel),
+		RefreshInterval:        defaultRefreshInterval,
+		StaleDuration:          defaultStaleDuration,
+		ModuleGracePeriod:      defaultGracePeriod,
+		RefreshDurationBuckets: defaultRefreshBuckets,
+		StationLabel:           defaultStationLabel,
+		ModuleLabel:            defaultModuleLabel,
+		WindUnit:               defaultWindUnit,
+		TemperatureUnits:       defaultTemperatureUnits,
+		MetricsPath:            defaultMetricsPath,
+		OnBadToken:             defaultOnBadToken,
+		ExchangeTimeout:        defaultExchangeTimeout,
+		ExchangeRetries:        defaultExchangeRetries,
 	}
 
+	// defaultRefreshBuckets covers the usual duration of a refresh, which takes a few seconds.
+	defaultRefreshBuckets = buckets{0.25, 0.5, 1, 2, 5, 10, 20, 30, 60}
+
+	defaultTemperatureUnits = temperatureUnits{"celsius"}
+
 	errNoBinaryName          = errors.New("need the binary name as first argument")
 	errNoListenAddress       = errors.New("no listen address")
+	errNoSocketPath          = errors.New("no path for unix socket")
+	errNoExternalURL         = errors.New("need an external URL when listening on a unix socket")
+	errExternalURLScheme     = errors.New("scheme needs to be http or https")
+	errExternalURLHost       = errors.New("needs to contain a host")
+	errExternalURLQuery      = errors.New("can not contain a query or fragment")
 	errNoTokenFile           = errors.New("need a token file to save the token")
 	errNoNetatmoClientID     = errors.New("need a NetAtmo client ID")
 	errNoNetatmoClientSecret = errors.New("need a NetAtmo client secret")
+	errInvalidRefreshJitter  = errors.New("refresh jitter needs to be positive and smaller than the refresh interval")
+	errInvalidRefreshBuckets = errors.New("refresh duration buckets need to be positive and strictly increasing")
+	errInvalidSensorBounds   = errors.New("sensor bounds need to have the format \"metric=min:max\" with min < max")
+	errInvalidExternalLabel  = errors.New("external labels need to have the format \"name=value\" with a non-empty value")
+	errInvalidLabelName      = errors.New("label names need to be valid Prometheus label names, which are different from each other and the other labels")
+	errInvalidStaleByType    = errors.New("stale durations need to have the format \"type=duration\" with a positive duration")
+	errInvalidComfort        = errors.New("comfort thresholds need to have the format \"name=limit1:limit2:limit3\"")
+	errInvalidWindUnit       = errors.New("wind unit needs to be one of kph, mph, ms or knots")
+	errInvalidTemperature    = errors.New("temperature units need to be a list of celsius and fahrenheit without duplicates")
+	errInvalidOnBadToken     = errors.New("behavior for bad tokens needs to be fail or reauth")
+	errValueAndFile          = errors.New("value and file can not be set at the same time")
+	errEmptySecretFile       = errors.New("file is empty")
+	errInvalidBackfill       = errors.New("backfill duration needs to be between zero and 24h")
+	errInvalidMaxResponse    = errors.New("maximum response size can not be negative")
+	errInvalidAverageWindow  = errors.New("average window can not be negative")
+	errInvalidExchange       = errors.New("timeout and retries of the token exchange can not be negative")
+	errInvalidMetricsPath    = errors.New("metrics path needs to start with a slash and can not be the start page")
+	errInvalidRoutePrefix    = errors.New("route prefix needs to start with a slash and can not end with one")
 )
 
 type logLevel logrus.Level
@@ -71,16 +243,373 @@ func (l *logLevel) Set(value string) error {
 	return nil
 }
 
+type buckets []float64
+
+func (b *buckets) Type() string {
+	return "seconds"
+}
+
+func (b *buckets) String() string {
+	parts := make([]string, 0, len(*b))
+	for _, bucket := range *b {
+		parts = append(parts, strconv.FormatFloat(bucket, 'g', -1, 64))
+	}
+
+	return strings.Join(parts, ",")
+}
+
+func (b *buckets) Set(value string) error {
+	parsed, err := parseBuckets(value)
+	if err != nil {
+		return err
+	}
+	*b = parsed
+
+	return nil
+}
+
+// Bounds contains the range of plausible values for a sensor metric.
+type Bounds struct {
+	Min float64
+	Max float64
+}
+
+type sensorBounds map[string]Bounds
+
+func (b *sensorBounds) Type() string {
+	return "bounds"
+}
+
+func (b *sensorBounds) String() string {
+	names := make([]string, 0, len(*b))
+	for name := range *b {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		bounds := (*b)[name]
+		parts = append(parts, fmt.Sprintf("%s=%s:%s", name,
+			strconv.FormatFloat(bounds.Min, 'g', -1, 64),
+			strconv.FormatFloat(bounds.Max, 'g', -1, 64)))
+	}
+
+	return strings.Join(parts, ",")
+}
+
+func (b *sensorBounds) Set(value string) error {
+	parsed := make(sensorBounds)
+	for _, part := range splitList(value) {
+		name, limits, ok := strings.Cut(part, "=")
+		if !ok {
+			return fmt.Errorf("%w: %s", errInvalidSensorBounds, part)
+		}
+
+		rawMin, rawMax, ok := strings.Cut(limits, ":")
+		if !ok {
+			return fmt.Errorf("%w: %s", errInvalidSensorBounds, part)
+		}
+
+		minValue, err := strconv.ParseFloat(rawMin, 64)
+		if err != nil {
+			return fmt.Errorf("%w: %s", errInvalidSensorBounds, part)
+		}
+
+		maxValue, err := strconv.ParseFloat(rawMax, 64)
+		if err != nil || minValue >= maxValue {
+			return fmt.Errorf("%w: %s", errInvalidSensorBounds, part)
+		}
+
+		parsed[strings.TrimSpace(name)] = Bounds{
+			Min: minValue,
+			Max: maxValue,
+		}
+	}
+	*b = parsed
+
+	return nil
+}
+
+type externalLabels map[string]string
+
+func (l *externalLabels) Type() string {
+	return "labels"
+}
+
+func (l *externalLabels) String() string {
+	names := make([]string, 0, len(*l))
+	for name := range *l {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, name+"="+(*l)[name])
+	}
+
+	return strings.Join(parts, ",")
+}
+
+func (l *externalLabels) Set(value string) error {
+	parsed := make(externalLabels)
+	for _, part := range splitList(value) {
+		name, labelValue, ok := strings.Cut(part, "=")
+		labelValue = strings.TrimSpace(labelValue)
+		if !ok || labelValue == "" || !model.LabelValue(labelValue).IsValid() {
+			return fmt.Errorf("%w: %s", errInvalidExternalLabel, part)
+		}
+
+		parsed[strings.TrimSpace(name)] = labelValue
+	}
+	*l = parsed
+
+	return nil
+}
+
+type staleDurations map[string]time.Duration
+
+func (d *staleDurations) Type() string {
+	return "durations"
+}
+
+func (d *staleDurations) String() string {
+	types := make([]string, 0, len(*d))
+	for moduleType := range *d {
+		types = append(types, moduleType)
+	}
+	sort.Strings(types)
+
+	parts := make([]string, 0, len(types))
+	for _, moduleType := range types {
+		parts = append(parts, moduleType+"="+(*d)[moduleType].String())
+	}
+
+	return strings.Join(parts, ",")
+}
+
+func (d *staleDurations) Set(value string) error {
+	parsed := make(staleDurations)
+	for _, part := range splitList(value) {
+		moduleType, rawDuration, ok := strings.Cut(part, "=")
+		if !ok {
+			return fmt.Errorf("%w: %s", errInvalidStaleByType, part)
+		}
+
+		duration, err := time.ParseDuration(strings.TrimSpace(rawDuration))
+		if err != nil || duration <= 0 {
+			return fmt.Errorf("%w: %s", errInvalidStaleByType, part)
+		}
+
+		parsed[strings.TrimSpace(moduleType)] = duration
+	}
+	*d = parsed
+
+	return nil
+}
+
+// ComfortLimits contains the three limits between the comfort levels of one input.
+type ComfortLimits [3]float64
+
+type comfortThresholds map[string]ComfortLimits
+
+func (c *comfortThresholds) Type() string {
+	return "thresholds"
+}
+
+func (c *comfortThresholds) String() string {
+	names := make([]string, 0, len(*c))
+	for name := range *c {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		limits := (*c)[name]
+		values := make([]string, 0, len(limits))
+		for _, limit := range limits {
+			values = append(values, strconv.FormatFloat(limit, 'g', -1, 64))
+		}
+		parts = append(parts, name+"="+strings.Join(values, ":"))
+	}
+
+	return strings.Join(parts, ",")
+}
+
+func (c *comfortThresholds) Set(value string) error {
+	parsed := make(comfortThresholds)
+	for _, part := range splitList(value) {
+		name, rawLimits, ok := strings.Cut(part, "=")
+		if !ok {
+			return fmt.Errorf("%w: %s", errInvalidComfort, part)
+		}
+
+		values := strings.Split(rawLimits, ":")
+		var limits ComfortLimits
+		if len(values) != len(limits) {
+			return fmt.Errorf("%w: %s", errInvalidComfort, part)
+		}
+
+		for i, raw := range values {
+			limit, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				return fmt.Errorf("%w: %s", errInvalidComfort, part)
+			}
+			limits[i] = limit
+		}
+
+		parsed[strings.TrimSpace(name)] = limits
+	}
+	*c = parsed
+
+	return nil
+}
+
+// windUnits contains the supported units of the wind strength.
+var windUnits = map[string]bool{
+	"kph":   true,
+	"mph":   true,
+	"ms":    true,
+	"knots": true,
+}
+
+type windUnit string
+
+func (w *windUnit) Type() string {
+	return "unit"
+}
+
+func (w *windUnit) String() string {
+	return string(*w)
+}
+
+func (w *windUnit) Set(value string) error {
+	if !windUnits[value] {
+		return fmt.Errorf("%w: %s", errInvalidWindUnit, value)
+	}
+	*w = windUnit(value)
+
+	return nil
+}
+
+// temperatureUnitNames contains the supported units of the temperature.
+var temperatureUnitNames = map[string]bool{
+	"celsius":    true,
+	"fahrenheit": true,
+}
+
+// temperatureUnits contains the units, in which the temperature metrics are exported.
+type temperatureUnits []string
+
+func (t *temperatureUnits) Type() string {
+	return "units"
+}
+
+func (t *temperatureUnits) String() string {
+	return strings.Join(*t, ",")
+}
+
+func (t *temperatureUnits) Set(value string) error {
+	units := splitList(value)
+	if len(units) == 0 {
+		return fmt.Errorf("%w: %q", errInvalidTemperature, value)
+	}
+
+	seen := make(map[string]bool, len(units))
+	for _, unit := range units {
+		if !temperatureUnitNames[unit] || seen[unit] {
+			return fmt.Errorf("%w: %s", errInvalidTemperature, unit)
+		}
+		seen[unit] = true
+	}
+	*t = units
+
+	return nil
+}
+
+// onBadToken selects what happens when the token file exists, but can not be parsed.
+type onBadToken string
+
+func (o *onBadToken) Type() string {
+	return "behavior"
+}
+
+func (o *onBadToken) String() string {
+	return string(*o)
+}
+
+func (o *onBadToken) Set(value string) error {
+	if value != "fail" && value != "reauth" {
+		return fmt.Errorf("%w: %s", errInvalidOnBadToken, value)
+	}
+	*o = onBadToken(value)
+
+	return nil
+}
+
 // Config contains the configuration options.
 type Config struct {
-	Addr            string
-	ExternalURL     string
-	TokenFile       string
-	DebugHandlers   bool
-	LogLevel        logLevel
-	RefreshInterval time.Duration
-	StaleDuration   time.Duration
-	Netatmo         netatmo.Config
+	Addr                   string
+	ExternalURL            string
+	PostAuthRedirectURL    string
+	MetricsPath            string
+	RoutePrefix            string
+	TokenFiles             []string
+	OnBadToken             onBadToken
+	CacheFile              string
+	ReplayFile             string
+	UserAgent              string
+	ReadHeaderTimeout      time.Duration
+	ReadTimeout            time.Duration
+	WriteTimeout           time.Duration
+	IdleTimeout            time.Duration
+	EnableCompression      bool
+	EnableHomeCoach        bool
+	SignalTrend            bool
+	EnableMetrics          []string
+	DisableMetrics         []string
+	SensorBounds           sensorBounds
+	ComfortThresholds      comfortThresholds
+	ConvenienceMetrics     bool
+	WindUnit               windUnit
+	TemperatureUnits       temperatureUnits
+	AcceptEmptyResponse    bool
+	CompactCache           bool
+	RemoteWriteURL         string
+	PrefixProcessMetrics   bool
+	StationLabel           string
+	ModuleLabel            string
+	ModuleIDLabel          bool
+	ExternalLabels         externalLabels
+	OmitMissingTimestamps  bool
+	MetricTimestamps       bool
+	LogCaller              bool
+	LogHostname            bool
+	TokenRefreshHandler    bool
+	TrustForwardedHeaders  bool
+	DebugHandlers          bool
+	Validate               bool
+	LogLevel               logLevel
+	RefreshInterval        time.Duration
+	RefreshJitter          time.Duration
+	StaleDuration          time.Duration
+	StaleByType            staleDurations
+	MaxCacheAge            time.Duration
+	ModuleGracePeriod      time.Duration
+	BackfillDuration       time.Duration
+	MaxResponseBytes       int64
+	AverageWindow          int
+	ExchangeTimeout        time.Duration
+	ExchangeRetries        int
+	RefreshDurationBuckets buckets
+	InitialRefreshTimeout  time.Duration
+	WarmupDelay            time.Duration
+	Netatmo                netatmo.Config
+	ClientIDFile           string
+	ClientSecretFile       string
+	RefreshToken           string
 }
 
 // Parse takes the arguments and environment variables provided and creates the Config from that.
@@ -92,28 +621,108 @@ func Parse(args []string, getEnv func(string) string) (Config, error) {
 	}
 
 	flagSet := pflag.NewFlagSet(args[0], pflag.ContinueOnError)
-	flagSet.StringVarP(&cfg.Addr, flagListenAddress, "a", cfg.Addr, "Address to listen on.")
+	flagSet.StringVarP(&cfg.Addr, flagListenAddress, "a", cfg.Addr, "Address to listen on. Use \"unix:/path/to/socket\" to listen on a Unix domain socket.")
 	flagSet.StringVar(&cfg.ExternalURL, flagExternalURL, cfg.ExternalURL, "External URL to use as base for OAuth redirect URL.")
-	flagSet.StringVar(&cfg.TokenFile, flagTokenFile, cfg.TokenFile, "Path to token file for loading/persisting authentication token.")
+	flagSet.StringVar(&cfg.PostAuthRedirectURL, flagPostAuthRedirect, cfg.PostAuthRedirectURL, "URL the user is redirected to after a successful authentication. Defaults to the start page of the exporter.")
+	flagSet.StringVar(&cfg.MetricsPath, flagMetricsPath, cfg.MetricsPath, "Path under which the metrics are served, below the route prefix.")
+	flagSet.StringVar(&cfg.RoutePrefix, flagRoutePrefix, cfg.RoutePrefix, "Prefix of the paths of all HTTP handlers, for example when running behind a shared ingress. The external URL needs to contain the prefix as well.")
+	flagSet.StringArrayVar(&cfg.TokenFiles, flagTokenFile, cfg.TokenFiles, "Path to token file for loading/persisting authentication token. Can be repeated, the paths are tried in order when loading and the token is saved to the first one.")
+	flagSet.Var(&cfg.OnBadToken, flagOnBadToken, "Behavior when the token file can not be parsed: fail stops the exporter, reauth starts it unauthenticated, so that it can be authorized again.")
+	flagSet.StringVar(&cfg.CacheFile, flagCacheFile, cfg.CacheFile, "Path to file for persisting the sensor data, so that it is available after a restart.")
+	flagSet.StringVar(&cfg.ReplayFile, flagReplayFile, cfg.ReplayFile, "Path to a captured API response, which is used instead of reading from the NetAtmo API. No credentials are needed in this mode.")
+	flagSet.StringVar(&cfg.UserAgent, flagUserAgent, cfg.UserAgent, "User-Agent used for requests to the NetAtmo API. Defaults to \"netatmo-exporter/<version>\".")
+	flagSet.DurationVar(&cfg.ReadHeaderTimeout, flagReadHeaderTimeout, cfg.ReadHeaderTimeout, "Maximum time for reading the headers of a request to the exporter. Zero disables the timeout.")
+	flagSet.DurationVar(&cfg.ReadTimeout, flagReadTimeout, cfg.ReadTimeout, "Maximum time for reading a complete request to the exporter. Zero disables the timeout.")
+	flagSet.DurationVar(&cfg.WriteTimeout, flagWriteTimeout, cfg.WriteTimeout, "Maximum time for writing the response to a request. Zero disables the timeout.")
+	flagSet.DurationVar(&cfg.IdleTimeout, flagIdleTimeout, cfg.IdleTimeout, "Maximum time an idle keep-alive connection is kept open. Zero uses the read timeout.")
+	flagSet.BoolVar(&cfg.EnableCompression, flagEnableCompression, cfg.EnableCompression, "Compresses the metrics response using gzip, if the client supports it.")
+	flagSet.BoolVar(&cfg.EnableHomeCoach, flagEnableHomeCoach, cfg.EnableHomeCoach, "Also reads the data of Healthy Home Coach devices.")
+	flagSet.BoolVar(&cfg.SignalTrend, flagSignalTrend, cfg.SignalTrend, "Adds metrics with the trend of the wifi and RF signal strength of every module since the previous refresh.")
+	flagSet.StringSliceVar(&cfg.EnableMetrics, flagEnableMetrics, cfg.EnableMetrics, "Comma-separated list of sensor metrics to export. All other sensor metrics are disabled.")
+	flagSet.StringSliceVar(&cfg.DisableMetrics, flagDisableMetrics, cfg.DisableMetrics, "Comma-separated list of sensor metrics to disable.")
+	flagSet.Var(&cfg.SensorBounds, flagSensorBounds, "Comma-separated list of plausible ranges for sensor metrics (\"metric=min:max\"). Values outside of the range are dropped.")
+	flagSet.Var(&cfg.ComfortThresholds, flagComfortThresholds, "Comma-separated list of limits between the comfort levels (\"name=limit1:limit2:limit3\"), overriding the defaults.")
+	flagSet.BoolVar(&cfg.ConvenienceMetrics, flagConvenience, cfg.ConvenienceMetrics, "Exports the indoor and outdoor temperature of every station without module labels.")
+	flagSet.Var(&cfg.WindUnit, flagWindUnit, "Unit of the wind and gust strength metrics: kph, mph, ms (meters per second) or knots.")
+	flagSet.Var(&cfg.TemperatureUnits, flagTemperatureUnits, "Comma-separated list of the units of the temperature metrics: celsius and fahrenheit. Every additional unit adds a series for each temperature metric.")
+	flagSet.BoolVar(&cfg.AcceptEmptyResponse, flagAcceptEmpty, cfg.AcceptEmptyResponse, "Replaces the cached data, even if a refresh returns no devices.")
+	flagSet.BoolVar(&cfg.CompactCache, flagCompactCache, cfg.CompactCache, "Only keeps the data needed for the enabled metrics in the cache.")
+	flagSet.StringVar(&cfg.RemoteWriteURL, flagRemoteWriteURL, cfg.RemoteWriteURL, "URL of a Prometheus remote-write endpoint. If set, the metrics are also pushed to it after every refresh interval.")
+	flagSet.BoolVar(&cfg.PrefixProcessMetrics, flagPrefixProcess, cfg.PrefixProcessMetrics, "Adds the prefix \"netatmo_exporter_\" to the Go runtime and process metrics of the exporter.")
+	flagSet.StringVar(&cfg.StationLabel, flagStationLabel, cfg.StationLabel, "Name of the label containing the station name.")
+	flagSet.StringVar(&cfg.ModuleLabel, flagModuleLabel, cfg.ModuleLabel, "Name of the label containing the module name.")
+	flagSet.BoolVar(&cfg.ModuleIDLabel, flagModuleIDLabel, cfg.ModuleIDLabel, "Adds a \"module_id\" label containing the ID of the module, so that modules with the same name are always distinct.")
+	flagSet.Var(&cfg.ExternalLabels, flagExternalLabels, "Comma-separated list of labels (\"name=value\") added to all metrics of the exporter.")
+	flagSet.BoolVar(&cfg.OmitMissingTimestamps, flagOmitTimestamps, cfg.OmitMissingTimestamps, "Leaves out timestamp metrics, like netatmo_last_refresh_time, while the time is not known instead of exporting zero.")
+	flagSet.BoolVar(&cfg.MetricTimestamps, flagMetricTimestamps, cfg.MetricTimestamps, "Exports the sensor metrics with the time of the measurement as timestamp, instead of the time of the scrape.")
+	flagSet.BoolVar(&cfg.LogCaller, flagLogCaller, cfg.LogCaller, "Adds the source file and line of the call to every log entry.")
+	flagSet.BoolVar(&cfg.LogHostname, flagLogHostname, cfg.LogHostname, "Adds the hostname to every log entry in the \"host\" field.")
+	flagSet.BoolVar(&cfg.TokenRefreshHandler, flagTokenRefresh, cfg.TokenRefreshHandler, "Enables the /auth/refresh endpoint, which forces a refresh of the token when called using POST.")
+	flagSet.BoolVar(&cfg.TrustForwardedHeaders, flagTrustForwarded, cfg.TrustForwardedHeaders, "Uses the X-Forwarded-Proto and X-Forwarded-Host headers for the OAuth redirect URL. Only enable this behind a reverse proxy setting these headers.")
 	flagSet.BoolVar(&cfg.DebugHandlers, flagDebugHandlers, cfg.DebugHandlers, "Enables debugging HTTP handlers.")
+	flagSet.BoolVar(&cfg.Validate, flagValidate, cfg.Validate, "Validates the configuration, prints the metrics of a single refresh and exits.")
 	flagSet.Var(&cfg.LogLevel, flagLogLevel, "Sets the minimum level output through logging.")
 	flagSet.DurationVar(&cfg.RefreshInterval, flagRefreshInterval, cfg.RefreshInterval, "Time interval used for internal caching of NetAtmo sensor data.")
-	flagSet.DurationVar(&cfg.StaleDuration, flagStaleDuration, cfg.StaleDuration, "Data age to consider as stale. Stale data does not create metrics anymore.")
+	flagSet.DurationVar(&cfg.RefreshJitter, flagRefreshJitter, cfg.RefreshJitter, "Maximum random offset added to or subtracted from the refresh interval for every refresh. Zero disables the jitter.")
+	flagSet.Var(&cfg.RefreshDurationBuckets, flagRefreshBuckets, "Comma-separated list of bucket boundaries in seconds for the refresh and API request duration histograms.")
+	flagSet.DurationVar(&cfg.InitialRefreshTimeout, flagInitialTimeout, cfg.InitialRefreshTimeout, "Maximum time the first scrape waits for the initial refresh to complete. Zero disables waiting.")
+	flagSet.DurationVar(&cfg.WarmupDelay, flagWarmupDelay, cfg.WarmupDelay, "Time after the start in which scrapes do not trigger the first refresh, so that the token can be renewed first. Zero disables the delay.")
+	flagSet.DurationVar(&cfg.StaleDuration, flagStaleDuration, cfg.StaleDuration, "Data age to consider as stale. Stale data does not create metrics anymore, except battery and signal strength.")
+	flagSet.Var(&cfg.StaleByType, flagStaleByType, "Comma-separated list of stale durations overriding the data age to consider as stale for a module type (\"type=duration\"), for example \"NAModule1=30m\".")
+	flagSet.DurationVar(&cfg.MaxCacheAge, flagMaxCacheAge, cfg.MaxCacheAge, "Maximum age of the cached data. Older data does not create sensor metrics anymore and netatmo_up is zero. Zero disables the limit.")
+	flagSet.DurationVar(&cfg.ModuleGracePeriod, flagModuleGracePeriod, cfg.ModuleGracePeriod, "Time a module is still exported as last seen and offline after it disappeared from the API.")
+	flagSet.DurationVar(&cfg.BackfillDuration, flagBackfillDuration, cfg.BackfillDuration, "Enables the /backfill handler returning the measurements of this duration from the getmeasure API as JSON. Zero disables the handler.")
+	flagSet.Int64Var(&cfg.MaxResponseBytes, flagMaxResponseBytes, cfg.MaxResponseBytes, "Maximum size in bytes of the responses of the NetAtmo API. Larger responses cause the refresh to fail. Zero disables the limit.")
+	flagSet.IntVar(&cfg.AverageWindow, flagAverageWindow, cfg.AverageWindow, "Number of measurements averaged in the additional CO2 and noise metrics with the suffix \"_avg\". Zero disables the averages.")
+	flagSet.DurationVar(&cfg.ExchangeTimeout, flagExchangeTimeout, cfg.ExchangeTimeout, "Maximum time for exchanging the authorization code for a token after the OAuth redirect. Zero disables the timeout.")
+	flagSet.IntVar(&cfg.ExchangeRetries, flagExchangeRetries, cfg.ExchangeRetries, "Number of times a failed exchange of the authorization code is retried before showing an error.")
 	flagSet.StringVarP(&cfg.Netatmo.ClientID, flagNetatmoClientID, "i", cfg.Netatmo.ClientID, "Client ID for NetAtmo app.")
 	flagSet.StringVarP(&cfg.Netatmo.ClientSecret, flagNetatmoClientSecret, "s", cfg.Netatmo.ClientSecret, "Client secret for NetAtmo app.")
+	flagSet.StringVar(&cfg.ClientIDFile, flagClientIDFile, cfg.ClientIDFile, "Path to a file containing the client ID for NetAtmo app.")
+	flagSet.StringVar(&cfg.ClientSecretFile, flagClientSecretFile, cfg.ClientSecretFile, "Path to a file containing the client secret for NetAtmo app.")
+	flagSet.StringVar(&cfg.RefreshToken, flagRefreshToken, cfg.RefreshToken, "Refresh token used for authentication, if the token file contains no token.")
 
 	if err := flagSet.Parse(args[1:]); err != nil {
 		return Config{}, err
 	}
 
-	if err := applyEnvironment(&cfg, getEnv); err != nil {
+	if err := applySecretFiles(&cfg, getEnv); err != nil {
+		return Config{}, err
+	}
+
+	env := &fileEnv{
+		getenv: getEnv,
+	}
+	if err := applyEnvironment(&cfg, env.get); err != nil {
 		return Config{}, fmt.Errorf("error in environment: %s", err)
 	}
 
+	if env.err != nil {
+		return Config{}, fmt.Errorf("error in environment: %w", env.err)
+	}
+
 	if len(cfg.Addr) == 0 {
 		return Config{}, errNoListenAddress
 	}
 
+	if !strings.HasPrefix(cfg.MetricsPath, "/") || cfg.MetricsPath == "/" {
+		return Config{}, fmt.Errorf("%w: %q", errInvalidMetricsPath, cfg.MetricsPath)
+	}
+
+	if cfg.RoutePrefix != "" && (!strings.HasPrefix(cfg.RoutePrefix, "/") || strings.HasSuffix(cfg.RoutePrefix, "/")) {
+		return Config{}, fmt.Errorf("%w: %q", errInvalidRoutePrefix, cfg.RoutePrefix)
+	}
+
+	if cfg.IsUnixSocket() {
+		if cfg.SocketPath() == "" {
+			return Config{}, errNoSocketPath
+		}
+
+		if cfg.ExternalURL == "" {
+			return Config{}, errNoExternalURL
+		}
+	}
+
 	if cfg.ExternalURL == "" {
 		host, port, err := net.SplitHostPort(cfg.Addr)
 		if err != nil {
@@ -124,75 +733,715 @@ func Parse(args []string, getEnv func(string) string) (Config, error) {
 			host = "127.0.0.1"
 		}
 
-		cfg.ExternalURL = fmt.Sprintf("http://%s:%s", host, port)
+		cfg.ExternalURL = fmt.Sprintf("http://%s:%s%s", host, port, cfg.RoutePrefix)
 	}
 
-	if cfg.TokenFile == "" {
-		return Config{}, errNoTokenFile
+	externalURL, err := parseExternalURL(cfg.ExternalURL)
+	if err != nil {
+		return Config{}, fmt.Errorf("invalid external URL %q: %w", cfg.ExternalURL, err)
 	}
+	cfg.ExternalURL = externalURL
 
-	if len(cfg.Netatmo.ClientID) == 0 {
-		return Config{}, errNoNetatmoClientID
+	if cfg.PostAuthRedirectURL != "" {
+		if err := validateHTTPURL(cfg.PostAuthRedirectURL); err != nil {
+			return Config{}, fmt.Errorf("invalid post-auth redirect URL %q: %w", cfg.PostAuthRedirectURL, err)
+		}
 	}
 
-	if len(cfg.Netatmo.ClientSecret) == 0 {
-		return Config{}, errNoNetatmoClientSecret
+	// The credentials are not used when replaying a captured response, so that bug reports can be reproduced
+	// without an account.
+	if cfg.ReplayFile == "" {
+		if cfg.PrimaryTokenFile() == "" {
+			return Config{}, errNoTokenFile
+		}
+
+		if len(cfg.Netatmo.ClientID) == 0 {
+			return Config{}, errNoNetatmoClientID
+		}
+
+		if len(cfg.Netatmo.ClientSecret) == 0 {
+			return Config{}, errNoNetatmoClientSecret
+		}
 	}
 
 	if cfg.StaleDuration < cfg.RefreshInterval {
 		return Config{}, fmt.Errorf("stale duration smaller than refresh interval: %s < %s", cfg.StaleDuration, cfg.RefreshInterval)
 	}
 
-	return cfg, nil
-}
+	for moduleType, duration := range cfg.StaleByType {
+		if duration < cfg.RefreshInterval {
+			return Config{}, fmt.Errorf("stale duration of %s smaller than refresh interval: %s < %s", moduleType, duration, cfg.RefreshInterval)
+		}
+	}
 
-func applyEnvironment(cfg *Config, getenv func(string) string) error {
-	if envAddr := getenv(envVarListenAddress); envAddr != "" {
-		cfg.Addr = envAddr
+	if cfg.MaxCacheAge > 0 && cfg.MaxCacheAge < cfg.RefreshInterval {
+		return Config{}, fmt.Errorf("maximum cache age smaller than refresh interval: %s < %s", cfg.MaxCacheAge, cfg.RefreshInterval)
 	}
 
-	if externalURL := getenv(envVarExternalURL); externalURL != "" {
-		cfg.ExternalURL = externalURL
+	if cfg.BackfillDuration < 0 || cfg.BackfillDuration > maxBackfillDuration {
+		return Config{}, fmt.Errorf("%w: %s", errInvalidBackfill, cfg.BackfillDuration)
 	}
 
-	if tokenFile := getenv(envVarTokenFile); tokenFile != "" {
-		cfg.TokenFile = tokenFile
+	if cfg.MaxResponseBytes < 0 {
+		return Config{}, fmt.Errorf("%w: %d", errInvalidMaxResponse, cfg.MaxResponseBytes)
 	}
 
-	if envDebugHandlers := getenv(envVarDebugHandlers); envDebugHandlers != "" {
-		cfg.DebugHandlers = true
+	if cfg.AverageWindow < 0 {
+		return Config{}, fmt.Errorf("%w: %d", errInvalidAverageWindow, cfg.AverageWindow)
 	}
 
-	if envLogLevel := getenv(envVarLogLevel); envLogLevel != "" {
-		if err := cfg.LogLevel.Set(envLogLevel); err != nil {
-			return err
+	if cfg.ExchangeTimeout < 0 || cfg.ExchangeRetries < 0 {
+		return Config{}, fmt.Errorf("%w: %s, %d", errInvalidExchange, cfg.ExchangeTimeout, cfg.ExchangeRetries)
+	}
+
+	if err := validateLabelNames(cfg.StationLabel, cfg.ModuleLabel, cfg.ModuleIDLabel); err != nil {
+		return Config{}, err
+	}
+
+	if err := validateExternalLabels(cfg.ExternalLabels, cfg.StationLabel, cfg.ModuleLabel, cfg.ModuleIDLabel); err != nil {
+		return Config{}, err
+	}
+
+	if cfg.RefreshJitter < 0 || (cfg.RefreshJitter > 0 && cfg.RefreshJitter >= cfg.RefreshInterval) {
+		return Config{}, fmt.Errorf("%w: %s", errInvalidRefreshJitter, cfg.RefreshJitter)
+	}
+
+	if err := validateBuckets(cfg.RefreshDurationBuckets); err != nil {
+		return Config{}, err
+	}
+
+	if cfg.RemoteWriteURL != "" {
+		if err := validateHTTPURL(cfg.RemoteWriteURL); err != nil {
+			return Config{}, fmt.Errorf("invalid remote-write URL %q: %w", cfg.RemoteWriteURL, err)
 		}
 	}
 
-	if envRefreshInterval := getenv(envVarRefreshInterval); envRefreshInterval != "" {
-		duration, err := time.ParseDuration(envRefreshInterval)
+	return cfg, nil
+}
+
+// applySecretFiles reads the credentials from the files given using flags. The environment is applied afterwards
+// and would silently replace the credentials read from the files, so setting both is rejected.
+func applySecretFiles(cfg *Config, getenv func(string) string) error {
+	for _, secret := range []struct {
+		flag     string
+		fileFlag string
+		envVar   string
+		fileName string
+		value    *string
+	}{
+		{flagNetatmoClientID, flagClientIDFile, envVarNetatmoClientID, cfg.ClientIDFile, &cfg.Netatmo.ClientID},
+		{flagNetatmoClientSecret, flagClientSecretFile, envVarNetatmoClientSecret, cfg.ClientSecretFile, &cfg.Netatmo.ClientSecret},
+	} {
+		if secret.fileName == "" {
+			continue
+		}
+
+		if *secret.value != "" {
+			return fmt.Errorf("%w: --%s and --%s", errValueAndFile, secret.flag, secret.fileFlag)
+		}
+
+		for _, envVar := range []string{secret.envVar, secret.envVar + fileSuffix} {
+			if getenv(envVar) != "" {
+				return fmt.Errorf("%w: --%s and %s", errValueAndFile, secret.fileFlag, envVar)
+			}
+		}
+
+		value, err := readSecretFile(secret.fileName)
 		if err != nil {
-			return err
+			return fmt.Errorf("error in --%s: %w", secret.fileFlag, err)
 		}
+		*secret.value = value
+	}
 
-		cfg.RefreshInterval = duration
+	return nil
+}
+
+// readSecretFile returns the content of the file without surrounding whitespace, like the newline added by
+// most editors.
+func readSecretFile(fileName string) (string, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return "", err
 	}
 
-	if envStaleDuration := getenv(envVarStaleDuration); envStaleDuration != "" {
-		duration, err := time.ParseDuration(envStaleDuration)
-		if err != nil {
-			return err
+	value := strings.TrimSpace(string(data))
+	if value == "" {
+		return "", fmt.Errorf("%w: %s", errEmptySecretFile, fileName)
+	}
+
+	return value, nil
+}
+
+// fileEnv looks up environment variables, which can also be read from a file named in the variable with the
+// fileSuffix appended, like NETATMO_CLIENT_SECRET_FILE. This is the usual way secrets are provided by Docker and
+// Kubernetes. The first error reading a file is kept in err, because the lookup itself can not return it.
+type fileEnv struct {
+	getenv func(string) string
+	err    error
+}
+
+func (e *fileEnv) get(key string) string {
+	value := e.getenv(key)
+	fileName := e.getenv(key + fileSuffix)
+	if fileName == "" {
+		return value
+	}
+
+	if value != "" {
+		e.fail(fmt.Errorf("%w: %s and %s", errValueAndFile, key, key+fileSuffix))
+		return ""
+	}
+
+	value, err := readSecretFile(fileName)
+	if err != nil {
+		e.fail(fmt.Errorf("error in %s: %w", key+fileSuffix, err))
+		return ""
+	}
+
+	return value
+}
+
+func (e *fileEnv) fail(err error) {
+	if e.err == nil {
+		e.err = err
+	}
+}
+
+// validateBuckets checks that the histogram buckets are positive and strictly increasing.
+func validateBuckets(b buckets) error {
+	for i, bucket := range b {
+		if bucket <= 0 || (i > 0 && bucket <= b[i-1]) {
+			return fmt.Errorf("%w: %s", errInvalidRefreshBuckets, b.String())
 		}
+	}
 
-		cfg.StaleDuration = duration
+	return nil
+}
+
+// moduleIDLabel is the name of the optional label containing the ID of the module.
+const moduleIDLabel = "module_id"
+
+// reservedLabels contains the other labels of the sensor metrics, which can not be used for station and module.
+var reservedLabels = map[string]bool{
+	"metric":      true,
+	"module_type": true,
+	"role":        true,
+}
+
+// validateLabelNames checks that the station and module label names are valid and do not clash with other labels.
+// The name of the module ID label is only reserved, if that label is enabled.
+func validateLabelNames(station, module string, moduleID bool) error {
+	for _, name := range []string{station, module} {
+		if !model.LabelName(name).IsValid() || strings.HasPrefix(name, model.ReservedLabelPrefix) || reservedLabels[name] || (moduleID && name == moduleIDLabel) {
+			return fmt.Errorf("%w: %q", errInvalidLabelName, name)
+		}
 	}
 
-	if envClientID := getenv(envVarNetatmoClientID); envClientID != "" {
-		cfg.Netatmo.ClientID = envClientID
+	if station == module {
+		return fmt.Errorf("%w: %q", errInvalidLabelName, station)
 	}
 
-	if envClientSecret := getenv(envVarNetatmoClientSecret); envClientSecret != "" {
-		cfg.Netatmo.ClientSecret = envClientSecret
+	return nil
+}
+
+// validateExternalLabels checks that the names of the external labels are valid and do not clash with the labels
+// of the sensor metrics.
+func validateExternalLabels(labels externalLabels, station, module string, moduleID bool) error {
+	for name := range labels {
+		if !model.LabelName(name).IsValid() || strings.HasPrefix(name, model.ReservedLabelPrefix) || reservedLabels[name] || name == station || name == module || (moduleID && name == moduleIDLabel) {
+			return fmt.Errorf("%w: %q", errInvalidLabelName, name)
+		}
+	}
+
+	return nil
+}
+
+// splitList splits a comma-separated list and removes surrounding whitespace from the elements.
+func splitList(raw string) []string {
+	var result []string
+	for _, part := range strings.Split(raw, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			result = append(result, part)
+		}
+	}
+
+	return result
+}
+
+// parseBuckets parses a comma-separated list of bucket boundaries.
+func parseBuckets(raw string) (buckets, error) {
+	var result buckets
+	for _, part := range strings.Split(raw, ",") {
+		bucket, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, bucket)
+	}
+
+	return result, nil
+}
+
+// parseExternalURL checks that the external URL is an absolute HTTP(S) URL and removes trailing slashes,
+// so that it can be used as a base for the OAuth redirect URL.
+func parseExternalURL(raw string) (string, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", err
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", errExternalURLScheme
+	}
+
+	if u.Host == "" {
+		return "", errExternalURLHost
+	}
+
+	if u.RawQuery != "" || u.Fragment != "" {
+		return "", errExternalURLQuery
+	}
+
+	return strings.TrimRight(u.String(), "/"), nil
+}
+
+// validateHTTPURL checks that the URL is an absolute HTTP(S) URL.
+func validateHTTPURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return errExternalURLScheme
+	}
+
+	if u.Host == "" {
+		return errExternalURLHost
+	}
+
+	return nil
+}
+
+// IsUnixSocket returns true if the listen address points to a Unix domain socket.
+func (c Config) IsUnixSocket() bool {
+	return strings.HasPrefix(c.Addr, UnixSocketPrefix)
+}
+
+// SocketPath returns the path of the Unix domain socket contained in the listen address.
+func (c Config) SocketPath() string {
+	return strings.TrimPrefix(c.Addr, UnixSocketPrefix)
+}
+
+// PrimaryTokenFile returns the token file the token is saved to, which is the first of the configured token files.
+func (c Config) PrimaryTokenFile() string {
+	if len(c.TokenFiles) == 0 {
+		return ""
+	}
+
+	return c.TokenFiles[0]
+}
+
+func applyEnvironment(cfg *Config, getenv func(string) string) error {
+	if envAddr := getenv(envVarListenAddress); envAddr != "" {
+		cfg.Addr = envAddr
+	}
+
+	if externalURL := getenv(envVarExternalURL); externalURL != "" {
+		cfg.ExternalURL = externalURL
+	}
+
+	if postAuthRedirect := getenv(envVarPostAuthRedirect); postAuthRedirect != "" {
+		cfg.PostAuthRedirectURL = postAuthRedirect
+	}
+
+	if metricsPath := getenv(envVarMetricsPath); metricsPath != "" {
+		cfg.MetricsPath = metricsPath
+	}
+
+	if routePrefix := getenv(envVarRoutePrefix); routePrefix != "" {
+		cfg.RoutePrefix = routePrefix
+	}
+
+	// The paths are separated like in PATH, so that a single path containing a comma is not split.
+	if tokenFiles := getenv(envVarTokenFile); tokenFiles != "" {
+		cfg.TokenFiles = filepath.SplitList(tokenFiles)
+	}
+
+	if cacheFile := getenv(envVarCacheFile); cacheFile != "" {
+		cfg.CacheFile = cacheFile
+	}
+
+	if replayFile := getenv(envVarReplayFile); replayFile != "" {
+		cfg.ReplayFile = replayFile
+	}
+
+	if userAgent := getenv(envVarUserAgent); userAgent != "" {
+		cfg.UserAgent = userAgent
+	}
+
+	if envReadHeaderTimeout := getenv(envVarReadHeaderTimeout); envReadHeaderTimeout != "" {
+		duration, err := time.ParseDuration(envReadHeaderTimeout)
+		if err != nil {
+			return err
+		}
+
+		cfg.ReadHeaderTimeout = duration
+	}
+
+	if envReadTimeout := getenv(envVarReadTimeout); envReadTimeout != "" {
+		duration, err := time.ParseDuration(envReadTimeout)
+		if err != nil {
+			return err
+		}
+
+		cfg.ReadTimeout = duration
+	}
+
+	if envWriteTimeout := getenv(envVarWriteTimeout); envWriteTimeout != "" {
+		duration, err := time.ParseDuration(envWriteTimeout)
+		if err != nil {
+			return err
+		}
+
+		cfg.WriteTimeout = duration
+	}
+
+	if envIdleTimeout := getenv(envVarIdleTimeout); envIdleTimeout != "" {
+		duration, err := time.ParseDuration(envIdleTimeout)
+		if err != nil {
+			return err
+		}
+
+		cfg.IdleTimeout = duration
+	}
+
+	if envEnableCompression := getenv(envVarEnableCompression); envEnableCompression != "" {
+		enableCompression, err := strconv.ParseBool(envEnableCompression)
+		if err != nil {
+			return err
+		}
+
+		cfg.EnableCompression = enableCompression
+	}
+
+	if envEnableHomeCoach := getenv(envVarEnableHomeCoach); envEnableHomeCoach != "" {
+		enableHomeCoach, err := strconv.ParseBool(envEnableHomeCoach)
+		if err != nil {
+			return err
+		}
+
+		cfg.EnableHomeCoach = enableHomeCoach
+	}
+
+	if envSignalTrend := getenv(envVarSignalTrend); envSignalTrend != "" {
+		signalTrend, err := strconv.ParseBool(envSignalTrend)
+		if err != nil {
+			return err
+		}
+
+		cfg.SignalTrend = signalTrend
+	}
+
+	if envEnableMetrics := getenv(envVarEnableMetrics); envEnableMetrics != "" {
+		cfg.EnableMetrics = splitList(envEnableMetrics)
+	}
+
+	if envDisableMetrics := getenv(envVarDisableMetrics); envDisableMetrics != "" {
+		cfg.DisableMetrics = splitList(envDisableMetrics)
+	}
+
+	if envSensorBounds := getenv(envVarSensorBounds); envSensorBounds != "" {
+		if err := cfg.SensorBounds.Set(envSensorBounds); err != nil {
+			return err
+		}
+	}
+
+	if envComfortThresholds := getenv(envVarComfortThresholds); envComfortThresholds != "" {
+		if err := cfg.ComfortThresholds.Set(envComfortThresholds); err != nil {
+			return err
+		}
+	}
+
+	if envConvenience := getenv(envVarConvenience); envConvenience != "" {
+		convenience, err := strconv.ParseBool(envConvenience)
+		if err != nil {
+			return err
+		}
+
+		cfg.ConvenienceMetrics = convenience
+	}
+
+	if envOnBadToken := getenv(envVarOnBadToken); envOnBadToken != "" {
+		if err := cfg.OnBadToken.Set(envOnBadToken); err != nil {
+			return err
+		}
+	}
+
+	if envWindUnit := getenv(envVarWindUnit); envWindUnit != "" {
+		if err := cfg.WindUnit.Set(envWindUnit); err != nil {
+			return err
+		}
+	}
+
+	if envTemperatureUnits := getenv(envVarTemperatureUnits); envTemperatureUnits != "" {
+		if err := cfg.TemperatureUnits.Set(envTemperatureUnits); err != nil {
+			return err
+		}
+	}
+
+	if envAcceptEmpty := getenv(envVarAcceptEmpty); envAcceptEmpty != "" {
+		acceptEmpty, err := strconv.ParseBool(envAcceptEmpty)
+		if err != nil {
+			return err
+		}
+
+		cfg.AcceptEmptyResponse = acceptEmpty
+	}
+
+	if envCompactCache := getenv(envVarCompactCache); envCompactCache != "" {
+		compactCache, err := strconv.ParseBool(envCompactCache)
+		if err != nil {
+			return err
+		}
+
+		cfg.CompactCache = compactCache
+	}
+
+	if remoteWriteURL := getenv(envVarRemoteWriteURL); remoteWriteURL != "" {
+		cfg.RemoteWriteURL = remoteWriteURL
+	}
+
+	if envPrefixProcess := getenv(envVarPrefixProcess); envPrefixProcess != "" {
+		prefixProcess, err := strconv.ParseBool(envPrefixProcess)
+		if err != nil {
+			return err
+		}
+
+		cfg.PrefixProcessMetrics = prefixProcess
+	}
+
+	if stationLabel := getenv(envVarStationLabel); stationLabel != "" {
+		cfg.StationLabel = stationLabel
+	}
+
+	if moduleLabel := getenv(envVarModuleLabel); moduleLabel != "" {
+		cfg.ModuleLabel = moduleLabel
+	}
+
+	if envModuleIDLabel := getenv(envVarModuleIDLabel); envModuleIDLabel != "" {
+		moduleIDLabel, err := strconv.ParseBool(envModuleIDLabel)
+		if err != nil {
+			return err
+		}
+
+		cfg.ModuleIDLabel = moduleIDLabel
+	}
+
+	if envExternalLabels := getenv(envVarExternalLabels); envExternalLabels != "" {
+		if err := cfg.ExternalLabels.Set(envExternalLabels); err != nil {
+			return err
+		}
+	}
+
+	if envOmitMissingTimestamps := getenv(envVarOmitTimestamps); envOmitMissingTimestamps != "" {
+		omitMissingTimestamps, err := strconv.ParseBool(envOmitMissingTimestamps)
+		if err != nil {
+			return err
+		}
+
+		cfg.OmitMissingTimestamps = omitMissingTimestamps
+	}
+
+	if envMetricTimestamps := getenv(envVarMetricTimestamps); envMetricTimestamps != "" {
+		metricTimestamps, err := strconv.ParseBool(envMetricTimestamps)
+		if err != nil {
+			return err
+		}
+
+		cfg.MetricTimestamps = metricTimestamps
+	}
+
+	if envLogCaller := getenv(envVarLogCaller); envLogCaller != "" {
+		logCaller, err := strconv.ParseBool(envLogCaller)
+		if err != nil {
+			return err
+		}
+
+		cfg.LogCaller = logCaller
+	}
+
+	if envLogHostname := getenv(envVarLogHostname); envLogHostname != "" {
+		logHostname, err := strconv.ParseBool(envLogHostname)
+		if err != nil {
+			return err
+		}
+
+		cfg.LogHostname = logHostname
+	}
+
+	if envTokenRefresh := getenv(envVarTokenRefresh); envTokenRefresh != "" {
+		tokenRefresh, err := strconv.ParseBool(envTokenRefresh)
+		if err != nil {
+			return err
+		}
+
+		cfg.TokenRefreshHandler = tokenRefresh
+	}
+
+	if envTrustForwarded := getenv(envVarTrustForwarded); envTrustForwarded != "" {
+		trustForwarded, err := strconv.ParseBool(envTrustForwarded)
+		if err != nil {
+			return err
+		}
+
+		cfg.TrustForwardedHeaders = trustForwarded
+	}
+
+	if envDebugHandlers := getenv(envVarDebugHandlers); envDebugHandlers != "" {
+		cfg.DebugHandlers = true
+	}
+
+	if envLogLevel := getenv(envVarLogLevel); envLogLevel != "" {
+		if err := cfg.LogLevel.Set(envLogLevel); err != nil {
+			return err
+		}
+	}
+
+	if envRefreshInterval := getenv(envVarRefreshInterval); envRefreshInterval != "" {
+		duration, err := time.ParseDuration(envRefreshInterval)
+		if err != nil {
+			return err
+		}
+
+		cfg.RefreshInterval = duration
+	}
+
+	if envRefreshJitter := getenv(envVarRefreshJitter); envRefreshJitter != "" {
+		duration, err := time.ParseDuration(envRefreshJitter)
+		if err != nil {
+			return err
+		}
+
+		cfg.RefreshJitter = duration
+	}
+
+	if envRefreshBuckets := getenv(envVarRefreshBuckets); envRefreshBuckets != "" {
+		buckets, err := parseBuckets(envRefreshBuckets)
+		if err != nil {
+			return err
+		}
+
+		cfg.RefreshDurationBuckets = buckets
+	}
+
+	if envInitialTimeout := getenv(envVarInitialTimeout); envInitialTimeout != "" {
+		duration, err := time.ParseDuration(envInitialTimeout)
+		if err != nil {
+			return err
+		}
+
+		cfg.InitialRefreshTimeout = duration
+	}
+
+	if envWarmupDelay := getenv(envVarWarmupDelay); envWarmupDelay != "" {
+		duration, err := time.ParseDuration(envWarmupDelay)
+		if err != nil {
+			return err
+		}
+
+		cfg.WarmupDelay = duration
+	}
+
+	if envStaleDuration := getenv(envVarStaleDuration); envStaleDuration != "" {
+		duration, err := time.ParseDuration(envStaleDuration)
+		if err != nil {
+			return err
+		}
+
+		cfg.StaleDuration = duration
+	}
+
+	if envStaleByType := getenv(envVarStaleByType); envStaleByType != "" {
+		if err := cfg.StaleByType.Set(envStaleByType); err != nil {
+			return err
+		}
+	}
+
+	if envMaxCacheAge := getenv(envVarMaxCacheAge); envMaxCacheAge != "" {
+		duration, err := time.ParseDuration(envMaxCacheAge)
+		if err != nil {
+			return err
+		}
+
+		cfg.MaxCacheAge = duration
+	}
+
+	if envGracePeriod := getenv(envVarModuleGracePeriod); envGracePeriod != "" {
+		duration, err := time.ParseDuration(envGracePeriod)
+		if err != nil {
+			return err
+		}
+
+		cfg.ModuleGracePeriod = duration
+	}
+
+	if envBackfill := getenv(envVarBackfillDuration); envBackfill != "" {
+		duration, err := time.ParseDuration(envBackfill)
+		if err != nil {
+			return err
+		}
+
+		cfg.BackfillDuration = duration
+	}
+
+	if envMaxResponse := getenv(envVarMaxResponseBytes); envMaxResponse != "" {
+		maxBytes, err := strconv.ParseInt(envMaxResponse, 10, 64)
+		if err != nil {
+			return err
+		}
+
+		cfg.MaxResponseBytes = maxBytes
+	}
+
+	if envAverageWindow := getenv(envVarAverageWindow); envAverageWindow != "" {
+		window, err := strconv.Atoi(envAverageWindow)
+		if err != nil {
+			return err
+		}
+
+		cfg.AverageWindow = window
+	}
+
+	if envExchangeTimeout := getenv(envVarExchangeTimeout); envExchangeTimeout != "" {
+		timeout, err := time.ParseDuration(envExchangeTimeout)
+		if err != nil {
+			return err
+		}
+
+		cfg.ExchangeTimeout = timeout
+	}
+
+	if envExchangeRetries := getenv(envVarExchangeRetries); envExchangeRetries != "" {
+		retries, err := strconv.Atoi(envExchangeRetries)
+		if err != nil {
+			return err
+		}
+
+		cfg.ExchangeRetries = retries
+	}
+
+	if envClientID := getenv(envVarNetatmoClientID); envClientID != "" {
+		cfg.Netatmo.ClientID = envClientID
+	}
+
+	if envClientSecret := getenv(envVarNetatmoClientSecret); envClientSecret != "" {
+		cfg.Netatmo.ClientSecret = envClientSecret
+	}
+
+	if envRefreshToken := getenv(envVarRefreshToken); envRefreshToken != "" {
+		cfg.RefreshToken = envRefreshToken
 	}
 
 	return nil
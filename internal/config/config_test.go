@@ -1,6 +1,9 @@
 package config
 
 import (
+	"errors"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 	"time"
@@ -37,12 +40,196 @@ func TestParseConfig(t *testing.T) {
 			},
 			env: map[string]string{},
 			wantConfig: Config{
-				Addr:            defaultConfig.Addr,
-				ExternalURL:     "http://127.0.0.1:9210",
-				TokenFile:       "token-file",
-				LogLevel:        logLevel(logrus.InfoLevel),
-				RefreshInterval: defaultRefreshInterval,
-				StaleDuration:   defaultStaleDuration,
+				Addr:                   defaultConfig.Addr,
+				ExternalURL:            "http://127.0.0.1:9210",
+				TokenFiles:             []string{"token-file"},
+				ReadHeaderTimeout:      defaultReadHeaderTimeout,
+				ReadTimeout:            defaultReadTimeout,
+				WriteTimeout:           defaultWriteTimeout,
+				IdleTimeout:            defaultIdleTimeout,
+				EnableCompression:      true,
+				LogLevel:               logLevel(logrus.InfoLevel),
+				RefreshInterval:        defaultRefreshInterval,
+				StaleDuration:          defaultStaleDuration,
+				ModuleGracePeriod:      defaultGracePeriod,
+				RefreshDurationBuckets: defaultRefreshBuckets,
+				StationLabel:           defaultStationLabel,
+				ModuleLabel:            defaultModuleLabel,
+				WindUnit:               defaultWindUnit,
+				TemperatureUnits:       defaultTemperatureUnits,
+				MetricsPath:            defaultMetricsPath,
+				OnBadToken:             defaultOnBadToken,
+				ExchangeTimeout:        defaultExchangeTimeout,
+				ExchangeRetries:        defaultExchangeRetries,
+				Netatmo: netatmo.Config{
+					ClientID:     "id",
+					ClientSecret: "secret",
+				},
+			},
+			wantErr: nil,
+		},
+		{
+			name: "route prefix",
+			args: []string{
+				"test-cmd",
+				"--" + flagTokenFile,
+				"token-file",
+				"--" + flagNetatmoClientID,
+				"id",
+				"--" + flagNetatmoClientSecret,
+				"secret",
+				"--" + flagRoutePrefix,
+				"/netatmo",
+			},
+			env: map[string]string{},
+			wantConfig: Config{
+				Addr:                   defaultConfig.Addr,
+				ExternalURL:            "http://127.0.0.1:9210/netatmo",
+				RoutePrefix:            "/netatmo",
+				TokenFiles:             []string{"token-file"},
+				ReadHeaderTimeout:      defaultReadHeaderTimeout,
+				ReadTimeout:            defaultReadTimeout,
+				WriteTimeout:           defaultWriteTimeout,
+				IdleTimeout:            defaultIdleTimeout,
+				EnableCompression:      true,
+				LogLevel:               logLevel(logrus.InfoLevel),
+				RefreshInterval:        defaultRefreshInterval,
+				StaleDuration:          defaultStaleDuration,
+				ModuleGracePeriod:      defaultGracePeriod,
+				RefreshDurationBuckets: defaultRefreshBuckets,
+				StationLabel:           defaultStationLabel,
+				ModuleLabel:            defaultModuleLabel,
+				WindUnit:               defaultWindUnit,
+				TemperatureUnits:       defaultTemperatureUnits,
+				MetricsPath:            defaultMetricsPath,
+				OnBadToken:             defaultOnBadToken,
+				ExchangeTimeout:        defaultExchangeTimeout,
+				ExchangeRetries:        defaultExchangeRetries,
+				Netatmo: netatmo.Config{
+					ClientID:     "id",
+					ClientSecret: "secret",
+				},
+			},
+			wantErr: nil,
+		},
+		{
+			name: "multiple token files",
+			args: []string{
+				"test-cmd",
+				"--" + flagTokenFile,
+				"token-new.json",
+				"--" + flagTokenFile,
+				"token-old.json",
+				"--" + flagNetatmoClientID,
+				"id",
+				"--" + flagNetatmoClientSecret,
+				"secret",
+			},
+			env: map[string]string{},
+			wantConfig: Config{
+				Addr:                   defaultConfig.Addr,
+				ExternalURL:            "http://127.0.0.1:9210",
+				TokenFiles:             []string{"token-new.json", "token-old.json"},
+				ReadHeaderTimeout:      defaultReadHeaderTimeout,
+				ReadTimeout:            defaultReadTimeout,
+				WriteTimeout:           defaultWriteTimeout,
+				IdleTimeout:            defaultIdleTimeout,
+				EnableCompression:      true,
+				LogLevel:               logLevel(logrus.InfoLevel),
+				RefreshInterval:        defaultRefreshInterval,
+				StaleDuration:          defaultStaleDuration,
+				ModuleGracePeriod:      defaultGracePeriod,
+				RefreshDurationBuckets: defaultRefreshBuckets,
+				StationLabel:           defaultStationLabel,
+				ModuleLabel:            defaultModuleLabel,
+				WindUnit:               defaultWindUnit,
+				TemperatureUnits:       defaultTemperatureUnits,
+				MetricsPath:            defaultMetricsPath,
+				OnBadToken:             defaultOnBadToken,
+				ExchangeTimeout:        defaultExchangeTimeout,
+				ExchangeRetries:        defaultExchangeRetries,
+				Netatmo: netatmo.Config{
+					ClientID:     "id",
+					ClientSecret: "secret",
+				},
+			},
+			wantErr: nil,
+		},
+		{
+			name: "token file containing comma",
+			args: []string{
+				"test-cmd",
+				"--" + flagTokenFile,
+				"tokens/a,b.json",
+				"--" + flagNetatmoClientID,
+				"id",
+				"--" + flagNetatmoClientSecret,
+				"secret",
+			},
+			env: map[string]string{},
+			wantConfig: Config{
+				Addr:                   defaultConfig.Addr,
+				ExternalURL:            "http://127.0.0.1:9210",
+				TokenFiles:             []string{"tokens/a,b.json"},
+				ReadHeaderTimeout:      defaultReadHeaderTimeout,
+				ReadTimeout:            defaultReadTimeout,
+				WriteTimeout:           defaultWriteTimeout,
+				IdleTimeout:            defaultIdleTimeout,
+				EnableCompression:      true,
+				LogLevel:               logLevel(logrus.InfoLevel),
+				RefreshInterval:        defaultRefreshInterval,
+				StaleDuration:          defaultStaleDuration,
+				ModuleGracePeriod:      defaultGracePeriod,
+				RefreshDurationBuckets: defaultRefreshBuckets,
+				StationLabel:           defaultStationLabel,
+				ModuleLabel:            defaultModuleLabel,
+				WindUnit:               defaultWindUnit,
+				TemperatureUnits:       defaultTemperatureUnits,
+				MetricsPath:            defaultMetricsPath,
+				OnBadToken:             defaultOnBadToken,
+				ExchangeTimeout:        defaultExchangeTimeout,
+				ExchangeRetries:        defaultExchangeRetries,
+				Netatmo: netatmo.Config{
+					ClientID:     "id",
+					ClientSecret: "secret",
+				},
+			},
+			wantErr: nil,
+		},
+		{
+			name: "multiple token files from environment",
+			args: []string{
+				"test-cmd",
+				"--" + flagNetatmoClientID,
+				"id",
+				"--" + flagNetatmoClientSecret,
+				"secret",
+			},
+			env: map[string]string{
+				envVarTokenFile: "token-new.json" + string(os.PathListSeparator) + "token-old.json",
+			},
+			wantConfig: Config{
+				Addr:                   defaultConfig.Addr,
+				ExternalURL:            "http://127.0.0.1:9210",
+				TokenFiles:             []string{"token-new.json", "token-old.json"},
+				ReadHeaderTimeout:      defaultReadHeaderTimeout,
+				ReadTimeout:            defaultReadTimeout,
+				WriteTimeout:           defaultWriteTimeout,
+				IdleTimeout:            defaultIdleTimeout,
+				EnableCompression:      true,
+				LogLevel:               logLevel(logrus.InfoLevel),
+				RefreshInterval:        defaultRefreshInterval,
+				StaleDuration:          defaultStaleDuration,
+				ModuleGracePeriod:      defaultGracePeriod,
+				RefreshDurationBuckets: defaultRefreshBuckets,
+				StationLabel:           defaultStationLabel,
+				ModuleLabel:            defaultModuleLabel,
+				WindUnit:               defaultWindUnit,
+				TemperatureUnits:       defaultTemperatureUnits,
+				MetricsPath:            defaultMetricsPath,
+				OnBadToken:             defaultOnBadToken,
+				ExchangeTimeout:        defaultExchangeTimeout,
+				ExchangeRetries:        defaultExchangeRetries,
 				Netatmo: netatmo.Config{
 					ClientID:     "id",
 					ClientSecret: "secret",
@@ -58,24 +245,127 @@ func TestParseConfig(t *testing.T) {
 			env: map[string]string{
 				envVarListenAddress:       ":8080",
 				envVarExternalURL:         "http://example.com",
+				envVarPostAuthRedirect:    "https://app.example.com/settings",
 				envVarTokenFile:           "token.json",
+				envVarOnBadToken:          "reauth",
+				envVarCacheFile:           "cache.json",
+				envVarReplayFile:          "capture.json",
+				envVarUserAgent:           "test-agent",
+				envVarReadHeaderTimeout:   "5s",
+				envVarReadTimeout:         "15s",
+				envVarWriteTimeout:        "45s",
+				envVarIdleTimeout:         "0s",
+				envVarEnableCompression:   "false",
+				envVarEnableHomeCoach:     "true",
+				envVarSignalTrend:         "true",
+				envVarEnableMetrics:       "netatmo_aircare_temperature_celsius, netatmo_aircare_co2_ppm",
+				envVarDisableMetrics:      "netatmo_aircare_co2_ppm",
+				envVarSensorBounds:        "netatmo_aircare_temperature_celsius=-50:60",
+				envVarComfortThresholds:   "co2=800:1200:1600",
+				envVarWindUnit:            "knots",
+				envVarTemperatureUnits:    "celsius,fahrenheit",
+				envVarConvenience:         "true",
+				envVarAcceptEmpty:         "true",
+				envVarCompactCache:        "true",
+				envVarRemoteWriteURL:      "https://prometheus.example.com/api/v1/write",
+				envVarPrefixProcess:       "true",
+				envVarTokenRefresh:        "true",
+				envVarTrustForwarded:      "true",
+				envVarStationLabel:        "location",
+				envVarModuleLabel:         "sensor",
+				envVarModuleIDLabel:       "true",
+				envVarExternalLabels:      "region=eu, site=home1",
+				envVarOmitTimestamps:      "true",
+				envVarMetricTimestamps:    "true",
+				envVarLogCaller:           "true",
+				envVarLogHostname:         "true",
+				envVarMetricsPath:         "/netatmo/metrics",
+				envVarRoutePrefix:         "/exporter",
 				envVarLogLevel:            "debug",
 				envVarRefreshInterval:     "5m",
+				envVarRefreshJitter:       "30s",
 				envVarStaleDuration:       "10m",
+				envVarStaleByType:         "NAModule1=30m",
+				envVarMaxCacheAge:         "1h",
+				envVarModuleGracePeriod:   "2h",
+				envVarBackfillDuration:    "1h",
+				envVarMaxResponseBytes:    "1048576",
+				envVarAverageWindow:       "6",
+				envVarExchangeTimeout:     "3s",
+				envVarExchangeRetries:     "5",
+				envVarRefreshBuckets:      "1, 2.5,10",
+				envVarInitialTimeout:      "5s",
+				envVarWarmupDelay:         "20s",
 				envVarNetatmoClientID:     "id",
 				envVarNetatmoClientSecret: "secret",
+				envVarRefreshToken:        "refresh-token",
 			},
 			wantConfig: Config{
-				Addr:            ":8080",
-				ExternalURL:     "http://example.com",
-				TokenFile:       "token.json",
-				LogLevel:        logLevel(logrus.DebugLevel),
-				RefreshInterval: 5 * time.Minute,
-				StaleDuration:   10 * time.Minute,
+				Addr:                ":8080",
+				ExternalURL:         "http://example.com",
+				PostAuthRedirectURL: "https://app.example.com/settings",
+				TokenFiles:          []string{"token.json"},
+				OnBadToken:          "reauth",
+				CacheFile:           "cache.json",
+				ReplayFile:          "capture.json",
+				UserAgent:           "test-agent",
+				ReadHeaderTimeout:   5 * time.Second,
+				ReadTimeout:         15 * time.Second,
+				WriteTimeout:        45 * time.Second,
+				EnableHomeCoach:     true,
+				SignalTrend:         true,
+				EnableMetrics:       []string{"netatmo_aircare_temperature_celsius", "netatmo_aircare_co2_ppm"},
+				DisableMetrics:      []string{"netatmo_aircare_co2_ppm"},
+				SensorBounds: sensorBounds{
+					"netatmo_aircare_temperature_celsius": {Min: -50, Max: 60},
+				},
+				ComfortThresholds: comfortThresholds{
+					"co2": {800, 1200, 1600},
+				},
+				ExternalLabels: externalLabels{
+					"region": "eu",
+					"site":   "home1",
+				},
+				StaleByType: staleDurations{
+					"NAModule1": 30 * time.Minute,
+				},
+				AcceptEmptyResponse:    true,
+				CompactCache:           true,
+				ConvenienceMetrics:     true,
+				RemoteWriteURL:         "https://prometheus.example.com/api/v1/write",
+				PrefixProcessMetrics:   true,
+				StationLabel:           "location",
+				ModuleLabel:            "sensor",
+				ModuleIDLabel:          true,
+				OmitMissingTimestamps:  true,
+				MetricTimestamps:       true,
+				LogCaller:              true,
+				LogHostname:            true,
+				WindUnit:               "knots",
+				TemperatureUnits:       temperatureUnits{"celsius", "fahrenheit"},
+				MetricsPath:            "/netatmo/metrics",
+				RoutePrefix:            "/exporter",
+				TokenRefreshHandler:    true,
+				TrustForwardedHeaders:  true,
+				LogLevel:               logLevel(logrus.DebugLevel),
+				RefreshInterval:        5 * time.Minute,
+				RefreshJitter:          30 * time.Second,
+				StaleDuration:          10 * time.Minute,
+				MaxCacheAge:            time.Hour,
+				ModuleGracePeriod:      2 * time.Hour,
+				BackfillDuration:       time.Hour,
+				MaxResponseBytes:       1048576,
+				AverageWindow:          6,
+				ExchangeTimeout:        3 * time.Second,
+				ExchangeRetries:        5,
+				RefreshDurationBuckets: []float64{1, 2.5, 10},
+				InitialRefreshTimeout:  5 * time.Second,
+				WarmupDelay:            20 * time.Second,
 				Netatmo: netatmo.Config{
 					ClientID:     "id",
 					ClientSecret: "secret",
 				},
+				RefreshToken: "refresh-token",
 			},
 			wantErr: nil,
 		},
@@ -101,65 +391,708 @@ func TestParseConfig(t *testing.T) {
 			wantErr: errNoListenAddress,
 		},
 		{
-			name: "no token file",
+			name: "validate",
 			args: []string{
 				"test-cmd",
+				"--" + flagValidate,
+				"--" + flagTokenFile,
+				"token-file",
+				"--" + flagNetatmoClientID,
+				"id",
+				"--" + flagNetatmoClientSecret,
+				"secret",
 			},
 			env: map[string]string{},
 			wantConfig: Config{
-				Addr: defaultConfig.Addr,
+				Addr:                   defaultConfig.Addr,
+				ExternalURL:            "http://127.0.0.1:9210",
+				TokenFiles:             []string{"token-file"},
+				ReadHeaderTimeout:      defaultReadHeaderTimeout,
+				ReadTimeout:            defaultReadTimeout,
+				WriteTimeout:           defaultWriteTimeout,
+				IdleTimeout:            defaultIdleTimeout,
+				EnableCompression:      true,
+				Validate:               true,
+				LogLevel:               logLevel(logrus.InfoLevel),
+				RefreshInterval:        defaultRefreshInterval,
+				StaleDuration:          defaultStaleDuration,
+				ModuleGracePeriod:      defaultGracePeriod,
+				RefreshDurationBuckets: defaultRefreshBuckets,
+				StationLabel:           defaultStationLabel,
+				ModuleLabel:            defaultModuleLabel,
+				WindUnit:               defaultWindUnit,
+				TemperatureUnits:       defaultTemperatureUnits,
+				MetricsPath:            defaultMetricsPath,
+				OnBadToken:             defaultOnBadToken,
+				ExchangeTimeout:        defaultExchangeTimeout,
+				ExchangeRetries:        defaultExchangeRetries,
 				Netatmo: netatmo.Config{
 					ClientID:     "id",
 					ClientSecret: "secret",
 				},
 			},
-			wantErr: errNoTokenFile,
+			wantErr: nil,
 		},
 		{
-			name: "no client id",
+			name: "refresh duration buckets",
 			args: []string{
 				"test-cmd",
+				"--" + flagRefreshBuckets,
+				"0.5,1,5",
 				"--" + flagTokenFile,
 				"token-file",
+				"--" + flagNetatmoClientID,
+				"id",
 				"--" + flagNetatmoClientSecret,
 				"secret",
 			},
 			env: map[string]string{},
 			wantConfig: Config{
-				Addr: defaultConfig.Addr,
+				Addr:                   defaultConfig.Addr,
+				ExternalURL:            "http://127.0.0.1:9210",
+				TokenFiles:             []string{"token-file"},
+				ReadHeaderTimeout:      defaultReadHeaderTimeout,
+				ReadTimeout:            defaultReadTimeout,
+				WriteTimeout:           defaultWriteTimeout,
+				IdleTimeout:            defaultIdleTimeout,
+				EnableCompression:      true,
+				LogLevel:               logLevel(logrus.InfoLevel),
+				RefreshInterval:        defaultRefreshInterval,
+				StaleDuration:          defaultStaleDuration,
+				ModuleGracePeriod:      defaultGracePeriod,
+				RefreshDurationBuckets: []float64{0.5, 1, 5},
+				StationLabel:           defaultStationLabel,
+				ModuleLabel:            defaultModuleLabel,
+				WindUnit:               defaultWindUnit,
+				TemperatureUnits:       defaultTemperatureUnits,
+				MetricsPath:            defaultMetricsPath,
+				OnBadToken:             defaultOnBadToken,
+				ExchangeTimeout:        defaultExchangeTimeout,
+				ExchangeRetries:        defaultExchangeRetries,
 				Netatmo: netatmo.Config{
 					ClientID:     "id",
 					ClientSecret: "secret",
 				},
 			},
-			wantErr: errNoNetatmoClientID,
+			wantErr: nil,
 		},
 		{
-			name: "no client secret",
+			name: "refresh jitter not smaller than interval",
+			args: []string{
+				"test-cmd",
+				"--" + flagRefreshInterval,
+				"5m",
+				"--" + flagRefreshJitter,
+				"5m",
+				"--" + flagTokenFile,
+				"token-file",
+				"--" + flagNetatmoClientID,
+				"id",
+				"--" + flagNetatmoClientSecret,
+				"secret",
+			},
+			env:        map[string]string{},
+			wantConfig: Config{},
+			wantErr:    errInvalidRefreshJitter,
+		},
+		{
+			name: "metrics path without slash",
+			args: []string{
+				"test-cmd",
+				"--" + flagMetricsPath,
+				"metrics",
+				"--" + flagTokenFile,
+				"token-file",
+				"--" + flagNetatmoClientID,
+				"id",
+				"--" + flagNetatmoClientSecret,
+				"secret",
+			},
+			env:        map[string]string{},
+			wantConfig: Config{},
+			wantErr:    errInvalidMetricsPath,
+		},
+		{
+			name: "route prefix with trailing slash",
+			args: []string{
+				"test-cmd",
+				"--" + flagRoutePrefix,
+				"/netatmo/",
+				"--" + flagTokenFile,
+				"token-file",
+				"--" + flagNetatmoClientID,
+				"id",
+				"--" + flagNetatmoClientSecret,
+				"secret",
+			},
+			env:        map[string]string{},
+			wantConfig: Config{},
+			wantErr:    errInvalidRoutePrefix,
+		},
+		{
+			name: "backfill duration too long",
+			args: []string{
+				"test-cmd",
+				"--" + flagBackfillDuration,
+				"48h",
+				"--" + flagTokenFile,
+				"token-file",
+				"--" + flagNetatmoClientID,
+				"id",
+				"--" + flagNetatmoClientSecret,
+				"secret",
+			},
+			env:        map[string]string{},
+			wantConfig: Config{},
+			wantErr:    errInvalidBackfill,
+		},
+		{
+			name: "negative maximum response size",
+			args: []string{
+				"test-cmd",
+				"--" + flagMaxResponseBytes,
+				"-1",
+				"--" + flagTokenFile,
+				"token-file",
+				"--" + flagNetatmoClientID,
+				"id",
+				"--" + flagNetatmoClientSecret,
+				"secret",
+			},
+			env:        map[string]string{},
+			wantConfig: Config{},
+			wantErr:    errInvalidMaxResponse,
+		},
+		{
+			name: "negative average window",
+			args: []string{
+				"test-cmd",
+				"--" + flagAverageWindow,
+				"-1",
+				"--" + flagTokenFile,
+				"token-file",
+				"--" + flagNetatmoClientID,
+				"id",
+				"--" + flagNetatmoClientSecret,
+				"secret",
+			},
+			env:        map[string]string{},
+			wantConfig: Config{},
+			wantErr:    errInvalidAverageWindow,
+		},
+		{
+			name: "negative exchange retries",
+			args: []string{
+				"test-cmd",
+				"--" + flagExchangeRetries,
+				"-1",
+				"--" + flagTokenFile,
+				"token-file",
+				"--" + flagNetatmoClientID,
+				"id",
+				"--" + flagNetatmoClientSecret,
+				"secret",
+			},
+			env:        map[string]string{},
+			wantConfig: Config{},
+			wantErr:    errInvalidExchange,
+		},
+		{
+			name: "refresh jitter negative",
+			args: []string{
+				"test-cmd",
+				"--" + flagRefreshJitter,
+				"-1s",
+				"--" + flagTokenFile,
+				"token-file",
+				"--" + flagNetatmoClientID,
+				"id",
+				"--" + flagNetatmoClientSecret,
+				"secret",
+			},
+			env:        map[string]string{},
+			wantConfig: Config{},
+			wantErr:    errInvalidRefreshJitter,
+		},
+		{
+			name: "refresh duration buckets not increasing",
+			args: []string{
+				"test-cmd",
+				"--" + flagRefreshBuckets,
+				"1,5,5",
+				"--" + flagTokenFile,
+				"token-file",
+				"--" + flagNetatmoClientID,
+				"id",
+				"--" + flagNetatmoClientSecret,
+				"secret",
+			},
+			env:        map[string]string{},
+			wantConfig: Config{},
+			wantErr:    errInvalidRefreshBuckets,
+		},
+		{
+			name: "refresh duration buckets not positive",
+			args: []string{
+				"test-cmd",
+				"--" + flagRefreshBuckets,
+				"0,1,5",
+				"--" + flagTokenFile,
+				"token-file",
+				"--" + flagNetatmoClientID,
+				"id",
+				"--" + flagNetatmoClientSecret,
+				"secret",
+			},
+			env:        map[string]string{},
+			wantConfig: Config{},
+			wantErr:    errInvalidRefreshBuckets,
+		},
+		{
+			name: "metric filter",
 			args: []string{
 				"test-cmd",
+				"--" + flagEnableMetrics,
+				"netatmo_aircare_temperature_celsius,netatmo_aircare_co2_ppm",
+				"--" + flagDisableMetrics,
+				"netatmo_aircare_co2_ppm",
 				"--" + flagTokenFile,
 				"token-file",
 				"--" + flagNetatmoClientID,
 				"id",
+				"--" + flagNetatmoClientSecret,
+				"secret",
 			},
 			env: map[string]string{},
 			wantConfig: Config{
-				Addr: defaultConfig.Addr,
+				Addr:                   defaultConfig.Addr,
+				ExternalURL:            "http://127.0.0.1:9210",
+				TokenFiles:             []string{"token-file"},
+				ReadHeaderTimeout:      defaultReadHeaderTimeout,
+				ReadTimeout:            defaultReadTimeout,
+				WriteTimeout:           defaultWriteTimeout,
+				IdleTimeout:            defaultIdleTimeout,
+				EnableCompression:      true,
+				EnableMetrics:          []string{"netatmo_aircare_temperature_celsius", "netatmo_aircare_co2_ppm"},
+				DisableMetrics:         []string{"netatmo_aircare_co2_ppm"},
+				LogLevel:               logLevel(logrus.InfoLevel),
+				RefreshInterval:        defaultRefreshInterval,
+				StaleDuration:          defaultStaleDuration,
+				ModuleGracePeriod:      defaultGracePeriod,
+				RefreshDurationBuckets: defaultRefreshBuckets,
+				StationLabel:           defaultStationLabel,
+				ModuleLabel:            defaultModuleLabel,
+				WindUnit:               defaultWindUnit,
+				TemperatureUnits:       defaultTemperatureUnits,
+				MetricsPath:            defaultMetricsPath,
+				OnBadToken:             defaultOnBadToken,
+				ExchangeTimeout:        defaultExchangeTimeout,
+				ExchangeRetries:        defaultExchangeRetries,
 				Netatmo: netatmo.Config{
 					ClientID:     "id",
 					ClientSecret: "secret",
 				},
 			},
-			wantErr: errNoNetatmoClientSecret,
+			wantErr: nil,
+		},
+		{
+			name: "disable compression",
+			args: []string{
+				"test-cmd",
+				"--" + flagEnableCompression + "=false",
+				"--" + flagTokenFile,
+				"token-file",
+				"--" + flagNetatmoClientID,
+				"id",
+				"--" + flagNetatmoClientSecret,
+				"secret",
+			},
+			env: map[string]string{},
+			wantConfig: Config{
+				Addr:                   defaultConfig.Addr,
+				ExternalURL:            "http://127.0.0.1:9210",
+				TokenFiles:             []string{"token-file"},
+				ReadHeaderTimeout:      defaultReadHeaderTimeout,
+				ReadTimeout:            defaultReadTimeout,
+				WriteTimeout:           defaultWriteTimeout,
+				IdleTimeout:            defaultIdleTimeout,
+				EnableCompression:      false,
+				LogLevel:               logLevel(logrus.InfoLevel),
+				RefreshInterval:        defaultRefreshInterval,
+				StaleDuration:          defaultStaleDuration,
+				ModuleGracePeriod:      defaultGracePeriod,
+				RefreshDurationBuckets: defaultRefreshBuckets,
+				StationLabel:           defaultStationLabel,
+				ModuleLabel:            defaultModuleLabel,
+				WindUnit:               defaultWindUnit,
+				TemperatureUnits:       defaultTemperatureUnits,
+				MetricsPath:            defaultMetricsPath,
+				OnBadToken:             defaultOnBadToken,
+				ExchangeTimeout:        defaultExchangeTimeout,
+				ExchangeRetries:        defaultExchangeRetries,
+				Netatmo: netatmo.Config{
+					ClientID:     "id",
+					ClientSecret: "secret",
+				},
+			},
+			wantErr: nil,
 		},
-	}
-
-	for _, tt := range tests {
-		tt := tt
-
-		t.Run(tt.name, func(t *testing.T) {
-			t.Parallel()
+
+		{
+			name: "unix socket",
+			args: []string{
+				"test-cmd",
+				"--" + flagListenAddress,
+				"unix:/run/netatmo-exporter.sock",
+				"--" + flagExternalURL,
+				"http://example.com",
+				"--" + flagTokenFile,
+				"token-file",
+				"--" + flagNetatmoClientID,
+				"id",
+				"--" + flagNetatmoClientSecret,
+				"secret",
+			},
+			env: map[string]string{},
+			wantConfig: Config{
+				Addr:                   "unix:/run/netatmo-exporter.sock",
+				ExternalURL:            "http://example.com",
+				TokenFiles:             []string{"token-file"},
+				ReadHeaderTimeout:      defaultReadHeaderTimeout,
+				ReadTimeout:            defaultReadTimeout,
+				WriteTimeout:           defaultWriteTimeout,
+				IdleTimeout:            defaultIdleTimeout,
+				EnableCompression:      true,
+				LogLevel:               logLevel(logrus.InfoLevel),
+				RefreshInterval:        defaultRefreshInterval,
+				StaleDuration:          defaultStaleDuration,
+				ModuleGracePeriod:      defaultGracePeriod,
+				RefreshDurationBuckets: defaultRefreshBuckets,
+				StationLabel:           defaultStationLabel,
+				ModuleLabel:            defaultModuleLabel,
+				WindUnit:               defaultWindUnit,
+				TemperatureUnits:       defaultTemperatureUnits,
+				MetricsPath:            defaultMetricsPath,
+				OnBadToken:             defaultOnBadToken,
+				ExchangeTimeout:        defaultExchangeTimeout,
+				ExchangeRetries:        defaultExchangeRetries,
+				Netatmo: netatmo.Config{
+					ClientID:     "id",
+					ClientSecret: "secret",
+				},
+			},
+			wantErr: nil,
+		},
+		{
+			name: "unix socket without path",
+			args: []string{
+				"test-cmd",
+				"--" + flagListenAddress,
+				"unix:",
+			},
+			env:        map[string]string{},
+			wantConfig: Config{},
+			wantErr:    errNoSocketPath,
+		},
+		{
+			name: "unix socket without external url",
+			args: []string{
+				"test-cmd",
+				"--" + flagListenAddress,
+				"unix:/run/netatmo-exporter.sock",
+			},
+			env:        map[string]string{},
+			wantConfig: Config{},
+			wantErr:    errNoExternalURL,
+		},
+		{
+			name: "external url with trailing slash",
+			args: []string{
+				"test-cmd",
+				"--" + flagExternalURL,
+				"https://example.com/netatmo/",
+				"--" + flagTokenFile,
+				"token-file",
+				"--" + flagNetatmoClientID,
+				"id",
+				"--" + flagNetatmoClientSecret,
+				"secret",
+			},
+			env: map[string]string{},
+			wantConfig: Config{
+				Addr:                   defaultConfig.Addr,
+				ExternalURL:            "https://example.com/netatmo",
+				TokenFiles:             []string{"token-file"},
+				ReadHeaderTimeout:      defaultReadHeaderTimeout,
+				ReadTimeout:            defaultReadTimeout,
+				WriteTimeout:           defaultWriteTimeout,
+				IdleTimeout:            defaultIdleTimeout,
+				EnableCompression:      true,
+				LogLevel:               logLevel(logrus.InfoLevel),
+				RefreshInterval:        defaultRefreshInterval,
+				StaleDuration:          defaultStaleDuration,
+				ModuleGracePeriod:      defaultGracePeriod,
+				RefreshDurationBuckets: defaultRefreshBuckets,
+				StationLabel:           defaultStationLabel,
+				ModuleLabel:            defaultModuleLabel,
+				WindUnit:               defaultWindUnit,
+				TemperatureUnits:       defaultTemperatureUnits,
+				MetricsPath:            defaultMetricsPath,
+				OnBadToken:             defaultOnBadToken,
+				ExchangeTimeout:        defaultExchangeTimeout,
+				ExchangeRetries:        defaultExchangeRetries,
+				Netatmo: netatmo.Config{
+					ClientID:     "id",
+					ClientSecret: "secret",
+				},
+			},
+			wantErr: nil,
+		},
+		{
+			name: "external url without scheme",
+			args: []string{
+				"test-cmd",
+				"--" + flagExternalURL,
+				"example.com:9210",
+			},
+			env:        map[string]string{},
+			wantConfig: Config{},
+			wantErr:    errExternalURLScheme,
+		},
+		{
+			name: "external url without host",
+			args: []string{
+				"test-cmd",
+				"--" + flagExternalURL,
+				"http:///netatmo",
+			},
+			env:        map[string]string{},
+			wantConfig: Config{},
+			wantErr:    errExternalURLHost,
+		},
+		{
+			name: "post-auth redirect url without host",
+			args: []string{
+				"test-cmd",
+				"--" + flagPostAuthRedirect,
+				"https:///settings",
+			},
+			env:        map[string]string{},
+			wantConfig: Config{},
+			wantErr:    errExternalURLHost,
+		},
+		{
+			name: "remote-write url without scheme",
+			args: []string{
+				"test-cmd",
+				"--" + flagRemoteWriteURL,
+				"prometheus.example.com/api/v1/write",
+				"--" + flagTokenFile,
+				"token-file",
+			},
+			env: map[string]string{
+				envVarNetatmoClientID:     "id",
+				envVarNetatmoClientSecret: "secret",
+			},
+			wantConfig: Config{},
+			wantErr:    errExternalURLScheme,
+		},
+		{
+			name: "invalid station label",
+			args: []string{
+				"test-cmd",
+				"--" + flagStationLabel,
+				"my-station",
+				"--" + flagTokenFile,
+				"token-file",
+				"--" + flagNetatmoClientID,
+				"id",
+				"--" + flagNetatmoClientSecret,
+				"secret",
+			},
+			env:        map[string]string{},
+			wantConfig: Config{},
+			wantErr:    errInvalidLabelName,
+		},
+		{
+			name: "reserved module label",
+			args: []string{
+				"test-cmd",
+				"--" + flagModuleLabel,
+				"role",
+				"--" + flagTokenFile,
+				"token-file",
+				"--" + flagNetatmoClientID,
+				"id",
+				"--" + flagNetatmoClientSecret,
+				"secret",
+			},
+			env:        map[string]string{},
+			wantConfig: Config{},
+			wantErr:    errInvalidLabelName,
+		},
+		{
+			name: "same label names",
+			args: []string{
+				"test-cmd",
+				"--" + flagModuleLabel,
+				"station",
+				"--" + flagTokenFile,
+				"token-file",
+				"--" + flagNetatmoClientID,
+				"id",
+				"--" + flagNetatmoClientSecret,
+				"secret",
+			},
+			env:        map[string]string{},
+			wantConfig: Config{},
+			wantErr:    errInvalidLabelName,
+		},
+		{
+			name: "module label clashing with module ID label",
+			args: []string{
+				"test-cmd",
+				"--" + flagModuleLabel,
+				"module_id",
+				"--" + flagModuleIDLabel,
+				"--" + flagTokenFile,
+				"token-file",
+				"--" + flagNetatmoClientID,
+				"id",
+				"--" + flagNetatmoClientSecret,
+				"secret",
+			},
+			env:        map[string]string{},
+			wantConfig: Config{},
+			wantErr:    errInvalidLabelName,
+		},
+		{
+			name: "external label clashing with station label",
+			args: []string{
+				"test-cmd",
+				"--" + flagExternalLabels,
+				"station=home",
+				"--" + flagTokenFile,
+				"token-file",
+				"--" + flagNetatmoClientID,
+				"id",
+				"--" + flagNetatmoClientSecret,
+				"secret",
+			},
+			env:        map[string]string{},
+			wantConfig: Config{},
+			wantErr:    errInvalidLabelName,
+		},
+		{
+			name: "invalid external label name",
+			args: []string{
+				"test-cmd",
+				"--" + flagExternalLabels,
+				"data-center=eu",
+				"--" + flagTokenFile,
+				"token-file",
+				"--" + flagNetatmoClientID,
+				"id",
+				"--" + flagNetatmoClientSecret,
+				"secret",
+			},
+			env:        map[string]string{},
+			wantConfig: Config{},
+			wantErr:    errInvalidLabelName,
+		},
+		{
+			name: "no token file",
+			args: []string{
+				"test-cmd",
+			},
+			env: map[string]string{},
+			wantConfig: Config{
+				Addr: defaultConfig.Addr,
+				Netatmo: netatmo.Config{
+					ClientID:     "id",
+					ClientSecret: "secret",
+				},
+			},
+			wantErr: errNoTokenFile,
+		},
+		{
+			name: "no client id",
+			args: []string{
+				"test-cmd",
+				"--" + flagTokenFile,
+				"token-file",
+				"--" + flagNetatmoClientSecret,
+				"secret",
+			},
+			env: map[string]string{},
+			wantConfig: Config{
+				Addr: defaultConfig.Addr,
+				Netatmo: netatmo.Config{
+					ClientID:     "id",
+					ClientSecret: "secret",
+				},
+			},
+			wantErr: errNoNetatmoClientID,
+		},
+		{
+			name: "no client secret",
+			args: []string{
+				"test-cmd",
+				"--" + flagTokenFile,
+				"token-file",
+				"--" + flagNetatmoClientID,
+				"id",
+			},
+			env: map[string]string{},
+			wantConfig: Config{
+				Addr: defaultConfig.Addr,
+				Netatmo: netatmo.Config{
+					ClientID:     "id",
+					ClientSecret: "secret",
+				},
+			},
+			wantErr: errNoNetatmoClientSecret,
+		},
+		{
+			name: "replay without credentials",
+			args: []string{
+				"test-cmd",
+				"--" + flagReplayFile,
+				"capture.json",
+			},
+			env: map[string]string{},
+			wantConfig: Config{
+				Addr:                   defaultConfig.Addr,
+				ExternalURL:            "http://127.0.0.1:9210",
+				ReplayFile:             "capture.json",
+				ReadHeaderTimeout:      defaultReadHeaderTimeout,
+				ReadTimeout:            defaultReadTimeout,
+				WriteTimeout:           defaultWriteTimeout,
+				IdleTimeout:            defaultIdleTimeout,
+				EnableCompression:      true,
+				LogLevel:               logLevel(logrus.InfoLevel),
+				RefreshInterval:        defaultRefreshInterval,
+				StaleDuration:          defaultStaleDuration,
+				ModuleGracePeriod:      defaultGracePeriod,
+				RefreshDurationBuckets: defaultRefreshBuckets,
+				StationLabel:           defaultStationLabel,
+				ModuleLabel:            defaultModuleLabel,
+				WindUnit:               defaultWindUnit,
+				TemperatureUnits:       defaultTemperatureUnits,
+				MetricsPath:            defaultMetricsPath,
+				OnBadToken:             defaultOnBadToken,
+				ExchangeTimeout:        defaultExchangeTimeout,
+				ExchangeRetries:        defaultExchangeRetries,
+			},
+			wantErr: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
 
 			getenv := func(key string) string {
 				return tt.env[key]
@@ -167,7 +1100,7 @@ func TestParseConfig(t *testing.T) {
 
 			config, err := Parse(tt.args, getenv)
 
-			if err != tt.wantErr {
+			if !errors.Is(err, tt.wantErr) {
 				t.Errorf("got error %q, want %q", err, tt.wantErr)
 			}
 
@@ -181,3 +1114,560 @@ func TestParseConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestComfortThresholdsSet(t *testing.T) {
+	tests := []struct {
+		name           string
+		value          string
+		wantThresholds comfortThresholds
+		wantErr        error
+	}{
+		{
+			name:  "success",
+			value: "co2=800:1200:1600, temperature_low=20:18:16",
+			wantThresholds: comfortThresholds{
+				"co2":             {800, 1200, 1600},
+				"temperature_low": {20, 18, 16},
+			},
+			wantErr: nil,
+		},
+		{
+			name:    "no limits",
+			value:   "co2",
+			wantErr: errInvalidComfort,
+		},
+		{
+			name:    "too few limits",
+			value:   "co2=800:1200",
+			wantErr: errInvalidComfort,
+		},
+		{
+			name:    "invalid number",
+			value:   "co2=800:high:1600",
+			wantErr: errInvalidComfort,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var thresholds comfortThresholds
+			err := thresholds.Set(tt.value)
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("got error %q, want %q", err, tt.wantErr)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if !reflect.DeepEqual(thresholds, tt.wantThresholds) {
+				t.Errorf("got thresholds %v, want %v", thresholds, tt.wantThresholds)
+			}
+		})
+	}
+}
+
+func TestOnBadTokenSet(t *testing.T) {
+	tests := []struct {
+		name         string
+		value        string
+		wantBehavior onBadToken
+		wantErr      error
+	}{
+		{
+			name:         "fail",
+			value:        "fail",
+			wantBehavior: "fail",
+			wantErr:      nil,
+		},
+		{
+			name:         "reauth",
+			value:        "reauth",
+			wantBehavior: "reauth",
+			wantErr:      nil,
+		},
+		{
+			name:    "unknown behavior",
+			value:   "ignore",
+			wantErr: errInvalidOnBadToken,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var behavior onBadToken
+			err := behavior.Set(tt.value)
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("got error %q, want %q", err, tt.wantErr)
+			}
+
+			if behavior != tt.wantBehavior {
+				t.Errorf("got behavior %q, want %q", behavior, tt.wantBehavior)
+			}
+		})
+	}
+}
+
+func TestTemperatureUnitsSet(t *testing.T) {
+	tests := []struct {
+		name      string
+		value     string
+		wantUnits temperatureUnits
+		wantErr   error
+	}{
+		{
+			name:      "single unit",
+			value:     "fahrenheit",
+			wantUnits: temperatureUnits{"fahrenheit"},
+			wantErr:   nil,
+		},
+		{
+			name:      "multiple units",
+			value:     "celsius, fahrenheit",
+			wantUnits: temperatureUnits{"celsius", "fahrenheit"},
+			wantErr:   nil,
+		},
+		{
+			name:    "unknown unit",
+			value:   "celsius,kelvin",
+			wantErr: errInvalidTemperature,
+		},
+		{
+			name:    "duplicate unit",
+			value:   "celsius,celsius",
+			wantErr: errInvalidTemperature,
+		},
+		{
+			name:    "empty list",
+			value:   ",",
+			wantErr: errInvalidTemperature,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var units temperatureUnits
+			err := units.Set(tt.value)
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("got error %q, want %q", err, tt.wantErr)
+			}
+
+			if !reflect.DeepEqual(units, tt.wantUnits) {
+				t.Errorf("got units %v, want %v", units, tt.wantUnits)
+			}
+		})
+	}
+}
+
+func TestWindUnitSet(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		wantUnit windUnit
+		wantErr  error
+	}{
+		{
+			name:     "mph",
+			value:    "mph",
+			wantUnit: "mph",
+			wantErr:  nil,
+		},
+		{
+			name:     "meters per second",
+			value:    "ms",
+			wantUnit: "ms",
+			wantErr:  nil,
+		},
+		{
+			name:    "unknown unit",
+			value:   "beaufort",
+			wantErr: errInvalidWindUnit,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var unit windUnit
+			err := unit.Set(tt.value)
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("got error %q, want %q", err, tt.wantErr)
+			}
+
+			if unit != tt.wantUnit {
+				t.Errorf("got unit %q, want %q", unit, tt.wantUnit)
+			}
+		})
+	}
+}
+
+func TestSensorBoundsSet(t *testing.T) {
+	tests := []struct {
+		name       string
+		value      string
+		wantBounds sensorBounds
+		wantErr    error
+	}{
+		{
+			name:  "success",
+			value: "netatmo_aircare_temperature_celsius=-50:60, netatmo_aircare_humidity_percent=0:100",
+			wantBounds: sensorBounds{
+				"netatmo_aircare_temperature_celsius": {Min: -50, Max: 60},
+				"netatmo_aircare_humidity_percent":    {Min: 0, Max: 100},
+			},
+			wantErr: nil,
+		},
+		{
+			name:    "no range",
+			value:   "netatmo_aircare_temperature_celsius",
+			wantErr: errInvalidSensorBounds,
+		},
+		{
+			name:    "no maximum",
+			value:   "netatmo_aircare_temperature_celsius=-50",
+			wantErr: errInvalidSensorBounds,
+		},
+		{
+			name:    "invalid number",
+			value:   "netatmo_aircare_temperature_celsius=low:60",
+			wantErr: errInvalidSensorBounds,
+		},
+		{
+			name:    "minimum not smaller than maximum",
+			value:   "netatmo_aircare_temperature_celsius=60:-50",
+			wantErr: errInvalidSensorBounds,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var bounds sensorBounds
+			err := bounds.Set(tt.value)
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("got error %q, want %q", err, tt.wantErr)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if !reflect.DeepEqual(bounds, tt.wantBounds) {
+				t.Errorf("got bounds %v, want %v", bounds, tt.wantBounds)
+			}
+		})
+	}
+}
+
+func TestExternalLabelsSet(t *testing.T) {
+	tests := []struct {
+		name       string
+		value      string
+		wantLabels externalLabels
+		wantErr    error
+	}{
+		{
+			name:  "success",
+			value: "region=eu, site = home1",
+			wantLabels: externalLabels{
+				"region": "eu",
+				"site":   "home1",
+			},
+			wantErr: nil,
+		},
+		{
+			name:    "no value",
+			value:   "region",
+			wantErr: errInvalidExternalLabel,
+		},
+		{
+			name:    "empty value",
+			value:   "region=",
+			wantErr: errInvalidExternalLabel,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var labels externalLabels
+			err := labels.Set(tt.value)
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("got error %q, want %q", err, tt.wantErr)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if !reflect.DeepEqual(labels, tt.wantLabels) {
+				t.Errorf("got labels %v, want %v", labels, tt.wantLabels)
+			}
+		})
+	}
+}
+
+func TestStaleDurationsSet(t *testing.T) {
+	tests := []struct {
+		name          string
+		value         string
+		wantDurations staleDurations
+		wantErr       error
+	}{
+		{
+			name:  "success",
+			value: "NAModule1=30m, NAModule3=1h",
+			wantDurations: staleDurations{
+				"NAModule1": 30 * time.Minute,
+				"NAModule3": time.Hour,
+			},
+			wantErr: nil,
+		},
+		{
+			name:    "no duration",
+			value:   "NAModule1",
+			wantErr: errInvalidStaleByType,
+		},
+		{
+			name:    "invalid duration",
+			value:   "NAModule1=long",
+			wantErr: errInvalidStaleByType,
+		},
+		{
+			name:    "zero duration",
+			value:   "NAModule1=0s",
+			wantErr: errInvalidStaleByType,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var durations staleDurations
+			err := durations.Set(tt.value)
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("got error %q, want %q", err, tt.wantErr)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if !reflect.DeepEqual(durations, tt.wantDurations) {
+				t.Errorf("got durations %v, want %v", durations, tt.wantDurations)
+			}
+		})
+	}
+}
+
+func TestParseSecretFiles(t *testing.T) {
+	dir := t.TempDir()
+	idFile := filepath.Join(dir, "client-id")
+	secretFile := filepath.Join(dir, "client-secret")
+	emptyFile := filepath.Join(dir, "empty")
+	for fileName, content := range map[string]string{
+		idFile:     "id\n",
+		secretFile: "  secret\n",
+		emptyFile:  "\n",
+	} {
+		if err := os.WriteFile(fileName, []byte(content), 0o600); err != nil {
+			t.Fatalf("error writing file: %s", err)
+		}
+	}
+	missingFile := filepath.Join(dir, "missing")
+
+	tests := []struct {
+		name             string
+		args             []string
+		env              map[string]string
+		wantClientID     string
+		wantClientSecret string
+		wantErr          error
+	}{
+		{
+			name: "flags",
+			args: []string{
+				"test-cmd",
+				"--" + flagTokenFile, "token-file",
+				"--" + flagClientIDFile, idFile,
+				"--" + flagClientSecretFile, secretFile,
+			},
+			env:              map[string]string{},
+			wantClientID:     "id",
+			wantClientSecret: "secret",
+		},
+		{
+			name: "env",
+			args: []string{
+				"test-cmd",
+				"--" + flagTokenFile, "token-file",
+			},
+			env: map[string]string{
+				envVarNetatmoClientID + fileSuffix:     idFile,
+				envVarNetatmoClientSecret + fileSuffix: secretFile,
+			},
+			wantClientID:     "id",
+			wantClientSecret: "secret",
+		},
+		{
+			name: "env overrides flag",
+			args: []string{
+				"test-cmd",
+				"--" + flagTokenFile, "token-file",
+				"--" + flagNetatmoClientID, "flag-id",
+				"--" + flagNetatmoClientSecret, "flag-secret",
+			},
+			env: map[string]string{
+				envVarNetatmoClientSecret + fileSuffix: secretFile,
+			},
+			wantClientID:     "flag-id",
+			wantClientSecret: "secret",
+		},
+		{
+			name: "missing file flag",
+			args: []string{
+				"test-cmd",
+				"--" + flagTokenFile, "token-file",
+				"--" + flagClientIDFile, missingFile,
+				"--" + flagNetatmoClientSecret, "secret",
+			},
+			env:     map[string]string{},
+			wantErr: os.ErrNotExist,
+		},
+		{
+			name: "missing file env",
+			args: []string{
+				"test-cmd",
+				"--" + flagTokenFile, "token-file",
+				"--" + flagNetatmoClientID, "id",
+			},
+			env: map[string]string{
+				envVarNetatmoClientSecret + fileSuffix: missingFile,
+			},
+			wantErr: os.ErrNotExist,
+		},
+		{
+			name: "empty file",
+			args: []string{
+				"test-cmd",
+				"--" + flagTokenFile, "token-file",
+				"--" + flagClientIDFile, emptyFile,
+				"--" + flagNetatmoClientSecret, "secret",
+			},
+			env:     map[string]string{},
+			wantErr: errEmptySecretFile,
+		},
+		{
+			name: "value and file flag",
+			args: []string{
+				"test-cmd",
+				"--" + flagTokenFile, "token-file",
+				"--" + flagNetatmoClientID, "id",
+				"--" + flagClientIDFile, idFile,
+				"--" + flagNetatmoClientSecret, "secret",
+			},
+			env:     map[string]string{},
+			wantErr: errValueAndFile,
+		},
+		{
+			name: "value and file env",
+			args: []string{
+				"test-cmd",
+				"--" + flagTokenFile, "token-file",
+				"--" + flagNetatmoClientID, "id",
+			},
+			env: map[string]string{
+				envVarNetatmoClientSecret:              "secret",
+				envVarNetatmoClientSecret + fileSuffix: secretFile,
+			},
+			wantErr: errValueAndFile,
+		},
+		{
+			name: "file flag and env",
+			args: []string{
+				"test-cmd",
+				"--" + flagTokenFile, "token-file",
+				"--" + flagClientIDFile, idFile,
+				"--" + flagNetatmoClientSecret, "secret",
+			},
+			env: map[string]string{
+				envVarNetatmoClientID: "env-id",
+			},
+			wantErr: errValueAndFile,
+		},
+		{
+			name: "file flag and file env",
+			args: []string{
+				"test-cmd",
+				"--" + flagTokenFile, "token-file",
+				"--" + flagNetatmoClientID, "id",
+				"--" + flagClientSecretFile, secretFile,
+			},
+			env: map[string]string{
+				envVarNetatmoClientSecret + fileSuffix: secretFile,
+			},
+			wantErr: errValueAndFile,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			getenv := func(key string) string {
+				return tt.env[key]
+			}
+
+			config, err := Parse(tt.args, getenv)
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("got error %q, want %q", err, tt.wantErr)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if config.Netatmo.ClientID != tt.wantClientID {
+				t.Errorf("got client ID %q, want %q", config.Netatmo.ClientID, tt.wantClientID)
+			}
+
+			if config.Netatmo.ClientSecret != tt.wantClientSecret {
+				t.Errorf("got client secret %q, want %q", config.Netatmo.ClientSecret, tt.wantClientSecret)
+			}
+		})
+	}
+}
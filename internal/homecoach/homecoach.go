@@ -0,0 +1,135 @@
+package homecoach
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	netatmo "github.com/exzz/netatmo-api-go"
+	"github.com/neothematrix/netatmo-exporter/v2/internal/collector"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/oauth2"
+)
+
+// DefaultURL is the API endpoint returning the data of Healthy Home Coach devices.
+const DefaultURL = "https://api.netatmo.com/api/gethomecoachsdata"
+
+// Client reads the data of Healthy Home Coach devices, which is not part of the weather station data.
+type Client struct {
+	URL        string
+	HTTPClient *http.Client
+	TokenFunc  func() (*oauth2.Token, error)
+}
+
+// New creates a new Client using the token returned by tokenFunc for authentication.
+func New(httpClient *http.Client, tokenFunc func() (*oauth2.Token, error)) *Client {
+	return &Client{
+		URL:        DefaultURL,
+		HTTPClient: httpClient,
+		TokenFunc:  tokenFunc,
+	}
+}
+
+// Read retrieves the current data of all Home Coach devices of the account.
+func (c *Client) Read() (*netatmo.DeviceCollection, error) {
+	token, err := c.TokenFunc()
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, c.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	token.SetAuthHeader(req)
+
+	res, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error reading home coach data: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status reading home coach data: %s", res.Status)
+	}
+
+	var devices netatmo.DeviceCollection
+	if err := json.NewDecoder(res.Body).Decode(&devices); err != nil {
+		return nil, fmt.Errorf("error decoding home coach data: %w", err)
+	}
+
+	return &devices, nil
+}
+
+var upDesc = prometheus.NewDesc(
+	"netatmo_homecoach_up",
+	"Zero if there was an error reading the Home Coach data during the last refresh.",
+	nil, nil)
+
+// Reader combines the data of the weather stations with the data of the Home Coach devices. Errors reading the
+// Home Coach data do not fail the refresh, so that they do not affect netatmo_up. They are reported in
+// netatmo_homecoach_up instead and the Home Coach devices of the last successful read are used.
+type Reader struct {
+	Log       logrus.FieldLogger
+	Weather   collector.ReadFunction
+	HomeCoach collector.ReadFunction
+
+	lock sync.Mutex
+	up   bool
+	last []*netatmo.Device
+}
+
+// NewReader creates a new Reader, which reads the weather station data using weather and the Home Coach data
+// using homeCoach.
+func NewReader(log logrus.FieldLogger, weather, homeCoach collector.ReadFunction) *Reader {
+	return &Reader{
+		Log:       log,
+		Weather:   weather,
+		HomeCoach: homeCoach,
+	}
+}
+
+// Read returns the devices of the weather stations and the Home Coach devices in one collection. Only errors
+// reading the weather station data are returned, the devices of the Home Coach are added in that case as well.
+func (r *Reader) Read() (*netatmo.DeviceCollection, error) {
+	devices, err := r.Weather()
+
+	homeCoach, homeCoachErr := r.HomeCoach()
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.up = homeCoachErr == nil
+	if homeCoachErr != nil {
+		r.Log.Errorf("Error reading Home Coach data: %s", homeCoachErr)
+	} else if homeCoach != nil {
+		r.last = homeCoach.Devices()
+	}
+
+	combined := &netatmo.DeviceCollection{}
+	if devices != nil {
+		combined.Body.Devices = append(combined.Body.Devices, devices.Devices()...)
+	}
+	combined.Body.Devices = append(combined.Body.Devices, r.last...)
+
+	return combined, err
+}
+
+// Describe implements prometheus.Collector.
+func (r *Reader) Describe(ch chan<- *prometheus.Desc) {
+	ch <- upDesc
+}
+
+// Collect implements prometheus.Collector.
+func (r *Reader) Collect(ch chan<- prometheus.Metric) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	up := 0.0
+	if r.up {
+		up = 1
+	}
+	ch <- prometheus.MustNewConstMetric(upDesc, prometheus.GaugeValue, up)
+}
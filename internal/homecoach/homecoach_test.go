@@ -0,0 +1,207 @@
+package homecoach
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	netatmo "github.com/exzz/netatmo-api-go"
+	"github.com/google/go-cmp/cmp"
+	"github.com/neothematrix/netatmo-exporter/v2/internal/collector"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus/hooks/test"
+	"golang.org/x/oauth2"
+)
+
+const testResponse = `{
+  "body": {
+    "devices": [
+      {
+        "_id": "70:ee:50:00:00:01",
+        "station_name": "Bedroom",
+        "type": "NHC",
+        "dashboard_data": {
+          "time_utc": 3500,
+          "Temperature": 21.5,
+          "CO2": 650,
+          "Humidity": 48,
+          "Noise": 35,
+          "Pressure": 1015.2,
+          "AbsolutePressure": 1004.3,
+          "health_idx": 1
+        }
+      }
+    ]
+  },
+  "status": "ok"
+}`
+
+func TestClientRead(t *testing.T) {
+	tt := []struct {
+		desc        string
+		tokenErr    error
+		status      int
+		wantDevices *netatmo.DeviceCollection
+		wantErr     bool
+	}{
+		{
+			desc:   "success",
+			status: http.StatusOK,
+			wantDevices: func() *netatmo.DeviceCollection {
+				dc := &netatmo.DeviceCollection{}
+				dc.Body.Devices = []*netatmo.Device{
+					{
+						ID:          "70:ee:50:00:00:01",
+						StationName: "Bedroom",
+						Type:        "NHC",
+						DashboardData: netatmo.DashboardData{
+							Temperature:      float32Ptr(21.5),
+							Humidity:         int32Ptr(48),
+							CO2:              int32Ptr(650),
+							Noise:            int32Ptr(35),
+							Pressure:         float32Ptr(1015.2),
+							AbsolutePressure: float32Ptr(1004.3),
+							HealthIdx:        int32Ptr(1),
+							LastMeasure:      int64Ptr(3500),
+						},
+					},
+				}
+				return dc
+			}(),
+		},
+		{
+			desc:     "not authenticated",
+			tokenErr: netatmo.ErrNotAuthenticated,
+			status:   http.StatusOK,
+			wantErr:  true,
+		},
+		{
+			desc:    "error status",
+			status:  http.StatusForbidden,
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if auth := r.Header.Get("Authorization"); auth != "Bearer access-token" {
+					t.Errorf("got authorization %q", auth)
+				}
+
+				w.WriteHeader(tc.status)
+				w.Write([]byte(testResponse))
+			}))
+			defer server.Close()
+
+			c := New(server.Client(), func() (*oauth2.Token, error) {
+				if tc.tokenErr != nil {
+					return nil, tc.tokenErr
+				}
+
+				return &oauth2.Token{AccessToken: "access-token"}, nil
+			})
+			c.URL = server.URL
+
+			devices, err := c.Read()
+			if (err != nil) != tc.wantErr {
+				t.Errorf("got error %v, want error %v", err, tc.wantErr)
+			}
+
+			if diff := cmp.Diff(devices, tc.wantDevices); diff != "" {
+				t.Errorf("devices differ: -got+want\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestReader(t *testing.T) {
+	testErr := errors.New("test error")
+	station := &netatmo.Device{ID: "70:ee:50:00:00:01", Type: "NAMain"}
+	homeCoach := &netatmo.Device{ID: "70:ee:50:00:00:02", Type: "NHC"}
+	read := func(device *netatmo.Device, err error) collector.ReadFunction {
+		return func() (*netatmo.DeviceCollection, error) {
+			if err != nil {
+				return nil, err
+			}
+
+			dc := &netatmo.DeviceCollection{}
+			dc.Body.Devices = []*netatmo.Device{device}
+			return dc, nil
+		}
+	}
+
+	tt := []struct {
+		desc        string
+		weather     collector.ReadFunction
+		homeCoach   collector.ReadFunction
+		wantDevices []*netatmo.Device
+		wantErr     error
+		wantUp      string
+	}{
+		{
+			desc:        "success",
+			weather:     read(station, nil),
+			homeCoach:   read(homeCoach, nil),
+			wantDevices: []*netatmo.Device{station, homeCoach},
+			wantUp:      "1",
+		},
+		{
+			desc:        "home coach failed",
+			weather:     read(station, nil),
+			homeCoach:   read(nil, testErr),
+			wantDevices: []*netatmo.Device{station, homeCoach},
+			wantUp:      "0",
+		},
+		{
+			desc:        "weather failed",
+			weather:     read(nil, testErr),
+			homeCoach:   read(homeCoach, nil),
+			wantDevices: []*netatmo.Device{homeCoach},
+			wantErr:     testErr,
+			wantUp:      "1",
+		},
+	}
+
+	log, _ := test.NewNullLogger()
+	r := NewReader(log, nil, nil)
+
+	// The steps build on each other, so they can not run in parallel.
+	for _, tc := range tt {
+		r.Weather = tc.weather
+		r.HomeCoach = tc.homeCoach
+
+		devices, err := r.Read()
+		if !errors.Is(err, tc.wantErr) {
+			t.Errorf("%s: got error %q, want %q", tc.desc, err, tc.wantErr)
+		}
+
+		if diff := cmp.Diff(devices.Devices(), tc.wantDevices); diff != "" {
+			t.Errorf("%s: devices differ: -got+want\n%s", tc.desc, diff)
+		}
+
+		want := `# HELP netatmo_homecoach_up Zero if there was an error reading the Home Coach data during the last refresh.
+# TYPE netatmo_homecoach_up gauge
+netatmo_homecoach_up ` + tc.wantUp + "\n"
+		if err := testutil.CollectAndCompare(r, strings.NewReader(want)); err != nil {
+			t.Errorf("%s: %s", tc.desc, err)
+		}
+	}
+}
+
+func int32Ptr(value int32) *int32 {
+	return &value
+}
+
+func int64Ptr(value int64) *int64 {
+	return &value
+}
+
+func float32Ptr(value float32) *float32 {
+	return &value
+}
@@ -1,6 +1,7 @@
 package logger
 
 import (
+	"fmt"
 	"os"
 
 	"github.com/sirupsen/logrus"
@@ -17,7 +18,8 @@ func NewLogger() *logrus.Logger {
 	}
 
 	return &logrus.Logger{
-		Out: os.Stderr,
+		Out:   os.Stderr,
+		Hooks: make(logrus.LevelHooks),
 		Formatter: &logrus.TextFormatter{
 			DisableTimestamp: true,
 		},
@@ -26,3 +28,42 @@ func NewLogger() *logrus.Logger {
 		ReportCaller: false,
 	}
 }
+
+// hostnameKey is the field containing the hostname in every log entry.
+const hostnameKey = "host"
+
+// Configure enables the options of the logger, which are only known after parsing the configuration. If
+// reportCaller is set, the source file and line of the call are added to every entry. If hostname is set, every
+// entry contains the hostname in the "host" field.
+func Configure(log *logrus.Logger, reportCaller, hostname bool) error {
+	log.SetReportCaller(reportCaller)
+	if !hostname {
+		return nil
+	}
+
+	name, err := os.Hostname()
+	if err != nil {
+		return fmt.Errorf("error getting hostname: %w", err)
+	}
+	log.AddHook(fieldHook{key: hostnameKey, value: name})
+
+	return nil
+}
+
+// fieldHook adds a field with a fixed value to all entries, which do not already contain it.
+type fieldHook struct {
+	key   string
+	value string
+}
+
+func (h fieldHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (h fieldHook) Fire(entry *logrus.Entry) error {
+	if _, ok := entry.Data[h.key]; !ok {
+		entry.Data[h.key] = h.value
+	}
+
+	return nil
+}
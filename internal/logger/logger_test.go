@@ -0,0 +1,82 @@
+package logger
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestConfigure(t *testing.T) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		t.Fatalf("error getting hostname: %s", err)
+	}
+
+	tt := []struct {
+		desc          string
+		reportCaller  bool
+		hostname      bool
+		wantContent   []string
+		wantNoContent []string
+	}{
+		{
+			desc:          "defaults",
+			wantNoContent: []string{"host=", "func=", "file="},
+		},
+		{
+			desc:          "caller",
+			reportCaller:  true,
+			wantContent:   []string{"func=", "logger_test.go:"},
+			wantNoContent: []string{"host="},
+		},
+		{
+			desc:          "hostname",
+			hostname:      true,
+			wantContent:   []string{"host=" + hostname},
+			wantNoContent: []string{"func="},
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+			log := NewLogger()
+			log.Out = &buf
+			if err := Configure(log, tc.reportCaller, tc.hostname); err != nil {
+				t.Fatalf("got error %q, want none", err)
+			}
+
+			log.Info("test message")
+
+			output := buf.String()
+			for _, content := range tc.wantContent {
+				if !strings.Contains(output, content) {
+					t.Errorf("output %q does not contain %q", output, content)
+				}
+			}
+
+			for _, content := range tc.wantNoContent {
+				if strings.Contains(output, content) {
+					t.Errorf("output %q contains %q", output, content)
+				}
+			}
+		})
+	}
+}
+
+func TestFieldHookKeepsExistingField(t *testing.T) {
+	var buf bytes.Buffer
+	log := NewLogger()
+	log.Out = &buf
+	log.AddHook(fieldHook{key: hostnameKey, value: "default"})
+
+	log.WithField(hostnameKey, "other").Info("test message")
+
+	if output := buf.String(); !strings.Contains(output, "host=other") {
+		t.Errorf("output %q does not contain the existing field", output)
+	}
+}
@@ -0,0 +1,94 @@
+package remotewrite
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// newWriteRequest creates the WriteRequest containing the metric families. All samples get the same timestamp.
+func newWriteRequest(families []*dto.MetricFamily, timestamp time.Time) *prompb.WriteRequest {
+	series := flatten(families)
+	req := &prompb.WriteRequest{
+		Timeseries: make([]prompb.TimeSeries, 0, len(series)),
+	}
+	for _, s := range series {
+		req.Timeseries = append(req.Timeseries, prompb.TimeSeries{
+			Labels: s.labels,
+			Samples: []prompb.Sample{
+				{
+					Value:     s.value,
+					Timestamp: timestamp.UnixMilli(),
+				},
+			},
+		})
+	}
+
+	return req
+}
+
+type series struct {
+	labels []prompb.Label
+	value  float64
+}
+
+// flatten converts the metric families into single series like in the text exposition format. Histograms and
+// summaries are split into their bucket, quantile, sum and count series.
+func flatten(families []*dto.MetricFamily) []series {
+	var result []series
+	for _, family := range families {
+		name := family.GetName()
+		for _, m := range family.GetMetric() {
+			add := func(suffix string, value float64, extra ...prompb.Label) {
+				labels := []prompb.Label{{Name: "__name__", Value: name + suffix}}
+				for _, pair := range m.GetLabel() {
+					labels = append(labels, prompb.Label{Name: pair.GetName(), Value: pair.GetValue()})
+				}
+				labels = append(labels, extra...)
+				sort.Slice(labels, func(i, j int) bool {
+					return labels[i].Name < labels[j].Name
+				})
+
+				result = append(result, series{labels: labels, value: value})
+			}
+
+			switch family.GetType() {
+			case dto.MetricType_COUNTER:
+				add("", m.GetCounter().GetValue())
+			case dto.MetricType_GAUGE:
+				add("", m.GetGauge().GetValue())
+			case dto.MetricType_UNTYPED:
+				add("", m.GetUntyped().GetValue())
+			case dto.MetricType_HISTOGRAM:
+				h := m.GetHistogram()
+				for _, b := range h.GetBucket() {
+					add("_bucket", float64(b.GetCumulativeCount()), prompb.Label{Name: "le", Value: formatFloat(b.GetUpperBound())})
+				}
+				add("_bucket", float64(h.GetSampleCount()), prompb.Label{Name: "le", Value: "+Inf"})
+				add("_sum", h.GetSampleSum())
+				add("_count", float64(h.GetSampleCount()))
+			case dto.MetricType_SUMMARY:
+				sum := m.GetSummary()
+				for _, q := range sum.GetQuantile() {
+					add("", q.GetValue(), prompb.Label{Name: "quantile", Value: formatFloat(q.GetQuantile())})
+				}
+				add("_sum", sum.GetSampleSum())
+				add("_count", float64(sum.GetSampleCount()))
+			}
+		}
+	}
+
+	return result
+}
+
+func formatFloat(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
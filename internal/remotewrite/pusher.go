@@ -0,0 +1,99 @@
+package remotewrite
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	maxErrorBodyLength = 512
+	remoteWriteVersion = "0.1.0"
+)
+
+// Pusher periodically sends the metrics of a Gatherer to a remote-write endpoint.
+type Pusher struct {
+	Log      logrus.FieldLogger
+	URL      string
+	Client   *http.Client
+	Gatherer prometheus.Gatherer
+	Interval time.Duration
+	clock    func() time.Time
+}
+
+// New creates a new Pusher, which sends the metrics of gatherer to url in the given interval.
+func New(log logrus.FieldLogger, url string, client *http.Client, gatherer prometheus.Gatherer, interval time.Duration) *Pusher {
+	return &Pusher{
+		Log:      log,
+		URL:      url,
+		Client:   client,
+		Gatherer: gatherer,
+		Interval: interval,
+		clock:    time.Now,
+	}
+}
+
+// Run pushes the metrics once immediately and then in every interval until the context is cancelled.
+func (p *Pusher) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := p.Push(ctx); err != nil {
+			p.Log.Errorf("Error pushing metrics: %s", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Push gathers the metrics and sends them to the remote-write endpoint. The request is limited to the interval,
+// so that an endpoint, which does not respond, does not block the following pushes.
+func (p *Pusher) Push(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, p.Interval)
+	defer cancel()
+
+	families, err := p.Gatherer.Gather()
+	if err != nil {
+		return fmt.Errorf("error gathering metrics: %w", err)
+	}
+
+	raw, err := newWriteRequest(families, p.clock()).Marshal()
+	if err != nil {
+		return fmt.Errorf("error encoding write request: %w", err)
+	}
+
+	body := snappy.Encode(nil, raw)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", remoteWriteVersion)
+
+	res, err := p.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode/100 != 2 {
+		message, _ := io.ReadAll(io.LimitReader(res.Body, maxErrorBodyLength))
+		return fmt.Errorf("unexpected status %s: %s", res.Status, bytes.TrimSpace(message))
+	}
+
+	p.Log.Debugf("Pushed %d metric families to %s.", len(families), p.URL)
+	return nil
+}
@@ -0,0 +1,172 @@
+package remotewrite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/sirupsen/logrus"
+)
+
+func TestPusherPush(t *testing.T) {
+	t.Parallel()
+
+	registry := prometheus.NewRegistry()
+	gauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "netatmo_sensor_temperature_celsius",
+		Help: "Temperature",
+	}, []string{"module", "station"})
+	gauge.WithLabelValues("Outdoor", "Home").Set(21.5)
+	histogram := prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "netatmo_refresh_duration_seconds",
+		Help:    "Duration",
+		Buckets: []float64{1, 5},
+	})
+	histogram.Observe(2)
+	registry.MustRegister(gauge, histogram)
+
+	tt := []struct {
+		desc       string
+		statusCode int
+		wantSeries []string
+		wantErr    error
+	}{
+		{
+			desc:       "success",
+			statusCode: http.StatusNoContent,
+			wantSeries: []string{
+				`__name__="netatmo_refresh_duration_seconds_bucket",le="1" 0 @1700000000000`,
+				`__name__="netatmo_refresh_duration_seconds_bucket",le="5" 1 @1700000000000`,
+				`__name__="netatmo_refresh_duration_seconds_bucket",le="+Inf" 1 @1700000000000`,
+				`__name__="netatmo_refresh_duration_seconds_sum" 2 @1700000000000`,
+				`__name__="netatmo_refresh_duration_seconds_count" 1 @1700000000000`,
+				`__name__="netatmo_sensor_temperature_celsius",module="Outdoor",station="Home" 21.5 @1700000000000`,
+			},
+		},
+		{
+			desc:       "error status",
+			statusCode: http.StatusBadRequest,
+			wantErr:    fmt.Errorf("unexpected status 400 Bad Request: invalid request"),
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			var gotSeries []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for header, want := range map[string]string{
+					"Content-Type":                      "application/x-protobuf",
+					"Content-Encoding":                  "snappy",
+					"X-Prometheus-Remote-Write-Version": remoteWriteVersion,
+				} {
+					if got := r.Header.Get(header); got != want {
+						t.Errorf("got header %s %q, want %q", header, got, want)
+					}
+				}
+
+				body, err := io.ReadAll(r.Body)
+				if err != nil {
+					t.Fatalf("error reading body: %s", err)
+				}
+
+				gotSeries = decodeWriteRequest(t, body)
+
+				if tc.statusCode/100 != 2 {
+					http.Error(w, "invalid request", tc.statusCode)
+					return
+				}
+				w.WriteHeader(tc.statusCode)
+			}))
+			defer server.Close()
+
+			p := New(logrus.New(), server.URL, server.Client(), registry, time.Minute)
+			p.clock = func() time.Time {
+				return time.UnixMilli(1700000000000)
+			}
+
+			err := p.Push(context.Background())
+			if err != tc.wantErr {
+				if err == nil || tc.wantErr == nil || err.Error() != tc.wantErr.Error() {
+					t.Errorf("got error %q, want %q", err, tc.wantErr)
+				}
+			}
+
+			if tc.wantSeries == nil {
+				return
+			}
+
+			if diff := cmp.Diff(gotSeries, tc.wantSeries); diff != "" {
+				t.Errorf("series differ: %s", diff)
+			}
+		})
+	}
+}
+
+func TestPusherPushTimeout(t *testing.T) {
+	t.Parallel()
+
+	// The handler never responds, it is only released when the test is done, so that the server can be closed.
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	p := New(logrus.New(), server.URL, server.Client(), prometheus.NewRegistry(), 50*time.Millisecond)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- p.Push(context.Background())
+	}()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("got error %q, want %q", err, context.DeadlineExceeded)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("push did not time out")
+	}
+}
+
+func decodeWriteRequest(t *testing.T, body []byte) []string {
+	t.Helper()
+
+	raw, err := snappy.Decode(nil, body)
+	if err != nil {
+		t.Fatalf("error decoding snappy: %s", err)
+	}
+
+	var req prompb.WriteRequest
+	if err := req.Unmarshal(raw); err != nil {
+		t.Fatalf("error decoding write request: %s", err)
+	}
+
+	var result []string
+	for _, timeSeries := range req.Timeseries {
+		var labels []string
+		for _, l := range timeSeries.Labels {
+			labels = append(labels, fmt.Sprintf("%s=%q", l.Name, l.Value))
+		}
+
+		for _, sample := range timeSeries.Samples {
+			result = append(result, fmt.Sprintf("%s %g @%d", strings.Join(labels, ","), sample.Value, sample.Timestamp))
+		}
+	}
+
+	return result
+}
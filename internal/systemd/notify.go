@@ -0,0 +1,124 @@
+package systemd
+
+import (
+	"context"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	envNotifySocket  = "NOTIFY_SOCKET"
+	envWatchdogUsec  = "WATCHDOG_USEC"
+	envWatchdogPID   = "WATCHDOG_PID"
+	stateReady       = "READY=1"
+	stateWatchdog    = "WATCHDOG=1"
+	stateStopping    = "STOPPING=1"
+	statusPrefix     = "STATUS="
+	watchdogDivision = 2
+)
+
+// Notifier sends notifications to the service manager using the sd_notify protocol.
+// All methods are no-ops when the process has not been started with a notification socket.
+type Notifier struct {
+	socket           string
+	watchdogInterval time.Duration
+}
+
+// NewNotifier creates a Notifier using the settings passed by systemd in the environment.
+func NewNotifier(getenv func(string) string) *Notifier {
+	n := &Notifier{
+		socket: getenv(envNotifySocket),
+	}
+
+	if pid := getenv(envWatchdogPID); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return n
+	}
+
+	if usec, err := strconv.ParseInt(getenv(envWatchdogUsec), 10, 64); err == nil && usec > 0 {
+		n.watchdogInterval = time.Duration(usec) * time.Microsecond
+	}
+
+	return n
+}
+
+// Enabled returns true, if notifications are sent to the service manager.
+func (n *Notifier) Enabled() bool {
+	return n.socket != ""
+}
+
+// WatchdogInterval returns the interval after which the service manager restarts the process, if it has not
+// received a watchdog notification. It is zero when the watchdog is not enabled.
+func (n *Notifier) WatchdogInterval() time.Duration {
+	if !n.Enabled() {
+		return 0
+	}
+
+	return n.watchdogInterval
+}
+
+// Ready notifies the service manager that the startup is complete. The status is shown by "systemctl status".
+func (n *Notifier) Ready(status string) error {
+	state := stateReady
+	if status != "" {
+		state += "\n" + statusPrefix + status
+	}
+
+	return n.notify(state)
+}
+
+// Stopping notifies the service manager that the process is shutting down.
+func (n *Notifier) Stopping() error {
+	return n.notify(stateStopping)
+}
+
+// RunWatchdog sends watchdog notifications at half the watchdog interval while healthy returns true.
+// It returns once the context is cancelled or immediately, if the watchdog is not enabled.
+func (n *Notifier) RunWatchdog(ctx context.Context, healthy func() bool, onError func(error)) {
+	interval := n.WatchdogInterval()
+	if interval == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval / watchdogDivision)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !healthy() {
+				continue
+			}
+
+			if err := n.notify(stateWatchdog); err != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+func (n *Notifier) notify(state string) error {
+	if !n.Enabled() {
+		return nil
+	}
+
+	addr := &net.UnixAddr{
+		Name: n.socket,
+		Net:  "unixgram",
+	}
+	if addr.Name[0] == '@' {
+		addr.Name = "\x00" + addr.Name[1:]
+	}
+
+	conn, err := net.DialUnix(addr.Net, nil, addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(state))
+	return err
+}
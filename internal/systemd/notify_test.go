@@ -0,0 +1,176 @@
+package systemd
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func listenNotify(t *testing.T) (string, *net.UnixConn) {
+	t.Helper()
+
+	socket := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Fatalf("error creating socket: %s", err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+	})
+
+	return socket, conn
+}
+
+func readState(t *testing.T, conn *net.UnixConn) string {
+	t.Helper()
+
+	if err := conn.SetReadDeadline(time.Now().Add(time.Second)); err != nil {
+		t.Fatalf("error setting deadline: %s", err)
+	}
+
+	buf := make([]byte, 1024)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("error reading notification: %s", err)
+	}
+
+	return string(buf[:n])
+}
+
+func TestNewNotifier(t *testing.T) {
+	tt := []struct {
+		desc             string
+		env              map[string]string
+		wantEnabled      bool
+		wantWatchdogTime time.Duration
+	}{
+		{
+			desc:             "disabled",
+			env:              map[string]string{},
+			wantEnabled:      false,
+			wantWatchdogTime: 0,
+		},
+		{
+			desc: "no watchdog",
+			env: map[string]string{
+				envNotifySocket: "/run/systemd/notify",
+			},
+			wantEnabled:      true,
+			wantWatchdogTime: 0,
+		},
+		{
+			desc: "watchdog",
+			env: map[string]string{
+				envNotifySocket: "/run/systemd/notify",
+				envWatchdogUsec: "30000000",
+				envWatchdogPID:  strconv.Itoa(os.Getpid()),
+			},
+			wantEnabled:      true,
+			wantWatchdogTime: 30 * time.Second,
+		},
+		{
+			desc: "watchdog for other process",
+			env: map[string]string{
+				envNotifySocket: "/run/systemd/notify",
+				envWatchdogUsec: "30000000",
+				envWatchdogPID:  "1",
+			},
+			wantEnabled:      true,
+			wantWatchdogTime: 0,
+		},
+		{
+			desc: "watchdog without socket",
+			env: map[string]string{
+				envWatchdogUsec: "30000000",
+			},
+			wantEnabled:      false,
+			wantWatchdogTime: 0,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			n := NewNotifier(func(key string) string {
+				return tc.env[key]
+			})
+
+			if n.Enabled() != tc.wantEnabled {
+				t.Errorf("got enabled %v, want %v", n.Enabled(), tc.wantEnabled)
+			}
+
+			if n.WatchdogInterval() != tc.wantWatchdogTime {
+				t.Errorf("got watchdog interval %s, want %s", n.WatchdogInterval(), tc.wantWatchdogTime)
+			}
+		})
+	}
+}
+
+func TestNotifierReady(t *testing.T) {
+	socket, conn := listenNotify(t)
+	n := NewNotifier(func(key string) string {
+		if key == envNotifySocket {
+			return socket
+		}
+		return ""
+	})
+
+	if err := n.Ready("First refresh failed"); err != nil {
+		t.Fatalf("error sending notification: %s", err)
+	}
+
+	want := "READY=1\nSTATUS=First refresh failed"
+	if got := readState(t, conn); got != want {
+		t.Errorf("got state %q, want %q", got, want)
+	}
+}
+
+func TestNotifierDisabled(t *testing.T) {
+	n := NewNotifier(func(string) string {
+		return ""
+	})
+
+	if err := n.Ready(""); err != nil {
+		t.Errorf("got error %q, want nil", err)
+	}
+}
+
+func TestNotifierRunWatchdog(t *testing.T) {
+	socket, conn := listenNotify(t)
+	n := NewNotifier(func(key string) string {
+		switch key {
+		case envNotifySocket:
+			return socket
+		case envWatchdogUsec:
+			return "20000"
+		default:
+			return ""
+		}
+	})
+
+	var healthy atomic.Bool
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go n.RunWatchdog(ctx, healthy.Load, func(err error) {
+		t.Errorf("error sending watchdog notification: %s", err)
+	})
+
+	if err := conn.SetReadDeadline(time.Now().Add(50 * time.Millisecond)); err != nil {
+		t.Fatalf("error setting deadline: %s", err)
+	}
+	if _, err := conn.Read(make([]byte, 1024)); err == nil {
+		t.Error("got notification while unhealthy")
+	}
+
+	healthy.Store(true)
+	if got := readState(t, conn); got != stateWatchdog {
+		t.Errorf("got state %q, want %q", got, stateWatchdog)
+	}
+}
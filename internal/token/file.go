@@ -0,0 +1,88 @@
+package token
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/oauth2"
+)
+
+// BadTokenPolicy selects what happens when a token file exists, but the token can not be loaded from it.
+type BadTokenPolicy string
+
+const (
+	// BadTokenFail returns the error, so that the exporter does not start.
+	BadTokenFail BadTokenPolicy = "fail"
+	// BadTokenReauth ignores the file, so that the exporter starts unauthenticated and can be authorized again.
+	BadTokenReauth BadTokenPolicy = "reauth"
+)
+
+// Restore loads the token like Load. Using BadTokenReauth, errors other than missing files are logged and
+// os.ErrNotExist is returned instead, as if there was no token file.
+func Restore(log logrus.FieldLogger, fileNames []string, policy BadTokenPolicy) (*oauth2.Token, string, error) {
+	token, fileName, err := Load(log, fileNames)
+	if err != nil && !os.IsNotExist(err) && policy == BadTokenReauth {
+		log.Errorf("Error loading token, starting unauthenticated: %s", err)
+		return nil, "", os.ErrNotExist
+	}
+
+	return token, fileName, err
+}
+
+// Load reads the token from the first of the files, which contains a token with a refresh token. Files which do
+// not exist or can not be parsed are skipped. If none of the tokens has a refresh token, the first parsed token
+// is returned. If no token could be loaded, the error of the first file is returned.
+func Load(log logrus.FieldLogger, fileNames []string) (*oauth2.Token, string, error) {
+	var (
+		fallback     *oauth2.Token
+		fallbackFile string
+		firstErr     error
+	)
+	for _, fileName := range fileNames {
+		token, err := loadFile(fileName)
+		if err != nil {
+			if len(fileNames) > 1 && !os.IsNotExist(err) {
+				log.Warnf("Error loading token from %s: %s", fileName, err)
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+
+		if token.RefreshToken != "" {
+			return token, fileName, nil
+		}
+
+		if fallback == nil {
+			fallback = token
+			fallbackFile = fileName
+		}
+	}
+
+	if fallback != nil {
+		return fallback, fallbackFile, nil
+	}
+
+	if firstErr == nil {
+		firstErr = os.ErrNotExist
+	}
+
+	return nil, "", firstErr
+}
+
+func loadFile(fileName string) (*oauth2.Token, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var token oauth2.Token
+	if err := json.NewDecoder(file).Decode(&token); err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
@@ -0,0 +1,204 @@
+package token
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestLoad(t *testing.T) {
+	const (
+		withRefresh    = `{"access_token":"access","refresh_token":"refresh"}`
+		withoutRefresh = `{"access_token":"access-only"}`
+		corrupt        = `{"access_token":`
+	)
+
+	tt := []struct {
+		desc             string
+		files            []string
+		wantFile         int
+		wantAccessToken  string
+		wantErr          bool
+		wantErrNotExists bool
+	}{
+		{
+			desc:            "single file",
+			files:           []string{withRefresh},
+			wantFile:        0,
+			wantAccessToken: "access",
+		},
+		{
+			desc:            "single file without refresh token",
+			files:           []string{withoutRefresh},
+			wantFile:        0,
+			wantAccessToken: "access-only",
+		},
+		{
+			desc:    "single corrupt file",
+			files:   []string{corrupt},
+			wantErr: true,
+		},
+		{
+			desc:             "single missing file",
+			files:            []string{""},
+			wantErr:          true,
+			wantErrNotExists: true,
+		},
+		{
+			desc:            "primary is used first",
+			files:           []string{withRefresh, withRefresh},
+			wantFile:        0,
+			wantAccessToken: "access",
+		},
+		{
+			desc:            "fallback for missing primary",
+			files:           []string{"", withRefresh},
+			wantFile:        1,
+			wantAccessToken: "access",
+		},
+		{
+			desc:            "fallback for corrupt primary",
+			files:           []string{corrupt, withRefresh},
+			wantFile:        1,
+			wantAccessToken: "access",
+		},
+		{
+			desc:            "fallback for primary without refresh token",
+			files:           []string{withoutRefresh, corrupt, withRefresh},
+			wantFile:        2,
+			wantAccessToken: "access",
+		},
+		{
+			desc:            "first token without refresh token",
+			files:           []string{corrupt, withoutRefresh, `{"access_token":"other"}`},
+			wantFile:        1,
+			wantAccessToken: "access-only",
+		},
+		{
+			desc:    "all files corrupt",
+			files:   []string{corrupt, corrupt},
+			wantErr: true,
+		},
+		{
+			desc:             "first error is returned",
+			files:            []string{"", corrupt},
+			wantErr:          true,
+			wantErrNotExists: true,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			fileNames := make([]string, 0, len(tc.files))
+			for i, content := range tc.files {
+				fileName := filepath.Join(dir, "token-"+string(rune('a'+i))+".json")
+				if content != "" {
+					if err := os.WriteFile(fileName, []byte(content), 0o600); err != nil {
+						t.Fatalf("error writing token file: %s", err)
+					}
+				}
+				fileNames = append(fileNames, fileName)
+			}
+
+			token, fileName, err := Load(logrus.New(), fileNames)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("got no error, want one")
+				}
+
+				if os.IsNotExist(err) != tc.wantErrNotExists {
+					t.Errorf("got error %q, want not-exists %v", err, tc.wantErrNotExists)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("got error %q, want none", err)
+			}
+
+			if fileName != fileNames[tc.wantFile] {
+				t.Errorf("got file %q, want %q", fileName, fileNames[tc.wantFile])
+			}
+
+			if token.AccessToken != tc.wantAccessToken {
+				t.Errorf("got access token %q, want %q", token.AccessToken, tc.wantAccessToken)
+			}
+		})
+	}
+}
+
+func TestRestore(t *testing.T) {
+	tt := []struct {
+		desc             string
+		content          string
+		policy           BadTokenPolicy
+		wantToken        bool
+		wantErr          bool
+		wantErrNotExists bool
+	}{
+		{
+			desc:      "valid file with fail",
+			content:   `{"access_token":"access","refresh_token":"refresh"}`,
+			policy:    BadTokenFail,
+			wantToken: true,
+		},
+		{
+			desc:      "valid file with reauth",
+			content:   `{"access_token":"access","refresh_token":"refresh"}`,
+			policy:    BadTokenReauth,
+			wantToken: true,
+		},
+		{
+			desc:    "corrupt file with fail",
+			content: `{"access_token":`,
+			policy:  BadTokenFail,
+			wantErr: true,
+		},
+		{
+			desc:             "corrupt file with reauth",
+			content:          `{"access_token":`,
+			policy:           BadTokenReauth,
+			wantErr:          true,
+			wantErrNotExists: true,
+		},
+		{
+			desc:             "missing file with fail",
+			policy:           BadTokenFail,
+			wantErr:          true,
+			wantErrNotExists: true,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			fileName := filepath.Join(t.TempDir(), "token.json")
+			if tc.content != "" {
+				if err := os.WriteFile(fileName, []byte(tc.content), 0o600); err != nil {
+					t.Fatalf("error writing token file: %s", err)
+				}
+			}
+
+			token, _, err := Restore(logrus.New(), []string{fileName}, tc.policy)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tc.wantErr)
+			}
+
+			if err != nil && os.IsNotExist(err) != tc.wantErrNotExists {
+				t.Errorf("got error %q, want not-exists %v", err, tc.wantErrNotExists)
+			}
+
+			if (token != nil) != tc.wantToken {
+				t.Errorf("got token %v, want token %v", token, tc.wantToken)
+			}
+		})
+	}
+}
@@ -7,37 +7,59 @@ import (
 
 const (
 	prefix = "netatmo_exporter_token_"
+
+	// clientIDLabel contains the client ID of the Netatmo app the token belongs to, so that the metrics of
+	// exporters using different apps can be told apart. The client secret is never exported.
+	clientIDLabel = "client_id"
 )
 
 var (
 	validDesc = prometheus.NewDesc(
 		prefix+"valid",
 		"Set to 1 if there is a valid token, 0 otherwise.",
-		nil, nil)
+		[]string{clientIDLabel}, nil)
 
 	expiryDesc = prometheus.NewDesc(
 		prefix+"expiry_time",
 		"Set to the unix timestamp when the token will expire. 0 if no expiry is set.",
-		nil, nil)
+		[]string{clientIDLabel}, nil)
+
+	// The token data contains no information about when the refresh token was issued, so only its presence
+	// can be exported and not its age.
+	refreshTokenPresentDesc = prometheus.NewDesc(
+		"netatmo_refresh_token_present",
+		"Set to 1 if the current token contains a refresh token, 0 otherwise.",
+		[]string{clientIDLabel}, nil)
+
+	authRequiredDesc = prometheus.NewDesc(
+		"netatmo_auth_required",
+		"Set to 1 if the exporter needs to be re-authenticated manually, 0 otherwise.",
+		[]string{clientIDLabel}, nil)
 )
 
-func Metric(tokenFunc func() (*oauth2.Token, error)) prometheus.Collector {
+// Metric creates a collector exposing the state of the token returned by tokenFunc. The metrics are labelled
+// with the client ID of the Netatmo app.
+func Metric(clientID string, tokenFunc func() (*oauth2.Token, error)) prometheus.Collector {
 	return &tokenMetric{
+		clientID:  clientID,
 		tokenFunc: tokenFunc,
 	}
 }
 
 type tokenMetric struct {
+	clientID  string
 	tokenFunc func() (*oauth2.Token, error)
 }
 
 func (t tokenMetric) Describe(dChan chan<- *prometheus.Desc) {
 	dChan <- validDesc
 	dChan <- expiryDesc
+	dChan <- refreshTokenPresentDesc
+	dChan <- authRequiredDesc
 }
 
 func (t tokenMetric) Collect(mChan chan<- prometheus.Metric) {
-	token, _ := t.tokenFunc()
+	token, err := t.tokenFunc()
 
 	valid := token.Valid()
 	validValue := 0.0
@@ -47,6 +69,27 @@ func (t tokenMetric) Collect(mChan chan<- prometheus.Metric) {
 		expiryValue = float64(token.Expiry.Unix())
 	}
 
-	mChan <- prometheus.MustNewConstMetric(validDesc, prometheus.GaugeValue, validValue)
-	mChan <- prometheus.MustNewConstMetric(expiryDesc, prometheus.GaugeValue, expiryValue)
+	mChan <- prometheus.MustNewConstMetric(validDesc, prometheus.GaugeValue, validValue, t.clientID)
+	mChan <- prometheus.MustNewConstMetric(expiryDesc, prometheus.GaugeValue, expiryValue, t.clientID)
+
+	refreshTokenValue := 0.0
+	if err == nil && token != nil && token.RefreshToken != "" {
+		refreshTokenValue = 1.0
+	}
+	mChan <- prometheus.MustNewConstMetric(refreshTokenPresentDesc, prometheus.GaugeValue, refreshTokenValue, t.clientID)
+
+	authRequiredValue := 0.0
+	if authRequired(token, err) {
+		authRequiredValue = 1.0
+	}
+	mChan <- prometheus.MustNewConstMetric(authRequiredDesc, prometheus.GaugeValue, authRequiredValue, t.clientID)
+}
+
+// authRequired returns true, if the token can not be used or renewed without user interaction.
+func authRequired(token *oauth2.Token, err error) bool {
+	if err != nil || token == nil {
+		return true
+	}
+
+	return token.RefreshToken == "" && !token.Valid()
 }
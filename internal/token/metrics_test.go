@@ -0,0 +1,165 @@
+package token
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"golang.org/x/oauth2"
+)
+
+func TestMetricAuthRequired(t *testing.T) {
+	tt := []struct {
+		desc             string
+		token            *oauth2.Token
+		err              error
+		wantAuthRequired string
+	}{
+		{
+			desc:             "not authenticated",
+			token:            nil,
+			err:              errors.New("not authenticated"),
+			wantAuthRequired: "1",
+		},
+		{
+			desc: "valid token",
+			token: &oauth2.Token{
+				AccessToken:  "access-token",
+				RefreshToken: "refresh-token",
+				Expiry:       time.Now().Add(time.Hour),
+			},
+			wantAuthRequired: "0",
+		},
+		{
+			desc: "expired token with refresh token",
+			token: &oauth2.Token{
+				AccessToken:  "access-token",
+				RefreshToken: "refresh-token",
+				Expiry:       time.Now().Add(-time.Hour),
+			},
+			wantAuthRequired: "0",
+		},
+		{
+			desc: "expired token without refresh token",
+			token: &oauth2.Token{
+				AccessToken: "access-token",
+				Expiry:      time.Now().Add(-time.Hour),
+			},
+			wantAuthRequired: "1",
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			metric := Metric("client-id", func() (*oauth2.Token, error) {
+				return tc.token, tc.err
+			})
+
+			want := `# HELP netatmo_auth_required Set to 1 if the exporter needs to be re-authenticated manually, 0 otherwise.
+# TYPE netatmo_auth_required gauge
+netatmo_auth_required{client_id="client-id"} ` + tc.wantAuthRequired + "\n"
+
+			if err := testutil.CollectAndCompare(metric, strings.NewReader(want), "netatmo_auth_required"); err != nil {
+				t.Errorf("metric differs: %s", err)
+			}
+		})
+	}
+}
+
+func TestMetricRefreshTokenPresent(t *testing.T) {
+	tt := []struct {
+		desc        string
+		token       *oauth2.Token
+		err         error
+		wantPresent string
+	}{
+		{
+			desc:        "not authenticated",
+			token:       nil,
+			err:         errors.New("not authenticated"),
+			wantPresent: "0",
+		},
+		{
+			desc: "token with refresh token",
+			token: &oauth2.Token{
+				AccessToken:  "access-token",
+				RefreshToken: "refresh-token",
+				Expiry:       time.Now().Add(time.Hour),
+			},
+			wantPresent: "1",
+		},
+		{
+			desc: "token without refresh token",
+			token: &oauth2.Token{
+				AccessToken: "access-token",
+				Expiry:      time.Now().Add(time.Hour),
+			},
+			wantPresent: "0",
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			metric := Metric("client-id", func() (*oauth2.Token, error) {
+				return tc.token, tc.err
+			})
+
+			want := `# HELP netatmo_refresh_token_present Set to 1 if the current token contains a refresh token, 0 otherwise.
+# TYPE netatmo_refresh_token_present gauge
+netatmo_refresh_token_present{client_id="client-id"} ` + tc.wantPresent + "\n"
+
+			if err := testutil.CollectAndCompare(metric, strings.NewReader(want), "netatmo_refresh_token_present"); err != nil {
+				t.Errorf("metric differs: %s", err)
+			}
+		})
+	}
+}
+
+func TestMetricClientID(t *testing.T) {
+	metric := Metric("client-id", func() (*oauth2.Token, error) {
+		return &oauth2.Token{
+			AccessToken:  "access-token",
+			RefreshToken: "refresh-token",
+			Expiry:       time.Now().Add(time.Hour),
+		}, nil
+	})
+
+	registry := prometheus.NewPedanticRegistry()
+	registry.MustRegister(metric)
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("error gathering metrics: %s", err)
+	}
+
+	if len(families) == 0 {
+		t.Fatal("no metrics gathered")
+	}
+
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			labels := make(map[string]string)
+			for _, label := range m.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+
+			if labels[clientIDLabel] != "client-id" {
+				t.Errorf("%s: got client ID %q, want %q", family.GetName(), labels[clientIDLabel], "client-id")
+			}
+
+			for name, value := range labels {
+				if name != clientIDLabel || strings.Contains(value, "secret") {
+					t.Errorf("%s: unexpected label %s=%q", family.GetName(), name, value)
+				}
+			}
+		}
+	}
+}
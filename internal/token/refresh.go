@@ -0,0 +1,77 @@
+package token
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var refreshesDesc = prometheus.NewDesc(
+	"netatmo_token_refreshes_total",
+	"Counts the successful refreshes of the access token.",
+	[]string{clientIDLabel}, nil)
+
+// RefreshCounter is a RoundTripper counting the successful token refreshes done through it. The token source
+// is created inside of the Netatmo client, so the refreshes are counted on the HTTP requests it sends instead.
+// It is a collector exposing the count at the same time.
+type RefreshCounter struct {
+	clientID  string
+	next      http.RoundTripper
+	refreshes atomic.Uint64
+}
+
+// NewRefreshCounter wraps the RoundTripper for counting the token refreshes of the Netatmo app with the client ID.
+func NewRefreshCounter(clientID string, next http.RoundTripper) *RefreshCounter {
+	return &RefreshCounter{
+		clientID: clientID,
+		next:     next,
+	}
+}
+
+func (c *RefreshCounter) RoundTrip(req *http.Request) (*http.Response, error) {
+	refresh := isRefresh(req)
+
+	res, err := c.next.RoundTrip(req)
+	if err == nil && refresh && res.StatusCode >= 200 && res.StatusCode < 300 {
+		c.refreshes.Add(1)
+	}
+
+	return res, err
+}
+
+func (c *RefreshCounter) Describe(dChan chan<- *prometheus.Desc) {
+	dChan <- refreshesDesc
+}
+
+func (c *RefreshCounter) Collect(mChan chan<- prometheus.Metric) {
+	mChan <- prometheus.MustNewConstMetric(refreshesDesc, prometheus.CounterValue, float64(c.refreshes.Load()), c.clientID)
+}
+
+// isRefresh checks if the request is a token request using the refresh-token grant. The body is read from a copy,
+// so the request is not modified.
+func isRefresh(req *http.Request) bool {
+	if req.Method != http.MethodPost || req.GetBody == nil {
+		return false
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return false
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return false
+	}
+
+	values, err := url.ParseQuery(string(data))
+	if err != nil {
+		return false
+	}
+
+	return values.Get("grant_type") == "refresh_token"
+}
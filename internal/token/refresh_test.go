@@ -0,0 +1,143 @@
+package token
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"golang.org/x/oauth2"
+)
+
+func TestRefreshCounter(t *testing.T) {
+	fail := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token":"new-access","refresh_token":"new-refresh","token_type":"bearer","expires_in":3600}`))
+	}))
+	defer server.Close()
+
+	counter := NewRefreshCounter("id", http.DefaultTransport)
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{
+		Transport: counter,
+	})
+	cfg := &oauth2.Config{
+		ClientID:     "id",
+		ClientSecret: "secret",
+		Endpoint: oauth2.Endpoint{
+			TokenURL:  server.URL,
+			AuthStyle: oauth2.AuthStyleInParams,
+		},
+	}
+
+	expired := &oauth2.Token{
+		AccessToken:  "access",
+		RefreshToken: "refresh",
+		Expiry:       time.Now().Add(-time.Hour),
+	}
+	valid := &oauth2.Token{
+		AccessToken:  "access",
+		RefreshToken: "refresh",
+		Expiry:       time.Now().Add(time.Hour),
+	}
+
+	steps := []struct {
+		desc string
+		run  func() error
+		want string
+	}{
+		{
+			desc: "valid token",
+			run: func() error {
+				_, err := cfg.TokenSource(ctx, valid).Token()
+				return err
+			},
+			want: "0",
+		},
+		{
+			desc: "expired token",
+			run: func() error {
+				_, err := cfg.TokenSource(ctx, expired).Token()
+				return err
+			},
+			want: "1",
+		},
+		{
+			desc: "code exchange",
+			run: func() error {
+				_, err := cfg.Exchange(ctx, "code")
+				return err
+			},
+			want: "1",
+		},
+		{
+			desc: "other post request",
+			run: func() error {
+				res, err := counter.RoundTrip(newPostRequest(t, server.URL, url.Values{"grant_type": {"other"}}))
+				if err == nil {
+					res.Body.Close()
+				}
+				return err
+			},
+			want: "1",
+		},
+		{
+			desc: "failed refresh",
+			run: func() error {
+				fail = true
+				defer func() {
+					fail = false
+				}()
+
+				if _, err := cfg.TokenSource(ctx, expired).Token(); err == nil {
+					t.Error("got no error, want one")
+				}
+				return nil
+			},
+			want: "1",
+		},
+		{
+			desc: "second refresh",
+			run: func() error {
+				_, err := cfg.TokenSource(ctx, expired).Token()
+				return err
+			},
+			want: "2",
+		},
+	}
+
+	for _, step := range steps {
+		if err := step.run(); err != nil {
+			t.Fatalf("%s: got error %q", step.desc, err)
+		}
+
+		want := `# HELP netatmo_token_refreshes_total Counts the successful refreshes of the access token.
+# TYPE netatmo_token_refreshes_total counter
+netatmo_token_refreshes_total{client_id="id"} ` + step.want + "\n"
+
+		if err := testutil.CollectAndCompare(counter, strings.NewReader(want)); err != nil {
+			t.Errorf("%s: metric differs: %s", step.desc, err)
+		}
+	}
+}
+
+func newPostRequest(t *testing.T, target string, values url.Values) *http.Request {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodPost, target, strings.NewReader(values.Encode()))
+	if err != nil {
+		t.Fatalf("error creating request: %s", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	return req
+}
@@ -0,0 +1,46 @@
+package transport
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// RequestDuration is a RoundTripper recording the duration of the requests sent through it. It only covers the
+// round trip until the response headers are received, so it does not contain the time for reading and decoding
+// the body. The token refreshes of the OAuth client are sent through the same transport, so they are recorded
+// as separate requests of the "/oauth2/token" endpoint. It is a collector exposing the durations at the same time.
+type RequestDuration struct {
+	next      http.RoundTripper
+	durations *prometheus.HistogramVec
+}
+
+// NewRequestDuration wraps the RoundTripper for recording the durations of the API requests using the buckets.
+// The durations are labelled with the path of the request, which only has a few values for the NetAtmo API.
+func NewRequestDuration(next http.RoundTripper, buckets []float64) *RequestDuration {
+	return &RequestDuration{
+		next: next,
+		durations: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "netatmo_api_request_duration_seconds",
+			Help:    "Duration of the requests to the NetAtmo API until the response headers are received.",
+			Buckets: buckets,
+		}, []string{"endpoint"}),
+	}
+}
+
+func (t *RequestDuration) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	res, err := t.next.RoundTrip(req)
+	t.durations.WithLabelValues(req.URL.Path).Observe(time.Since(start).Seconds())
+
+	return res, err
+}
+
+func (t *RequestDuration) Describe(dChan chan<- *prometheus.Desc) {
+	t.durations.Describe(dChan)
+}
+
+func (t *RequestDuration) Collect(mChan chan<- prometheus.Metric) {
+	t.durations.Collect(mChan)
+}
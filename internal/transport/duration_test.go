@@ -0,0 +1,94 @@
+package transport
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestRequestDuration(t *testing.T) {
+	t.Parallel()
+
+	const delay = 50 * time.Millisecond
+
+	server := httptest.NewServer(http.HandlerFunc(func(wr http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay)
+		wr.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	durations := NewRequestDuration(http.DefaultTransport, []float64{0.01, 1})
+	client := &http.Client{
+		Transport: durations,
+	}
+
+	res, err := client.Get(server.URL + "/api/getstationsdata")
+	if err != nil {
+		t.Fatalf("got error: %s", err)
+	}
+	res.Body.Close()
+
+	registry := prometheus.NewPedanticRegistry()
+	registry.MustRegister(durations)
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("got error gathering metrics: %s", err)
+	}
+
+	if len(families) != 1 || len(families[0].GetMetric()) != 1 {
+		t.Fatalf("got %d metric families, want one with one series", len(families))
+	}
+
+	metric := families[0].GetMetric()[0]
+	if label := metric.GetLabel()[0]; label.GetName() != "endpoint" || label.GetValue() != "/api/getstationsdata" {
+		t.Errorf("got label %s=%q, want endpoint=%q", label.GetName(), label.GetValue(), "/api/getstationsdata")
+	}
+
+	histogram := metric.GetHistogram()
+	if histogram.GetSampleCount() != 1 {
+		t.Errorf("got %d samples, want 1", histogram.GetSampleCount())
+	}
+
+	if histogram.GetSampleSum() < delay.Seconds() {
+		t.Errorf("got duration %f, want at least %f", histogram.GetSampleSum(), delay.Seconds())
+	}
+
+	if count := histogram.GetBucket()[0].GetCumulativeCount(); count != 0 {
+		t.Errorf("got %d samples faster than the delay, want none", count)
+	}
+}
+
+type errorTransport struct{}
+
+func (errorTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestRequestDurationError(t *testing.T) {
+	t.Parallel()
+
+	durations := NewRequestDuration(errorTransport{}, prometheus.DefBuckets)
+	req, err := http.NewRequest(http.MethodGet, "https://api.netatmo.com/oauth2/token", nil)
+	if err != nil {
+		t.Fatalf("got error creating request: %s", err)
+	}
+
+	if _, err := durations.RoundTrip(req); err == nil {
+		t.Error("wanted error from the wrapped transport")
+	}
+
+	registry := prometheus.NewPedanticRegistry()
+	registry.MustRegister(durations)
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("got error gathering metrics: %s", err)
+	}
+
+	if len(families) != 1 || families[0].GetMetric()[0].GetHistogram().GetSampleCount() != 1 {
+		t.Error("failed request was not recorded")
+	}
+}
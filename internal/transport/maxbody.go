@@ -0,0 +1,77 @@
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// ErrBodyTooLarge is returned when reading a response body, which is larger than the configured limit.
+var ErrBodyTooLarge = errors.New("response body too large")
+
+type maxBodyTransport struct {
+	next     http.RoundTripper
+	maxBytes int64
+}
+
+// MaxBodySize wraps the RoundTripper so that reading a response body fails with ErrBodyTooLarge after maxBytes
+// have been read. This protects against endpoints returning enormous responses. Responses announcing a larger
+// Content-Length fail right away.
+func MaxBodySize(next http.RoundTripper, maxBytes int64) http.RoundTripper {
+	return &maxBodyTransport{
+		next:     next,
+		maxBytes: maxBytes,
+	}
+}
+
+func (t *maxBodyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.ContentLength > t.maxBytes {
+		res.Body.Close()
+		return nil, fmt.Errorf("%w: %d bytes exceeds limit of %d bytes", ErrBodyTooLarge, res.ContentLength, t.maxBytes)
+	}
+
+	res.Body = &limitedBody{
+		body:      res.Body,
+		remaining: t.maxBytes,
+		maxBytes:  t.maxBytes,
+	}
+
+	return res, nil
+}
+
+// limitedBody returns an error instead of EOF, when the body contains more data than allowed.
+type limitedBody struct {
+	body      io.ReadCloser
+	remaining int64
+	maxBytes  int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining <= 0 {
+		// Only fail if there is more data, so that bodies of exactly the maximum size can be read.
+		var probe [1]byte
+		if n, _ := b.body.Read(probe[:]); n > 0 {
+			return 0, fmt.Errorf("%w: limit of %d bytes exceeded", ErrBodyTooLarge, b.maxBytes)
+		}
+
+		return 0, io.EOF
+	}
+
+	if int64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+
+	n, err := b.body.Read(p)
+	b.remaining -= int64(n)
+	return n, err
+}
+
+func (b *limitedBody) Close() error {
+	return b.body.Close()
+}
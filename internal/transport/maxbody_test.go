@@ -0,0 +1,75 @@
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMaxBodySize(t *testing.T) {
+	tt := []struct {
+		desc          string
+		body          string
+		contentLength bool
+		wantErr       error
+	}{
+		{
+			desc: "smaller body",
+			body: "small",
+		},
+		{
+			desc: "body of maximum size",
+			body: strings.Repeat("a", 10),
+		},
+		{
+			desc:    "oversized chunked body",
+			body:    strings.Repeat("a", 1000),
+			wantErr: ErrBodyTooLarge,
+		},
+		{
+			desc:          "oversized content length",
+			body:          strings.Repeat("a", 1000),
+			contentLength: true,
+			wantErr:       ErrBodyTooLarge,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			server := httptest.NewServer(http.HandlerFunc(func(wr http.ResponseWriter, r *http.Request) {
+				if !tc.contentLength {
+					// Flushing before writing the body results in a chunked response without Content-Length.
+					wr.(http.Flusher).Flush()
+				}
+				fmt.Fprint(wr, tc.body)
+			}))
+			defer server.Close()
+
+			client := &http.Client{
+				Transport: MaxBodySize(http.DefaultTransport, 10),
+			}
+
+			res, err := client.Get(server.URL)
+			var body []byte
+			if err == nil {
+				body, err = io.ReadAll(res.Body)
+				res.Body.Close()
+			}
+
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tc.wantErr)
+			}
+
+			if tc.wantErr == nil && string(body) != tc.body {
+				t.Errorf("got body %q, want %q", body, tc.body)
+			}
+		})
+	}
+}
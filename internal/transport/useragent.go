@@ -0,0 +1,24 @@
+package transport
+
+import "net/http"
+
+type userAgentTransport struct {
+	next      http.RoundTripper
+	userAgent string
+}
+
+// UserAgent wraps the RoundTripper so that all requests carry the provided User-Agent header.
+func UserAgent(next http.RoundTripper, userAgent string) http.RoundTripper {
+	return &userAgentTransport{
+		next:      next,
+		userAgent: userAgent,
+	}
+}
+
+func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// RoundTrippers should not modify the original request.
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", t.userAgent)
+
+	return t.next.RoundTrip(req)
+}
@@ -0,0 +1,39 @@
+package transport
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestUserAgent(t *testing.T) {
+	var gotUserAgent string
+	server := httptest.NewServer(http.HandlerFunc(func(wr http.ResponseWriter, r *http.Request) {
+		gotUserAgent = r.UserAgent()
+	}))
+	defer server.Close()
+
+	client := &http.Client{
+		Transport: UserAgent(http.DefaultTransport, "netatmo-exporter/test"),
+	}
+
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatalf("error creating request: %s", err)
+	}
+	req.Header.Set("User-Agent", "original")
+
+	res, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("error during request: %s", err)
+	}
+	res.Body.Close()
+
+	if gotUserAgent != "netatmo-exporter/test" {
+		t.Errorf("got user agent %q, want %q", gotUserAgent, "netatmo-exporter/test")
+	}
+
+	if req.Header.Get("User-Agent") != "original" {
+		t.Errorf("original request was modified: %q", req.Header.Get("User-Agent"))
+	}
+}
@@ -0,0 +1,49 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/exzz/netatmo-api-go"
+	"github.com/neothematrix/netatmo-exporter/v2/internal/backfill"
+	"github.com/sirupsen/logrus"
+)
+
+// BackfillHandler creates a handler which outputs the measurements of all modules during the last duration as
+// JSON. Prometheus can not ingest these samples through scraping, so they are provided for importing them
+// using other tools.
+func BackfillHandler(log logrus.FieldLogger, readFunc func() (*netatmo.DeviceCollection, error), modulesFunc func(devices []*netatmo.Device, begin, end time.Time) []backfill.Module, duration time.Duration) http.Handler {
+	return http.HandlerFunc(func(wr http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			wr.Header().Set("Allow", "GET, HEAD")
+			http.Error(wr, "Method not allowed.", http.StatusMethodNotAllowed)
+			return
+		}
+
+		devices, err := readFunc()
+		if err != nil {
+			http.Error(wr, fmt.Sprintf("Error retrieving data: %s", err), http.StatusBadGateway)
+			return
+		}
+
+		end := time.Now()
+		begin := end.Add(-duration)
+		data := struct {
+			Begin   int64             `json:"begin"`
+			End     int64             `json:"end"`
+			Modules []backfill.Module `json:"modules"`
+		}{
+			Begin:   begin.Unix(),
+			End:     end.Unix(),
+			Modules: modulesFunc(devices.Devices(), begin, end),
+		}
+
+		wr.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(wr).Encode(data); err != nil {
+			log.Errorf("Can not encode backfill response: %s", err)
+			return
+		}
+	})
+}
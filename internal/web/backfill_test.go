@@ -0,0 +1,116 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/exzz/netatmo-api-go"
+	"github.com/google/go-cmp/cmp"
+	"github.com/neothematrix/netatmo-exporter/v2/internal/backfill"
+	"github.com/sirupsen/logrus"
+)
+
+func TestBackfillHandler(t *testing.T) {
+	modules := []backfill.Module{
+		{
+			Station:  "Home",
+			Module:   "Living Room",
+			ModuleID: "70:ee:50:00:00:01",
+			Type:     "NAMain",
+			Types:    []string{"Temperature"},
+			Samples: []backfill.Sample{
+				{Time: 3600, Values: map[string]float64{"Temperature": 21.5}},
+			},
+		},
+	}
+	device := &netatmo.Device{ID: "70:ee:50:00:00:01", Type: "NAMain"}
+
+	tt := []struct {
+		desc        string
+		method      string
+		readErr     error
+		wantStatus  int
+		wantModules []backfill.Module
+	}{
+		{
+			desc:        "success",
+			method:      http.MethodGet,
+			wantStatus:  http.StatusOK,
+			wantModules: modules,
+		},
+		{
+			desc:       "error retrieving data",
+			method:     http.MethodGet,
+			readErr:    errors.New("test error"),
+			wantStatus: http.StatusBadGateway,
+		},
+		{
+			desc:       "wrong method",
+			method:     http.MethodPost,
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			readFunc := func() (*netatmo.DeviceCollection, error) {
+				if tc.readErr != nil {
+					return nil, tc.readErr
+				}
+
+				dc := &netatmo.DeviceCollection{}
+				dc.Body.Devices = []*netatmo.Device{device}
+				return dc, nil
+			}
+			modulesFunc := func(devices []*netatmo.Device, begin, end time.Time) []backfill.Module {
+				if diff := cmp.Diff(devices, []*netatmo.Device{device}); diff != "" {
+					t.Errorf("devices differ: -got+want\n%s", diff)
+				}
+
+				if got := end.Sub(begin); got != time.Hour {
+					t.Errorf("got duration %s, want %s", got, time.Hour)
+				}
+
+				return modules
+			}
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(tc.method, "/backfill", nil)
+
+			h := BackfillHandler(logrus.New(), readFunc, modulesFunc, time.Hour)
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tc.wantStatus {
+				t.Errorf("got code %d, want %d", rec.Code, tc.wantStatus)
+			}
+
+			if rec.Code != http.StatusOK {
+				return
+			}
+
+			var data struct {
+				Begin   int64             `json:"begin"`
+				End     int64             `json:"end"`
+				Modules []backfill.Module `json:"modules"`
+			}
+			if err := json.NewDecoder(rec.Body).Decode(&data); err != nil {
+				t.Fatalf("error decoding response: %s", err)
+			}
+
+			if got := data.End - data.Begin; got != 3600 {
+				t.Errorf("got range %d, want %d", got, 3600)
+			}
+
+			if diff := cmp.Diff(data.Modules, tc.wantModules); diff != "" {
+				t.Errorf("modules differ: -got+want\n%s", diff)
+			}
+		})
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/exzz/netatmo-api-go"
@@ -67,3 +69,39 @@ func DebugTokenHandler(log logrus.FieldLogger, tokenFunc func() (*oauth2.Token,
 		}
 	})
 }
+
+// DebugConfigHandler creates a handler which returns the OAuth configuration used for authenticating the exporter,
+// so that it can be compared to the application registration. The client secret and tokens are not returned.
+func DebugConfigHandler(log logrus.FieldLogger, externalURL, clientID string, authCodeURLFunc func(redirectURL, state string) string) http.Handler {
+	return http.HandlerFunc(func(wr http.ResponseWriter, r *http.Request) {
+		redirectURL := CallbackURL(externalURL)
+		authURL, err := url.Parse(authCodeURLFunc(redirectURL, ""))
+		if err != nil {
+			http.Error(wr, fmt.Sprintf("Error parsing authorization URL: %s", err), http.StatusInternalServerError)
+			return
+		}
+
+		scopes := strings.Fields(authURL.Query().Get("scope"))
+		authURL.RawQuery = ""
+
+		data := struct {
+			RedirectURL      string   `json:"redirectURL"`
+			ClientID         string   `json:"clientID"`
+			Scopes           []string `json:"scopes"`
+			AuthorizationURL string   `json:"authorizationURL"`
+		}{
+			RedirectURL:      redirectURL,
+			ClientID:         clientID,
+			Scopes:           scopes,
+			AuthorizationURL: authURL.String(),
+		}
+
+		wr.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(wr)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(data); err != nil {
+			log.Errorf("Can not encode config debug response: %s", err)
+			return
+		}
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
 	"time"
 
@@ -137,3 +138,41 @@ func TestDebugTokenHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestDebugConfigHandler(t *testing.T) {
+	authCodeURLFunc := func(redirectURL, state string) string {
+		return "https://api.netatmo.com/oauth2/authorize?" + url.Values{
+			"client_id":     {"test-id"},
+			"redirect_uri":  {redirectURL},
+			"response_type": {"code"},
+			"scope":         {"read_station read_homecoach"},
+			"state":         {state},
+		}.Encode()
+	}
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	h := DebugConfigHandler(logrus.New(), "http://example.com:9210", "test-id", authCodeURLFunc)
+
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("got code %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	wantBody := `{
+  "redirectURL": "http://example.com:9210/auth/callback",
+  "clientID": "test-id",
+  "scopes": [
+    "read_station",
+    "read_homecoach"
+  ],
+  "authorizationURL": "https://api.netatmo.com/oauth2/authorize"
+}
+`
+	body := rec.Body.String()
+	if diff := cmp.Diff(body, wantBody); diff != "" {
+		t.Errorf("body differs: -got+want\n%s", diff)
+	}
+}
@@ -18,14 +18,19 @@ const netatmoDevSite = "https://dev.netatmo.com/apps/"
 var homeHtml string
 
 type homeContext struct {
+	Authenticated  bool
 	Valid          bool
 	Token          *oauth2.Token
 	NetAtmoDevSite string
+	RoutePrefix    string
+	MetricsPath    string
 }
 
 // HomeHandler produces a simple website showing the exporter's status in a human-readable form.
-// It provides links to other information and help for authentication as well.
-func HomeHandler(tokenFunc func() (*oauth2.Token, error)) http.Handler {
+// It provides links to other information and help for authentication as well. The link for authorizing the
+// exporter is only shown, while it is not authenticated. The links contain the routePrefix all handlers are
+// registered with.
+func HomeHandler(tokenFunc func() (*oauth2.Token, error), routePrefix, metricsPath string) http.Handler {
 	homeTemplate, err := template.New("home.html").Funcs(map[string]any{
 		"remaining": remaining,
 	}).Parse(homeHtml)
@@ -35,6 +40,7 @@ func HomeHandler(tokenFunc func() (*oauth2.Token, error)) http.Handler {
 
 	return http.HandlerFunc(func(wr http.ResponseWriter, r *http.Request) {
 		token, err := tokenFunc()
+		authenticated := err == nil && token != nil
 		switch {
 		case err == netatmo.ErrNotAuthenticated:
 		case err != nil:
@@ -44,9 +50,12 @@ func HomeHandler(tokenFunc func() (*oauth2.Token, error)) http.Handler {
 		}
 
 		context := homeContext{
+			Authenticated:  authenticated,
 			Valid:          token.Valid(),
 			Token:          token,
 			NetAtmoDevSite: netatmoDevSite,
+			RoutePrefix:    routePrefix,
+			MetricsPath:    metricsPath,
 		}
 
 		wr.Header().Set("Content-Type", "text/html")
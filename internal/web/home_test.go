@@ -0,0 +1,108 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/exzz/netatmo-api-go"
+	"golang.org/x/oauth2"
+)
+
+func TestHomeHandler(t *testing.T) {
+	tt := []struct {
+		desc          string
+		tokenFunc     func() (*oauth2.Token, error)
+		routePrefix   string
+		wantStatus    int
+		wantContent   []string
+		wantNoContent []string
+	}{
+		{
+			desc: "not authenticated",
+			tokenFunc: func() (*oauth2.Token, error) {
+				return nil, netatmo.ErrNotAuthenticated
+			},
+			wantStatus:    http.StatusOK,
+			wantContent:   []string{`href="/auth/authorize"`, "Authorize with Netatmo", `href="/metrics"`},
+			wantNoContent: []string{"Authenticated, the token expires at"},
+		},
+		{
+			desc: "authenticated",
+			tokenFunc: func() (*oauth2.Token, error) {
+				return &oauth2.Token{
+					AccessToken:  "access-token",
+					RefreshToken: "refresh-token",
+					Expiry:       time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC),
+				}, nil
+			},
+			wantStatus:    http.StatusOK,
+			wantContent:   []string{"Authenticated, the token expires at 2030-01-02 03:04:05", `href="/metrics"`},
+			wantNoContent: []string{`href="/auth/authorize"`, "no refresh-token"},
+		},
+		{
+			desc: "authenticated without refresh token",
+			tokenFunc: func() (*oauth2.Token, error) {
+				return &oauth2.Token{
+					AccessToken: "access-token",
+					Expiry:      time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC),
+				}, nil
+			},
+			wantStatus:    http.StatusOK,
+			wantContent:   []string{"Authenticated, the token expires at", "no refresh-token"},
+			wantNoContent: []string{`href="/auth/authorize"`},
+		},
+		{
+			desc: "route prefix",
+			tokenFunc: func() (*oauth2.Token, error) {
+				return nil, netatmo.ErrNotAuthenticated
+			},
+			routePrefix:   "/netatmo",
+			wantStatus:    http.StatusOK,
+			wantContent:   []string{`href="/netatmo/auth/authorize"`, `action="/netatmo/auth/settoken"`, `href="/netatmo/metrics"`, `href="/netatmo/version"`},
+			wantNoContent: []string{`href="/metrics"`},
+		},
+		{
+			desc: "error",
+			tokenFunc: func() (*oauth2.Token, error) {
+				return nil, errors.New("test error")
+			},
+			wantStatus:  http.StatusInternalServerError,
+			wantContent: []string{"Error getting token: test error"},
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+			h := HomeHandler(tc.tokenFunc, tc.routePrefix, "/metrics")
+
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tc.wantStatus {
+				t.Errorf("got code %d, want %d", rec.Code, tc.wantStatus)
+			}
+
+			body := rec.Body.String()
+			for _, content := range tc.wantContent {
+				if !strings.Contains(body, content) {
+					t.Errorf("body does not contain %q", content)
+				}
+			}
+
+			for _, content := range tc.wantNoContent {
+				if strings.Contains(body, content) {
+					t.Errorf("body contains %q", content)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package web
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const handlerMetricsPrefix = "netatmo_exporter_http_"
+
+// HandlerMetrics contains the metrics of the HTTP handlers of the exporter. All metrics have a "handler" label
+// containing the path the handler is registered at, so that the number of series does not depend on the
+// requested URLs.
+type HandlerMetrics struct {
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	inFlight *prometheus.GaugeVec
+}
+
+// NewHandlerMetrics creates the metrics of the HTTP handlers. They need to be registered to be exported.
+func NewHandlerMetrics() *HandlerMetrics {
+	return &HandlerMetrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: handlerMetricsPrefix + "requests_total",
+			Help: "Counts the HTTP requests by handler, method and status code.",
+		}, []string{"handler", "method", "code"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    handlerMetricsPrefix + "request_duration_seconds",
+			Help:    "Histogram of the time it took to respond to HTTP requests by handler and method.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"handler", "method"}),
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: handlerMetricsPrefix + "requests_in_flight",
+			Help: "Contains the number of HTTP requests currently served by the handler.",
+		}, []string{"handler"}),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (m *HandlerMetrics) Describe(ch chan<- *prometheus.Desc) {
+	m.requests.Describe(ch)
+	m.duration.Describe(ch)
+	m.inFlight.Describe(ch)
+}
+
+// Collect implements prometheus.Collector
+func (m *HandlerMetrics) Collect(ch chan<- prometheus.Metric) {
+	m.requests.Collect(ch)
+	m.duration.Collect(ch)
+	m.inFlight.Collect(ch)
+}
+
+// Instrument wraps the handler, so that its requests are counted using the given name as the handler label.
+func (m *HandlerMetrics) Instrument(name string, next http.Handler) http.Handler {
+	labels := prometheus.Labels{"handler": name}
+
+	return promhttp.InstrumentHandlerInFlight(m.inFlight.With(labels),
+		promhttp.InstrumentHandlerDuration(m.duration.MustCurryWith(labels),
+			promhttp.InstrumentHandlerCounter(m.requests.MustCurryWith(labels), next)))
+}
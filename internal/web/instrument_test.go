@@ -0,0 +1,40 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestHandlerMetrics(t *testing.T) {
+	metrics := NewHandlerMetrics()
+	handler := metrics.Instrument("/version", http.HandlerFunc(func(wr http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(wr, "Method not allowed.", http.StatusMethodNotAllowed)
+			return
+		}
+	}))
+
+	for _, method := range []string{http.MethodGet, http.MethodGet, http.MethodPost} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, "/version?unused=parameter", nil))
+	}
+
+	want := `# HELP netatmo_exporter_http_requests_in_flight Contains the number of HTTP requests currently served by the handler.
+# TYPE netatmo_exporter_http_requests_in_flight gauge
+netatmo_exporter_http_requests_in_flight{handler="/version"} 0
+# HELP netatmo_exporter_http_requests_total Counts the HTTP requests by handler, method and status code.
+# TYPE netatmo_exporter_http_requests_total counter
+netatmo_exporter_http_requests_total{code="200",handler="/version",method="get"} 2
+netatmo_exporter_http_requests_total{code="405",handler="/version",method="post"} 1
+`
+	if err := testutil.CollectAndCompare(metrics, strings.NewReader(want), "netatmo_exporter_http_requests_total", "netatmo_exporter_http_requests_in_flight"); err != nil {
+		t.Error(err)
+	}
+
+	if count := testutil.CollectAndCount(metrics, "netatmo_exporter_http_request_duration_seconds"); count != 2 {
+		t.Errorf("got %d duration series, want 2", count)
+	}
+}
@@ -0,0 +1,44 @@
+package web
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+const maxLogLevelLength = 64
+
+// LogLevelHandler creates a handler which returns the current log level on GET requests and changes it
+// on PUT requests. The new level is read from the request body.
+func LogLevelHandler(log *logrus.Logger) http.Handler {
+	return http.HandlerFunc(func(wr http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut:
+			body, err := io.ReadAll(io.LimitReader(r.Body, maxLogLevelLength))
+			if err != nil {
+				http.Error(wr, fmt.Sprintf("Error reading request: %s", err), http.StatusBadRequest)
+				return
+			}
+
+			level, err := logrus.ParseLevel(strings.TrimSpace(string(body)))
+			if err != nil {
+				http.Error(wr, fmt.Sprintf("Invalid log level: %s", err), http.StatusBadRequest)
+				return
+			}
+
+			log.SetLevel(level)
+			log.Infof("Changed log level to %s.", level)
+		default:
+			wr.Header().Set("Allow", "GET, PUT")
+			http.Error(wr, "Method not allowed.", http.StatusMethodNotAllowed)
+			return
+		}
+
+		wr.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintln(wr, log.GetLevel())
+	})
+}
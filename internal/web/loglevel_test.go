@@ -0,0 +1,83 @@
+package web
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sirupsen/logrus"
+)
+
+func TestLogLevelHandler(t *testing.T) {
+	tt := []struct {
+		desc       string
+		method     string
+		body       string
+		wantStatus int
+		wantBody   string
+		wantLevel  logrus.Level
+	}{
+		{
+			desc:       "get",
+			method:     http.MethodGet,
+			wantStatus: http.StatusOK,
+			wantBody:   "info\n",
+			wantLevel:  logrus.InfoLevel,
+		},
+		{
+			desc:       "set",
+			method:     http.MethodPut,
+			body:       "debug\n",
+			wantStatus: http.StatusOK,
+			wantBody:   "debug\n",
+			wantLevel:  logrus.DebugLevel,
+		},
+		{
+			desc:       "invalid level",
+			method:     http.MethodPut,
+			body:       "verbose",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   "Invalid log level: not a valid logrus Level: \"verbose\"\n",
+			wantLevel:  logrus.InfoLevel,
+		},
+		{
+			desc:       "wrong method",
+			method:     http.MethodPost,
+			body:       "debug",
+			wantStatus: http.StatusMethodNotAllowed,
+			wantBody:   "Method not allowed.\n",
+			wantLevel:  logrus.InfoLevel,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			log := logrus.New()
+			log.SetOutput(io.Discard)
+			log.SetLevel(logrus.InfoLevel)
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(tc.method, "/debug/log-level", strings.NewReader(tc.body))
+
+			LogLevelHandler(log).ServeHTTP(rec, req)
+
+			if rec.Code != tc.wantStatus {
+				t.Errorf("got code %d, want %d", rec.Code, tc.wantStatus)
+			}
+
+			if diff := cmp.Diff(rec.Body.String(), tc.wantBody); diff != "" {
+				t.Errorf("body differs: -got+want\n%s", diff)
+			}
+
+			if log.GetLevel() != tc.wantLevel {
+				t.Errorf("got level %s, want %s", log.GetLevel(), tc.wantLevel)
+			}
+		})
+	}
+}
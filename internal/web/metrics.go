@@ -0,0 +1,103 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/neothematrix/netatmo-exporter/v2/internal/collector"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
+)
+
+// MetricsHandler creates a handler which serves the metrics of the gatherer. If compression is enabled,
+// the response is compressed using gzip for clients which accept it.
+func MetricsHandler(gatherer prometheus.Gatherer, enableCompression bool) http.Handler {
+	return promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{
+		DisableCompression: !enableCompression,
+	})
+}
+
+// seriesInfo contains the series currently emitted for one metric name.
+type seriesInfo struct {
+	Name   string              `json:"name"`
+	Series int                 `json:"series"`
+	Labels []map[string]string `json:"labels"`
+}
+
+// DebugSeriesHandler creates a handler which returns the number of series and their label sets for every metric
+// of the gatherer, so that the cardinality can be checked without a Prometheus server.
+func DebugSeriesHandler(log logrus.FieldLogger, gatherer prometheus.Gatherer) http.Handler {
+	return http.HandlerFunc(func(wr http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			wr.Header().Set("Allow", "GET, HEAD")
+			http.Error(wr, "Method not allowed.", http.StatusMethodNotAllowed)
+			return
+		}
+
+		families, err := gatherer.Gather()
+		if err != nil {
+			http.Error(wr, fmt.Sprintf("Error gathering metrics: %s", err), http.StatusInternalServerError)
+			return
+		}
+
+		data := struct {
+			Series  int          `json:"series"`
+			Metrics []seriesInfo `json:"metrics"`
+		}{
+			Metrics: make([]seriesInfo, 0, len(families)),
+		}
+		for _, family := range families {
+			info := seriesInfo{
+				Name:   family.GetName(),
+				Series: len(family.GetMetric()),
+				Labels: make([]map[string]string, 0, len(family.GetMetric())),
+			}
+			for _, metric := range family.GetMetric() {
+				labels := make(map[string]string, len(metric.GetLabel()))
+				for _, label := range metric.GetLabel() {
+					labels[label.GetName()] = label.GetValue()
+				}
+				info.Labels = append(info.Labels, labels)
+			}
+
+			data.Series += info.Series
+			data.Metrics = append(data.Metrics, info)
+		}
+
+		wr.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(wr)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(data); err != nil {
+			log.Errorf("Can not encode series debug response: %s", err)
+			return
+		}
+	})
+}
+
+// DescribeHandler creates a handler which lists the metrics the collector can export with their help and labels,
+// also if no data is available for them. This is useful for generating documentation and for checking dashboards.
+func DescribeHandler(log logrus.FieldLogger, descriptionsFunc func() []collector.MetricDescription) http.Handler {
+	return http.HandlerFunc(func(wr http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			wr.Header().Set("Allow", "GET, HEAD")
+			http.Error(wr, "Method not allowed.", http.StatusMethodNotAllowed)
+			return
+		}
+
+		data := struct {
+			Metrics []collector.MetricDescription `json:"metrics"`
+		}{
+			Metrics: descriptionsFunc(),
+		}
+
+		wr.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(wr)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(data); err != nil {
+			log.Errorf("Can not encode metrics description response: %s", err)
+			return
+		}
+	})
+}
@@ -0,0 +1,233 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/neothematrix/netatmo-exporter/v2/internal/collector"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	tt := []struct {
+		desc              string
+		enableCompression bool
+		acceptEncoding    string
+		wantEncoding      string
+	}{
+		{
+			desc:              "compression",
+			enableCompression: true,
+			acceptEncoding:    "gzip",
+			wantEncoding:      "gzip",
+		},
+		{
+			desc:              "client without gzip",
+			enableCompression: true,
+			acceptEncoding:    "",
+			wantEncoding:      "",
+		},
+		{
+			desc:              "compression disabled",
+			enableCompression: false,
+			acceptEncoding:    "gzip",
+			wantEncoding:      "",
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			registry := prometheus.NewRegistry()
+			registry.MustRegister(prometheus.NewGauge(prometheus.GaugeOpts{
+				Name: "test_gauge",
+				Help: "Test gauge.",
+			}))
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			if tc.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tc.acceptEncoding)
+			}
+
+			h := MetricsHandler(registry, tc.enableCompression)
+
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Errorf("got code %d, want %d", rec.Code, http.StatusOK)
+			}
+
+			encoding := rec.Header().Get("Content-Encoding")
+			if encoding != tc.wantEncoding {
+				t.Errorf("got encoding %q, want %q", encoding, tc.wantEncoding)
+			}
+		})
+	}
+}
+
+func TestDebugSeriesHandler(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "test_gauge",
+		Help: "Test gauge.",
+	}))
+	vec := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "test_module_gauge",
+		Help: "Test gauge with labels.",
+	}, []string{"module", "station"})
+	vec.WithLabelValues("Indoor", "Home").Set(1)
+	vec.WithLabelValues("Outdoor", "Home").Set(2)
+	registry.MustRegister(vec)
+
+	tt := []struct {
+		desc       string
+		method     string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			desc:       "success",
+			method:     http.MethodGet,
+			wantStatus: http.StatusOK,
+			wantBody: `{
+  "series": 3,
+  "metrics": [
+    {
+      "name": "test_gauge",
+      "series": 1,
+      "labels": [
+        {}
+      ]
+    },
+    {
+      "name": "test_module_gauge",
+      "series": 2,
+      "labels": [
+        {
+          "module": "Indoor",
+          "station": "Home"
+        },
+        {
+          "module": "Outdoor",
+          "station": "Home"
+        }
+      ]
+    }
+  ]
+}
+`,
+		},
+		{
+			desc:       "wrong method",
+			method:     http.MethodPost,
+			wantStatus: http.StatusMethodNotAllowed,
+			wantBody: `Method not allowed.
+`,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(tc.method, "/metrics/json", nil)
+
+			h := DebugSeriesHandler(logrus.New(), registry)
+
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tc.wantStatus {
+				t.Errorf("got code %d, want %d", rec.Code, tc.wantStatus)
+			}
+
+			if diff := cmp.Diff(rec.Body.String(), tc.wantBody); diff != "" {
+				t.Errorf("body differs: -got+want\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDescribeHandler(t *testing.T) {
+	descriptionsFunc := func() []collector.MetricDescription {
+		return []collector.MetricDescription{
+			{
+				Name:   "netatmo_aircare_co2_ppm",
+				Help:   "CO2 concentration in parts per million",
+				Labels: []string{"module", "station"},
+			},
+			{
+				Name:   "netatmo_up",
+				Help:   "Zero if there was an error during the last refresh try.",
+				Labels: []string{},
+			},
+		}
+	}
+
+	tt := []struct {
+		desc       string
+		method     string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			desc:       "success",
+			method:     http.MethodGet,
+			wantStatus: http.StatusOK,
+			wantBody: `{
+  "metrics": [
+    {
+      "name": "netatmo_aircare_co2_ppm",
+      "help": "CO2 concentration in parts per million",
+      "labels": [
+        "module",
+        "station"
+      ]
+    },
+    {
+      "name": "netatmo_up",
+      "help": "Zero if there was an error during the last refresh try.",
+      "labels": []
+    }
+  ]
+}
+`,
+		},
+		{
+			desc:       "wrong method",
+			method:     http.MethodPost,
+			wantStatus: http.StatusMethodNotAllowed,
+			wantBody: `Method not allowed.
+`,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(tc.method, "/metrics/describe", nil)
+
+			h := DescribeHandler(logrus.New(), descriptionsFunc)
+
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tc.wantStatus {
+				t.Errorf("got code %d, want %d", rec.Code, tc.wantStatus)
+			}
+
+			if diff := cmp.Diff(rec.Body.String(), tc.wantBody); diff != "" {
+				t.Errorf("body differs: -got+want\n%s", diff)
+			}
+		})
+	}
+}
@@ -2,38 +2,148 @@ package web
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/exzz/netatmo-api-go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
 	"golang.org/x/oauth2"
 )
 
-func AuthorizeHandler(externalURL string, client *netatmo.Client) http.HandlerFunc {
+// CallbackURL returns the URL the user is redirected to after authorizing the exporter.
+// This needs to match the redirect URI configured for the application in the NetAtmo developer console.
+func CallbackURL(externalURL string) string {
+	return externalURL + "/auth/callback"
+}
+
+// AuthConfigInfo creates a metric containing the callback URL created from the external URL, so that a mismatch
+// with the redirect URI of the application can be found without reading the logs. When the forwarding headers
+// are trusted, the callback URL used for a request can differ from it.
+func AuthConfigInfo(externalURL string, trustForwarded bool) prometheus.Collector {
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "netatmo_auth_config_info",
+		Help: "Contains the callback URL used for the authorization of the exporter. The value is always one.",
+		ConstLabels: prometheus.Labels{
+			"callback_url":            CallbackURL(externalURL),
+			"trust_forwarded_headers": strconv.FormatBool(trustForwarded),
+		},
+	})
+	gauge.Set(1)
+
+	return gauge
+}
+
+// AuthorizeHandler redirects the user to the NetAtmo authorization. If trustForwarded is set, the scheme and host
+// of the redirect URL are taken from the X-Forwarded-Proto and X-Forwarded-Host headers of the request, so that
+// the exporter can be reached using different URLs through a reverse proxy. This must only be enabled, if the
+// proxy sets these headers, as a client could otherwise choose the redirect URL.
+func AuthorizeHandler(externalURL string, trustForwarded bool, client *netatmo.Client) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		redirectURL := externalURL + "/auth/callback"
+		base := externalURL
+		if trustForwarded {
+			base = forwardedURL(r, externalURL)
+		}
+		redirectURL := CallbackURL(base)
 		authURL := client.AuthCodeURL(redirectURL, "definitelyrandom")
+		LoggerFromContext(r.Context(), logrus.StandardLogger()).Debugf("Redirecting to authorization with redirect URL %s.", redirectURL)
 
 		http.Redirect(w, r, authURL, http.StatusFound)
 	}
 }
 
-func CallbackHandler(ctx context.Context, client *netatmo.Client) http.HandlerFunc {
+// forwardedURL replaces the scheme and host of externalURL with the ones contained in the forwarding headers of
+// the request. The path of externalURL is kept. Invalid header values are ignored.
+func forwardedURL(r *http.Request, externalURL string) string {
+	u, err := url.Parse(externalURL)
+	if err != nil {
+		return externalURL
+	}
+
+	// Proxies in a chain append their values, so only the last one was set by the proxy in front of the exporter.
+	// The values before it are passed on from the request and can be chosen by the client.
+	if proto := lastHeaderValue(r, "X-Forwarded-Proto"); proto == "http" || proto == "https" {
+		u.Scheme = proto
+	}
+
+	if host := lastHeaderValue(r, "X-Forwarded-Host"); host != "" && !strings.ContainsAny(host, "/?#@\\ ") {
+		u.Host = host
+	}
+
+	return u.String()
+}
+
+func lastHeaderValue(r *http.Request, name string) string {
+	values := r.Header.Values(name)
+	if len(values) == 0 {
+		return ""
+	}
+
+	value := values[len(values)-1]
+	if i := strings.LastIndex(value, ","); i >= 0 {
+		value = value[i+1:]
+	}
+	return strings.ToLower(strings.TrimSpace(value))
+}
+
+// codeExchanger contains the methods of the NetAtmo client used for exchanging the authorization code.
+type codeExchanger interface {
+	tokenClient
+	Exchange(ctx context.Context, code, state string) error
+}
+
+const (
+	// defaultExchangeBackoff is the wait before the first retry of the exchange, if ExchangeRetry has none set.
+	defaultExchangeBackoff = time.Second
+	// maxExchangeBackoff limits the wait between the retries of the exchange.
+	maxExchangeBackoff = 10 * time.Second
+)
+
+// ExchangeRetry configures how the exchange of the authorization code is retried, so that a short network
+// problem does not force the user to start the authorization again.
+type ExchangeRetry struct {
+	// Timeout limits every attempt of the exchange. Zero disables the timeout.
+	Timeout time.Duration
+	// Retries is the number of attempts after the first failed one.
+	Retries int
+	// Backoff is the wait before the first retry, which is doubled for every further one up to ten seconds.
+	// Zero uses a backoff of one second.
+	Backoff time.Duration
+}
+
+// CallbackHandler processes the redirect back from the NetAtmo authorization. After a successful authentication
+// the user is redirected to redirectURL or to the start page of the exporter, if that is empty.
+func CallbackHandler(ctx context.Context, client codeExchanger, redirectURL string, retry ExchangeRetry) http.HandlerFunc {
+	if redirectURL == "" {
+		redirectURL = "/"
+	}
+
 	return func(w http.ResponseWriter, r *http.Request) {
+		log := LoggerFromContext(r.Context(), logrus.StandardLogger())
 		values := r.URL.Query()
-		if err := doCallback(ctx, client, values); err != nil {
+		if err := doCallback(ctx, r.Context(), log, client, values, retry); err != nil {
+			log.WithFields(logrus.Fields{
+				"state":      values.Get("state"),
+				"error_code": values.Get("error"),
+			}).Warnf("Error processing OAuth callback: %s", err)
+
 			w.WriteHeader(http.StatusBadRequest)
 			fmt.Fprintf(w, "Error processing code: %s", err)
 			return
 		}
 
-		http.Redirect(w, r, "/", http.StatusFound)
+		log.Info("Authenticated using the OAuth callback.")
+		http.Redirect(w, r, redirectURL, http.StatusFound)
 	}
 }
 
-func doCallback(ctx context.Context, client *netatmo.Client, query url.Values) error {
+func doCallback(ctx, requestCtx context.Context, log logrus.FieldLogger, client codeExchanger, query url.Values, retry ExchangeRetry) error {
 	if err := query.Get("error"); err != "" {
 		return errors.New("user did not accept")
 	}
@@ -41,10 +151,64 @@ func doCallback(ctx context.Context, client *netatmo.Client, query url.Values) e
 	state := query.Get("state")
 	code := query.Get("code")
 
-	return client.Exchange(ctx, code, state)
+	backoff := retry.Backoff
+	if backoff <= 0 {
+		backoff = defaultExchangeBackoff
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := exchange(ctx, client, code, state, retry.Timeout)
+		if err == nil || attempt >= retry.Retries {
+			return err
+		}
+
+		// The code can only be used once, so a rejection by the API is not retried.
+		var retrieveErr *oauth2.RetrieveError
+		if errors.As(err, &retrieveErr) {
+			return err
+		}
+
+		log.Debugf("Retrying exchange of the authorization code in %s: %s", backoff, err)
+		select {
+		case <-requestCtx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > maxExchangeBackoff {
+			backoff = maxExchangeBackoff
+		}
+	}
 }
 
-func SetTokenHandler(ctx context.Context, client *netatmo.Client) http.HandlerFunc {
+// exchange does a single attempt of exchanging the code, which is limited by timeout. The client keeps using the
+// context of the exchange for refreshing the token, so after an exchange using a context with a timeout, the
+// token is handed over to ctx, which outlives the attempt.
+func exchange(ctx context.Context, client codeExchanger, code, state string, timeout time.Duration) error {
+	if timeout <= 0 {
+		return client.Exchange(ctx, code, state)
+	}
+
+	attemptCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if err := client.Exchange(attemptCtx, code, state); err != nil {
+		return err
+	}
+
+	token, err := client.CurrentToken()
+	if err != nil {
+		return err
+	}
+	client.InitWithToken(ctx, token)
+
+	return nil
+}
+
+// SetTokenHandler initializes the client using the refresh token entered on the start page and redirects back to
+// homePath afterwards.
+func SetTokenHandler(ctx context.Context, client *netatmo.Client, homePath string) http.HandlerFunc {
 	return func(wr http.ResponseWriter, r *http.Request) {
 		refreshToken := r.FormValue("refresh_token")
 		if refreshToken == "" {
@@ -56,7 +220,114 @@ func SetTokenHandler(ctx context.Context, client *netatmo.Client) http.HandlerFu
 			RefreshToken: refreshToken,
 		}
 		client.InitWithToken(ctx, token)
+		LoggerFromContext(r.Context(), logrus.StandardLogger()).Info("Token set using the web interface.")
+
+		http.Redirect(wr, r, homePath, http.StatusFound)
+	}
+}
+
+// defaultTokenURL is the endpoint of the NetAtmo API used for refreshing the token, if it can not be derived from
+// the authorization endpoint of the client.
+const defaultTokenURL = "https://api.netatmo.com/oauth2/token"
+
+// tokenClient contains the methods of the NetAtmo client needed for replacing its token.
+type tokenClient interface {
+	CurrentToken() (*oauth2.Token, error)
+	InitWithToken(ctx context.Context, token *oauth2.Token)
+}
+
+// TokenConfig creates the OAuth configuration used for refreshing the token of the client. The NetAtmo client only
+// exposes its authorization endpoint, so the token endpoint is taken from the same server, which authCodeURLFunc
+// returns, so that the refresh goes to the API the client is configured for.
+func TokenConfig(cfg netatmo.Config, authCodeURLFunc func(redirectURL, state string) string) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     cfg.ClientID,
+		ClientSecret: cfg.ClientSecret,
+		Endpoint: oauth2.Endpoint{
+			TokenURL:  tokenURL(authCodeURLFunc("", "")),
+			AuthStyle: oauth2.AuthStyleInParams,
+		},
+	}
+}
+
+// tokenURL returns the token endpoint next to the authorization endpoint contained in authCodeURL, which ends in
+// "/authorize" like the one of NetAtmo. Otherwise the default endpoint is returned.
+func tokenURL(authCodeURL string) string {
+	u, err := url.Parse(authCodeURL)
+	if err != nil || u.Host == "" || !strings.HasSuffix(u.Path, "/authorize") {
+		return defaultTokenURL
+	}
+
+	u.Path = strings.TrimSuffix(u.Path, "authorize") + "token"
+	u.RawQuery = ""
+	u.Fragment = ""
+	return u.String()
+}
+
+// RefreshToken forces a refresh of the token. The new token is requested using a separate token source and
+// the client only switches to it after the refresh was successful, so a failed refresh keeps the current token.
+func RefreshToken(ctx context.Context, client tokenClient, config *oauth2.Config) (*oauth2.Token, error) {
+	current, err := client.CurrentToken()
+	if err != nil {
+		return nil, err
+	}
+
+	if current.RefreshToken == "" {
+		return nil, errors.New("token has no refresh token")
+	}
 
-		http.Redirect(wr, r, "/", http.StatusFound)
+	token, err := config.TokenSource(ctx, &oauth2.Token{
+		RefreshToken: current.RefreshToken,
+	}).Token()
+	if err != nil {
+		return nil, err
 	}
+
+	client.InitWithToken(ctx, token)
+	return token, nil
+}
+
+// TokenRefreshHandler creates a handler which forces a refresh of the token when called using POST.
+// After a successful refresh, the token is persisted using saveFunc and the new expiry is returned.
+func TokenRefreshHandler(log logrus.FieldLogger, refreshFunc func() (*oauth2.Token, error), saveFunc func() error) http.Handler {
+	return http.HandlerFunc(func(wr http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			wr.Header().Set("Allow", http.MethodPost)
+			http.Error(wr, "Only POST is supported.", http.StatusMethodNotAllowed)
+			return
+		}
+
+		log := LoggerFromContext(r.Context(), log)
+		token, err := refreshFunc()
+		var retrieveErr *oauth2.RetrieveError
+		switch {
+		case err == netatmo.ErrNotAuthenticated:
+			http.Error(wr, "No token available.", http.StatusNotFound)
+			return
+		case errors.As(err, &retrieveErr) && retrieveErr.ErrorCode == "invalid_grant":
+			log.Warnf("Refresh token was rejected: %s", err)
+			http.Error(wr, "The refresh token was rejected. Please authenticate again.", http.StatusUnauthorized)
+			return
+		case err != nil:
+			http.Error(wr, fmt.Sprintf("Error refreshing token: %s", err), http.StatusBadGateway)
+			return
+		default:
+		}
+
+		if err := saveFunc(); err != nil {
+			log.Errorf("Error persisting refreshed token: %s", err)
+		}
+
+		data := struct {
+			Expiry time.Time `json:"expiry"`
+		}{
+			Expiry: token.Expiry,
+		}
+
+		wr.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(wr).Encode(data); err != nil {
+			log.Errorf("Can not encode token refresh response: %s", err)
+			return
+		}
+	})
 }
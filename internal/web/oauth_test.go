@@ -0,0 +1,564 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/exzz/netatmo-api-go"
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"golang.org/x/oauth2"
+)
+
+func TestForwardedURL(t *testing.T) {
+	tt := []struct {
+		desc    string
+		headers map[string]string
+		want    string
+	}{
+		{
+			desc:    "no headers",
+			headers: map[string]string{},
+			want:    "http://127.0.0.1:9210/netatmo",
+		},
+		{
+			desc: "proto and host",
+			headers: map[string]string{
+				"X-Forwarded-Proto": "https",
+				"X-Forwarded-Host":  "example.com",
+			},
+			want: "https://example.com/netatmo",
+		},
+		{
+			desc: "multiple proxies",
+			headers: map[string]string{
+				"X-Forwarded-Proto": "http, https",
+				"X-Forwarded-Host":  "proxy.internal, example.com",
+			},
+			want: "https://example.com/netatmo",
+		},
+		{
+			desc: "values set by the client",
+			headers: map[string]string{
+				"X-Forwarded-Proto": "https,http",
+				"X-Forwarded-Host":  "evil.example.com,127.0.0.1:9210",
+			},
+			want: "http://127.0.0.1:9210/netatmo",
+		},
+		{
+			desc: "invalid values",
+			headers: map[string]string{
+				"X-Forwarded-Proto": "ftp",
+				"X-Forwarded-Host":  "evil.example.com/path",
+			},
+			want: "http://127.0.0.1:9210/netatmo",
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(http.MethodGet, "/auth/authorize", nil)
+			for name, value := range tc.headers {
+				req.Header.Set(name, value)
+			}
+
+			got := forwardedURL(req, "http://127.0.0.1:9210/netatmo")
+			if got != tc.want {
+				t.Errorf("got URL %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestAuthConfigInfo(t *testing.T) {
+	want := `# HELP netatmo_auth_config_info Contains the callback URL used for the authorization of the exporter. The value is always one.
+# TYPE netatmo_auth_config_info gauge
+netatmo_auth_config_info{callback_url="https://netatmo.example.com/exporter/auth/callback",trust_forwarded_headers="false"} 1
+`
+
+	if err := testutil.CollectAndCompare(AuthConfigInfo("https://netatmo.example.com/exporter", false), strings.NewReader(want)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCallbackHandlerError(t *testing.T) {
+	log, hook := test.NewNullLogger()
+	handler := CallbackHandler(context.Background(), netatmo.NewClient(netatmo.Config{}), "", ExchangeRetry{})
+
+	req := httptest.NewRequest(http.MethodGet, "/auth/callback?state=test-state&error=access_denied", nil)
+	req = req.WithContext(WithLogger(req.Context(), log.WithField("request_id", "test-id")))
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, req)
+
+	if res.Code != http.StatusBadRequest {
+		t.Errorf("got status %d, want %d", res.Code, http.StatusBadRequest)
+	}
+
+	entry := hook.LastEntry()
+	if entry == nil {
+		t.Fatal("got no log entry")
+	}
+
+	if entry.Level != logrus.WarnLevel {
+		t.Errorf("got level %s, want %s", entry.Level, logrus.WarnLevel)
+	}
+
+	wantFields := logrus.Fields{
+		"request_id": "test-id",
+		"state":      "test-state",
+		"error_code": "access_denied",
+	}
+	if diff := cmp.Diff(entry.Data, wantFields); diff != "" {
+		t.Errorf("log fields differ: %s", diff)
+	}
+}
+
+type fakeExchanger struct {
+	fakeTokenClient
+	code  string
+	state string
+}
+
+func (e *fakeExchanger) Exchange(_ context.Context, code, state string) error {
+	e.code = code
+	e.state = state
+	e.current = &oauth2.Token{AccessToken: "access-token"}
+	return nil
+}
+
+func TestCallbackHandlerSuccess(t *testing.T) {
+	tt := []struct {
+		desc         string
+		redirectURL  string
+		wantLocation string
+	}{
+		{
+			desc:         "default",
+			wantLocation: "/",
+		},
+		{
+			desc:         "configured redirect",
+			redirectURL:  "https://app.example.com/settings",
+			wantLocation: "https://app.example.com/settings",
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			exchanger := &fakeExchanger{}
+			handler := CallbackHandler(context.Background(), exchanger, tc.redirectURL, ExchangeRetry{})
+
+			req := httptest.NewRequest(http.MethodGet, "/auth/callback?state=test-state&code=test-code", nil)
+			res := httptest.NewRecorder()
+			handler.ServeHTTP(res, req)
+
+			if res.Code != http.StatusFound {
+				t.Errorf("got status %d, want %d", res.Code, http.StatusFound)
+			}
+
+			if location := res.Header().Get("Location"); location != tc.wantLocation {
+				t.Errorf("got location %q, want %q", location, tc.wantLocation)
+			}
+
+			if exchanger.code != "test-code" || exchanger.state != "test-state" {
+				t.Errorf("got code %q and state %q, want %q and %q", exchanger.code, exchanger.state, "test-code", "test-state")
+			}
+		})
+	}
+}
+
+// flakyExchanger returns the errors in order, before the exchange succeeds.
+type flakyExchanger struct {
+	fakeTokenClient
+	errs     []error
+	attempts int
+	ctx      context.Context
+	initCtx  context.Context
+}
+
+func (e *flakyExchanger) Exchange(ctx context.Context, _, _ string) error {
+	e.ctx = ctx
+	e.attempts++
+	if e.attempts <= len(e.errs) {
+		return e.errs[e.attempts-1]
+	}
+
+	e.current = &oauth2.Token{AccessToken: "access-token"}
+	return nil
+}
+
+func (e *flakyExchanger) InitWithToken(ctx context.Context, token *oauth2.Token) {
+	e.initCtx = ctx
+	e.fakeTokenClient.InitWithToken(ctx, token)
+}
+
+func TestCallbackHandlerRetry(t *testing.T) {
+	errNetwork := errors.New("connection reset by peer")
+
+	tt := []struct {
+		desc         string
+		errs         []error
+		retries      int
+		wantStatus   int
+		wantAttempts int
+	}{
+		{
+			desc:         "failing once",
+			errs:         []error{errNetwork},
+			retries:      2,
+			wantStatus:   http.StatusFound,
+			wantAttempts: 2,
+		},
+		{
+			desc:         "retries exhausted",
+			errs:         []error{errNetwork, errNetwork, errNetwork},
+			retries:      2,
+			wantStatus:   http.StatusBadRequest,
+			wantAttempts: 3,
+		},
+		{
+			desc:         "retries disabled",
+			errs:         []error{errNetwork},
+			wantStatus:   http.StatusBadRequest,
+			wantAttempts: 1,
+		},
+		{
+			desc:         "rejected code",
+			errs:         []error{&oauth2.RetrieveError{ErrorCode: "invalid_grant"}},
+			retries:      2,
+			wantStatus:   http.StatusBadRequest,
+			wantAttempts: 1,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			exchanger := &flakyExchanger{errs: tc.errs}
+			handler := CallbackHandler(context.Background(), exchanger, "", ExchangeRetry{
+				Timeout: 5 * time.Second,
+				Retries: tc.retries,
+				Backoff: time.Millisecond,
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/auth/callback?state=test-state&code=test-code", nil)
+			res := httptest.NewRecorder()
+			handler.ServeHTTP(res, req)
+
+			if res.Code != tc.wantStatus {
+				t.Errorf("got status %d, want %d", res.Code, tc.wantStatus)
+			}
+
+			if exchanger.attempts != tc.wantAttempts {
+				t.Errorf("got %d attempts, want %d", exchanger.attempts, tc.wantAttempts)
+			}
+
+			if _, ok := exchanger.ctx.Deadline(); !ok {
+				t.Error("got exchange without a deadline")
+			}
+
+			if exchanger.ctx.Err() == nil {
+				t.Error("context of the attempt was not cancelled")
+			}
+
+			if tc.wantStatus != http.StatusFound {
+				return
+			}
+
+			if exchanger.initCtx == nil {
+				t.Fatal("token was not handed over after the exchange")
+			}
+
+			if _, ok := exchanger.initCtx.Deadline(); ok {
+				t.Error("got deadline for the context used for refreshing the token")
+			}
+		})
+	}
+}
+
+func TestCallbackHandlerRetryCancelled(t *testing.T) {
+	t.Parallel()
+
+	exchanger := &flakyExchanger{errs: []error{errors.New("connection reset by peer")}}
+	handler := CallbackHandler(context.Background(), exchanger, "", ExchangeRetry{
+		Retries: 2,
+		Backoff: time.Hour,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest(http.MethodGet, "/auth/callback?state=test-state&code=test-code", nil).WithContext(ctx)
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, req)
+
+	if res.Code != http.StatusBadRequest {
+		t.Errorf("got status %d, want %d", res.Code, http.StatusBadRequest)
+	}
+
+	if exchanger.attempts != 1 {
+		t.Errorf("got %d attempts, want %d", exchanger.attempts, 1)
+	}
+}
+
+func TestTokenRefreshHandler(t *testing.T) {
+	tt := []struct {
+		desc        string
+		method      string
+		refreshFunc func() (*oauth2.Token, error)
+		wantStatus  int
+		wantBody    string
+		wantSaved   bool
+	}{
+		{
+			desc:   "success",
+			method: http.MethodPost,
+			refreshFunc: func() (*oauth2.Token, error) {
+				return &oauth2.Token{
+					AccessToken:  "access-token",
+					RefreshToken: "refresh-token",
+					Expiry:       time.Unix(0, 0).UTC(),
+				}, nil
+			},
+			wantStatus: http.StatusOK,
+			wantBody: `{"expiry":"1970-01-01T00:00:00Z"}
+`,
+			wantSaved: true,
+		},
+		{
+			desc:       "wrong method",
+			method:     http.MethodGet,
+			wantStatus: http.StatusMethodNotAllowed,
+			wantBody:   "Only POST is supported.\n",
+		},
+		{
+			desc:   "no token",
+			method: http.MethodPost,
+			refreshFunc: func() (*oauth2.Token, error) {
+				return nil, netatmo.ErrNotAuthenticated
+			},
+			wantStatus: http.StatusNotFound,
+			wantBody:   "No token available.\n",
+		},
+		{
+			desc:   "refresh token expired",
+			method: http.MethodPost,
+			refreshFunc: func() (*oauth2.Token, error) {
+				return nil, &oauth2.RetrieveError{
+					Response:  &http.Response{StatusCode: http.StatusBadRequest},
+					ErrorCode: "invalid_grant",
+				}
+			},
+			wantStatus: http.StatusUnauthorized,
+			wantBody:   "The refresh token was rejected. Please authenticate again.\n",
+		},
+		{
+			desc:   "error",
+			method: http.MethodPost,
+			refreshFunc: func() (*oauth2.Token, error) {
+				return nil, errors.New("test error")
+			},
+			wantStatus: http.StatusBadGateway,
+			wantBody:   "Error refreshing token: test error\n",
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(tc.method, "/", nil)
+
+			saved := false
+			saveFunc := func() error {
+				saved = true
+				return nil
+			}
+
+			log := logrus.New()
+			h := TokenRefreshHandler(log, tc.refreshFunc, saveFunc)
+
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tc.wantStatus {
+				t.Errorf("got code %d, want %d", rec.Code, tc.wantStatus)
+			}
+
+			body := rec.Body.String()
+			if diff := cmp.Diff(body, tc.wantBody); diff != "" {
+				t.Errorf("body differs: -got+want\n%s", diff)
+			}
+
+			if saved != tc.wantSaved {
+				t.Errorf("got saved %v, want %v", saved, tc.wantSaved)
+			}
+		})
+	}
+}
+
+type fakeTokenClient struct {
+	current *oauth2.Token
+}
+
+func (c *fakeTokenClient) CurrentToken() (*oauth2.Token, error) {
+	if c.current == nil {
+		return nil, netatmo.ErrNotAuthenticated
+	}
+
+	return c.current, nil
+}
+
+func (c *fakeTokenClient) InitWithToken(_ context.Context, token *oauth2.Token) {
+	c.current = token
+}
+
+func TestTokenConfig(t *testing.T) {
+	tt := []struct {
+		desc        string
+		authCodeURL string
+		want        string
+	}{
+		{
+			desc:        "netatmo",
+			authCodeURL: "https://api.netatmo.com/oauth2/authorize?client_id=test-id&scope=read_station",
+			want:        "https://api.netatmo.com/oauth2/token",
+		},
+		{
+			desc:        "other server",
+			authCodeURL: "https://netatmo.example.com/api/oauth2/authorize?client_id=test-id",
+			want:        "https://netatmo.example.com/api/oauth2/token",
+		},
+		{
+			desc:        "unknown path",
+			authCodeURL: "https://netatmo.example.com/login",
+			want:        defaultTokenURL,
+		},
+		{
+			desc:        "empty",
+			authCodeURL: "",
+			want:        defaultTokenURL,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			config := TokenConfig(netatmo.Config{ClientID: "test-id"}, func(_, _ string) string {
+				return tc.authCodeURL
+			})
+			if config.Endpoint.TokenURL != tc.want {
+				t.Errorf("got token URL %q, want %q", config.Endpoint.TokenURL, tc.want)
+			}
+		})
+	}
+}
+
+func TestRefreshToken(t *testing.T) {
+	current := &oauth2.Token{
+		AccessToken:  "old-access-token",
+		RefreshToken: "old-refresh-token",
+	}
+
+	tt := []struct {
+		desc         string
+		current      *oauth2.Token
+		status       int
+		wantErr      bool
+		wantAccess   string
+		wantRefresh  string
+		wantRequests int
+	}{
+		{
+			desc:         "success",
+			current:      current,
+			status:       http.StatusOK,
+			wantAccess:   "new-access-token",
+			wantRefresh:  "new-refresh-token",
+			wantRequests: 1,
+		},
+		{
+			desc:         "refresh failed",
+			current:      current,
+			status:       http.StatusBadRequest,
+			wantErr:      true,
+			wantAccess:   "old-access-token",
+			wantRefresh:  "old-refresh-token",
+			wantRequests: 1,
+		},
+		{
+			desc: "no refresh token",
+			current: &oauth2.Token{
+				AccessToken: "old-access-token",
+			},
+			wantErr:    true,
+			wantAccess: "old-access-token",
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				if got := r.FormValue("refresh_token"); got != "old-refresh-token" {
+					t.Errorf("got refresh token %q, want %q", got, "old-refresh-token")
+				}
+
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tc.status)
+				if tc.status != http.StatusOK {
+					fmt.Fprint(w, `{"error":"invalid_grant"}`)
+					return
+				}
+
+				fmt.Fprint(w, `{"access_token":"new-access-token","refresh_token":"new-refresh-token","expires_in":10800}`)
+			}))
+			defer server.Close()
+
+			config := TokenConfig(netatmo.Config{}, netatmo.NewClient(netatmo.Config{}).AuthCodeURL)
+			config.Endpoint.TokenURL = server.URL
+
+			client := &fakeTokenClient{
+				current: tc.current,
+			}
+			_, err := RefreshToken(context.Background(), client, config)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("got error %v, want error %v", err, tc.wantErr)
+			}
+
+			if client.current.AccessToken != tc.wantAccess {
+				t.Errorf("got access token %q, want %q", client.current.AccessToken, tc.wantAccess)
+			}
+
+			if client.current.RefreshToken != tc.wantRefresh {
+				t.Errorf("got refresh token %q, want %q", client.current.RefreshToken, tc.wantRefresh)
+			}
+
+			if requests != tc.wantRequests {
+				t.Errorf("got %d requests, want %d", requests, tc.wantRequests)
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// ProbeHandler creates a handler which only serves the metrics of the station selected using the "station" parameter.
+// For every request a new registry is created containing only the collector returned by collectorFunc.
+func ProbeHandler(collectorFunc func(stationName string) prometheus.Collector) http.Handler {
+	return http.HandlerFunc(func(wr http.ResponseWriter, r *http.Request) {
+		stationName := r.URL.Query().Get("station")
+		if stationName == "" {
+			http.Error(wr, "The \"station\" parameter can not be empty.", http.StatusBadRequest)
+			return
+		}
+
+		registry := prometheus.NewRegistry()
+		if err := registry.Register(collectorFunc(stationName)); err != nil {
+			http.Error(wr, fmt.Sprintf("Error registering collector: %s", err), http.StatusInternalServerError)
+			return
+		}
+
+		promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(wr, r)
+	})
+}
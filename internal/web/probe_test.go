@@ -0,0 +1,68 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestProbeHandler(t *testing.T) {
+	collectorFunc := func(stationName string) prometheus.Collector {
+		g := prometheus.NewGauge(prometheus.GaugeOpts{
+			Name:        "test_gauge",
+			Help:        "Test gauge.",
+			ConstLabels: prometheus.Labels{"station": stationName},
+		})
+		g.Set(1)
+		return g
+	}
+
+	tt := []struct {
+		desc       string
+		query      string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			desc:       "success",
+			query:      "?station=Home",
+			wantStatus: http.StatusOK,
+			wantBody: `# HELP test_gauge Test gauge.
+# TYPE test_gauge gauge
+test_gauge{station="Home"} 1
+`,
+		},
+		{
+			desc:       "no station",
+			query:      "",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   "The \"station\" parameter can not be empty.\n",
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/probe"+tc.query, nil)
+
+			h := ProbeHandler(collectorFunc)
+
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tc.wantStatus {
+				t.Errorf("got code %d, want %d", rec.Code, tc.wantStatus)
+			}
+
+			body := rec.Body.String()
+			if diff := cmp.Diff(body, tc.wantBody); diff != "" {
+				t.Errorf("body differs: -got+want\n%s", diff)
+			}
+		})
+	}
+}
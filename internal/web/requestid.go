@@ -0,0 +1,51 @@
+package web
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+)
+
+// RequestIDHeader contains the ID of the request in the response.
+const RequestIDHeader = "X-Request-Id"
+
+type loggerKey struct{}
+
+// WithLogger returns a context containing the logger, which is used by the handlers when logging.
+func WithLogger(ctx context.Context, log logrus.FieldLogger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, log)
+}
+
+// LoggerFromContext returns the logger contained in the context. If there is none, fallback is returned.
+func LoggerFromContext(ctx context.Context, fallback logrus.FieldLogger) logrus.FieldLogger {
+	if log, ok := ctx.Value(loggerKey{}).(logrus.FieldLogger); ok {
+		return log
+	}
+
+	return fallback
+}
+
+// RequestID creates a middleware, which generates an ID for every request. The ID is returned in the
+// X-Request-Id header and added as a field to the logger put into the request context, so that the log
+// lines of a request can be correlated.
+func RequestID(log logrus.FieldLogger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := newRequestID()
+		w.Header().Set(RequestIDHeader, requestID)
+
+		ctx := WithLogger(r.Context(), log.WithField("request_id", requestID))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func newRequestID() string {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "unknown"
+	}
+
+	return hex.EncodeToString(id)
+}
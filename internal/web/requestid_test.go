@@ -0,0 +1,51 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestRequestID(t *testing.T) {
+	log, hook := test.NewNullLogger()
+	handler := RequestID(log, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		LoggerFromContext(r.Context(), logrus.StandardLogger()).Info("test")
+	}))
+
+	var ids []string
+	for i := 0; i < 2; i++ {
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/auth/authorize", nil))
+
+		id := res.Header().Get(RequestIDHeader)
+		if id == "" {
+			t.Fatal("got no request ID")
+		}
+
+		entry := hook.LastEntry()
+		if entry == nil {
+			t.Fatal("got no log entry")
+		}
+
+		if got := entry.Data["request_id"]; got != id {
+			t.Errorf("got request ID %q in log, want %q", got, id)
+		}
+		ids = append(ids, id)
+	}
+
+	if ids[0] == ids[1] {
+		t.Errorf("got the same request ID twice: %s", ids[0])
+	}
+}
+
+func TestLoggerFromContextFallback(t *testing.T) {
+	fallback, _ := test.NewNullLogger()
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if got := LoggerFromContext(req.Context(), fallback); got != fallback {
+		t.Errorf("got logger %v, want fallback", got)
+	}
+}
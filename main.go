@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -11,15 +12,20 @@ import (
 	"time"
 
 	"github.com/exzz/netatmo-api-go"
-	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
-	"github.com/sirupsen/logrus"
-	"github.com/spf13/pflag"
+	"github.com/neothematrix/netatmo-exporter/v2/internal/backfill"
 	"github.com/neothematrix/netatmo-exporter/v2/internal/collector"
 	"github.com/neothematrix/netatmo-exporter/v2/internal/config"
+	"github.com/neothematrix/netatmo-exporter/v2/internal/homecoach"
 	"github.com/neothematrix/netatmo-exporter/v2/internal/logger"
+	"github.com/neothematrix/netatmo-exporter/v2/internal/remotewrite"
+	"github.com/neothematrix/netatmo-exporter/v2/internal/systemd"
 	"github.com/neothematrix/netatmo-exporter/v2/internal/token"
+	"github.com/neothematrix/netatmo-exporter/v2/internal/transport"
 	"github.com/neothematrix/netatmo-exporter/v2/internal/web"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
 	"golang.org/x/oauth2"
 )
 
@@ -29,6 +35,13 @@ var (
 		syscall.SIGTERM,
 	}
 
+	// refreshSignal forces a refresh of the data without stopping the exporter.
+	refreshSignal = syscall.SIGUSR1
+
+	// shutdownRefreshTimeout is the maximum time the shutdown waits for a running refresh, which might still
+	// refresh the token, before saving the token.
+	shutdownRefreshTimeout = 5 * time.Second
+
 	log = logger.NewLogger()
 )
 
@@ -42,73 +55,344 @@ func main() {
 	default:
 	}
 	log.SetLevel(logrus.Level(cfg.LogLevel))
+	if err := logger.Configure(log, cfg.LogCaller, cfg.LogHostname); err != nil {
+		log.Fatalf("Error configuring logger: %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	apiTransport := transport.UserAgent(http.DefaultTransport, userAgent(cfg.UserAgent))
+	if cfg.MaxResponseBytes > 0 {
+		apiTransport = transport.MaxBodySize(apiTransport, cfg.MaxResponseBytes)
+	}
+	apiDurations := transport.NewRequestDuration(apiTransport, cfg.RefreshDurationBuckets)
+	refreshCounter := token.NewRefreshCounter(cfg.Netatmo.ClientID, apiDurations)
+	httpClient := &http.Client{
+		Transport: refreshCounter,
+	}
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, httpClient)
 
 	client := netatmo.NewClient(cfg.Netatmo)
 
-	if cfg.TokenFile != "" {
-		token, err := loadToken(cfg.TokenFile)
+	restored := false
+	if len(cfg.TokenFiles) > 0 {
+		savedToken, tokenFile, err := token.Restore(log, cfg.TokenFiles, token.BadTokenPolicy(cfg.OnBadToken))
 		switch {
 		case os.IsNotExist(err):
 		case err != nil:
 			log.Fatalf("Error loading token: %s", err)
 		default:
-			if token.RefreshToken == "" {
+			if savedToken.RefreshToken == "" {
 				log.Warn("Restored token has no refresh-token! Exporter will need to be re-authenticated manually.")
-			} else if token.Expiry.IsZero() {
+			} else if savedToken.Expiry.IsZero() {
 				log.Warn("Restored token has no expiry time! Token will be renewed immediately.")
-				token.Expiry = time.Now().Add(time.Second)
+				savedToken.Expiry = time.Now().Add(time.Second)
 			}
 
-			log.Infof("Loaded token from %s.", cfg.TokenFile)
-			client.InitWithToken(context.Background(), token)
+			log.Infof("Loaded token from %s.", tokenFile)
+			client.InitWithToken(ctx, savedToken)
+			restored = true
 		}
-
-		registerSignalHandler(client, cfg.TokenFile)
 	} else {
 		log.Warn("No token-file set! Authentication will be lost on restart.")
 	}
 
-	metrics := collector.New(log, client.Read, cfg.RefreshInterval, cfg.StaleDuration)
-	prometheus.MustRegister(metrics)
+	if !restored && cfg.RefreshToken != "" {
+		bootstrapToken(ctx, client, cfg)
+	}
+
+	registerer := labelledRegisterer(prometheus.DefaultRegisterer, cfg.ExternalLabels)
+	readFunction := collector.ReadFunction(client.Read)
+	if cfg.EnableHomeCoach {
+		homeCoach := homecoach.New(httpClient, client.CurrentToken)
+		reader := homecoach.NewReader(log, client.Read, homeCoach.Read)
+		registerer.MustRegister(reader)
+		readFunction = reader.Read
+	}
+
+	if cfg.ReplayFile != "" {
+		log.Warnf("Replaying data from %s instead of reading from the NetAtmo API.", cfg.ReplayFile)
+		readFunction = collector.ReplayFile(cfg.ReplayFile)
+	}
+
+	if cfg.Validate {
+		if err := runValidate(ctx, os.Stdout, client, readFunction, cfg); err != nil {
+			log.Fatalf("Validation failed: %s", err)
+		}
+		return
+	}
+
+	metrics := newCollector(ctx, readFunction, cfg)
+	if cfg.CacheFile != "" {
+		metrics.CacheFile = cfg.CacheFile
+		err := metrics.LoadCacheFile()
+		switch {
+		case os.IsNotExist(err):
+		case err != nil:
+			log.Errorf("Error loading cache file: %s", err)
+		default:
+			log.Infof("Loaded cached data from %s.", cfg.CacheFile)
+		}
+	}
+	if cfg.PrefixProcessMetrics {
+		prefixProcessMetrics(prometheus.DefaultRegisterer)
+	}
+	registerer.MustRegister(metrics)
+
+	tokenMetric := token.Metric(cfg.Netatmo.ClientID, client.CurrentToken)
+	registerer.MustRegister(tokenMetric)
+	registerer.MustRegister(refreshCounter)
+	registerer.MustRegister(apiDurations)
+	registerer.MustRegister(featuresCollector(cfg))
+	registerer.MustRegister(debugHandlersCollector(cfg))
+	registerer.MustRegister(startTimeCollector(startTime))
+	registerer.MustRegister(web.AuthConfigInfo(cfg.ExternalURL, cfg.TrustForwardedHeaders))
+
+	handlerMetrics := web.NewHandlerMetrics()
+	registerer.MustRegister(handlerMetrics)
 
-	tokenMetric := token.Metric(client.CurrentToken)
-	prometheus.MustRegister(tokenMetric)
+	// All handlers are registered below the route prefix, which is empty by default. The path without the prefix
+	// is used as the label of the handler metrics.
+	handle := func(path string, handler http.Handler) {
+		http.Handle(cfg.RoutePrefix+path, handlerMetrics.Instrument(path, handler))
+	}
+	homePath := cfg.RoutePrefix + "/"
+	postAuthRedirectURL := cfg.PostAuthRedirectURL
+	if postAuthRedirectURL == "" {
+		postAuthRedirectURL = homePath
+	}
 
 	if cfg.DebugHandlers {
-		http.Handle("/debug/data", web.DebugDataHandler(log, client.Read))
-		http.Handle("/debug/token", web.DebugTokenHandler(log, client.CurrentToken))
+		handle("/debug/data", web.DebugDataHandler(log, client.Read))
+		handle("/debug/token", web.DebugTokenHandler(log, client.CurrentToken))
+		handle("/debug/log-level", web.LogLevelHandler(log))
+		handle("/debug/config", web.DebugConfigHandler(log, cfg.ExternalURL, cfg.Netatmo.ClientID, client.AuthCodeURL))
+		handle("/metrics/json", web.DebugSeriesHandler(log, prometheus.DefaultGatherer))
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	log.Infof("OAuth redirect URL: %s", web.CallbackURL(cfg.ExternalURL))
+	handle("/auth/authorize", web.RequestID(log, web.AuthorizeHandler(cfg.ExternalURL, cfg.TrustForwardedHeaders, client)))
+	handle("/auth/callback", web.RequestID(log, web.CallbackHandler(ctx, client, postAuthRedirectURL, web.ExchangeRetry{
+		Timeout: cfg.ExchangeTimeout,
+		Retries: cfg.ExchangeRetries,
+	})))
+	handle("/auth/settoken", web.RequestID(log, web.SetTokenHandler(ctx, client, homePath)))
+	if cfg.TokenRefreshHandler {
+		tokenConfig := web.TokenConfig(cfg.Netatmo, client.AuthCodeURL)
+		handle("/auth/refresh", web.RequestID(log, web.TokenRefreshHandler(log, func() (*oauth2.Token, error) {
+			return web.RefreshToken(ctx, client, tokenConfig)
+		}, func() error {
+			if cfg.PrimaryTokenFile() == "" {
+				return nil
+			}
 
-	http.Handle("/auth/authorize", web.AuthorizeHandler(cfg.ExternalURL, client))
-	http.Handle("/auth/callback", web.CallbackHandler(ctx, client))
-	http.Handle("/auth/settoken", web.SetTokenHandler(ctx, client))
-	http.Handle("/metrics", promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{}))
-	http.Handle("/version", versionHandler(log))
-	http.Handle("/", web.HomeHandler(client.CurrentToken))
+			return saveToken(client.CurrentToken, cfg.PrimaryTokenFile())
+		})))
+	}
+	if cfg.BackfillDuration > 0 {
+		history := backfill.New(httpClient, client.CurrentToken)
+		handle("/backfill", web.BackfillHandler(log, client.Read, history.Modules, cfg.BackfillDuration))
+	}
+	handle(cfg.MetricsPath, web.MetricsHandler(prometheus.DefaultGatherer, cfg.EnableCompression))
+	handle("/metrics/describe", web.DescribeHandler(log, metrics.Descriptions))
+	handle("/probe", web.ProbeHandler(metrics.StationCollector))
+	handle("/version", versionHandler(log))
+	handle("/", web.HomeHandler(client.CurrentToken, cfg.RoutePrefix, cfg.MetricsPath))
+
+	listener, err := listen(cfg)
+	if err != nil {
+		log.Fatalf("Error creating listener: %s", err)
+	}
+
+	if cfg.RemoteWriteURL != "" {
+		log.Infof("Pushing metrics to %s every %s.", cfg.RemoteWriteURL, cfg.RefreshInterval)
+		// The remote-write endpoint is not part of the NetAtmo API, so the pusher does not use the NetAtmo
+		// User-Agent and is not counted as a token refresh.
+		pusher := remotewrite.New(log, cfg.RemoteWriteURL, &http.Client{}, prometheus.DefaultGatherer, cfg.RefreshInterval)
+		go pusher.Run(ctx)
+	}
+
+	notifier := systemd.NewNotifier(os.Getenv)
+	if notifier.Enabled() {
+		go notifySystemd(ctx, notifier, metrics)
+	}
+
+	registerRefreshSignal(ctx, metrics)
+	registerSignalHandler(client, metrics, cfg.PrimaryTokenFile(), func() {
+		if err := notifier.Stopping(); err != nil {
+			log.Errorf("Error notifying systemd: %s", err)
+		}
+		cancel()
+		if cfg.IsUnixSocket() {
+			removeSocket(cfg.SocketPath())
+		}
+	})
 
 	log.Infof("Listen on %s...", cfg.Addr)
-	log.Fatal(http.ListenAndServe(cfg.Addr, nil))
+	log.Fatal(newServer(cfg).Serve(listener))
+}
+
+// newServer creates the HTTP server using the timeouts from the configuration, so that slow clients can not
+// keep connections open indefinitely.
+func newServer(cfg config.Config) *http.Server {
+	return &http.Server{
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+	}
 }
 
-func loadToken(fileName string) (*oauth2.Token, error) {
-	file, err := os.Open(fileName)
+func newCollector(ctx context.Context, readFunction collector.ReadFunction, cfg config.Config) *collector.NetatmoCollector {
+	metrics := collector.New(ctx, log, readFunction, cfg.RefreshInterval, cfg.StaleDuration)
+	metrics.RefreshJitter = cfg.RefreshJitter
+	metrics.RefreshDurationBuckets = []float64(cfg.RefreshDurationBuckets)
+	metrics.AcceptEmptyResponse = cfg.AcceptEmptyResponse
+	metrics.CompactCache = cfg.CompactCache
+	metrics.ConvenienceMetrics = cfg.ConvenienceMetrics
+	metrics.InitialRefreshTimeout = cfg.InitialRefreshTimeout
+	metrics.WarmupDelay = cfg.WarmupDelay
+	metrics.ModuleGracePeriod = cfg.ModuleGracePeriod
+	metrics.MaxCacheAge = cfg.MaxCacheAge
+	metrics.LabelNames = collector.LabelNames{
+		Station: cfg.StationLabel,
+		Module:  cfg.ModuleLabel,
+	}
+	metrics.ModuleIDLabel = cfg.ModuleIDLabel
+	metrics.OmitMissingTimestamps = cfg.OmitMissingTimestamps
+	metrics.MetricTimestamps = cfg.MetricTimestamps
+	metrics.AverageWindow = cfg.AverageWindow
+	metrics.EnableHomeCoach = cfg.EnableHomeCoach
+	metrics.SignalTrend = cfg.SignalTrend
+
+	disabledMetrics, unknown := collector.MetricFilter(cfg.EnableMetrics, cfg.DisableMetrics)
+	for _, name := range unknown {
+		log.Warnf("Unknown metric in metric filter: %s", name)
+	}
+	metrics.DisabledMetrics = disabledMetrics
+
+	if len(cfg.SensorBounds) > 0 {
+		metrics.SensorBounds = make(map[string]collector.Bounds, len(cfg.SensorBounds))
+		for name, bounds := range cfg.SensorBounds {
+			if !collector.IsSensorMetric(name) {
+				log.Warnf("Unknown metric in sensor bounds: %s", name)
+			}
+			metrics.SensorBounds[name] = collector.Bounds(bounds)
+		}
+	}
+
+	if len(cfg.StaleByType) > 0 {
+		metrics.TypeStaleThresholds = cfg.StaleByType
+	}
+
+	windUnit, err := collector.ParseWindUnit(string(cfg.WindUnit))
 	if err != nil {
-		return nil, err
+		log.Fatalf("Error in configuration: %s", err)
+	}
+	metrics.WindUnit = windUnit
+
+	for _, name := range cfg.TemperatureUnits {
+		unit, err := collector.ParseTemperatureUnit(name)
+		if err != nil {
+			log.Fatalf("Error in configuration: %s", err)
+		}
+		metrics.TemperatureUnits = append(metrics.TemperatureUnits, unit)
+	}
+
+	for name, limits := range cfg.ComfortThresholds {
+		if err := metrics.ComfortThresholds.Set(name, collector.ComfortLimits(limits)); err != nil {
+			log.Fatalf("Error in configuration: %s", err)
+		}
+	}
+
+	return metrics
+}
+
+// labelledRegisterer returns a Registerer adding the external labels to all metrics registered using it.
+func labelledRegisterer(registerer prometheus.Registerer, labels map[string]string) prometheus.Registerer {
+	if len(labels) == 0 {
+		return registerer
+	}
+
+	return prometheus.WrapRegistererWith(labels, registerer)
+}
+
+// prefixProcessMetrics replaces the default Go runtime and process collectors with ones using a "netatmo_exporter_"
+// prefix, so that the metrics do not clash with other exporters. The Netatmo metrics are not affected.
+func prefixProcessMetrics(registerer prometheus.Registerer) {
+	goCollector := collectors.NewGoCollector()
+	processCollector := collectors.NewProcessCollector(collectors.ProcessCollectorOpts{})
+	registerer.Unregister(goCollector)
+	registerer.Unregister(processCollector)
+
+	prometheus.WrapRegistererWithPrefix("netatmo_exporter_", registerer).MustRegister(goCollector, processCollector)
+}
+
+// notifySystemd does the first refresh of the data and notifies systemd that the exporter is ready. Afterwards
+// it sends watchdog notifications while the refreshes are successful.
+func notifySystemd(ctx context.Context, notifier *systemd.Notifier, metrics *collector.NetatmoCollector) {
+	metrics.RefreshNow()
+
+	status := ""
+	if !metrics.Up() {
+		log.Warn("First refresh failed, notifying systemd anyway.")
+		status = "First refresh failed."
+	}
+
+	if err := notifier.Ready(status); err != nil {
+		log.Errorf("Error notifying systemd: %s", err)
+	}
+
+	notifier.RunWatchdog(ctx, metrics.Up, func(err error) {
+		log.Errorf("Error sending watchdog notification: %s", err)
+	})
+}
+
+func listen(cfg config.Config) (net.Listener, error) {
+	if !cfg.IsUnixSocket() {
+		return net.Listen("tcp", cfg.Addr)
 	}
-	defer file.Close()
 
-	var token oauth2.Token
-	if err := json.NewDecoder(file).Decode(&token); err != nil {
-		return nil, err
+	socketPath := cfg.SocketPath()
+	if err := os.Remove(socketPath); err == nil {
+		log.Warnf("Removed stale socket file %s.", socketPath)
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("error removing stale socket file: %w", err)
 	}
 
-	return &token, nil
+	return net.Listen("unix", socketPath)
+}
+
+func removeSocket(socketPath string) {
+	if err := os.Remove(socketPath); err != nil && !os.IsNotExist(err) {
+		log.Errorf("Error removing socket file: %s", err)
+	}
 }
 
-func registerSignalHandler(client *netatmo.Client, fileName string) {
+// bootstrapToken initializes the client using only the refresh token from the configuration. The access token
+// is retrieved immediately, so that a rejected refresh token is noticed on startup.
+func bootstrapToken(ctx context.Context, client *netatmo.Client, cfg config.Config) {
+	log.Info("Using refresh token from configuration.")
+	client.InitWithToken(ctx, &oauth2.Token{
+		RefreshToken: cfg.RefreshToken,
+	})
+
+	if _, err := client.CurrentToken(); err != nil {
+		log.Warnf("Refresh token from configuration was rejected, authenticate the exporter manually: %s", err)
+		return
+	}
+
+	if cfg.PrimaryTokenFile() == "" {
+		return
+	}
+
+	if err := saveToken(client.CurrentToken, cfg.PrimaryTokenFile()); err != nil {
+		log.Errorf("Error persisting token: %s", err)
+	}
+}
+
+func registerSignalHandler(client *netatmo.Client, metrics *collector.NetatmoCollector, fileName string, cleanup func()) {
 	ch := make(chan os.Signal, 1)
 	signal.Notify(ch, signals...)
 	go func() {
@@ -116,16 +400,51 @@ func registerSignalHandler(client *netatmo.Client, fileName string) {
 		signal.Reset(signals...)
 		log.Debugf("Got signal: %s", sig)
 
-		if err := saveToken(client, fileName); err != nil {
-			log.Errorf("Error persisting token: %s", err)
+		if fileName != "" {
+			persistToken(metrics, client.CurrentToken, fileName, shutdownRefreshTimeout)
 		}
 
+		cleanup()
 		os.Exit(0)
 	}()
 }
 
-func saveToken(client *netatmo.Client, fileName string) error {
-	token, err := client.CurrentToken()
+func registerRefreshSignal(ctx context.Context, metrics *collector.NetatmoCollector) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, refreshSignal)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				signal.Stop(ch)
+				return
+			case sig := <-ch:
+				log.Infof("Got signal %s, refreshing data.", sig)
+				if err := metrics.ForceRefresh(); err != nil {
+					log.Errorf("Error during forced refresh: %s", err)
+					continue
+				}
+
+				log.Info("Forced refresh completed.")
+			}
+		}
+	}()
+}
+
+// persistToken saves the token during the shutdown. A running refresh of the data might still refresh the token,
+// so it waits up to timeout for the refresh to complete first.
+func persistToken(metrics *collector.NetatmoCollector, tokenFunc func() (*oauth2.Token, error), fileName string, timeout time.Duration) {
+	if !metrics.WaitForRefresh(timeout) {
+		log.Warnf("Refresh still running after %s, saving the current token.", timeout)
+	}
+
+	if err := saveToken(tokenFunc, fileName); err != nil {
+		log.Errorf("Error persisting token: %s", err)
+	}
+}
+
+func saveToken(tokenFunc func() (*oauth2.Token, error), fileName string) error {
+	token, err := tokenFunc()
 	switch {
 	case err == netatmo.ErrNotAuthenticated:
 		return nil
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/exzz/netatmo-api-go"
+	"github.com/neothematrix/netatmo-exporter/v2/internal/collector"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/oauth2"
+)
+
+func TestPersistTokenWaitsForRefresh(t *testing.T) {
+	var lock sync.Mutex
+	currentToken := &oauth2.Token{AccessToken: "old-access", RefreshToken: "old-refresh"}
+	tokenFunc := func() (*oauth2.Token, error) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		return currentToken, nil
+	}
+
+	started := make(chan struct{})
+	metrics := collector.New(context.Background(), log, func() (*netatmo.DeviceCollection, error) {
+		close(started)
+
+		// The slow read refreshes the token before returning.
+		time.Sleep(100 * time.Millisecond)
+		lock.Lock()
+		currentToken = &oauth2.Token{AccessToken: "new-access", RefreshToken: "new-refresh"}
+		lock.Unlock()
+
+		return &netatmo.DeviceCollection{}, nil
+	}, time.Minute, time.Hour)
+	go metrics.ForceRefresh()
+	<-started
+
+	fileName := filepath.Join(t.TempDir(), "token.json")
+	persistToken(metrics, tokenFunc, fileName, 5*time.Second)
+
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatalf("error reading token file: %s", err)
+	}
+
+	var saved oauth2.Token
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatalf("error decoding token file: %s", err)
+	}
+
+	if saved.RefreshToken != "new-refresh" {
+		t.Errorf("got refresh token %q, want %q", saved.RefreshToken, "new-refresh")
+	}
+}
+
+func TestLabelledRegisterer(t *testing.T) {
+	lastMeasure := time.Now().Unix()
+	temperature := float32(21.5)
+	dc := &netatmo.DeviceCollection{}
+	dc.Body.Devices = []*netatmo.Device{
+		{
+			ID:          "aa:bb:cc:dd:ee:f0",
+			ModuleName:  "Living Room",
+			StationName: "Home",
+			Type:        "NAMain",
+			DashboardData: netatmo.DashboardData{
+				Temperature: &temperature,
+				LastMeasure: &lastMeasure,
+			},
+		},
+	}
+
+	tt := []struct {
+		desc   string
+		labels map[string]string
+		want   string
+	}{
+		{
+			desc: "no labels",
+			want: `# HELP netatmo_aircare_temperature_celsius Temperature measurement in celsius
+# TYPE netatmo_aircare_temperature_celsius gauge
+netatmo_aircare_temperature_celsius{module="Living Room",module_type="NAMain",role="station",station="Home"} 21.5
+`,
+		},
+		{
+			desc: "external labels",
+			labels: map[string]string{
+				"region": "eu",
+				"site":   "home1",
+			},
+			want: `# HELP netatmo_aircare_temperature_celsius Temperature measurement in celsius
+# TYPE netatmo_aircare_temperature_celsius gauge
+netatmo_aircare_temperature_celsius{module="Living Room",module_type="NAMain",region="eu",role="station",site="home1",station="Home"} 21.5
+`,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			metrics := collector.New(context.Background(), logrus.New(), func() (*netatmo.DeviceCollection, error) {
+				return dc, nil
+			}, time.Minute, time.Hour)
+			metrics.RefreshData(time.Now())
+
+			registry := prometheus.NewPedanticRegistry()
+			labelledRegisterer(registry, tc.labels).MustRegister(metrics)
+
+			if err := testutil.GatherAndCompare(registry, strings.NewReader(tc.want), "netatmo_aircare_temperature_celsius"); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
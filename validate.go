@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/exzz/netatmo-api-go"
+	"github.com/neothematrix/netatmo-exporter/v2/internal/collector"
+	"github.com/neothematrix/netatmo-exporter/v2/internal/config"
+	"github.com/prometheus/common/expfmt"
+)
+
+// runValidate does a single refresh of the sensor data and writes the resulting metrics to out.
+// The read is skipped when the client is not authenticated, unless the data is replayed from a file.
+func runValidate(ctx context.Context, out io.Writer, client *netatmo.Client, readFunction collector.ReadFunction, cfg config.Config) error {
+	if _, err := client.CurrentToken(); err == netatmo.ErrNotAuthenticated && cfg.ReplayFile == "" {
+		log.Warn("Not authenticated, skipping read of sensor data.")
+		return nil
+	}
+
+	var readErr error
+	metrics := newCollector(ctx, func() (*netatmo.DeviceCollection, error) {
+		devices, err := readFunction()
+		readErr = err
+		return devices, err
+	}, cfg)
+	metrics.RefreshNow()
+	if readErr != nil {
+		return fmt.Errorf("error reading data: %w", readErr)
+	}
+
+	families, err := metrics.Snapshot()
+	if err != nil {
+		return err
+	}
+
+	encoder := expfmt.NewEncoder(out, expfmt.FmtText)
+	for _, family := range families {
+		if err := encoder.Encode(family); err != nil {
+			return fmt.Errorf("error encoding metrics: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -3,7 +3,9 @@ package main
 import (
 	"encoding/json"
 	"net/http"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
 )
 
@@ -13,8 +15,25 @@ var (
 
 	// GitCommit contains the git commit hash set during the build.
 	GitCommit = ""
+
+	// startTime is the time the process was started.
+	startTime = time.Now()
 )
 
+// userAgent returns the User-Agent used for requests to the Netatmo API, unless an override is set.
+func userAgent(override string) string {
+	if override != "" {
+		return override
+	}
+
+	version := Version
+	if version == "" {
+		version = "dev"
+	}
+
+	return "netatmo-exporter/" + version
+}
+
 func versionHandler(log logrus.FieldLogger) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		info := struct {
@@ -31,3 +50,15 @@ func versionHandler(log logrus.FieldLogger) http.Handler {
 		}
 	})
 }
+
+// startTimeCollector creates a metric containing the start time of the process, which can be used for calculating
+// the uptime and finding restarts.
+func startTimeCollector(start time.Time) prometheus.Collector {
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "netatmo_exporter_start_time_seconds",
+		Help: "Contains the time the exporter process was started.",
+	})
+	gauge.Set(float64(start.UnixNano()) / 1e9)
+
+	return gauge
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestStartTimeCollector(t *testing.T) {
+	want := `# HELP netatmo_exporter_start_time_seconds Contains the time the exporter process was started.
+# TYPE netatmo_exporter_start_time_seconds gauge
+netatmo_exporter_start_time_seconds 3600.5
+`
+
+	collector := startTimeCollector(time.Unix(3600, 500000000))
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(want)); err != nil {
+		t.Error(err)
+	}
+}